@@ -121,6 +121,79 @@ func (c *Client) TokenBalances(tokenAddress common.Address, blockchain string) (
 	return th, nil
 }
 
+// tokenIdsBalances is a wrapper around the generated function for GraphQL query TokenIdsBalances.
+func (c *Client) tokenIdsBalances(
+	tokenAddress common.Address,
+	tokenIDs []string,
+	blockchain gql.TokenBlockchain,
+	limit int,
+	cursor string,
+) (*gql.GetTokenIdsBalancesResponse, error) {
+	cctx, cancel := context.WithTimeout(c.ctx, apiTimeout)
+	defer cancel()
+	r := 0
+	var err error
+	resp := &gql.GetTokenIdsBalancesResponse{}
+	for r < maxAPIRetries {
+		resp, err = gql.GetTokenIdsBalances(cctx, c.Client, tokenAddress, tokenIDs, blockchain, limit, cursor)
+		if err != nil {
+			r += 1
+			time.Sleep(time.Second * 3)
+			continue
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("max GraphQL retries reached, error: %w", err)
+}
+
+// TokenIdsBalances gets the holders of any of the given token IDs of an
+// ERC-721 or ERC-1155 token in a given chain calling the Airstack API. The
+// balance of every holder is the sum of its balances of the given token IDs.
+// This function also takes care of Airstack API pagination.
+func (c *Client) TokenIdsBalances(tokenAddress common.Address, tokenIDs []string, blockchain string) ([]*TokenHolder, error) {
+	chain, ok := c.blockchainToTokenBlockchain(blockchain)
+	if !ok {
+		return nil, fmt.Errorf("invalid blockchain provided")
+	}
+	balances := map[common.Address]*big.Int{}
+	hasNextPage := true
+	cursor := ""
+	totalPages := 0
+	for hasNextPage {
+		resp, err := c.tokenIdsBalances(tokenAddress, tokenIDs, chain, airstackAPIlimit, cursor)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get token balances from Airstack: %w", err)
+		}
+		for _, holder := range resp.TokenBalances.TokenBalance {
+			if len(holder.Owner.Addresses) == 0 {
+				continue
+			}
+			balance, ok := new(big.Int).SetString(holder.Amount, 10)
+			if !ok {
+				continue
+			}
+			owner := holder.Owner.Addresses[0]
+			if current, ok := balances[owner]; ok {
+				balance.Add(balance, current)
+			}
+			balances[owner] = balance
+		}
+		cursor = resp.TokenBalances.PageInfo.NextCursor
+		hasNextPage = cursor != ""
+		totalPages++
+	}
+	th := make([]*TokenHolder, 0, len(balances))
+	for address, balance := range balances {
+		th = append(th, &TokenHolder{
+			Address: address,
+			Balance: balance,
+		})
+	}
+	log.Debugf("fetched %d holders of %d token IDs in %d pages for token %s",
+		len(th), len(tokenIDs), totalPages, tokenAddress)
+	return th, nil
+}
+
 // CheckIfHolder checks if a given address is a holder of a given token in a given chain
 // Returns the balance of the token if the address is a holder.
 func (c *Client) CheckIfHolder(tokenAddress common.Address, blockchain string, address common.Address) (uint64, error) {
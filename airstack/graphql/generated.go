@@ -432,6 +432,96 @@ func (v *GetTokenDetailsTokensTokensOutputToken) GetDecimals() int { return v.De
 // GetTotalSupply returns GetTokenDetailsTokensTokensOutputToken.TotalSupply, and is useful for accessing the field via an interface.
 func (v *GetTokenDetailsTokensTokensOutputToken) GetTotalSupply() string { return v.TotalSupply }
 
+// GetTokenIdsBalancesResponse is returned by GetTokenIdsBalances on success.
+type GetTokenIdsBalancesResponse struct {
+	TokenBalances GetTokenIdsBalancesTokenBalancesTokenBalancesOutput `json:"TokenBalances"`
+}
+
+// GetTokenBalances returns GetTokenIdsBalancesResponse.TokenBalances, and is useful for accessing the field via an interface.
+func (v *GetTokenIdsBalancesResponse) GetTokenBalances() GetTokenIdsBalancesTokenBalancesTokenBalancesOutput {
+	return v.TokenBalances
+}
+
+// GetTokenIdsBalancesTokenBalancesTokenBalancesOutput includes the requested fields of the GraphQL type TokenBalancesOutput.
+type GetTokenIdsBalancesTokenBalancesTokenBalancesOutput struct {
+	TokenBalance []GetTokenIdsBalancesTokenBalancesTokenBalancesOutputTokenBalance `json:"TokenBalance"`
+	PageInfo     GetTokenIdsBalancesTokenBalancesTokenBalancesOutputPageInfo       `json:"pageInfo"`
+}
+
+// GetTokenBalance returns GetTokenIdsBalancesTokenBalancesTokenBalancesOutput.TokenBalance, and is useful for accessing the field via an interface.
+func (v *GetTokenIdsBalancesTokenBalancesTokenBalancesOutput) GetTokenBalance() []GetTokenIdsBalancesTokenBalancesTokenBalancesOutputTokenBalance {
+	return v.TokenBalance
+}
+
+// GetPageInfo returns GetTokenIdsBalancesTokenBalancesTokenBalancesOutput.PageInfo, and is useful for accessing the field via an interface.
+func (v *GetTokenIdsBalancesTokenBalancesTokenBalancesOutput) GetPageInfo() GetTokenIdsBalancesTokenBalancesTokenBalancesOutputPageInfo {
+	return v.PageInfo
+}
+
+// GetTokenIdsBalancesTokenBalancesTokenBalancesOutputPageInfo includes the requested fields of the GraphQL type PageInfo.
+type GetTokenIdsBalancesTokenBalancesTokenBalancesOutputPageInfo struct {
+	NextCursor  string `json:"nextCursor"`
+	PrevCursor  string `json:"prevCursor"`
+	HasNextPage bool   `json:"hasNextPage"`
+	HasPrevPage bool   `json:"hasPrevPage"`
+}
+
+// GetNextCursor returns GetTokenIdsBalancesTokenBalancesTokenBalancesOutputPageInfo.NextCursor, and is useful for accessing the field via an interface.
+func (v *GetTokenIdsBalancesTokenBalancesTokenBalancesOutputPageInfo) GetNextCursor() string {
+	return v.NextCursor
+}
+
+// GetPrevCursor returns GetTokenIdsBalancesTokenBalancesTokenBalancesOutputPageInfo.PrevCursor, and is useful for accessing the field via an interface.
+func (v *GetTokenIdsBalancesTokenBalancesTokenBalancesOutputPageInfo) GetPrevCursor() string {
+	return v.PrevCursor
+}
+
+// GetHasNextPage returns GetTokenIdsBalancesTokenBalancesTokenBalancesOutputPageInfo.HasNextPage, and is useful for accessing the field via an interface.
+func (v *GetTokenIdsBalancesTokenBalancesTokenBalancesOutputPageInfo) GetHasNextPage() bool {
+	return v.HasNextPage
+}
+
+// GetHasPrevPage returns GetTokenIdsBalancesTokenBalancesTokenBalancesOutputPageInfo.HasPrevPage, and is useful for accessing the field via an interface.
+func (v *GetTokenIdsBalancesTokenBalancesTokenBalancesOutputPageInfo) GetHasPrevPage() bool {
+	return v.HasPrevPage
+}
+
+// GetTokenIdsBalancesTokenBalancesTokenBalancesOutputTokenBalance includes the requested fields of the GraphQL type TokenBalance.
+type GetTokenIdsBalancesTokenBalancesTokenBalancesOutputTokenBalance struct {
+	// Nested Query allowing to retrieve address, domain names, social profiles of the owner
+	Owner GetTokenIdsBalancesTokenBalancesTokenBalancesOutputTokenBalanceOwnerWallet `json:"owner"`
+	// Unique NFT token ID
+	TokenId string `json:"tokenId"`
+	// Token amount the address currently holds
+	Amount string `json:"amount"`
+}
+
+// GetOwner returns GetTokenIdsBalancesTokenBalancesTokenBalancesOutputTokenBalance.Owner, and is useful for accessing the field via an interface.
+func (v *GetTokenIdsBalancesTokenBalancesTokenBalancesOutputTokenBalance) GetOwner() GetTokenIdsBalancesTokenBalancesTokenBalancesOutputTokenBalanceOwnerWallet {
+	return v.Owner
+}
+
+// GetTokenId returns GetTokenIdsBalancesTokenBalancesTokenBalancesOutputTokenBalance.TokenId, and is useful for accessing the field via an interface.
+func (v *GetTokenIdsBalancesTokenBalancesTokenBalancesOutputTokenBalance) GetTokenId() string {
+	return v.TokenId
+}
+
+// GetAmount returns GetTokenIdsBalancesTokenBalancesTokenBalancesOutputTokenBalance.Amount, and is useful for accessing the field via an interface.
+func (v *GetTokenIdsBalancesTokenBalancesTokenBalancesOutputTokenBalance) GetAmount() string {
+	return v.Amount
+}
+
+// GetTokenIdsBalancesTokenBalancesTokenBalancesOutputTokenBalanceOwnerWallet includes the requested fields of the GraphQL type Wallet.
+type GetTokenIdsBalancesTokenBalancesTokenBalancesOutputTokenBalanceOwnerWallet struct {
+	// Returns addresses associated with the identity input
+	Addresses []common.Address `json:"addresses"`
+}
+
+// GetAddresses returns GetTokenIdsBalancesTokenBalancesTokenBalancesOutputTokenBalanceOwnerWallet.Addresses, and is useful for accessing the field via an interface.
+func (v *GetTokenIdsBalancesTokenBalancesTokenBalancesOutputTokenBalanceOwnerWallet) GetAddresses() []common.Address {
+	return v.Addresses
+}
+
 type TokenBlockchain string
 
 const (
@@ -538,6 +628,30 @@ func (v *__GetTokenDetailsInput) GetTokenAddress() common.Address { return v.Tok
 // GetBlockchain returns __GetTokenDetailsInput.Blockchain, and is useful for accessing the field via an interface.
 func (v *__GetTokenDetailsInput) GetBlockchain() TokenBlockchain { return v.Blockchain }
 
+// __GetTokenIdsBalancesInput is used internally by genqlient
+type __GetTokenIdsBalancesInput struct {
+	TokenAddress common.Address  `json:"tokenAddress"`
+	TokenIds     []string        `json:"tokenIds"`
+	Blockchain   TokenBlockchain `json:"blockchain"`
+	Limit        int             `json:"limit"`
+	Cursor       string          `json:"cursor"`
+}
+
+// GetTokenAddress returns __GetTokenIdsBalancesInput.TokenAddress, and is useful for accessing the field via an interface.
+func (v *__GetTokenIdsBalancesInput) GetTokenAddress() common.Address { return v.TokenAddress }
+
+// GetTokenIds returns __GetTokenIdsBalancesInput.TokenIds, and is useful for accessing the field via an interface.
+func (v *__GetTokenIdsBalancesInput) GetTokenIds() []string { return v.TokenIds }
+
+// GetBlockchain returns __GetTokenIdsBalancesInput.Blockchain, and is useful for accessing the field via an interface.
+func (v *__GetTokenIdsBalancesInput) GetBlockchain() TokenBlockchain { return v.Blockchain }
+
+// GetLimit returns __GetTokenIdsBalancesInput.Limit, and is useful for accessing the field via an interface.
+func (v *__GetTokenIdsBalancesInput) GetLimit() int { return v.Limit }
+
+// GetCursor returns __GetTokenIdsBalancesInput.Cursor, and is useful for accessing the field via an interface.
+func (v *__GetTokenIdsBalancesInput) GetCursor() string { return v.Cursor }
+
 // The query or mutation executed by CheckFarcasterFollowing.
 const CheckFarcasterFollowing_Operation = `
 query CheckFarcasterFollowing ($userId: Identity, $followingId: Identity) {
@@ -855,3 +969,58 @@ func GetTokenDetails(
 
 	return &data, err
 }
+
+// The query or mutation executed by GetTokenIdsBalances.
+const GetTokenIdsBalances_Operation = `
+query GetTokenIdsBalances ($tokenAddress: Address!, $tokenIds: [String!], $blockchain: TokenBlockchain!, $limit: Int, $cursor: String) {
+	TokenBalances(input: {filter:{tokenAddress:{_eq:$tokenAddress},tokenId:{_in:$tokenIds}},blockchain:$blockchain,limit:$limit,cursor:$cursor}) {
+		TokenBalance {
+			owner {
+				addresses
+			}
+			tokenId
+			amount
+		}
+		pageInfo {
+			nextCursor
+			prevCursor
+			hasNextPage
+			hasPrevPage
+		}
+	}
+}
+`
+
+func GetTokenIdsBalances(
+	ctx context.Context,
+	client graphql.Client,
+	tokenAddress common.Address,
+	tokenIds []string,
+	blockchain TokenBlockchain,
+	limit int,
+	cursor string,
+) (*GetTokenIdsBalancesResponse, error) {
+	req := &graphql.Request{
+		OpName: "GetTokenIdsBalances",
+		Query:  GetTokenIdsBalances_Operation,
+		Variables: &__GetTokenIdsBalancesInput{
+			TokenAddress: tokenAddress,
+			TokenIds:     tokenIds,
+			Blockchain:   blockchain,
+			Limit:        limit,
+			Cursor:       cursor,
+		},
+	}
+	var err error
+
+	var data GetTokenIdsBalancesResponse
+	resp := &graphql.Response{Data: &data}
+
+	err = client.MakeRequest(
+		ctx,
+		req,
+		resp,
+	)
+
+	return &data, err
+}
@@ -18,11 +18,13 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/vocdoni/vote-frame/alfafrens"
+	"github.com/vocdoni/vote-frame/communityhub"
 	"github.com/vocdoni/vote-frame/farcasterapi"
 	"github.com/vocdoni/vote-frame/farcasterapi/neynar"
+	"github.com/vocdoni/vote-frame/features"
 	"github.com/vocdoni/vote-frame/helpers"
 	"github.com/vocdoni/vote-frame/mongo"
+	"github.com/vocdoni/vote-frame/onchain"
 	"go.vocdoni.io/dvote/api"
 	"go.vocdoni.io/dvote/apiclient"
 	"go.vocdoni.io/dvote/httprouter"
@@ -44,9 +46,9 @@ const (
 )
 
 var (
-	// maxElectionSize is the maximum number of participants in an election
-	maxElectionSize = defaultMaxElectionSize
-
+	// ErrCensusTooLarge is returned when the census has more participants
+	// than the maximum allowed for the user or the community
+	ErrCensusTooLarge = fmt.Errorf("census too large")
 	// ErrNoValidParticipants is returned when no valid participants are found
 	ErrNoValidParticipants = fmt.Errorf("no valid participants")
 	// ErrUserNotFoundInFarcaster is returned when a user is not found in the farcaster API
@@ -77,10 +79,22 @@ const (
 	FrameCensusTypeERC20
 	// FrameCensusTypeAlfaFrensChannel is a census created from the users who follow a specific AlfaFrens Channel
 	FrameCensusTypeAlfaFrensChannel
+	// FrameCensusTypeERC20Votes is a census created from the voting power of the delegatees of an ERC20Votes token
+	FrameCensusTypeERC20Votes
+	// FrameCensusTypeFollowing is a census created from the users followed by a
+	// user, or only the mutual follows of the user
+	FrameCensusTypeFollowing
+	// FrameCensusTypeEngaged is a census created from the voters of the
+	// previous polls of a community
+	FrameCensusTypeEngaged
+	// FrameCensusTypeMembers is a census created from the members list of a
+	// community
+	FrameCensusTypeMembers
 )
 
 // CensusInfo contains the information of a census.
 type CensusInfo struct {
+	CensusID           types.HexBytes `json:"censusId,omitempty"`
 	Root               types.HexBytes `json:"root"`
 	Url                string         `json:"uri"`
 	Size               uint64         `json:"size"`
@@ -108,11 +122,13 @@ func (c *CensusInfo) FromFile(file string) error {
 }
 
 // FarcasterParticipant is a participant in the Farcaster network to be included in the census.
+// Delegated contains the part of the weight received from every delegator of the participant.
 type FarcasterParticipant struct {
-	PubKey   []byte   `json:"pubkey"`
-	Weight   *big.Int `json:"weight"`
-	Username string   `json:"username"`
-	FID      uint64   `json:"fid"`
+	PubKey    []byte              `json:"pubkey"`
+	Weight    *big.Int            `json:"weight"`
+	Username  string              `json:"username"`
+	FID       uint64              `json:"fid"`
+	Delegated map[uint64]*big.Int `json:"-"`
 }
 
 // CreateCensus creates a new census from a list of participants.
@@ -222,78 +238,15 @@ func (v *vocdoniHandler) censusFromDatabaseByRoot(_ *apirest.APIdata, ctx *httpr
 // censusCSV creates a new census from a CSV file containing Ethereum addresses and weights.
 // It builds the census async and returns the census ID.
 func (v *vocdoniHandler) censusCSV(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
-	censusID, err := v.cli.NewCensus(api.CensusTypeWeighted)
-	if err != nil {
-		return err
-	}
-	v.backgroundQueue.Store(censusID.String(), CensusInfo{})
 	userFID, err := v.db.UserFromAuthToken(msg.AuthToken)
 	if err != nil {
 		return fmt.Errorf("cannot get user from auth token: %w", err)
 	}
-	if err := v.db.AddCensus(censusID, userFID); err != nil {
-		return fmt.Errorf("cannot add census to database: %w", err)
-	}
-	totalCSVaddresses := uint32(0)
-	go func() {
-		startTime := time.Now()
-		log.Debugw("building census from csv", "censusID", censusID)
-		var participants []*FarcasterParticipant
-		var err error
-		v.trackStepProgress(censusID, 1, 2, func(progress chan int) {
-			participants, totalCSVaddresses, err = v.farcasterCensusFromEthereumCSV(msg.Data, progress)
-		})
-		if err != nil {
-			log.Warnw("failed to build census from ethereum csv", "err", err.Error())
-			v.backgroundQueue.Store(censusID.String(), CensusInfo{Error: err.Error()})
-			return
-		}
-		var ci *CensusInfo
-		v.trackStepProgress(censusID, 2, 2, func(progress chan int) {
-			ci, err = CreateCensus(v.cli, participants, FrameCensusTypeCSV, progress)
-		})
-		if err != nil {
-			log.Errorw(err, "failed to create census")
-			v.backgroundQueue.Store(censusID.String(), CensusInfo{Error: err.Error()})
-			return
-		}
-		// since each participant can have multiple signers, we need to get the unique usernames
-		uniqueParticipantsMap := make(map[string]*big.Int)
-		totalWeight := new(big.Int).SetUint64(0)
-		for _, p := range participants {
-			if _, ok := uniqueParticipantsMap[p.Username]; ok {
-				// if the username is already in the map, continue
-				continue
-			}
-			uniqueParticipantsMap[p.Username] = p.Weight
-			totalWeight.Add(totalWeight, p.Weight)
-		}
-		uniqueParticipants := []string{}
-		for k := range uniqueParticipantsMap {
-			uniqueParticipants = append(uniqueParticipants, k)
-		}
-		ci.Usernames = uniqueParticipants
-		ci.FromTotalAddresses = totalCSVaddresses
-		log.Infow("census created from CSV",
-			"censusID", censusID.String(),
-			"size", len(ci.Usernames),
-			"totalWeight", totalWeight.String(),
-			"duration", time.Since(startTime),
-			"fromTotalAddresses", totalCSVaddresses)
-
-		// store the census info in the map
-		v.backgroundQueue.Store(censusID.String(), *ci)
-
-		// add participants to the census in the database
-		if err := v.db.AddParticipantsToCensus(censusID, uniqueParticipantsMap, ci.FromTotalAddresses, totalWeight, ci.Url); err != nil {
-			log.Errorw(err, fmt.Sprintf("failed to add participants to census %s", censusID.String()))
-		}
-	}()
-	data, err := json.Marshal(map[string]string{"censusId": censusID.String()})
+	params, err := json.Marshal(&csvCensusParams{CSV: string(msg.Data)})
 	if err != nil {
 		return err
 	}
-	return ctx.Send(data, http.StatusOK)
+	return v.sendProviderCensus(ctx, censusProviderCSV, userFID, params, "", nil)
 }
 
 // censusChannelExists checks if a Warpcast Channel exists. It returns a NotFound
@@ -317,46 +270,29 @@ func (v *vocdoniHandler) censusChannelExists(_ *apirest.APIdata, ctx *httprouter
 // censusChannel creates a new census that includes the users who follow a
 // specific Warpcast Channel. It builds the census async and returns the census
 // ID. If no channelID is provided, it returns a BadRequest error. If the
-// channel does not exist, it returns a NotFound error. The process of creating
-// the census includes fetching the users FIDs from the farcaster API and
-// querying the database to get the users signer keys. The census is created
-// from the participants and the progress is updated in the queue.
+// channel does not exist, it returns a NotFound error. The census is built by
+// the channel census provider.
 func (v *vocdoniHandler) censusChannel(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
 	// extract userFID from auth token
 	userFID, err := v.db.UserFromAuthToken(msg.AuthToken)
 	if err != nil {
 		return fmt.Errorf("cannot get user from auth token: %w", err)
 	}
-	// check if channelID is provided, it is required so if it's not provided
-	// return a BadRequest error
-	channelID := ctx.URLParam("channelID")
-	if channelID == "" {
-		return ctx.Send([]byte("channelID is required"), http.StatusBadRequest)
-	}
-	// check if the channel exists, if not return a NotFound error. If something
-	// fails when checking the channel existence, return the error.
-	exists, err := v.fcapi.ChannelExists(ctx.Request.Context(), channelID)
+	params, err := json.Marshal(&channelCensusParams{ChannelID: ctx.URLParam("channelID")})
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return ctx.Send([]byte("channel not found"), http.StatusNotFound)
-	}
-	// create a censusID for the queue and store into it
-	data, err := v.censusWarpcastChannel(channelID, userFID, nil)
-	if err != nil {
-		log.Warnf("error creating census for the chanel: %s: %v", channelID, err)
-		return ctx.Send([]byte("error creating channel census"), http.StatusInternalServerError)
-	}
-	return ctx.Send(data, http.StatusOK)
+	return v.sendProviderCensus(ctx, censusProviderChannel, userFID, params, "", nil)
 }
 
+// censusFollowersHandler creates a new census from the followers of the user
+// provided in the URL. The census is built by the followers census provider
+// on behalf of the authenticated user, whose reputation limits its size.
 func (v *vocdoniHandler) censusFollowersHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
-	req := struct {
-		Profile FarcasterProfile `json:"profile"`
-	}{}
-	if err := json.Unmarshal(msg.Data, &req); err != nil {
-		return err
+	// extract userFID from auth token
+	userFID, err := v.db.UserFromAuthToken(msg.AuthToken)
+	if err != nil {
+		return ctx.Send([]byte("cannot get user from auth token"), http.StatusUnauthorized)
 	}
 	// check if userFid is provided, it is required so if it's not provided
 	// return a BadRequest error
@@ -364,49 +300,69 @@ func (v *vocdoniHandler) censusFollowersHandler(msg *apirest.APIdata, ctx *httpr
 	if strUserFid == "" {
 		return ctx.Send([]byte("userFid is required"), http.StatusBadRequest)
 	}
-	userFID, err := strconv.ParseUint(strUserFid, 10, 64)
+	followedFID, err := strconv.ParseUint(strUserFid, 10, 64)
 	if err != nil {
 		return ctx.Send([]byte("invalid userFid"), http.StatusBadRequest)
 	}
-	// create the census from the followers of the user and return the data as
-	// response
-	data, err := v.censusFollowers(userFID, nil)
+	params, err := json.Marshal(&followersCensusParams{UserFID: followedFID})
 	if err != nil {
 		return err
 	}
-	return ctx.Send(data, http.StatusOK)
+	return v.sendProviderCensus(ctx, censusProviderFollowers, userFID, params, "", nil)
 }
 
-// censusAlfafrensChannelHandler creates a new census from the users who follow the AlfaFrens channel of the user
-// making the request.
-func (v *vocdoniHandler) censusAlfafrensChannelHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+// censusFollowingHandler creates a new census from the users followed by the
+// user provided in the URL. If the request body sets 'mutualOnly', only the
+// mutual follows of the user are included. The census is built by the
+// following census provider on behalf of the authenticated user, whose
+// reputation limits its size.
+func (v *vocdoniHandler) censusFollowingHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
 	// extract userFID from auth token
 	userFID, err := v.db.UserFromAuthToken(msg.AuthToken)
 	if err != nil {
-		return fmt.Errorf("cannot get user from auth token: %w", err)
+		return ctx.Send([]byte("cannot get user from auth token"), http.StatusUnauthorized)
 	}
-
-	// create a censusID for the queue and store into it
-	censusID, err := v.cli.NewCensus(api.CensusTypeWeighted)
+	strUserFid := ctx.URLParam("userFid")
+	if strUserFid == "" {
+		return ctx.Send([]byte("userFid is required"), http.StatusBadRequest)
+	}
+	followingFID, err := strconv.ParseUint(strUserFid, 10, 64)
+	if err != nil {
+		return ctx.Send([]byte("invalid userFid"), http.StatusBadRequest)
+	}
+	req := &followingCensusParams{}
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, req); err != nil {
+			return ctx.Send([]byte("invalid request body"), http.StatusBadRequest)
+		}
+	}
+	req.UserFID = followingFID
+	params, err := json.Marshal(req)
 	if err != nil {
 		return err
 	}
-	data, err := v.censusAlfafrensChannel(censusID, userFID)
+	return v.sendProviderCensus(ctx, censusProviderFollowing, userFID, params, "", nil)
+}
+
+// censusAlfafrensChannelHandler creates a new census from the users who follow the AlfaFrens channel of the user
+// making the request.
+func (v *vocdoniHandler) censusAlfafrensChannelHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	// extract userFID from auth token
+	userFID, err := v.db.UserFromAuthToken(msg.AuthToken)
 	if err != nil {
-		log.Warnf("error creating census for alfafrens channel of user: %d: %v", userFID, err)
-		return ctx.Send([]byte("error creating channel census"), http.StatusInternalServerError)
+		return fmt.Errorf("cannot get user from auth token: %w", err)
 	}
-	return ctx.Send(data, http.StatusOK)
+	return v.sendProviderCensus(ctx, censusProviderAlfafrens, userFID, nil, "", nil)
 }
 
 // censusCommunity creates a new census from a community. The census of the
-// community can be of type channel, NFT, or ERC20. If the community is a
-// channel, the census is created from the users who follow the channel, and
-// the process is async. If the community is an NFT or ERC20, the census is
-// created from the token holders of the token addresses in the community, using
-// the AirStack API. The process is sync and the census is created in the same
-// request. The census is created from the participants and the progress is
-// updated in the queue.
+// community can be of type channel, followers, NFT, ERC20 or engaged, and it
+// is built by the census provider of the same source, taking into account the
+// delegations of the community. If the request includes an engaged rule, the
+// census is built from the voters of the previous polls of the community that
+// match it, whatever the census type of the community. The weighting of the
+// request, or the one of the community if it is not provided, is applied to
+// the participants. The process is async and returns the census ID.
 func (v *vocdoniHandler) censusCommunity(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
 	// extract userFID from auth token
 	userFID, err := v.db.UserFromAuthToken(msg.AuthToken)
@@ -414,7 +370,9 @@ func (v *vocdoniHandler) censusCommunity(msg *apirest.APIdata, ctx *httprouter.H
 		return fmt.Errorf("cannot get user from auth token: %w", err)
 	}
 	req := struct {
-		CommunityID string `json:"communityID"`
+		CommunityID string                      `json:"communityID"`
+		Engaged     *mongo.CommunityEngagedRule `json:"engaged,omitempty"`
+		Weighting   *mongo.CensusWeighting      `json:"weighting,omitempty"`
 	}{}
 	if err := json.Unmarshal(msg.Data, &req); err != nil {
 		return err
@@ -428,79 +386,117 @@ func (v *vocdoniHandler) censusCommunity(msg *apirest.APIdata, ctx *httprouter.H
 	if community == nil {
 		return ctx.Send([]byte("community not found"), http.StatusNotFound)
 	}
-	// check if the user is admin of the community
-	if !v.db.IsCommunityAdmin(userFID, req.CommunityID) {
-		return fmt.Errorf("user is not an admin of the community")
+	// check if the user is allowed to create polls in the community, which
+	// requires to create their censuses
+	if !community.Can(userFID, mongo.PermissionCreatePoll) {
+		return ctx.Send([]byte("user is not allowed to create polls in the community"), http.StatusForbidden)
+	}
+	// use the engaged rule of the request if it is provided, instead of the
+	// census of the community
+	if req.Engaged != nil {
+		community.Census = mongo.CommunityCensus{
+			Type:    mongo.TypeCommunityCensusEngaged,
+			Engaged: req.Engaged,
+		}
 	}
-	// getting the delegations of the community to build the census taking into
-	// account them
-	delegations, err := v.db.FinalDelegationsByCommunity(req.CommunityID)
+	provider, params, err := communityCensusProvider(community, userFID)
 	if err != nil {
-		return err
+		return ctx.Send([]byte(err.Error()), http.StatusBadRequest)
 	}
-	// check the type to create it from the correct source (channel, airstak
-	// (nft/erc20) or user followers) and in the correct way (async or sync)
+	return v.sendProviderCensus(ctx, provider, userFID, params, req.CommunityID, req.Weighting)
+}
+
+// communityCensusProvider returns the name of the census provider and its
+// parameters to build the census of the community provided. The followers
+// censuses are built from the followers of the user referenced by the
+// community or, if it has no valid reference, from the followers of the user
+// provided.
+func communityCensusProvider(community *mongo.Community, userFID uint64) (string, json.RawMessage, error) {
+	var provider string
+	var params any
 	switch community.Census.Type {
 	case mongo.TypeCommunityCensusFollowers:
-		// if the census type is followers, create the census from the users who
-		// follow the user, the process is async so return add the censusID to the
-		// queue and return it to the client
-		data, err := v.censusFollowers(userFID, delegations)
+		fid, err := communityhub.DecodeUserChannelFID(community.Census.Channel)
 		if err != nil {
-			log.Warnf("error creating census for the user: %d: %v", userFID, err)
-			return ctx.Send([]byte("error creating user followers census"), http.StatusInternalServerError)
+			fid = userFID
 		}
-		return ctx.Send(data, http.StatusOK)
+		provider, params = censusProviderFollowers, &followersCensusParams{UserFID: fid}
 	case mongo.TypeCommunityCensusChannel:
-		// if the census type is a channel, create the census from the users who
-		// follow the channel, the process is async so return add the censusID
-		// to the queue and return it to the client
-		data, err := v.censusWarpcastChannel(community.Census.Channel, userFID, delegations)
-		if err != nil {
-			log.Warnf("error creating census for the chanel: %s: %v", community.Census.Channel, err)
-			return ctx.Send([]byte("error creating channel census"), http.StatusInternalServerError)
+		provider, params = censusProviderChannel, &channelCensusParams{ChannelIDs: community.Census.CensusChannels()}
+	case mongo.TypeCommunityCensusEngaged:
+		if community.Census.Engaged == nil {
+			return "", nil, fmt.Errorf("no engaged rule provided")
+		}
+		provider, params = censusProviderEngaged, &engagedCensusParams{
+			CommunityID:          community.ID,
+			CommunityEngagedRule: *community.Census.Engaged,
+		}
+	case mongo.TypeCommunityCensusMembers:
+		provider, params = censusProviderMembers, &membersCensusParams{CommunityID: community.ID}
+	case mongo.TypeCommunityCensusERC20Votes:
+		if len(community.Census.Addresses) == 0 {
+			return "", nil, fmt.Errorf("no census token provided")
+		}
+		provider, params = censusProviderVotes, &votesCensusParams{
+			Address:    community.Census.Addresses[0].Address,
+			Blockchain: community.Census.Addresses[0].Blockchain,
 		}
-		return ctx.Send(data, http.StatusOK)
 	case mongo.TypeCommunityCensusNFT, mongo.TypeCommunityCensusERC20:
-		// if the census type is not a channel, the type is NFT or ERC20, so create
-		// the census sync and from the token holders from airstack
-		censusAddresses := []*CensusToken{}
+		tokens := &CensusTokensRequest{}
 		for _, addr := range community.Census.Addresses {
-			censusAddresses = append(censusAddresses, &CensusToken{
+			tokens.Tokens = append(tokens.Tokens, &CensusToken{
 				Address:    addr.Address,
 				Blockchain: addr.Blockchain,
+				Standard:   addr.Standard,
+				TokenIDs:   addr.TokenIDs,
 			})
 		}
-		// check valid token
-		if err := v.checkTokens(censusAddresses); err != nil {
-			return err
-		}
-		// convert the census type to the correct type for the CreateCensus function
-		var censusType int
-		switch community.Census.Type {
-		case mongo.TypeCommunityCensusNFT:
-			// set the census type to NFT
-			censusType = NFTtype
-		case mongo.TypeCommunityCensusERC20:
-			// set the census type to ERC20 and check the number of tokens is 1
-			censusType = ERC20type
-			if len(censusAddresses) != 1 {
-				return fmt.Errorf("erc20 census must have only one token address")
-			}
-		}
-		// create the census from the token holders
-		data, err := v.censusTokenAirstack(censusAddresses, censusType, userFID, delegations)
-		if err != nil {
-			return fmt.Errorf("cannot create erc20/nft based census: %w", err)
+		provider, params = censusProviderNFT, tokens
+		if community.Census.Type == mongo.TypeCommunityCensusERC20 {
+			provider = censusProviderERC20
 		}
-		return ctx.Send(data, http.StatusOK)
 	default:
-		return ctx.Send([]byte("invalid census type"), http.StatusBadRequest)
+		return "", nil, fmt.Errorf("invalid census type")
 	}
+	bParams, err := json.Marshal(params)
+	if err != nil {
+		return "", nil, err
+	}
+	return provider, bParams, nil
 }
 
-// censusQueueInfo returns the status of the census creation process.
-// Returns 204 if the census is not yet ready or not found.
+// maxCensusSize returns the maximum size of the censuses that the user
+// provided can create. The censuses of the communities have their own maximum
+// size, the rest depend on the reputation of the user.
+func (v *vocdoniHandler) maxCensusSize(userFID uint64, communityID string) uint64 {
+	if communityID != "" {
+		return features.CommunityMaxCensusSize()
+	}
+	var reputation uint32
+	if accessProfile, err := v.db.UserAccessProfile(userFID); err == nil {
+		reputation = accessProfile.Reputation
+	}
+	return features.MaxCensusSize(reputation)
+}
+
+// cappedCensus returns a copy of the census provided with its size limited to
+// the maximum size provided. It is used for the censuses that cannot be
+// rejected by their size, like the census of all Farcaster users, to set the
+// maximum number of votes of the poll.
+func cappedCensus(census *CensusInfo, maxSize uint64) *CensusInfo {
+	capped := *census
+	if capped.Size > maxSize {
+		capped.Size = maxSize
+	}
+	return &capped
+}
+
+// censusQueueInfo returns the status of the census creation process. The
+// status is read from the census job stored in the database, so it can be
+// queried to any instance of the service. Returns 202 with the progress if the
+// census is not yet ready, 404 if the census job is not found, 413 with the
+// error if the census is larger than allowed and 500 with the error if the
+// census creation failed.
 func (v *vocdoniHandler) censusQueueInfo(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
 	var censusID types.HexBytes
 	var err error
@@ -508,37 +504,52 @@ func (v *vocdoniHandler) censusQueueInfo(msg *apirest.APIdata, ctx *httprouter.H
 	if err != nil {
 		return err
 	}
-	iCensusInfo, ok := v.backgroundQueue.Load(censusID.String())
-	if !ok {
-		return ctx.Send(nil, http.StatusNotFound)
-	}
-	censusInfo, ok := iCensusInfo.(CensusInfo)
-	if !ok {
-		return ctx.Send(nil, http.StatusNotFound)
-	}
-	if censusInfo.Error != "" {
-		return ctx.Send([]byte(censusInfo.Error), http.StatusInternalServerError)
-	}
-	if len(censusInfo.Usernames) > maxUsersNamesToReturn {
-		censusInfo.Usernames = nil
+	job, err := v.db.CensusJob(censusID.String())
+	if err != nil {
+		if errors.Is(err, mongo.ErrCensusJobUnknown) {
+			return ctx.Send(nil, http.StatusNotFound)
+		}
+		return err
 	}
-	if censusInfo.Root == nil {
+	switch job.Status {
+	case mongo.CensusJobStatusFailed:
+		if strings.HasPrefix(job.Error, ErrCensusTooLarge.Error()) {
+			return ctx.Send([]byte(job.Error), http.StatusRequestEntityTooLarge)
+		}
+		return ctx.Send([]byte(job.Error), http.StatusInternalServerError)
+	case mongo.CensusJobStatusDone:
+		if job.Result == nil {
+			return ctx.Send(nil, http.StatusNotFound)
+		}
+		root, err := hex.DecodeString(job.Result.Root)
+		if err != nil {
+			return fmt.Errorf("cannot decode census root: %w", err)
+		}
+		censusInfo := CensusInfo{
+			CensusID:           censusID,
+			Root:               root,
+			Url:                job.Result.URL,
+			Size:               job.Result.Size,
+			Usernames:          job.Result.Usernames,
+			FromTotalAddresses: job.Result.FromTotalAddresses,
+		}
+		if len(censusInfo.Usernames) > maxUsersNamesToReturn {
+			censusInfo.Usernames = nil
+		}
+		data, err := json.Marshal(censusInfo)
+		if err != nil {
+			return err
+		}
+		return ctx.Send(data, http.StatusOK)
+	default:
 		data, err := json.Marshal(map[string]uint32{
-			"progress": censusInfo.Progress,
+			"progress": job.Progress,
 		})
 		if err != nil {
 			return err
 		}
 		return ctx.Send(data, http.StatusAccepted)
 	}
-	if err = v.db.SetRootForCensus(censusID, censusInfo.Root); err != nil {
-		return fmt.Errorf("cannot set root for census: %w", err)
-	}
-	data, err := json.Marshal(censusInfo)
-	if err != nil {
-		return err
-	}
-	return ctx.Send(data, http.StatusOK)
 }
 
 const (
@@ -559,32 +570,15 @@ func (v *vocdoniHandler) censusBlockchainsAirstack(msg *apirest.APIdata, ctx *ht
 	return ctx.Send(data, http.StatusOK)
 }
 
+// censusTokenNFTAirstack creates a new census from the holders of a list of
+// NFT tokens. The census is built by the NFT census provider.
 func (v *vocdoniHandler) censusTokenNFTAirstack(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
-	req := &CensusTokensRequest{}
-	if err := json.Unmarshal(msg.Data, &req); err != nil {
-		return err
-	}
-	// check valid tokens length
-	if len(req.Tokens) > MAXNFTTokens || len(req.Tokens) == 0 {
-		return fmt.Errorf("invalid number of NFT tokens, bounds between 1 and 3")
-	}
-
 	// extract userFID from auth token
 	userFID, err := v.db.UserFromAuthToken(msg.AuthToken)
 	if err != nil {
 		return fmt.Errorf("cannot get user from auth token: %w", err)
 	}
-
-	// check valid tokens
-	if err := v.checkTokens(req.Tokens); err != nil {
-		return err
-	}
-
-	data, err := v.censusTokenAirstack(req.Tokens, NFTtype, userFID, nil)
-	if err != nil {
-		return fmt.Errorf("cannot create nft census: %w", err)
-	}
-	return ctx.Send(data, http.StatusOK)
+	return v.sendProviderCensus(ctx, censusProviderNFT, userFID, msg.Data, "", nil)
 }
 
 func (v *vocdoniHandler) checkTokens(tokens []*CensusToken) error {
@@ -592,6 +586,17 @@ func (v *vocdoniHandler) checkTokens(tokens []*CensusToken) error {
 		if len(token.Address) == 0 {
 			return fmt.Errorf("invalid token information: %v", token)
 		}
+		// the tokens with token IDs are checked apart, since their holders
+		// can be retrieved also from the blockchain
+		if len(token.TokenIDs) > 0 {
+			if err := v.checkTokenIDs(token); err != nil {
+				return err
+			}
+			continue
+		}
+		if v.airstack == nil {
+			return fmt.Errorf("token %s requires token IDs, only token IDs can be queried on-chain", token.Address)
+		}
 		ok := false
 		for _, bk := range v.airstack.Blockchains() {
 			if bk == token.Blockchain {
@@ -615,399 +620,125 @@ func (v *vocdoniHandler) checkTokens(tokens []*CensusToken) error {
 	return nil
 }
 
+// censusTokenERC20Airstack creates a new census from the holders of an ERC20
+// token. The census is built by the ERC20 census provider.
 func (v *vocdoniHandler) censusTokenERC20Airstack(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
-	req := &CensusTokensRequest{}
-	if err := json.Unmarshal(msg.Data, &req); err != nil {
-		return err
-	}
-	if len(req.Tokens) != MAXERC20Tokens {
-		return fmt.Errorf("invalid number of ERC20 tokens, must be %d", MAXERC20Tokens)
-	}
-
 	// extract userFID from auth token
 	userFID, err := v.db.UserFromAuthToken(msg.AuthToken)
 	if err != nil {
 		return fmt.Errorf("cannot get user from auth token: %w", err)
 	}
+	return v.sendProviderCensus(ctx, censusProviderERC20, userFID, msg.Data, "", nil)
+}
 
-	// check valid token
-	if err := v.checkTokens(req.Tokens); err != nil {
-		return err
-	}
+func (v *vocdoniHandler) checkERC20ContractHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	// TODO: It should receive CheckCensusSource instance
+	return ctx.Send([]byte("ok"), http.StatusOK)
+}
 
-	data, err := v.censusTokenAirstack(req.Tokens, ERC20type, userFID, nil)
-	if err != nil {
-		return fmt.Errorf("cannot create erc20 census: %w", err)
-	}
-	return ctx.Send(data, http.StatusOK)
+func (v *vocdoniHandler) checkNFTContractHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	// TODO: It should receive CheckCensusSource instance
+	return ctx.Send([]byte("ok"), http.StatusOK)
 }
 
-func (v *vocdoniHandler) censusTokenAirstack(tokens []*CensusToken, tokenType int, createdByFID uint64, delegations []mongo.Delegation) ([]byte, error) {
-	if v.airstack == nil {
-		return nil, fmt.Errorf("airstack service not available")
-	}
-	censusID, err := v.cli.NewCensus(api.CensusTypeWeighted)
-	if err != nil {
-		return nil, err
+// checkTokenIDs checks that the token provided, which includes token IDs, has
+// a valid standard and token IDs, and that its holders can be retrieved from
+// Airstack or directly from the blockchain.
+func (v *vocdoniHandler) checkTokenIDs(token *CensusToken) error {
+	if token.Standard != mongo.TokenStandardERC721 && token.Standard != mongo.TokenStandardERC1155 {
+		return fmt.Errorf("invalid standard for token %s, token IDs are only supported for %s and %s tokens",
+			token.Address, mongo.TokenStandardERC721, mongo.TokenStandardERC1155)
 	}
-	if err := v.db.AddCensus(censusID, createdByFID); err != nil {
-		return nil, fmt.Errorf("cannot add census to database: %w", err)
+	if _, err := helpers.ExpandTokenIDs(token.TokenIDs, helpers.MaxTokenIDs); err != nil {
+		return fmt.Errorf("invalid token IDs for token %s: %w", token.Address, err)
 	}
-	v.backgroundQueue.Store(censusID.String(), CensusInfo{})
-	go func() {
-		startTime := time.Now()
-		log.Debugw("building Airstack based census", "censusID", censusID)
-		// get holders for each token
-		var holders [][]string
-		var err error
-		v.trackStepProgress(censusID, 1, 3, func(progress chan int) {
-			holders, err = v.getTokenHoldersFromAirstack(tokens, censusID, progress)
-		})
-		if err != nil {
-			log.Warnw("failed to build census from NFT, cannot get holders", "err", err.Error())
-			v.backgroundQueue.Store(censusID.String(), CensusInfo{Error: err.Error()})
-			return
-		}
-		// create census from token holders
-		var participants []*FarcasterParticipant
-		v.trackStepProgress(censusID, 2, 3, func(progress chan int) {
-			participants, _, err = v.processCensusRecords(holders, delegations, progress)
-		})
-		if err != nil {
-			log.Warnw("failed to build census from NFT", "err", err.Error())
-			v.backgroundQueue.Store(censusID.String(), CensusInfo{Error: err.Error()})
-			return
-		}
-		var ci *CensusInfo
-		v.trackStepProgress(censusID, 3, 3, func(progress chan int) {
-			if tokenType == ERC20type {
-				ci, err = CreateCensus(v.cli, participants, FrameCensusTypeERC20, progress)
-			} else if tokenType == NFTtype {
-				ci, err = CreateCensus(v.cli, participants, FrameCensusTypeNFT, progress)
-			}
-		})
-		if err != nil {
-			log.Errorw(err, "failed to create census")
-			v.backgroundQueue.Store(censusID.String(), CensusInfo{Error: err.Error()})
-			return
-		}
-
-		// since each participant can have multiple signers, we need to get the unique usernames
-		uniqueParticipantsMap := make(map[string]*big.Int)
-		totalWeight := new(big.Int).SetUint64(0)
-		for _, p := range participants {
-			if _, ok := uniqueParticipantsMap[p.Username]; ok {
-				// if the username is already in the map, continue
-				continue
+	if v.airstack != nil {
+		for _, bk := range v.airstack.Blockchains() {
+			if bk == token.Blockchain {
+				return nil
 			}
-			uniqueParticipantsMap[p.Username] = p.Weight
-			totalWeight.Add(totalWeight, p.Weight)
-		}
-		uniqueParticipants := []string{}
-		for k := range uniqueParticipantsMap {
-			uniqueParticipants = append(uniqueParticipants, k)
 		}
-		ci.Usernames = uniqueParticipants
-		ci.FromTotalAddresses = uint32(len(holders))
-		log.Infow("census created from Airstack",
-			"censusID", censusID.String(),
-			"size", len(ci.Usernames),
-			"totalWeight", totalWeight.String(),
-			"duration", time.Since(startTime),
-			"totalAddresses", ci.FromTotalAddresses,
-			"participants", len(ci.Usernames),
-		)
-		// store the census info in the memory map
-		v.backgroundQueue.Store(censusID.String(), *ci)
-		// add participants to the census in the database
-		if err := v.db.AddParticipantsToCensus(
-			censusID,
-			uniqueParticipantsMap,
-			ci.FromTotalAddresses,
-			totalWeight,
-			ci.Url,
-		); err != nil {
-			log.Errorw(err, fmt.Sprintf("failed to add participants to census %s", censusID.String()))
+	}
+	if v.onchain != nil {
+		if _, ok := v.onchain.ChainID(token.Blockchain); ok {
+			return nil
 		}
-	}()
-	data, err := json.Marshal(map[string]string{"censusId": censusID.String()})
-	if err != nil {
-		return nil, err
 	}
-	return data, nil
+	return fmt.Errorf("invalid blockchain for token %s provided", token.Address)
 }
 
-// censusWarpcastChannel helper method creates a new census from a Warpcast
-// Channel. The process is async and returns the json encoded censusID. It
-// updates the progress in the queue and the result when it's ready.
-func (v *vocdoniHandler) censusWarpcastChannel(channelID string, authorFID uint64, delegations []mongo.Delegation) ([]byte, error) {
-	// create a censusID for the queue and store into it
-	censusID, err := v.cli.NewCensus(api.CensusTypeWeighted)
+// getTokenIDsHolders returns the holders of the token IDs of the token
+// provided and their balances. It tries to get them from the Airstack API
+// and, if it is not available for the token blockchain or it fails, directly
+// from the token contract.
+func (v *vocdoniHandler) getTokenIDsHolders(ctx context.Context, token *CensusToken) ([][]string, error) {
+	tokenIDs, err := helpers.ExpandTokenIDs(token.TokenIDs, helpers.MaxTokenIDs)
 	if err != nil {
-		return nil, err
-	}
-	v.backgroundQueue.Store(censusID.String(), CensusInfo{})
-	if err := v.db.AddCensus(censusID, authorFID); err != nil {
-		return nil, fmt.Errorf("cannot add census to database: %w", err)
-	}
-	// run a goroutine to create the census, update the queue with the progress,
-	// and update the queue result when it's ready
-	go func() {
-		internalCtx, cancel := context.WithCancel(context.Background())
-		defer cancel()
-		var err error
-		// get the fids of the users in the channel from neynar farcaster API, if
-		// the channel does not exist, return a NotFound error
-		var users []uint64
-		v.trackStepProgress(censusID, 1, 3, func(progress chan int) {
-			users, err = v.fcapi.ChannelFIDs(internalCtx, channelID, progress)
-		})
-		if err != nil {
-			log.Errorw(err, "failed to get channel fids from farcaster API")
-			v.backgroundQueue.Store(censusID.String(), CensusInfo{Error: err.Error()})
-			return
-		}
-		if len(users) == 0 {
-			log.Errorw(fmt.Errorf("no valid participants found for the channel %s", channelID), "")
-			v.backgroundQueue.Store(censusID.String(), CensusInfo{Error: "no valid participants found for the channel"})
-			return
-		}
-		// create the participants from the database users using the fids
-		var participants []*FarcasterParticipant
-		v.trackStepProgress(censusID, 2, 3, func(progress chan int) {
-			participants = v.farcasterCensusFromFids(users, delegations, progress)
-		})
-		if len(participants) == 0 {
-			log.Errorw(fmt.Errorf("no valid participant signers found for the channel %s", channelID), "")
-			v.backgroundQueue.Store(censusID.String(), CensusInfo{Error: "no valid participant signers found for the channel"})
-			return
-		}
-		// create the census from the participants
-		var censusInfo *CensusInfo
-		v.trackStepProgress(censusID, 3, 3, func(progress chan int) {
-			censusInfo, err = CreateCensus(v.cli, participants, FrameCensusTypeChannelGated, progress)
-		})
-		if err != nil {
-			v.backgroundQueue.Store(censusID.String(), CensusInfo{Error: err.Error()})
-			return
-		}
-		uniqueParticipantsMap := make(map[string]*big.Int)
-		for _, p := range participants {
-			if _, ok := uniqueParticipantsMap[p.Username]; !ok {
-				uniqueParticipantsMap[p.Username] = new(big.Int).SetUint64(1)
-			}
-		}
-		// only return the username list if it's less than the maxUsersNamesToReturn
-		if len(uniqueParticipantsMap) < maxUsersNamesToReturn {
-			for username := range uniqueParticipantsMap {
-				censusInfo.Usernames = append(censusInfo.Usernames, username)
+		return nil, fmt.Errorf("invalid token IDs for token %s: %w", token.Address, err)
+	}
+	tokenAddress := common.HexToAddress(token.Address)
+	holders := [][]string{}
+	if v.airstack != nil {
+		tokenHolders, err := v.airstack.TokenIdsBalances(tokenAddress, helpers.BigIntsToStrings(tokenIDs), token.Blockchain)
+		if err == nil {
+			for _, tokenHolder := range tokenHolders {
+				holders = append(holders, []string{tokenHolder.Address.String(), tokenHolder.Balance.String()})
 			}
+			return holders, nil
 		}
-		censusInfo.FromTotalAddresses = uint32(len(users))
-		v.backgroundQueue.Store(censusID.String(), *censusInfo)
-		// add participants to the census in the database
-		if err := v.db.AddParticipantsToCensus(
-			censusID,
-			uniqueParticipantsMap,
-			censusInfo.FromTotalAddresses,
-			new(big.Int).SetUint64(uint64(len(uniqueParticipantsMap))),
-			censusInfo.Url,
-		); err != nil {
-			log.Errorw(err, fmt.Sprintf("failed to add participants to census %s", censusID.String()))
-		}
-		log.Infow("census created from channel",
-			"channelID", channelID,
-			"participants", len(censusInfo.Usernames))
-	}()
-	// return the censusID to the client
-	return json.Marshal(map[string]string{"censusId": censusID.String()})
-}
-
-// censusFollowers helper creates a new census from the followers of a user.
-// The process is async and returns the json encoded censusID. It updates the
-// progress in the queue and the result when it's ready. If something fails
-// during the process, it returns an error or the error is stored in the queue
-// if it's async.
-func (v *vocdoniHandler) censusFollowers(userFID uint64, delegations []mongo.Delegation) ([]byte, error) {
-	// create a censusID for the queue and store into it
-	censusID, err := v.cli.NewCensus(api.CensusTypeWeighted)
-	if err != nil {
-		return nil, err
+		log.Warnw("cannot get token IDs holders from airstack, trying on-chain",
+			"token", token.Address, "blockchain", token.Blockchain, "error", err)
 	}
-	// store the censusID in the database and the queue
-	if err := v.db.AddCensus(censusID, userFID); err != nil {
-		return nil, fmt.Errorf("cannot add census to database: %w", err)
+	if v.onchain == nil {
+		return nil, fmt.Errorf("cannot get token %s holders", token.Address)
+	}
+	chainID, ok := v.onchain.ChainID(token.Blockchain)
+	if !ok {
+		return nil, fmt.Errorf("blockchain %s not available on-chain", token.Blockchain)
+	}
+	var tokenHolders []*onchain.Holder
+	switch token.Standard {
+	case mongo.TokenStandardERC721:
+		tokenHolders, err = v.onchain.ERC721Holders(ctx, chainID, tokenAddress, tokenIDs)
+	case mongo.TokenStandardERC1155:
+		tokenHolders, err = v.onchain.ERC1155Holders(ctx, chainID, tokenAddress, tokenIDs)
+	default:
+		return nil, fmt.Errorf("unsupported token standard %s", token.Standard)
 	}
-	v.backgroundQueue.Store(censusID.String(), CensusInfo{})
-	// run a goroutine to create the census, update the queue with the progress,
-	// and update the queue result when it's ready
-	go func() {
-		internalCtx, cancel := context.WithCancel(context.Background())
-		defer cancel()
-		users, err := v.fcapi.UserFollowers(internalCtx, userFID)
-		if err != nil {
-			v.backgroundQueue.Store(censusID.String(), CensusInfo{Error: err.Error()})
-			return
-		}
-		// include poll author in the census
-		users = append(users, userFID)
-		// create the participants from the database users using the fids
-		var participants []*FarcasterParticipant
-		v.trackStepProgress(censusID, 1, 2, func(progress chan int) {
-			participants = v.farcasterCensusFromFids(users, delegations, progress)
-		})
-		if len(participants) == 0 {
-			v.backgroundQueue.Store(censusID.String(), CensusInfo{Error: "no valid participants"})
-			return
-		}
-		// create the census from the participants
-		var censusInfo *CensusInfo
-		v.trackStepProgress(censusID, 2, 2, func(progress chan int) {
-			censusInfo, err = CreateCensus(v.cli, participants, FrameCensusTypeFollowers, progress)
-		})
-		if err != nil {
-			v.backgroundQueue.Store(censusID.String(), CensusInfo{Error: err.Error()})
-			return
-		}
-		uniqueParticipantsMap := make(map[string]*big.Int)
-		totalWeight := new(big.Int).SetUint64(0)
-		for _, p := range participants {
-			if _, ok := uniqueParticipantsMap[p.Username]; ok {
-				// if the username is already in the map, continue
-				continue
-			}
-			uniqueParticipantsMap[p.Username] = p.Weight
-			totalWeight.Add(totalWeight, p.Weight)
-		}
-		// only return the username list if it's less than the maxUsersNamesToReturn
-		if len(uniqueParticipantsMap) < maxUsersNamesToReturn {
-			for u := range uniqueParticipantsMap {
-				censusInfo.Usernames = append(censusInfo.Usernames, u)
-			}
-		}
-		// store the census info in the database
-		if err := v.db.AddParticipantsToCensus(
-			censusID,
-			uniqueParticipantsMap,
-			uint32(len(users)),
-			totalWeight,
-			censusInfo.Url,
-		); err != nil {
-			log.Errorw(err, fmt.Sprintf("failed to add participants to census %s", censusID.String()))
-		}
-
-		censusInfo.FromTotalAddresses = uint32(len(users))
-		v.backgroundQueue.Store(censusID.String(), *censusInfo)
-		log.Infow("census created from user followers",
-			"fid", userFID,
-			"participants", len(censusInfo.Usernames))
-	}()
-	// return the censusID to the client
-	return json.Marshal(map[string]string{"censusId": censusID.String()})
-}
-
-// censusAlfafrensChannel creates a new census from an AlfaFrens Channel.
-func (v *vocdoniHandler) censusAlfafrensChannel(censusID types.HexBytes, ownerFID uint64) ([]byte, error) {
-	v.backgroundQueue.Store(censusID.String(), CensusInfo{})
-	if err := v.db.AddCensus(censusID, ownerFID); err != nil {
-		return nil, fmt.Errorf("cannot add census to database: %w", err)
-	}
-	// get the channel address from the alfafrens API
-	channelAddr, err := alfafrens.ChannelByFid(ownerFID)
 	if err != nil {
-		return nil, fmt.Errorf("cannot get alfafrens channel address for user %d: %w", ownerFID, err)
+		return nil, fmt.Errorf("cannot get token %s holders on-chain: %w", token.Address, err)
 	}
-	// run a goroutine to create the census, update the queue with the progress,
-	// and update the queue result when it's ready
-	go func() {
-		var err error
-		var users []uint64
-		// get the fids of the users in the channel from neynar farcaster API, if
-		// the channel does not exist, return a NotFound error
-		v.trackStepProgress(censusID, 1, 3, func(progress chan int) {
-			progress <- 10
-			users, err = alfafrens.ChannelFids(channelAddr)
-			progress <- 100
-		})
-		if err != nil {
-			log.Errorw(err, "failed to get channel fids from farcaster API")
-			v.backgroundQueue.Store(censusID.String(), CensusInfo{Error: err.Error()})
-			return
-		}
-		if len(users) == 0 {
-			log.Errorw(fmt.Errorf("no valid participants found for alfafrens channel %s", channelAddr.String()), "")
-			v.backgroundQueue.Store(censusID.String(), CensusInfo{Error: "no valid participants found for the channel"})
-			return
-		}
-		// create the participants from the database users using the fids
-		var participants []*FarcasterParticipant
-		v.trackStepProgress(censusID, 1, 2, func(progress chan int) {
-			participants = v.farcasterCensusFromFids(users, nil, progress)
-		})
-		if len(participants) == 0 {
-			log.Errorw(fmt.Errorf("no valid participant signers found for alfafrens channel %s", channelAddr.String()), "")
-			v.backgroundQueue.Store(censusID.String(), CensusInfo{Error: "no valid participant signers found for the channel"})
-			return
-		}
-		// create the census from the participants
-		var censusInfo *CensusInfo
-		v.trackStepProgress(censusID, 2, 2, func(progress chan int) {
-			censusInfo, err = CreateCensus(v.cli, participants, FrameCensusTypeAlfaFrensChannel, progress)
-		})
-		if err != nil {
-			v.backgroundQueue.Store(censusID.String(), CensusInfo{Error: err.Error()})
-			return
-		}
-		uniqueParticipantsMap := make(map[string]*big.Int)
-		for _, p := range participants {
-			if _, ok := uniqueParticipantsMap[p.Username]; !ok {
-				uniqueParticipantsMap[p.Username] = new(big.Int).SetUint64(1)
-			}
-		}
-		for username := range uniqueParticipantsMap {
-			censusInfo.Usernames = append(censusInfo.Usernames, username)
-		}
-		censusInfo.FromTotalAddresses = uint32(len(users))
-		v.backgroundQueue.Store(censusID.String(), *censusInfo)
-		// add participants to the census in the database
-		if err := v.db.AddParticipantsToCensus(
-			censusID,
-			uniqueParticipantsMap,
-			censusInfo.FromTotalAddresses,
-			new(big.Int).SetUint64(uint64(len(uniqueParticipantsMap))),
-			censusInfo.Url,
-		); err != nil {
-			log.Errorw(err, fmt.Sprintf("failed to add participants to census %s", censusID.String()))
-		}
-		log.Infow("census created for alfafrens channel",
-			"channelID", channelAddr.String(),
-			"participants", len(censusInfo.Usernames))
-	}()
-	// return the censusID to the client
-	return json.Marshal(map[string]string{"censusId": censusID.String()})
-}
-
-func (v *vocdoniHandler) checkERC20ContractHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
-	// TODO: It should receive CheckCensusSource instance
-	return ctx.Send([]byte("ok"), http.StatusOK)
-}
-
-func (v *vocdoniHandler) checkNFTContractHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
-	// TODO: It should receive CheckCensusSource instance
-	return ctx.Send([]byte("ok"), http.StatusOK)
+	for _, tokenHolder := range tokenHolders {
+		holders = append(holders, []string{tokenHolder.Address.String(), tokenHolder.Balance.String()})
+	}
+	return holders, nil
 }
 
-// getTokenHoldersFromAirstack retuns a list of token holders ans their balances given a list of tokens
+// getTokenHolders retuns a list of token holders ans their balances given a list of tokens
 // It fetches the information of the token holders by consuming the Airstack API
 // The holders list balances is truncated to the number of decimals of the token (if any).
-func (v *vocdoniHandler) getTokenHoldersFromAirstack(
-	tokens []*CensusToken, censusID types.HexBytes, progress chan int,
+// The holders of the tokens with token IDs can also be fetched from the blockchain.
+func (v *vocdoniHandler) getTokenHolders(
+	ctx context.Context, tokens []*CensusToken, progress chan int,
 ) ([][]string, error) {
 	holders := make([][]string, 0)
 	processedTokens := 0
 	totalTokens := len(tokens)
 	totalHolders := 0
 	for _, token := range tokens {
+		if len(token.TokenIDs) > 0 {
+			tokenHolders, err := v.getTokenIDsHolders(ctx, token)
+			if err != nil {
+				return nil, err
+			}
+			holders = append(holders, tokenHolders...)
+			totalHolders += len(tokenHolders)
+			processedTokens++
+			if progress != nil {
+				progress <- 100 * processedTokens / totalTokens
+			}
+			continue
+		}
 		tokenAddress := common.HexToAddress(token.Address)
 		// Get the number of decimals for the token
 		decimals, err := v.airstack.TokenDecimalsByToken(token.Address, token.Blockchain)
@@ -1017,11 +748,8 @@ func (v *vocdoniHandler) getTokenHoldersFromAirstack(
 
 		tokenHolders, err := v.airstack.TokenBalances(tokenAddress, token.Blockchain)
 		if err != nil {
-			log.Warnw("failed to create census for token %s: %v", token.Address, err)
-			v.backgroundQueue.Store(censusID.String(), CensusInfo{
-				Error: fmt.Sprintf("cannot get token %s details: %v", token.Address, err),
-			})
-			return nil, err
+			log.Warnw("failed to create census for token", "token", token.Address, "error", err)
+			return nil, fmt.Errorf("cannot get token %s details: %w", token.Address, err)
 		}
 
 		for _, tokenHolder := range tokenHolders {
@@ -1032,23 +760,60 @@ func (v *vocdoniHandler) getTokenHoldersFromAirstack(
 		// update the progress if the progress channel is provided
 		// since the response time of GetTokenBalances is unknown, because it dependends on the total number
 		// holders and cannot be known beforehand, update at least the progress between tokens
+		processedTokens++
 		if progress != nil {
-			if currentProcessedTokens := processedTokens + 1; totalTokens >= int(currentProcessedTokens) {
-				currentProgress := 100 * currentProcessedTokens / totalTokens
-				progress <- currentProgress
-			}
+			progress <- 100 * processedTokens / totalTokens
 		}
 	}
-	log.Debugf("airstack total holders found: %d", totalHolders)
+	log.Debugf("total token holders found: %d", totalHolders)
 	return holders, nil
 }
 
-func (v *vocdoniHandler) farcasterCensusFromEthereumCSV(csv []byte, progress chan int) ([]*FarcasterParticipant, uint32, error) {
-	records, err := ParseCSV(csv)
-	if err != nil {
-		return nil, 0, err
+// delegatedWeight returns the weight of the user provided once the delegations
+// are applied and the part of it received from every delegator. The weightOf
+// function returns the own weight of any user. The share of the weight of its
+// delegators is added to the own weight of the user, rounded down. The
+// delegators keep their own weight, so they can override their delegations
+// by voting themselves, and the weight they delegated is substracted from the
+// results of their delegates when both vote.
+func delegatedWeight(userID uint64, weightOf func(uint64) *big.Int,
+	delegations []mongo.Delegation,
+) (*big.Int, map[uint64]*big.Int) {
+	weight := new(big.Int).Set(weightOf(userID))
+	var delegated map[uint64]*big.Int
+	for _, delegation := range delegations {
+		if delegation.To != userID {
+			continue
+		}
+		portion := delegation.Portion(weightOf(delegation.From))
+		if portion.Sign() == 0 {
+			continue
+		}
+		weight.Add(weight, portion)
+		if delegated == nil {
+			delegated = map[uint64]*big.Int{}
+		}
+		if current, ok := delegated[delegation.From]; ok {
+			portion.Add(portion, current)
+		}
+		delegated[delegation.From] = portion
 	}
-	return v.processCensusRecords(records, nil, progress)
+	return weight, delegated
+}
+
+// delegationsWeightScale returns the smallest weight that can be split exactly
+// between the shares of the delegations provided. It is 1 if every delegation
+// moves the whole weight of its delegator.
+func delegationsWeightScale(delegations []mongo.Delegation) int64 {
+	divisor := uint64(mongo.DelegationFullShare)
+	for _, delegation := range delegations {
+		a, b := divisor, uint64(delegation.Shared())
+		for b != 0 {
+			a, b = b, a%b
+		}
+		divisor = a
+	}
+	return int64(mongo.DelegationFullShare / divisor)
 }
 
 // farcasterCensusFromFids creates a list of Farcaster participants from a list
@@ -1066,6 +831,10 @@ func (v *vocdoniHandler) farcasterCensusFromFids(fids []uint64, delegations []mo
 	participantsCh := make(chan *FarcasterParticipant)
 	concurrencyLimit := make(chan struct{}, 10)
 	var processedFids atomic.Uint32
+	// every user has the same weight, scaled to split it exactly between the
+	// partial delegations
+	baseWeight := big.NewInt(delegationsWeightScale(delegations))
+	weightOf := func(uint64) *big.Int { return baseWeight }
 	// Start goroutines to consume data from channel
 	go func() {
 		for {
@@ -1088,25 +857,13 @@ func (v *vocdoniHandler) farcasterCensusFromFids(fids []uint64, delegations []mo
 		go func(idx int, fid uint64) {
 			defer wg.Done()
 			defer func() { <-concurrencyLimit }()
-			// by default, a user has not delegated weight and has a weight of
-			// 1. If the user has the vote delegated, the weight is 0. If the
-			// user has votes delegations, the weight is the number of
-			// delegations. The final user weight is the sum of the user weight
-			// and the delegated weight, if that sum is 0, the user is not
-			// included in the census.
-			userWeight := int64(1)
-			delegatedWeight := int64(0)
-			for _, delegation := range delegations {
-				if delegation.From == fid {
-					userWeight = 0
-				}
-				if delegation.To == fid {
-					delegatedWeight++
-				}
-			}
-			// if the final weight is 0, the user is not included in the census
-			finalWeight := userWeight + delegatedWeight
-			if finalWeight == 0 {
+			// by default, a user has not delegated weight and has the base
+			// weight. If the user has votes delegations, the share of the base
+			// weight of every delegator is added. The delegators keep their
+			// own weight to be able to override their delegations. If the
+			// final weight is 0, the user is not included in the census.
+			finalWeight, delegated := delegatedWeight(fid, weightOf, delegations)
+			if finalWeight.Sign() == 0 {
 				return
 			}
 			// get the user from the database
@@ -1125,10 +882,11 @@ func (v *vocdoniHandler) farcasterCensusFromFids(fids []uint64, delegations []mo
 					return
 				}
 				participantsCh <- &FarcasterParticipant{
-					PubKey:   signerBytes,
-					Weight:   big.NewInt(finalWeight),
-					Username: user.Username,
-					FID:      fid,
+					PubKey:    signerBytes,
+					Weight:    new(big.Int).Set(finalWeight),
+					Username:  user.Username,
+					FID:       fid,
+					Delegated: delegated,
 				}
 			}
 			// update the progress if the progress channel is provided
@@ -1150,44 +908,40 @@ func (v *vocdoniHandler) farcasterCensusFromFids(fids []uint64, delegations []mo
 }
 
 // trackStepProgress tracks the progress of a step in the census creation
-// process. It updates the census progress in the queue. This method must
-// envolve the steps actions secuentally in a goroutine to avoid blocking the
-// main thread, while the progress is tracked in the main. This method creates
-// its own channel and goroutine to track the progress of the current step. This
-// channel is provided to the action function to update the progress and it's
-// closed when the action function finishes. The action function is expected to
-// update the progress channel with the progress of the step.
+// process. It updates the census job progress in the database. This method
+// must envolve the steps actions secuentally in a goroutine to avoid blocking
+// the main thread, while the progress is tracked in the main. This method
+// creates its own channel and goroutine to track the progress of the current
+// step. This channel is provided to the action function to update the progress
+// and it's closed when the action function finishes. The action function is
+// expected to update the progress channel with the progress of the step. The
+// database is only updated when the overall progress changes, and every update
+// renews the lease of the census job.
 func (v *vocdoniHandler) trackStepProgress(censusID types.HexBytes, step, totalSteps int, action func(chan int)) {
 	progress := make(chan int)
-	chanClosed := atomic.Bool{}
+	done := make(chan struct{})
 	go func() {
-		for {
-			p, ok := <-progress
-			if !ok {
-				chanClosed.Store(true)
-				return
-			}
-			censusInfo, ok := v.backgroundQueue.Load(censusID.String())
-			if !ok {
-				return
-			}
-			ci, ok := censusInfo.(CensusInfo)
-			if !ok || ci.Root != nil {
-				return
-			}
+		defer close(done)
+		lastProgress := int64(-1)
+		for p := range progress {
 			// calc partial progress of current step
 			stepIndex := uint32(step - 1)
 			partialStep := 100 / uint32(totalSteps)
 			stepProgress := stepIndex*partialStep + uint32(p)/uint32(totalSteps)
-			// update the census progress
-			ci.Progress = stepProgress
-			v.backgroundQueue.Store(censusID.String(), ci)
+			if int64(stepProgress) == lastProgress {
+				continue
+			}
+			lastProgress = int64(stepProgress)
+			// update the census job progress
+			if err := v.db.UpdateCensusJobProgress(censusID.String(), v.instanceID,
+				step, totalSteps, stepProgress, censusJobLease); err != nil {
+				log.Debugw("cannot update census job progress", "censusID", censusID.String(), "error", err)
+			}
 		}
 	}()
 	action(progress)
-	if !chanClosed.Load() {
-		close(progress)
-	}
+	close(progress)
+	<-done
 }
 
 // processRecord processes a single record of a plain-text census and returns the corresponding Farcaster participants.
@@ -1322,44 +1076,33 @@ func (v *vocdoniHandler) processCensusRecords(records [][]string, delegations []
 				return
 			}
 
-			// find the addres on the map to get the weight
-			// the weight is the sum of the weights of all the addresses of the user
-			userWeight := new(big.Int).SetUint64(0)
-			for _, addr := range user.Addresses {
-				weightAddress, ok := addressMap[helpers.NormalizeAddressString(addr)]
-				if ok {
-					userWeight = userWeight.Add(userWeight, weightAddress)
-				}
-			}
-			// by default, a user has not delegated weight and has a weight is
-			// the sum of weights of all addresses of the user. If the user has
-			// the vote delegated, the weight is 0. If the
-			// user has votes delegations, the weight is the number of
-			// delegations. The final user weight is the sum of the user weight
-			// and the delegated weight, if that sum is 0, the user is not
-			// included in the census.
-			delegatedWeight := big.NewInt(0)
-			for _, delegation := range delegations {
-				if delegation.From == user.UserID {
-					userWeight = big.NewInt(0)
-				}
-				if delegation.To == user.UserID {
-					delegator, err := v.db.User(delegation.From)
-					if err != nil {
-						log.Warnw("error fetching user from database", "address", addr, "error", err)
-						continue
+			// find the addres on the map to get the weight of a user, the
+			// weight is the sum of the weights of all the addresses of the user
+			weightOf := func(fid uint64) *big.Int {
+				weightUser := user
+				if fid != user.UserID {
+					if weightUser, err = v.db.User(fid); err != nil {
+						log.Warnw("error fetching user from database", "fid", fid, "error", err)
+						return big.NewInt(0)
 					}
-					for _, addr := range delegator.Addresses {
-						weightAddress, ok := addressMap[helpers.NormalizeAddressString(addr)]
-						if ok {
-							delegatedWeight = delegatedWeight.Add(delegatedWeight, weightAddress)
-						}
+				}
+				weight := new(big.Int)
+				for _, addr := range weightUser.Addresses {
+					weightAddress, ok := addressMap[helpers.NormalizeAddressString(addr)]
+					if ok {
+						weight.Add(weight, weightAddress)
 					}
 				}
+				return weight
 			}
-			// if the final weight is 0, the user is not included in the census
-			finalWeight := userWeight.Add(userWeight, delegatedWeight)
-			if finalWeight.Cmp(big.NewInt(0)) == 0 {
+			// by default, a user has not delegated weight and has a weight is
+			// the sum of weights of all addresses of the user. If the user has
+			// votes delegations, the share of the weight of every delegator is
+			// added. The delegators keep their own weight to be able to
+			// override their delegations. If the final weight is 0, the user
+			// is not included in the census.
+			finalWeight, delegated := delegatedWeight(user.UserID, weightOf, delegations)
+			if finalWeight.Sign() == 0 {
 				return
 			}
 
@@ -1370,10 +1113,11 @@ func (v *vocdoniHandler) processCensusRecords(records [][]string, delegations []
 					continue
 				}
 				participantsCh <- &FarcasterParticipant{
-					PubKey:   signerBytes,
-					Weight:   finalWeight,
-					Username: user.Username,
-					FID:      user.UserID,
+					PubKey:    signerBytes,
+					Weight:    finalWeight,
+					Username:  user.Username,
+					FID:       user.UserID,
+					Delegated: delegated,
 				}
 			}
 			processedAddresses.Add(1)
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vocdoni/vote-frame/mongo"
+	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/log"
+)
+
+// defaultCensusCacheTTL is the time that a census can be reused by other
+// polls with the same source if the community does not define its own TTL.
+var defaultCensusCacheTTL = 30 * time.Minute
+
+// censusCacheKey returns the key that identifies the censuses built by the
+// census provider provided with the same parameters, delegations and
+// weighting. The delegations are sorted, so the key does not depend on their
+// order.
+func censusCacheKey(provider string, params json.RawMessage, delegations []mongo.Delegation,
+	weighting *mongo.CensusWeighting,
+) string {
+	pairs := make([]string, 0, len(delegations))
+	for _, d := range delegations {
+		pairs = append(pairs, fmt.Sprintf("%d:%d:%d", d.From, d.To, d.Shared()))
+	}
+	sort.Strings(pairs)
+	hash := sha256.New()
+	hash.Write([]byte(provider))
+	hash.Write([]byte{0})
+	hash.Write(params)
+	for _, pair := range pairs {
+		hash.Write([]byte{0})
+		hash.Write([]byte(pair))
+	}
+	if weighting != nil {
+		hash.Write([]byte{0})
+		hash.Write([]byte(weighting.Source + ":" + weighting.Mode))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// censusCacheTTL returns the time that a census built for the community
+// provided can be reused. If no community is provided or the community does
+// not define its own TTL, the default one is returned.
+func (v *vocdoniHandler) censusCacheTTL(communityID string) time.Duration {
+	if communityID == "" {
+		return defaultCensusCacheTTL
+	}
+	community, err := v.db.Community(communityID)
+	if err != nil || community == nil || community.CensusCacheTTL == 0 {
+		return defaultCensusCacheTTL
+	}
+	return time.Duration(community.CensusCacheTTL) * time.Second
+}
+
+// cachedCensus looks for a fresh census built with the same cache key and, if
+// it exists, creates a new census that reuses its root and participants. The
+// new census job is stored as done, so the client gets the result as soon as
+// it checks it. The new job has no cache key, so the census freshness always
+// depends on the time when it was actually built. The new job gets the params
+// of the current request, not the ones of the cached census, since the cache
+// key does not include the community, so the census is linked to the right
+// one. The cached census is not reused if it is larger than the maximum size
+// of the params. It returns the json encoded census ID or nil if there is no
+// fresh census to reuse.
+func (v *vocdoniHandler) cachedCensus(cacheKey, provider string, createdBy uint64,
+	ttl time.Duration, params *censusJobParams,
+) ([]byte, error) {
+	cached, err := v.db.FreshCensusJob(cacheKey, ttl)
+	if err != nil {
+		if errors.Is(err, mongo.ErrCensusJobUnknown) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if cached.Result == nil || (params.MaxSize > 0 && cached.Result.Size > params.MaxSize) {
+		return nil, nil
+	}
+	bParams, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode census job params: %w", err)
+	}
+	cachedCensusID, err := hex.DecodeString(cached.CensusID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cached census ID: %w", err)
+	}
+	censusID, err := v.cli.NewCensus(api.CensusTypeWeighted)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.db.AddCensus(censusID, createdBy); err != nil {
+		return nil, fmt.Errorf("cannot add census to database: %w", err)
+	}
+	if err := v.db.CloneCensus(cachedCensusID, censusID); err != nil {
+		return nil, err
+	}
+	job := &mongo.CensusJob{
+		CensusID:  censusID.String(),
+		Type:      provider,
+		Params:    bParams,
+		CreatedBy: createdBy,
+	}
+	if err := v.db.AddCensusJob(job, v.instanceID, censusJobLease); err != nil {
+		return nil, fmt.Errorf("cannot add census job to database: %w", err)
+	}
+	if err := v.db.FinishCensusJob(job.CensusID, v.instanceID, cached.Result); err != nil {
+		return nil, fmt.Errorf("cannot store census job result: %w", err)
+	}
+	log.Infow("census reused from cache",
+		"censusID", censusID.String(),
+		"cachedCensusID", cached.CensusID,
+		"provider", provider)
+	return json.Marshal(map[string]string{"censusId": censusID.String()})
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vocdoni/vote-frame/mongo"
+	"go.vocdoni.io/dvote/httprouter"
+	"go.vocdoni.io/dvote/httprouter/apirest"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/vochain/state"
+)
+
+const (
+	censusExportFormatCSV  = "csv"
+	censusExportFormatJSON = "json"
+	// censusExportFlushEvery is the number of participants written to the
+	// response between flushes when a census is exported.
+	censusExportFlushEvery = 1000
+)
+
+// censusParticipantFID returns the FID of the participant with the username
+// provided in the census provided. The censuses created before the FIDs were
+// stored with the participants fall back to the users database.
+func (v *vocdoniHandler) censusParticipantFID(census *mongo.Census, username string) uint64 {
+	if fid, ok := census.ParticipantFIDs[username]; ok {
+		return fid
+	}
+	user, err := v.db.UserByUsername(username)
+	if err != nil {
+		return 0
+	}
+	return user.UserID
+}
+
+// censusExportHandler streams the participants of the census with the ID
+// provided, with their username, FID and weight. The format can be 'csv'
+// (default) or 'json' and is set by the 'format' query parameter. It is a raw
+// HTTP handler to write the participants to the response as they are
+// encoded instead of buffering the whole census.
+func (v *vocdoniHandler) censusExportHandler(w http.ResponseWriter, r *http.Request) {
+	censusID, err := hex.DecodeString(chi.URLParam(r, "censusID"))
+	if err != nil {
+		http.Error(w, "invalid census ID", http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = censusExportFormatCSV
+	}
+	if format != censusExportFormatCSV && format != censusExportFormatJSON {
+		http.Error(w, "invalid format, must be csv or json", http.StatusBadRequest)
+		return
+	}
+	census, err := v.db.Census(censusID)
+	if err != nil {
+		http.Error(w, "census not found", http.StatusNotFound)
+		return
+	}
+	// sort the participants by username to get always the same export
+	usernames := make([]string, 0, len(census.Participants))
+	for username := range census.Participants {
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+
+	flusher, _ := w.(http.Flusher)
+	filename := fmt.Sprintf("census-%s.%s", census.CensusID, format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	switch format {
+	case censusExportFormatCSV:
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		csvWriter := csv.NewWriter(w)
+		if err := csvWriter.Write([]string{"username", "fid", "weight"}); err != nil {
+			return
+		}
+		for i, username := range usernames {
+			fid := v.censusParticipantFID(&census, username)
+			record := []string{username, strconv.FormatUint(fid, 10), census.Participants[username]}
+			if err := csvWriter.Write(record); err != nil {
+				log.Debugw("census export interrupted", "censusID", census.CensusID, "error", err)
+				return
+			}
+			if (i+1)%censusExportFlushEvery == 0 {
+				csvWriter.Flush()
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+		}
+		csvWriter.Flush()
+	case censusExportFormatJSON:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write([]byte("[")); err != nil {
+			return
+		}
+		for i, username := range usernames {
+			participant, err := json.Marshal(&CensusParticipant{
+				Username: username,
+				FID:      v.censusParticipantFID(&census, username),
+				Weight:   census.Participants[username],
+			})
+			if err != nil {
+				return
+			}
+			if i > 0 {
+				participant = append([]byte(","), participant...)
+			}
+			if _, err := w.Write(participant); err != nil {
+				log.Debugw("census export interrupted", "censusID", census.CensusID, "error", err)
+				return
+			}
+			if (i+1)%censusExportFlushEvery == 0 && flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if _, err := w.Write([]byte("]")); err != nil {
+			return
+		}
+	}
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+// censusParticipantHandler returns the weight of a participant of the census
+// with the ID provided, the delegations that added weight to it when the
+// census was built, with the weight attributed to each delegator, and the
+// inclusion proofs of its keys in the census tree, that can be verified
+// against the census root. The participant can be provided by FID or
+// username.
+func (v *vocdoniHandler) censusParticipantHandler(_ *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	censusID, err := hex.DecodeString(ctx.URLParam("censusID"))
+	if err != nil {
+		return ctx.Send([]byte("invalid census ID"), http.StatusBadRequest)
+	}
+	census, err := v.db.Census(censusID)
+	if err != nil {
+		return ctx.Send([]byte("census not found"), http.StatusNotFound)
+	}
+	// get the user by FID or username
+	var user *mongo.User
+	fidOrUsername := ctx.URLParam("fidOrUsername")
+	if fid, err := strconv.ParseUint(fidOrUsername, 10, 64); err == nil {
+		user, err = v.db.User(fid)
+		if err != nil {
+			return ctx.Send([]byte("participant not found"), http.StatusNotFound)
+		}
+	} else {
+		user, err = v.db.UserByUsername(fidOrUsername)
+		if err != nil {
+			return ctx.Send([]byte("participant not found"), http.StatusNotFound)
+		}
+	}
+	strWeight, ok := census.Participants[user.Username]
+	if !ok {
+		return ctx.Send([]byte("participant not found"), http.StatusNotFound)
+	}
+	participant := &CensusParticipantInfo{
+		CensusParticipant: CensusParticipant{
+			Username: user.Username,
+			FID:      user.UserID,
+			Weight:   strWeight,
+		},
+		Root:        census.Root,
+		Delegations: []mongo.CensusDelegation{},
+		Proofs:      []*CensusParticipantProof{},
+	}
+	// get the delegations that added weight to the participant when the
+	// census was built, with the weight attributed to each delegator, so
+	// they match the weight and the proofs of the census and not the current
+	// delegations of the community
+	for _, delegation := range census.Delegations {
+		if delegation.To == user.UserID {
+			participant.Delegations = append(participant.Delegations, delegation)
+		}
+	}
+	// generate the inclusion proofs of every signer of the user, since each
+	// signer is a different key of the census tree
+	if census.Root != "" {
+		root, err := hex.DecodeString(census.Root)
+		if err != nil {
+			return fmt.Errorf("invalid census root: %w", err)
+		}
+		for _, signer := range user.Signers {
+			signerBytes, err := hex.DecodeString(strings.TrimPrefix(signer, "0x"))
+			if err != nil {
+				log.Warnw("error decoding signer", "signer", signer, "err", err)
+				continue
+			}
+			voterID := state.NewFarcasterVoterID(signerBytes, user.UserID)
+			proof, err := v.cli.CensusGenProof(root, voterID.Address())
+			if err != nil {
+				log.Debugw("cannot generate census proof", "signer", signer, "error", err)
+				continue
+			}
+			weight := "1"
+			if proof.LeafWeight != nil {
+				weight = proof.LeafWeight.String()
+			}
+			participant.Proofs = append(participant.Proofs, &CensusParticipantProof{
+				Signer:    signer,
+				Key:       types.HexBytes(voterID.Address()),
+				Proof:     proof.Proof,
+				Siblings:  proof.Siblings,
+				LeafValue: proof.LeafValue,
+				Weight:    weight,
+			})
+		}
+	}
+	data, err := json.Marshal(participant)
+	if err != nil {
+		return err
+	}
+	return ctx.Send(data, http.StatusOK)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vocdoni/vote-frame/mongo"
+	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/types"
+)
+
+const (
+	// censusJobLease is the time that an instance owns a census job without
+	// renewing it. If the instance stops, other instance can claim the job
+	// once the lease expires.
+	censusJobLease = 2 * time.Minute
+	// censusJobResumeInterval is the time between checks for orphaned census
+	// jobs to be resumed.
+	censusJobResumeInterval = time.Minute
+	// maxCensusJobAttempts is the maximum number of times that a census job is
+	// started before it is marked as failed.
+	maxCensusJobAttempts = 3
+)
+
+// censusJobParams contains the parameters required to build a census in
+// background. They are stored with the census job to be able to resume or
+// retry it from any instance. The delegations are not stored, if the census
+// is created for a community, they are fetched again from the database using
+// the community ID when the job is started. The maximum size of the census is
+// fixed when the census is requested, according to the reputation of the user
+// or the community.
+type censusJobParams struct {
+	Params      json.RawMessage        `json:"params,omitempty"`
+	CommunityID string                 `json:"communityId,omitempty"`
+	MaxSize     uint64                 `json:"maxSize,omitempty"`
+	Weighting   *mongo.CensusWeighting `json:"weighting,omitempty"`
+}
+
+// enqueueCensusJob creates a new census ID, registers it in the database with
+// a census job for the census provider and parameters provided and starts
+// building it in background. The cache key allows to reuse the census later.
+// It returns the json encoded census ID to be sent to the client.
+func (v *vocdoniHandler) enqueueCensusJob(provider, cacheKey string, createdBy uint64,
+	params *censusJobParams,
+) ([]byte, error) {
+	bParams, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode census job params: %w", err)
+	}
+	censusID, err := v.cli.NewCensus(api.CensusTypeWeighted)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.db.AddCensus(censusID, createdBy); err != nil {
+		return nil, fmt.Errorf("cannot add census to database: %w", err)
+	}
+	job := &mongo.CensusJob{
+		CensusID:  censusID.String(),
+		Type:      provider,
+		Params:    bParams,
+		CacheKey:  cacheKey,
+		CreatedBy: createdBy,
+	}
+	if err := v.db.AddCensusJob(job, v.instanceID, censusJobLease); err != nil {
+		return nil, fmt.Errorf("cannot add census job to database: %w", err)
+	}
+	go v.runCensusJob(job)
+	return json.Marshal(map[string]string{"censusId": censusID.String()})
+}
+
+// runCensusJob builds the census of the job provided, which must be owned by
+// the current instance. It renews the lease of the job while the census is
+// being built and stores the result or the error in the database when it
+// finishes.
+func (v *vocdoniHandler) runCensusJob(job *mongo.CensusJob) {
+	censusID, err := hex.DecodeString(job.CensusID)
+	if err != nil {
+		log.Warnw("invalid census job ID", "censusID", job.CensusID, "error", err)
+		return
+	}
+	// keep the lease of the job alive until the census is ready
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		ticker := time.NewTicker(censusJobLease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := v.db.RenewCensusJobLease(job.CensusID, v.instanceID, censusJobLease); err != nil {
+					log.Warnw("cannot renew census job lease", "censusID", job.CensusID, "error", err)
+				}
+			}
+		}
+	}()
+	log.Infow("running census job", "censusID", job.CensusID, "type", job.Type, "attempt", job.Attempts)
+	censusInfo, err := v.buildCensusFromJob(censusID, job)
+	if err != nil {
+		log.Warnw("census job failed", "censusID", job.CensusID, "type", job.Type, "error", err)
+		if err := v.db.FailCensusJob(job.CensusID, v.instanceID, err.Error()); err != nil {
+			log.Warnw("cannot store census job error", "censusID", job.CensusID, "error", err)
+		}
+		return
+	}
+	if err := v.db.SetRootForCensus(censusID, censusInfo.Root); err != nil {
+		log.Warnw("cannot set root for census", "censusID", job.CensusID, "error", err)
+	}
+	// the usernames are only returned if there are not too many of them, so
+	// there is no need to store them either
+	usernames := censusInfo.Usernames
+	if len(usernames) > maxUsersNamesToReturn {
+		usernames = nil
+	}
+	if err := v.db.FinishCensusJob(job.CensusID, v.instanceID, &mongo.CensusJobResult{
+		Root:               censusInfo.Root.String(),
+		URL:                censusInfo.Url,
+		Size:               censusInfo.Size,
+		Usernames:          usernames,
+		FromTotalAddresses: censusInfo.FromTotalAddresses,
+	}); err != nil {
+		log.Warnw("cannot store census job result", "censusID", job.CensusID, "error", err)
+	}
+}
+
+// buildCensusFromJob decodes the parameters of the census job provided and
+// builds the census using the census provider of the job type.
+func (v *vocdoniHandler) buildCensusFromJob(censusID types.HexBytes, job *mongo.CensusJob) (*CensusInfo, error) {
+	params := &censusJobParams{}
+	if err := json.Unmarshal(job.Params, params); err != nil {
+		return nil, fmt.Errorf("cannot decode census job params: %w", err)
+	}
+	// get the delegations of the community if the census is for a community
+	var delegations []mongo.Delegation
+	if params.CommunityID != "" {
+		var err error
+		if delegations, err = v.db.FinalDelegationsByCommunity(params.CommunityID); err != nil {
+			return nil, fmt.Errorf("cannot get community delegations: %w", err)
+		}
+	}
+	provider, ok := v.censusProvider(job.Type)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCensusProviderUnknown, job.Type)
+	}
+	censusInfo, err := v.buildProviderCensus(censusID, provider, params.Params, delegations, params.MaxSize, params.Weighting)
+	if err != nil {
+		return nil, err
+	}
+	// keep a snapshot of the delegations used to build the census of the
+	// community, so the delegated weight can be audited later
+	if params.CommunityID != "" {
+		snapshotID, err := v.db.AddDelegationSnapshot(params.CommunityID, delegations)
+		if err != nil {
+			log.Warnw("cannot store delegations snapshot", "censusID", censusID.String(), "error", err)
+		} else if err := v.db.SetCensusDelegationSnapshot(censusID, snapshotID); err != nil {
+			log.Warnw("cannot link delegations snapshot", "censusID", censusID.String(), "error", err)
+		}
+	}
+	return censusInfo, nil
+}
+
+// resumeCensusJobsAtBackground looks for census jobs that are not finished
+// and are not being built by any instance (because their lease has expired)
+// and resumes them. The jobs that have been started too many times are
+// marked as failed. It must run in the background.
+func resumeCensusJobsAtBackground(ctx context.Context, v *vocdoniHandler) {
+	for {
+		for {
+			job, err := v.db.ClaimNextCensusJob(v.instanceID, censusJobLease)
+			if err != nil {
+				if mongo.IsDBClosed(err) {
+					log.Warn("database client is disconnected")
+					return
+				}
+				if !errors.Is(err, mongo.ErrCensusJobUnknown) {
+					log.Errorw(err, "failed to claim census job")
+				}
+				break
+			}
+			if job.Attempts > maxCensusJobAttempts {
+				if err := v.db.FailCensusJob(job.CensusID, v.instanceID, "too many attempts to build the census"); err != nil {
+					log.Warnw("cannot store census job error", "censusID", job.CensusID, "error", err)
+				}
+				continue
+			}
+			log.Infow("resuming census job", "censusID", job.CensusID, "type", job.Type)
+			go v.runCensusJob(job)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(censusJobResumeInterval):
+		}
+	}
+}
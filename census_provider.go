@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/vocdoni/vote-frame/farcasterapi"
+	"github.com/vocdoni/vote-frame/mongo"
+	"go.vocdoni.io/dvote/httprouter"
+	"go.vocdoni.io/dvote/httprouter/apirest"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/types"
+)
+
+var (
+	// ErrInvalidCensusParams is returned when the parameters provided to a
+	// census provider are not valid.
+	ErrInvalidCensusParams = fmt.Errorf("invalid census parameters")
+	// ErrCensusProviderUnknown is returned when the requested census provider
+	// is not registered.
+	ErrCensusProviderUnknown = fmt.Errorf("census provider unknown")
+)
+
+// CensusProviderParam describes a parameter accepted by a census provider.
+type CensusProviderParam struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+}
+
+// CensusProvider is a source of participants to build a census. Every
+// provider is registered by its name, which is used to create censuses from it
+// through the generic census endpoint. The census creation process is common
+// to every provider: the provider parameters are validated when the request is
+// received, then a census job is created and the provider streams the
+// participants in background while the progress is tracked, and finally the
+// census is published and stored.
+type CensusProvider interface {
+	// Name returns the unique name of the provider.
+	Name() string
+	// Description returns a human readable description of the provider.
+	Description() string
+	// Params returns the schema of the parameters accepted by the provider.
+	Params() []CensusProviderParam
+	// CensusType returns the type of the censuses built by the provider.
+	CensusType() FrameCensusType
+	// Validate checks the parameters provided by the user who requests the
+	// census and returns them normalized, ready to be stored with the census
+	// job. It must return an error wrapping ErrInvalidCensusParams if the
+	// parameters are not valid.
+	Validate(ctx context.Context, userFID uint64, params json.RawMessage) (json.RawMessage, error)
+	// Build sends the participants of the census to the participants channel
+	// and the progress (0-100) of the process to the progress channel. It
+	// must not close any of the channels. It returns the total number of
+	// addresses or users processed, including the ones that are not included
+	// in the census.
+	Build(ctx context.Context, params json.RawMessage, delegations []mongo.Delegation,
+		participants chan<- *FarcasterParticipant, progress chan int) (uint32, error)
+}
+
+// RegisterCensusProvider adds the census provider to the registry of the
+// handler. It returns an error if other provider with the same name is
+// already registered.
+func (v *vocdoniHandler) RegisterCensusProvider(provider CensusProvider) error {
+	v.censusProvidersLock.Lock()
+	defer v.censusProvidersLock.Unlock()
+	if v.censusProviders == nil {
+		v.censusProviders = map[string]CensusProvider{}
+	}
+	if _, ok := v.censusProviders[provider.Name()]; ok {
+		return fmt.Errorf("census provider %s already registered", provider.Name())
+	}
+	v.censusProviders[provider.Name()] = provider
+	return nil
+}
+
+// censusProvider returns the census provider registered with the name
+// provided.
+func (v *vocdoniHandler) censusProvider(name string) (CensusProvider, bool) {
+	v.censusProvidersLock.RLock()
+	defer v.censusProvidersLock.RUnlock()
+	provider, ok := v.censusProviders[name]
+	return provider, ok
+}
+
+// newProviderCensus validates the parameters for the census provider with the
+// name provided and creates a census job to build the census in background.
+// If the community ID is provided, the delegations of the community are taken
+// into account when the census is built. If a census with the same provider,
+// parameters and delegations has been built recently, it is reused instead of
+// building it again, unless a refresh is requested. The census is rejected if
+// it is larger than the maximum size allowed for the user or the community.
+// It returns the json encoded census ID.
+func (v *vocdoniHandler) newProviderCensus(ctx context.Context, name string, userFID uint64,
+	params json.RawMessage, communityID string, weighting *mongo.CensusWeighting, refresh bool,
+) ([]byte, error) {
+	provider, ok := v.censusProvider(name)
+	if !ok {
+		return nil, ErrCensusProviderUnknown
+	}
+	validParams, err := provider.Validate(ctx, userFID, params)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateCensusWeighting(weighting); err != nil {
+		return nil, err
+	}
+	var delegations []mongo.Delegation
+	if communityID != "" {
+		if delegations, err = v.db.FinalDelegationsByCommunity(communityID); err != nil {
+			return nil, fmt.Errorf("cannot get community delegations: %w", err)
+		}
+		if weighting == nil {
+			weighting = v.communityCensusWeighting(communityID)
+		}
+	}
+	jobParams := &censusJobParams{
+		Params:      validParams,
+		CommunityID: communityID,
+		MaxSize:     v.maxCensusSize(userFID, communityID),
+		Weighting:   weighting,
+	}
+	cacheKey := censusCacheKey(provider.Name(), validParams, delegations, weighting)
+	if !refresh {
+		data, err := v.cachedCensus(cacheKey, provider.Name(), userFID, v.censusCacheTTL(communityID), jobParams)
+		if err != nil {
+			log.Warnw("cannot reuse cached census", "provider", provider.Name(), "error", err)
+		} else if data != nil {
+			return data, nil
+		}
+	}
+	return v.enqueueCensusJob(provider.Name(), cacheKey, userFID, jobParams)
+}
+
+// sendProviderCensus creates a census from the census provider with the name
+// provided and sends the census ID as response, or the right error status
+// according to the error returned. The census creator can skip the census
+// cache and force to build it again using the 'refresh' query parameter.
+func (v *vocdoniHandler) sendProviderCensus(ctx *httprouter.HTTPContext, name string, userFID uint64,
+	params json.RawMessage, communityID string, weighting *mongo.CensusWeighting,
+) error {
+	refresh, _ := strconv.ParseBool(ctx.Request.URL.Query().Get("refresh"))
+	data, err := v.newProviderCensus(ctx.Request.Context(), name, userFID, params, communityID, weighting, refresh)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrCensusProviderUnknown), errors.Is(err, farcasterapi.ErrChannelNotFound):
+			return ctx.Send([]byte(err.Error()), http.StatusNotFound)
+		case errors.Is(err, ErrInvalidCensusParams):
+			return ctx.Send([]byte(err.Error()), http.StatusBadRequest)
+		}
+		log.Warnw("error creating census", "provider", name, "error", err)
+		return ctx.Send([]byte("error creating census"), http.StatusInternalServerError)
+	}
+	return ctx.Send(data, http.StatusOK)
+}
+
+// censusProviderHandler creates a new census from the census provider set in
+// the URL. The body includes the parameters of the provider and, optionally,
+// the ID of a community managed by the user to take into account its
+// delegations and the weighting to apply to the participants. It builds the
+// census async and returns the census ID.
+func (v *vocdoniHandler) censusProviderHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	userFID, err := v.db.UserFromAuthToken(msg.AuthToken)
+	if err != nil {
+		return fmt.Errorf("cannot get user from auth token: %w", err)
+	}
+	req := &CensusProviderRequest{}
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, req); err != nil {
+			return ctx.Send([]byte("invalid request body"), http.StatusBadRequest)
+		}
+	}
+	if req.CommunityID != "" && !v.db.HasCommunityPermission(userFID, req.CommunityID, mongo.PermissionCreatePoll) {
+		return ctx.Send([]byte("user is not allowed to create polls in the community"), http.StatusForbidden)
+	}
+	return v.sendProviderCensus(ctx, ctx.URLParam("provider"), userFID, req.Params, req.CommunityID, req.Weighting)
+}
+
+// censusProvidersHandler returns the list of registered census providers with
+// the schema of their parameters.
+func (v *vocdoniHandler) censusProvidersHandler(_ *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	v.censusProvidersLock.RLock()
+	providers := []*CensusProviderInfo{}
+	for _, provider := range v.censusProviders {
+		providers = append(providers, &CensusProviderInfo{
+			Name:        provider.Name(),
+			Description: provider.Description(),
+			Params:      provider.Params(),
+		})
+	}
+	v.censusProvidersLock.RUnlock()
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].Name < providers[j].Name
+	})
+	data, err := json.Marshal(map[string][]*CensusProviderInfo{"providers": providers})
+	if err != nil {
+		return err
+	}
+	return ctx.Send(data, http.StatusOK)
+}
+
+// buildProviderCensus builds the census using the census provider provided.
+// It collects the participants streamed by the provider, creates and
+// publishes the census and stores its participants in the database. If the
+// maximum size provided is not zero and the provider streams more
+// participants, the census is rejected before publishing it. It updates the
+// progress of the census job and returns the census information when it's
+// ready.
+func (v *vocdoniHandler) buildProviderCensus(censusID types.HexBytes, provider CensusProvider,
+	params json.RawMessage, delegations []mongo.Delegation, maxSize uint64, weighting *mongo.CensusWeighting,
+) (*CensusInfo, error) {
+	internalCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startTime := time.Now()
+	log.Debugw("building census", "censusID", censusID, "provider", provider.Name())
+	// collect the participants streamed by the provider
+	var participants []*FarcasterParticipant
+	var fromTotal uint32
+	var err error
+	v.trackStepProgress(censusID, 1, 2, func(progress chan int) {
+		participantsCh := make(chan *FarcasterParticipant)
+		collected := make(chan struct{})
+		go func() {
+			defer close(collected)
+			for p := range participantsCh {
+				participants = append(participants, p)
+			}
+		}()
+		fromTotal, err = provider.Build(internalCtx, params, delegations, participantsCh, progress)
+		close(participantsCh)
+		<-collected
+	})
+	if err != nil {
+		return nil, err
+	}
+	// apply the weighting to the participants streamed by the provider
+	if participants, err = v.weightCensusParticipants(participants, weighting); err != nil {
+		return nil, err
+	}
+	if len(participants) == 0 {
+		return nil, ErrNoValidParticipants
+	}
+	if maxSize > 0 && uint64(len(participants)) > maxSize {
+		return nil, fmt.Errorf("%w: the census size is %d and the maximum allowed is %d",
+			ErrCensusTooLarge, len(participants), maxSize)
+	}
+	// create the census from the participants
+	var censusInfo *CensusInfo
+	v.trackStepProgress(censusID, 2, 2, func(progress chan int) {
+		censusInfo, err = CreateCensus(v.cli, participants, provider.CensusType(), progress)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// since each participant can have multiple signers, we need to get the unique usernames
+	uniqueParticipantsMap := make(map[string]*big.Int)
+	participantFIDs := make(map[string]uint64)
+	uniqueParticipants := []*FarcasterParticipant{}
+	totalWeight := new(big.Int).SetUint64(0)
+	for _, p := range participants {
+		if _, ok := uniqueParticipantsMap[p.Username]; ok {
+			// if the username is already in the map, continue
+			continue
+		}
+		uniqueParticipantsMap[p.Username] = p.Weight
+		participantFIDs[p.Username] = p.FID
+		uniqueParticipants = append(uniqueParticipants, p)
+		totalWeight.Add(totalWeight, p.Weight)
+	}
+	// the delegators keep their own weight in the census, so the weight they
+	// delegated to other participants is counted twice in the total
+	censusDelegations, doubleCounted := attributedCensusDelegations(uniqueParticipants)
+	totalWeight.Sub(totalWeight, doubleCounted)
+	// only return the username list if it's less than the maxUsersNamesToReturn
+	if len(uniqueParticipantsMap) < maxUsersNamesToReturn {
+		for username := range uniqueParticipantsMap {
+			censusInfo.Usernames = append(censusInfo.Usernames, username)
+		}
+	}
+	censusInfo.FromTotalAddresses = fromTotal
+	// add participants to the census in the database
+	if err := v.db.AddParticipantsToCensus(
+		censusID,
+		uniqueParticipantsMap,
+		participantFIDs,
+		censusInfo.FromTotalAddresses,
+		totalWeight,
+		censusInfo.Url,
+	); err != nil {
+		log.Errorw(err, fmt.Sprintf("failed to add participants to census %s", censusID.String()))
+	}
+	if err := v.db.SetCensusDelegations(censusID, censusDelegations); err != nil {
+		log.Errorw(err, fmt.Sprintf("failed to add delegations to census %s", censusID.String()))
+	}
+	log.Infow("census created",
+		"censusID", censusID.String(),
+		"provider", provider.Name(),
+		"participants", len(uniqueParticipantsMap),
+		"totalWeight", totalWeight.String(),
+		"fromTotalAddresses", fromTotal,
+		"duration", time.Since(startTime))
+	return censusInfo, nil
+}
+
+// trackSubProgress splits the progress of a census provider into several
+// parts. The progress (0-100) sent by the action to the channel provided is
+// scaled to the range [from, to] of the parent progress channel. It returns
+// when the action finishes.
+func trackSubProgress(progress chan int, from, to int, action func(chan int)) {
+	subProgress := make(chan int)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for p := range subProgress {
+			if progress != nil {
+				progress <- from + p*(to-from)/100
+			}
+		}
+	}()
+	action(subProgress)
+	close(subProgress)
+	<-done
+}
+
+// sendParticipants sends the participants provided to the participants
+// channel of a census provider, stopping if the context is done.
+func sendParticipants(ctx context.Context, participants []*FarcasterParticipant,
+	participantsCh chan<- *FarcasterParticipant,
+) error {
+	for _, p := range participants {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case participantsCh <- p:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,885 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/vote-frame/alfafrens"
+	"github.com/vocdoni/vote-frame/communityhub"
+	"github.com/vocdoni/vote-frame/farcasterapi"
+	"github.com/vocdoni/vote-frame/helpers"
+	"github.com/vocdoni/vote-frame/mongo"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/types"
+)
+
+const (
+	censusProviderCSV       = "csv"
+	censusProviderChannel   = "channel"
+	censusProviderFollowers = "followers"
+	censusProviderFollowing = "following"
+	censusProviderAlfafrens = "alfafrens"
+	censusProviderNFT       = "nft"
+	censusProviderERC20     = "erc20"
+	censusProviderFile      = "file"
+	censusProviderVotes     = "erc20votes"
+	censusProviderEngaged   = "engaged"
+	censusProviderMembers   = "members"
+)
+
+// registerDefaultCensusProviders registers the census providers of every
+// source supported by the service. The token based providers are only
+// registered if the Airstack service is available, except the NFT one, which
+// is also registered if the tokens can be queried on-chain, to support the NFT
+// censuses based on token IDs. The governance token provider is registered if
+// the tokens can be queried on-chain.
+func (v *vocdoniHandler) registerDefaultCensusProviders() error {
+	providers := []CensusProvider{
+		&csvCensusProvider{v: v},
+		&channelCensusProvider{v: v},
+		&followersCensusProvider{v: v},
+		&followingCensusProvider{v: v},
+		&alfafrensCensusProvider{v: v},
+		&fileCensusProvider{v: v},
+		&engagedCensusProvider{v: v},
+		&membersCensusProvider{v: v},
+	}
+	if v.airstack != nil || v.onchain != nil {
+		providers = append(providers, &tokenCensusProvider{v: v, tokenType: NFTtype})
+	}
+	if v.airstack != nil {
+		providers = append(providers, &tokenCensusProvider{v: v, tokenType: ERC20type})
+	}
+	if v.onchain != nil {
+		providers = append(providers, &votesCensusProvider{v: v})
+	}
+	for _, provider := range providers {
+		if err := v.RegisterCensusProvider(provider); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeCensusParams decodes the census provider parameters provided into the
+// destination provided. It returns an error wrapping ErrInvalidCensusParams if
+// the parameters cannot be decoded.
+func decodeCensusParams(params json.RawMessage, dst any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, dst); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCensusParams, err)
+	}
+	return nil
+}
+
+// csvCensusParams are the parameters of the CSV census provider.
+type csvCensusParams struct {
+	CSV string `json:"csv"`
+}
+
+// csvCensusProvider builds a census from a CSV file containing Ethereum
+// addresses and weights.
+type csvCensusProvider struct {
+	v *vocdoniHandler
+}
+
+func (p *csvCensusProvider) Name() string {
+	return censusProviderCSV
+}
+
+func (p *csvCensusProvider) Description() string {
+	return "Farcaster users with the Ethereum addresses and weights of a CSV file"
+}
+
+func (p *csvCensusProvider) Params() []CensusProviderParam {
+	return []CensusProviderParam{
+		{Name: "csv", Type: "string", Description: "CSV content with address and weight columns", Required: true},
+	}
+}
+
+func (p *csvCensusProvider) CensusType() FrameCensusType {
+	return FrameCensusTypeCSV
+}
+
+func (p *csvCensusProvider) Validate(_ context.Context, _ uint64, params json.RawMessage) (json.RawMessage, error) {
+	req := &csvCensusParams{}
+	if err := decodeCensusParams(params, req); err != nil {
+		return nil, err
+	}
+	if req.CSV == "" {
+		return nil, fmt.Errorf("%w: csv is required", ErrInvalidCensusParams)
+	}
+	if _, err := ParseCSV([]byte(req.CSV)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCensusParams, err)
+	}
+	return json.Marshal(req)
+}
+
+func (p *csvCensusProvider) Build(ctx context.Context, params json.RawMessage, delegations []mongo.Delegation,
+	participants chan<- *FarcasterParticipant, progress chan int,
+) (uint32, error) {
+	req := &csvCensusParams{}
+	if err := decodeCensusParams(params, req); err != nil {
+		return 0, err
+	}
+	records, err := ParseCSV([]byte(req.CSV))
+	if err != nil {
+		return 0, err
+	}
+	csvParticipants, total, err := p.v.processCensusRecords(records, delegations, progress)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build census from ethereum csv: %w", err)
+	}
+	return total, sendParticipants(ctx, csvParticipants, participants)
+}
+
+// channelCensusParams are the parameters of the Warpcast channel census
+// provider. The census can be built from a single channel or from several
+// channels.
+type channelCensusParams struct {
+	ChannelID  string   `json:"channelID,omitempty"`
+	ChannelIDs []string `json:"channelIDs,omitempty"`
+}
+
+// channels returns the unique channels of the parameters, including the
+// single channel and the list of channels.
+func (p *channelCensusParams) channels() []string {
+	channels := []string{}
+	included := map[string]bool{}
+	for _, channelID := range append([]string{p.ChannelID}, p.ChannelIDs...) {
+		if channelID == "" || included[channelID] {
+			continue
+		}
+		included[channelID] = true
+		channels = append(channels, channelID)
+	}
+	return channels
+}
+
+// channelCensusProvider builds a census from the users who follow a Warpcast
+// channel, or from the union of the users who follow several channels.
+type channelCensusProvider struct {
+	v *vocdoniHandler
+}
+
+func (p *channelCensusProvider) Name() string {
+	return censusProviderChannel
+}
+
+func (p *channelCensusProvider) Description() string {
+	return "Farcaster users who follow a Warpcast channel or any of several channels"
+}
+
+func (p *channelCensusProvider) Params() []CensusProviderParam {
+	return []CensusProviderParam{
+		{Name: "channelID", Type: "string", Description: "ID of the Warpcast channel", Required: false},
+		{Name: "channelIDs", Type: "[]string", Description: "IDs of several Warpcast channels", Required: false},
+	}
+}
+
+func (p *channelCensusProvider) CensusType() FrameCensusType {
+	return FrameCensusTypeChannelGated
+}
+
+func (p *channelCensusProvider) Validate(ctx context.Context, _ uint64, params json.RawMessage) (json.RawMessage, error) {
+	req := &channelCensusParams{}
+	if err := decodeCensusParams(params, req); err != nil {
+		return nil, err
+	}
+	channels := req.channels()
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("%w: channelID or channelIDs is required", ErrInvalidCensusParams)
+	}
+	for _, channelID := range channels {
+		exists, err := p.v.fcapi.ChannelExists(ctx, channelID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("%w: %s", farcasterapi.ErrChannelNotFound, channelID)
+		}
+	}
+	// normalize the parameters to get the same cache key for the same
+	// channels, a single channel is always set as the channel ID
+	if len(channels) == 1 {
+		return json.Marshal(&channelCensusParams{ChannelID: channels[0]})
+	}
+	return json.Marshal(&channelCensusParams{ChannelIDs: channels})
+}
+
+func (p *channelCensusProvider) Build(ctx context.Context, params json.RawMessage, delegations []mongo.Delegation,
+	participants chan<- *FarcasterParticipant, progress chan int,
+) (uint32, error) {
+	req := &channelCensusParams{}
+	if err := decodeCensusParams(params, req); err != nil {
+		return 0, err
+	}
+	// get the fids of the users in the channels from the farcaster API,
+	// merging the users who follow more than one channel
+	channels := req.channels()
+	users := []uint64{}
+	included := map[uint64]bool{}
+	for i, channelID := range channels {
+		var channelUsers []uint64
+		var err error
+		from, to := i*50/len(channels), (i+1)*50/len(channels)
+		trackSubProgress(progress, from, to, func(progress chan int) {
+			channelUsers, err = p.v.fcapi.ChannelFIDs(ctx, channelID, progress)
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to get channel %s fids from farcaster API: %w", channelID, err)
+		}
+		for _, fid := range channelUsers {
+			if !included[fid] {
+				included[fid] = true
+				users = append(users, fid)
+			}
+		}
+	}
+	if len(users) == 0 {
+		return 0, fmt.Errorf("no valid participants found for the channel")
+	}
+	// create the participants from the database users using the fids
+	var channelParticipants []*FarcasterParticipant
+	trackSubProgress(progress, 50, 100, func(progress chan int) {
+		channelParticipants = p.v.farcasterCensusFromFids(users, delegations, progress)
+	})
+	if len(channelParticipants) == 0 {
+		return 0, fmt.Errorf("no valid participant signers found for the channel")
+	}
+	return uint32(len(users)), sendParticipants(ctx, channelParticipants, participants)
+}
+
+// followersCensusParams are the parameters of the user followers census
+// provider.
+type followersCensusParams struct {
+	UserFID uint64 `json:"userFid"`
+}
+
+// followersCensusProvider builds a census from the followers of a Farcaster
+// user, including the user.
+type followersCensusProvider struct {
+	v *vocdoniHandler
+}
+
+func (p *followersCensusProvider) Name() string {
+	return censusProviderFollowers
+}
+
+func (p *followersCensusProvider) Description() string {
+	return "Farcaster users who follow a user, including the user"
+}
+
+func (p *followersCensusProvider) Params() []CensusProviderParam {
+	return []CensusProviderParam{
+		{Name: "userFid", Type: "uint64", Description: "FID of the user, the requester by default", Required: false},
+	}
+}
+
+func (p *followersCensusProvider) CensusType() FrameCensusType {
+	return FrameCensusTypeFollowers
+}
+
+func (p *followersCensusProvider) Validate(_ context.Context, userFID uint64, params json.RawMessage) (json.RawMessage, error) {
+	req := &followersCensusParams{}
+	if err := decodeCensusParams(params, req); err != nil {
+		return nil, err
+	}
+	if req.UserFID == 0 {
+		req.UserFID = userFID
+	}
+	return json.Marshal(req)
+}
+
+func (p *followersCensusProvider) Build(ctx context.Context, params json.RawMessage, delegations []mongo.Delegation,
+	participants chan<- *FarcasterParticipant, progress chan int,
+) (uint32, error) {
+	req := &followersCensusParams{}
+	if err := decodeCensusParams(params, req); err != nil {
+		return 0, err
+	}
+	users, err := p.v.fcapi.UserFollowers(ctx, req.UserFID)
+	if err != nil {
+		return 0, err
+	}
+	// include poll author in the census
+	users = append(users, req.UserFID)
+	// create the participants from the database users using the fids
+	followers := p.v.farcasterCensusFromFids(users, delegations, progress)
+	return uint32(len(users)), sendParticipants(ctx, followers, participants)
+}
+
+// followingCensusParams are the parameters of the user following census
+// provider.
+type followingCensusParams struct {
+	UserFID    uint64 `json:"userFid"`
+	MutualOnly bool   `json:"mutualOnly,omitempty"`
+}
+
+// followingCensusProvider builds a census from the users followed by a
+// Farcaster user, including the user. If only the mutual follows are
+// requested, the users followed that do not follow the user back are
+// excluded.
+type followingCensusProvider struct {
+	v *vocdoniHandler
+}
+
+func (p *followingCensusProvider) Name() string {
+	return censusProviderFollowing
+}
+
+func (p *followingCensusProvider) Description() string {
+	return "Farcaster users followed by a user, or only its mutual follows, including the user"
+}
+
+func (p *followingCensusProvider) Params() []CensusProviderParam {
+	return []CensusProviderParam{
+		{Name: "userFid", Type: "uint64", Description: "FID of the user, the requester by default", Required: false},
+		{Name: "mutualOnly", Type: "bool", Description: "include only the users who also follow the user", Required: false},
+	}
+}
+
+func (p *followingCensusProvider) CensusType() FrameCensusType {
+	return FrameCensusTypeFollowing
+}
+
+func (p *followingCensusProvider) Validate(_ context.Context, userFID uint64, params json.RawMessage) (json.RawMessage, error) {
+	req := &followingCensusParams{}
+	if err := decodeCensusParams(params, req); err != nil {
+		return nil, err
+	}
+	if req.UserFID == 0 {
+		req.UserFID = userFID
+	}
+	return json.Marshal(req)
+}
+
+func (p *followingCensusProvider) Build(ctx context.Context, params json.RawMessage, delegations []mongo.Delegation,
+	participants chan<- *FarcasterParticipant, progress chan int,
+) (uint32, error) {
+	req := &followingCensusParams{}
+	if err := decodeCensusParams(params, req); err != nil {
+		return 0, err
+	}
+	users, err := p.v.fcapi.UserFollowing(ctx, req.UserFID)
+	if err != nil {
+		return 0, err
+	}
+	if req.MutualOnly {
+		followers, err := p.v.fcapi.UserFollowers(ctx, req.UserFID)
+		if err != nil {
+			return 0, err
+		}
+		users = mutualFollows(users, followers)
+	}
+	// include poll author in the census
+	users = append(users, req.UserFID)
+	// create the participants from the database users using the fids
+	following := p.v.farcasterCensusFromFids(users, delegations, progress)
+	return uint32(len(users)), sendParticipants(ctx, following, participants)
+}
+
+// mutualFollows returns the FIDs of the users followed that are also in the
+// list of followers provided.
+func mutualFollows(following, followers []uint64) []uint64 {
+	isFollower := make(map[uint64]bool, len(followers))
+	for _, fid := range followers {
+		isFollower[fid] = true
+	}
+	mutual := []uint64{}
+	for _, fid := range following {
+		if isFollower[fid] {
+			mutual = append(mutual, fid)
+		}
+	}
+	return mutual
+}
+
+// alfafrensCensusParams are the parameters of the AlfaFrens channel census
+// provider.
+type alfafrensCensusParams struct {
+	Channel string `json:"channel"`
+}
+
+// alfafrensCensusProvider builds a census from the users who follow the
+// AlfaFrens channel of the user who requests it.
+type alfafrensCensusProvider struct {
+	v *vocdoniHandler
+}
+
+func (p *alfafrensCensusProvider) Name() string {
+	return censusProviderAlfafrens
+}
+
+func (p *alfafrensCensusProvider) Description() string {
+	return "Farcaster users who follow the AlfaFrens channel of the requester"
+}
+
+func (p *alfafrensCensusProvider) Params() []CensusProviderParam {
+	return []CensusProviderParam{}
+}
+
+func (p *alfafrensCensusProvider) CensusType() FrameCensusType {
+	return FrameCensusTypeAlfaFrensChannel
+}
+
+func (p *alfafrensCensusProvider) Validate(_ context.Context, userFID uint64, _ json.RawMessage) (json.RawMessage, error) {
+	// get the channel address from the alfafrens API
+	channelAddr, err := alfafrens.ChannelByFid(userFID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get alfafrens channel address for user %d: %w", userFID, err)
+	}
+	return json.Marshal(&alfafrensCensusParams{Channel: channelAddr.String()})
+}
+
+func (p *alfafrensCensusProvider) Build(ctx context.Context, params json.RawMessage, delegations []mongo.Delegation,
+	participants chan<- *FarcasterParticipant, progress chan int,
+) (uint32, error) {
+	req := &alfafrensCensusParams{}
+	if err := decodeCensusParams(params, req); err != nil {
+		return 0, err
+	}
+	channelAddr, err := hex.DecodeString(strings.TrimPrefix(req.Channel, "0x"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid alfafrens channel address %s: %w", req.Channel, err)
+	}
+	// get the fids of the users in the channel from the alfafrens API
+	progress <- 10
+	users, err := alfafrens.ChannelFids(types.HexBytes(channelAddr))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get channel fids from alfafrens API: %w", err)
+	}
+	if len(users) == 0 {
+		return 0, fmt.Errorf("no valid participants found for the channel")
+	}
+	// create the participants from the database users using the fids
+	var channelParticipants []*FarcasterParticipant
+	trackSubProgress(progress, 20, 100, func(progress chan int) {
+		channelParticipants = p.v.farcasterCensusFromFids(users, delegations, progress)
+	})
+	if len(channelParticipants) == 0 {
+		return 0, fmt.Errorf("no valid participant signers found for the channel")
+	}
+	return uint32(len(users)), sendParticipants(ctx, channelParticipants, participants)
+}
+
+// tokenCensusProvider builds a census from the holders of a list of NFT
+// tokens or an ERC20 token, using the Airstack API. The holders of the NFT
+// tokens with token IDs can also be fetched directly from the blockchain.
+type tokenCensusProvider struct {
+	v         *vocdoniHandler
+	tokenType int
+}
+
+func (p *tokenCensusProvider) Name() string {
+	if p.tokenType == ERC20type {
+		return censusProviderERC20
+	}
+	return censusProviderNFT
+}
+
+func (p *tokenCensusProvider) Description() string {
+	if p.tokenType == ERC20type {
+		return "Farcaster users who hold an ERC20 token, weighted by their balance"
+	}
+	return "Farcaster users who hold any of the NFT tokens, weighted by their balance"
+}
+
+func (p *tokenCensusProvider) Params() []CensusProviderParam {
+	return []CensusProviderParam{
+		{
+			Name:        "tokens",
+			Type:        "[]token",
+			Description: "list of tokens with address, blockchain and, optionally, standard (erc721 or erc1155) and token IDs or ranges of IDs",
+			Required:    true,
+		},
+	}
+}
+
+func (p *tokenCensusProvider) CensusType() FrameCensusType {
+	if p.tokenType == ERC20type {
+		return FrameCensusTypeERC20
+	}
+	return FrameCensusTypeNFT
+}
+
+func (p *tokenCensusProvider) Validate(_ context.Context, _ uint64, params json.RawMessage) (json.RawMessage, error) {
+	req := &CensusTokensRequest{}
+	if err := decodeCensusParams(params, req); err != nil {
+		return nil, err
+	}
+	// check valid tokens length
+	switch p.tokenType {
+	case ERC20type:
+		if len(req.Tokens) != MAXERC20Tokens {
+			return nil, fmt.Errorf("%w: invalid number of ERC20 tokens, must be %d",
+				ErrInvalidCensusParams, MAXERC20Tokens)
+		}
+		for _, token := range req.Tokens {
+			if len(token.TokenIDs) > 0 || (token.Standard != "" && token.Standard != mongo.TokenStandardERC20) {
+				return nil, fmt.Errorf("%w: ERC20 tokens do not support token IDs", ErrInvalidCensusParams)
+			}
+		}
+	default:
+		if len(req.Tokens) > MAXNFTTokens || len(req.Tokens) == 0 {
+			return nil, fmt.Errorf("%w: invalid number of NFT tokens, bounds between 1 and %d",
+				ErrInvalidCensusParams, MAXNFTTokens)
+		}
+	}
+	// check valid tokens
+	if err := p.v.checkTokens(req.Tokens); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCensusParams, err)
+	}
+	return json.Marshal(req)
+}
+
+func (p *tokenCensusProvider) Build(ctx context.Context, params json.RawMessage, delegations []mongo.Delegation,
+	participants chan<- *FarcasterParticipant, progress chan int,
+) (uint32, error) {
+	req := &CensusTokensRequest{}
+	if err := decodeCensusParams(params, req); err != nil {
+		return 0, err
+	}
+	// get holders for each token
+	var holders [][]string
+	var err error
+	trackSubProgress(progress, 0, 50, func(progress chan int) {
+		holders, err = p.v.getTokenHolders(ctx, req.Tokens, progress)
+	})
+	if err != nil {
+		return 0, err
+	}
+	// create census from token holders
+	var holderParticipants []*FarcasterParticipant
+	trackSubProgress(progress, 50, 100, func(progress chan int) {
+		holderParticipants, _, err = p.v.processCensusRecords(holders, delegations, progress)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to build census from token holders: %w", err)
+	}
+	return uint32(len(holders)), sendParticipants(ctx, holderParticipants, participants)
+}
+
+// fileCensusParams are the parameters of the file census provider.
+type fileCensusParams struct {
+	FIDs []uint64 `json:"fids"`
+}
+
+// fileCensusProvider builds a census from a file with a list of Farcaster
+// users FIDs.
+type fileCensusProvider struct {
+	v *vocdoniHandler
+}
+
+func (p *fileCensusProvider) Name() string {
+	return censusProviderFile
+}
+
+func (p *fileCensusProvider) Description() string {
+	return "Farcaster users listed by FID in a file"
+}
+
+func (p *fileCensusProvider) Params() []CensusProviderParam {
+	return []CensusProviderParam{
+		{Name: "fids", Type: "[]uint64", Description: "list of Farcaster users FIDs", Required: true},
+	}
+}
+
+func (p *fileCensusProvider) CensusType() FrameCensusType {
+	return FrameCensusTypeFile
+}
+
+func (p *fileCensusProvider) Validate(_ context.Context, _ uint64, params json.RawMessage) (json.RawMessage, error) {
+	req := &fileCensusParams{}
+	if err := decodeCensusParams(params, req); err != nil {
+		return nil, err
+	}
+	if len(req.FIDs) == 0 {
+		return nil, fmt.Errorf("%w: fids are required", ErrInvalidCensusParams)
+	}
+	return json.Marshal(req)
+}
+
+func (p *fileCensusProvider) Build(ctx context.Context, params json.RawMessage, delegations []mongo.Delegation,
+	participants chan<- *FarcasterParticipant, progress chan int,
+) (uint32, error) {
+	req := &fileCensusParams{}
+	if err := decodeCensusParams(params, req); err != nil {
+		return 0, err
+	}
+	fileParticipants := p.v.farcasterCensusFromFids(req.FIDs, delegations, progress)
+	return uint32(len(req.FIDs)), sendParticipants(ctx, fileParticipants, participants)
+}
+
+// votesSnapshotBlockBucket is the number of blocks that the default snapshot
+// block of the governance token censuses is rounded down to.
+const votesSnapshotBlockBucket = 100
+
+// votesCensusParams are the parameters of the governance token census
+// provider. If the snapshot block is not provided, the previous block to the
+// latest one when the census is requested, rounded down to a multiple of
+// votesSnapshotBlockBucket, is used.
+type votesCensusParams struct {
+	Address       string `json:"address"`
+	Blockchain    string `json:"blockchain"`
+	SnapshotBlock uint64 `json:"snapshotBlock,omitempty"`
+}
+
+// votesCensusProvider builds a census from the voting power of the delegatees
+// of an OpenZeppelin ERC20Votes governance token at a snapshot block, reading
+// it directly from the token contract.
+type votesCensusProvider struct {
+	v *vocdoniHandler
+}
+
+func (p *votesCensusProvider) Name() string {
+	return censusProviderVotes
+}
+
+func (p *votesCensusProvider) Description() string {
+	return "Farcaster users with delegated voting power of an ERC20Votes token at a snapshot block, weighted by it"
+}
+
+func (p *votesCensusProvider) Params() []CensusProviderParam {
+	return []CensusProviderParam{
+		{Name: "address", Type: "string", Description: "address of the ERC20Votes token", Required: true},
+		{Name: "blockchain", Type: "string", Description: "blockchain or chain alias of the token", Required: true},
+		{Name: "snapshotBlock", Type: "uint64", Description: "block to read the voting power at, by default a recent one", Required: false},
+	}
+}
+
+func (p *votesCensusProvider) CensusType() FrameCensusType {
+	return FrameCensusTypeERC20Votes
+}
+
+func (p *votesCensusProvider) Validate(ctx context.Context, _ uint64, params json.RawMessage) (json.RawMessage, error) {
+	req := &votesCensusParams{}
+	if err := decodeCensusParams(params, req); err != nil {
+		return nil, err
+	}
+	if !common.IsHexAddress(req.Address) {
+		return nil, fmt.Errorf("%w: invalid token address", ErrInvalidCensusParams)
+	}
+	chainID, ok := p.v.onchain.ChainID(req.Blockchain)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid blockchain for token %s provided", ErrInvalidCensusParams, req.Address)
+	}
+	// getPastVotes only accepts past timepoints, so the snapshot must be lower
+	// than the latest block, it also fixes the snapshot of the censuses
+	// requested without it, so they can be resumed with the same result. The
+	// default snapshot is rounded down to a bucket of blocks, so the censuses
+	// requested without it get the same params, and so the same cache key,
+	// until a new bucket starts
+	lastBlock, err := p.v.onchain.BlockNumber(ctx, chainID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get latest block: %w", err)
+	}
+	if req.SnapshotBlock == 0 {
+		req.SnapshotBlock = (lastBlock - 1) / votesSnapshotBlockBucket * votesSnapshotBlockBucket
+	}
+	if req.SnapshotBlock >= lastBlock {
+		return nil, fmt.Errorf("%w: snapshot block must be lower than %d", ErrInvalidCensusParams, lastBlock)
+	}
+	req.Address = common.HexToAddress(req.Address).Hex()
+	return json.Marshal(req)
+}
+
+func (p *votesCensusProvider) Build(ctx context.Context, params json.RawMessage, delegations []mongo.Delegation,
+	participants chan<- *FarcasterParticipant, progress chan int,
+) (uint32, error) {
+	req := &votesCensusParams{}
+	if err := decodeCensusParams(params, req); err != nil {
+		return 0, err
+	}
+	chainID, ok := p.v.onchain.ChainID(req.Blockchain)
+	if !ok {
+		return 0, fmt.Errorf("blockchain %s not available on-chain", req.Blockchain)
+	}
+	token := common.HexToAddress(req.Address)
+	decimals, err := p.v.onchain.TokenDecimals(ctx, chainID, token)
+	if err != nil {
+		log.Warnw("cannot get token decimals", "token", req.Address, "error", err)
+	}
+	holders, err := p.v.onchain.PastVotes(ctx, chainID, token, req.SnapshotBlock)
+	if err != nil {
+		return 0, fmt.Errorf("cannot get voting power of token %s: %w", req.Address, err)
+	}
+	records := make([][]string, 0, len(holders))
+	for _, holder := range holders {
+		records = append(records, []string{
+			holder.Address.String(),
+			helpers.TruncateDecimals(holder.Balance, uint32(decimals)).String(),
+		})
+	}
+	if progress != nil {
+		progress <- 50
+	}
+	// create census from the delegatees
+	var votesParticipants []*FarcasterParticipant
+	trackSubProgress(progress, 50, 100, func(progress chan int) {
+		votesParticipants, _, err = p.v.processCensusRecords(records, delegations, progress)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to build census from token delegatees: %w", err)
+	}
+	return uint32(len(records)), sendParticipants(ctx, votesParticipants, participants)
+}
+
+// engagedCensusParams are the parameters of the engaged community members
+// census provider.
+type engagedCensusParams struct {
+	CommunityID string `json:"communityID"`
+	mongo.CommunityEngagedRule
+}
+
+// engagedCensusProvider builds a census from the voters of the previous polls
+// of a community, including only the users who voted in at least a minimum
+// number of the last polls of the community. It only uses the votes stored in
+// the database.
+type engagedCensusProvider struct {
+	v *vocdoniHandler
+}
+
+func (p *engagedCensusProvider) Name() string {
+	return censusProviderEngaged
+}
+
+func (p *engagedCensusProvider) Description() string {
+	return "Farcaster users who voted in at least a number of the last polls of a community"
+}
+
+func (p *engagedCensusProvider) Params() []CensusProviderParam {
+	return []CensusProviderParam{
+		{Name: "communityID", Type: "string", Description: "ID of the community", Required: true},
+		{Name: "minVotes", Type: "uint32", Description: "minimum number of polls voted", Required: true},
+		{Name: "lastPolls", Type: "uint32", Description: "number of last polls of the community to consider", Required: true},
+		{Name: "window", Type: "uint64", Description: "only consider the polls created in the last seconds provided", Required: false},
+	}
+}
+
+func (p *engagedCensusProvider) CensusType() FrameCensusType {
+	return FrameCensusTypeEngaged
+}
+
+func (p *engagedCensusProvider) Validate(_ context.Context, userFID uint64, params json.RawMessage) (json.RawMessage, error) {
+	req := &engagedCensusParams{}
+	if err := decodeCensusParams(params, req); err != nil {
+		return nil, err
+	}
+	if req.CommunityID == "" {
+		return nil, fmt.Errorf("%w: communityID is required", ErrInvalidCensusParams)
+	}
+	if err := communityhub.ValidateEngagedRule(&req.CommunityEngagedRule); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCensusParams, err)
+	}
+	// the votes of the community members are only available to the members
+	// of the community allowed to create polls
+	community, err := p.v.db.Community(req.CommunityID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get community: %w", err)
+	}
+	if community == nil {
+		return nil, fmt.Errorf("%w: community not found", ErrInvalidCensusParams)
+	}
+	if !community.Can(userFID, mongo.PermissionCreatePoll) {
+		return nil, fmt.Errorf("%w: user is not allowed to create polls in the community", ErrInvalidCensusParams)
+	}
+	return json.Marshal(req)
+}
+
+func (p *engagedCensusProvider) Build(ctx context.Context, params json.RawMessage, delegations []mongo.Delegation,
+	participants chan<- *FarcasterParticipant, progress chan int,
+) (uint32, error) {
+	req := &engagedCensusParams{}
+	if err := decodeCensusParams(params, req); err != nil {
+		return 0, err
+	}
+	users, err := p.v.db.CommunityEngagedVoters(req.CommunityID, &req.CommunityEngagedRule)
+	if err != nil {
+		return 0, fmt.Errorf("cannot get engaged voters of the community: %w", err)
+	}
+	if len(users) == 0 {
+		return 0, fmt.Errorf("no voters of the community match the engaged rule")
+	}
+	// create the participants from the database users using the fids
+	engaged := p.v.farcasterCensusFromFids(users, delegations, progress)
+	return uint32(len(users)), sendParticipants(ctx, engaged, participants)
+}
+
+// membersCensusParams are the parameters of the community members census
+// provider.
+type membersCensusParams struct {
+	CommunityID string `json:"communityID"`
+}
+
+// membersCensusProvider builds a census from the members list of a community
+// curated by its admins, which is stored in the database.
+type membersCensusProvider struct {
+	v *vocdoniHandler
+}
+
+func (p *membersCensusProvider) Name() string {
+	return censusProviderMembers
+}
+
+func (p *membersCensusProvider) Description() string {
+	return "Farcaster users included in the members list of a community"
+}
+
+func (p *membersCensusProvider) Params() []CensusProviderParam {
+	return []CensusProviderParam{
+		{Name: "communityID", Type: "string", Description: "ID of the community", Required: true},
+	}
+}
+
+func (p *membersCensusProvider) CensusType() FrameCensusType {
+	return FrameCensusTypeMembers
+}
+
+func (p *membersCensusProvider) Validate(_ context.Context, userFID uint64, params json.RawMessage) (json.RawMessage, error) {
+	req := &membersCensusParams{}
+	if err := decodeCensusParams(params, req); err != nil {
+		return nil, err
+	}
+	if req.CommunityID == "" {
+		return nil, fmt.Errorf("%w: communityID is required", ErrInvalidCensusParams)
+	}
+	// the members of the community are only available to the members of the
+	// community allowed to create polls
+	community, err := p.v.db.Community(req.CommunityID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get community: %w", err)
+	}
+	if community == nil {
+		return nil, fmt.Errorf("%w: community not found", ErrInvalidCensusParams)
+	}
+	if !community.Can(userFID, mongo.PermissionCreatePoll) {
+		return nil, fmt.Errorf("%w: user is not allowed to create polls in the community", ErrInvalidCensusParams)
+	}
+	return json.Marshal(req)
+}
+
+func (p *membersCensusProvider) Build(ctx context.Context, params json.RawMessage, delegations []mongo.Delegation,
+	participants chan<- *FarcasterParticipant, progress chan int,
+) (uint32, error) {
+	req := &membersCensusParams{}
+	if err := decodeCensusParams(params, req); err != nil {
+		return 0, err
+	}
+	members, err := p.v.db.CommunityMembers(req.CommunityID)
+	if err != nil {
+		return 0, fmt.Errorf("cannot get members of the community: %w", err)
+	}
+	if len(members) == 0 {
+		return 0, fmt.Errorf("the community has no members")
+	}
+	// create the participants from the database users using the fids
+	membersParticipants := p.v.farcasterCensusFromFids(members, delegations, progress)
+	return uint32(len(members)), sendParticipants(ctx, membersParticipants, participants)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/vocdoni/vote-frame/mongo"
+	"go.vocdoni.io/dvote/log"
+)
+
+// validateCensusWeighting checks that the census weighting provided has a
+// known source and mode. A nil weighting is valid and keeps the weights of the
+// census source.
+func validateCensusWeighting(weighting *mongo.CensusWeighting) error {
+	if weighting == nil {
+		return nil
+	}
+	switch weighting.Source {
+	case mongo.CensusWeightingSourceReputation, mongo.CensusWeightingSourcePoints:
+	default:
+		return fmt.Errorf("%w: unknown weighting source '%s'", ErrInvalidCensusParams, weighting.Source)
+	}
+	switch weighting.Mode {
+	case mongo.CensusWeightingModeMultiply, mongo.CensusWeightingModeReplace:
+	default:
+		return fmt.Errorf("%w: unknown weighting mode '%s'", ErrInvalidCensusParams, weighting.Mode)
+	}
+	return nil
+}
+
+// communityCensusWeighting returns the weighting applied to the censuses built
+// for the community provided, or nil if it has no weighting.
+func (v *vocdoniHandler) communityCensusWeighting(communityID string) *mongo.CensusWeighting {
+	community, err := v.db.Community(communityID)
+	if err != nil || community == nil {
+		return nil
+	}
+	return community.CensusWeighting
+}
+
+// weightCensusParticipants applies the census weighting provided to the
+// participants of a census. The reputation or the points of every participant
+// are read from the database when the census is built, so the resulting
+// weights are a snapshot of them. The weight of every participant is
+// multiplied or replaced by its value, and the participants that end up with
+// no weight are excluded from the census. The weight received from delegators
+// is multiplied too, but it is dropped when the weights are replaced, since it
+// is not part of the final weight anymore. It returns the weighted
+// participants.
+func (v *vocdoniHandler) weightCensusParticipants(participants []*FarcasterParticipant,
+	weighting *mongo.CensusWeighting,
+) ([]*FarcasterParticipant, error) {
+	if weighting == nil {
+		return participants, nil
+	}
+	// get the values of every unique participant, since each participant can
+	// have multiple signers
+	fids := []uint64{}
+	included := map[uint64]bool{}
+	for _, p := range participants {
+		if !included[p.FID] {
+			included[p.FID] = true
+			fids = append(fids, p.FID)
+		}
+	}
+	reputations, err := v.db.UsersReputation(fids)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get participants reputation: %w", err)
+	}
+	weighted := make([]*FarcasterParticipant, 0, len(participants))
+	for _, p := range participants {
+		var value uint64
+		if reputation, ok := reputations[p.FID]; ok {
+			switch weighting.Source {
+			case mongo.CensusWeightingSourceReputation:
+				value = reputation.TotalReputation
+			case mongo.CensusWeightingSourcePoints:
+				value = reputation.TotalPoints
+			}
+		}
+		weight := new(big.Int).SetUint64(value)
+		var delegated map[uint64]*big.Int
+		if weighting.Mode == mongo.CensusWeightingModeMultiply && p.Weight != nil {
+			weight.Mul(weight, p.Weight)
+			if len(p.Delegated) > 0 {
+				delegated = make(map[uint64]*big.Int, len(p.Delegated))
+				for from, portion := range p.Delegated {
+					delegated[from] = new(big.Int).Mul(portion, new(big.Int).SetUint64(value))
+				}
+			}
+		}
+		if weight.Sign() <= 0 {
+			continue
+		}
+		weighted = append(weighted, &FarcasterParticipant{
+			PubKey:    p.PubKey,
+			Weight:    weight,
+			Username:  p.Username,
+			FID:       p.FID,
+			Delegated: delegated,
+		})
+	}
+	log.Debugw("census participants weighted",
+		"source", weighting.Source,
+		"mode", weighting.Mode,
+		"participants", len(participants),
+		"weighted", len(weighted))
+	return weighted, nil
+}
@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -43,31 +45,35 @@ func (v *vocdoniHandler) parseCommunityIDFromURL(ctx *httprouter.HTTPContext) (s
 	return communityID, chainAlias, id, nil
 }
 
-// censusChannelOrAddresses gets the census channel or addresses based on the
+// censusChannelOrAddresses gets the census channels or addresses based on the
 // type of the census provided from the database. If the census provided is
-// based on a channel, it gets the channel information from the farcaster API,
-// and returns a nil for the addresses and the channel information. If the
-// census provided is based on addresses, it converts the address from the
-// database to the API format and returns them, with a nil for the channel
-// information.
-func (v *vocdoniHandler) censusChannelOrAddresses(ctx context.Context,
+// based on channels, it gets the channels information from the farcaster API,
+// and returns a nil for the addresses and the channels information. The
+// channels that are not found are skipped, and if none is found, it returns
+// an ErrChannelNotFound error. If the census provided is based on addresses,
+// it converts the address from the database to the API format and returns
+// them, with a nil for the channels information. If the census provided is
+// based on the members list of the community provided, it returns the FIDs
+// of its members.
+func (v *vocdoniHandler) censusChannelOrAddresses(ctx context.Context, communityID string,
 	dbCensus mongo.CommunityCensus,
-) ([]*CensusAddress, *Channel, *User, error) {
-	var censusChannel *Channel
+) ([]*CensusAddress, []*Channel, []uint64, *User, error) {
+	var censusChannels []*Channel
 	var censusAddresses []*CensusAddress
+	var censusMembers []uint64
 	var user *User
 	switch dbCensus.Type {
 	case mongo.TypeCommunityCensusFollowers:
 		fid, err := communityhub.DecodeUserChannelFID(dbCensus.Channel)
 		if err != nil {
-			return nil, nil, nil, fmt.Errorf("invalid user reference: %w", err)
+			return nil, nil, nil, nil, fmt.Errorf("invalid user reference: %w", err)
 		}
 		dbUser, err := v.db.User(fid)
 		if err != nil {
-			return nil, nil, nil, fmt.Errorf("error getting user: %w", err)
+			return nil, nil, nil, nil, fmt.Errorf("error getting user: %w", err)
 		}
 		if dbUser == nil {
-			return nil, nil, nil, fmt.Errorf("user not found")
+			return nil, nil, nil, nil, fmt.Errorf("user not found")
 		}
 		user = &User{
 			FID:         dbUser.UserID,
@@ -76,35 +82,63 @@ func (v *vocdoniHandler) censusChannelOrAddresses(ctx context.Context,
 			Avatar:      dbUser.Avatar,
 		}
 	case mongo.TypeCommunityCensusChannel:
-		channel, err := v.fcapi.Channel(ctx, dbCensus.Channel)
-		if err != nil {
-			return nil, nil, nil, err
-		}
-		if channel == nil {
-			return nil, nil, nil, farcasterapi.ErrChannelNotFound
+		for _, channelID := range dbCensus.CensusChannels() {
+			channel, err := v.fcapi.Channel(ctx, channelID)
+			if err != nil {
+				if errors.Is(err, farcasterapi.ErrChannelNotFound) {
+					log.Warnw("community census channel not found", "channel", channelID)
+					continue
+				}
+				return nil, nil, nil, nil, err
+			}
+			if channel == nil {
+				continue
+			}
+			censusChannels = append(censusChannels, &Channel{
+				ID:          channel.ID,
+				Name:        channel.Name,
+				Description: channel.Description,
+				Followers:   channel.Followers,
+				ImageURL:    channel.Image,
+				URL:         channel.URL,
+			})
 		}
-		censusChannel = &Channel{
-			ID:          channel.ID,
-			Name:        channel.Name,
-			Description: channel.Description,
-			Followers:   channel.Followers,
-			ImageURL:    channel.Image,
-			URL:         channel.URL,
+		if len(censusChannels) == 0 {
+			return nil, nil, nil, nil, farcasterapi.ErrChannelNotFound
 		}
-	case mongo.TypeCommunityCensusERC20, mongo.TypeCommunityCensusNFT:
+	case mongo.TypeCommunityCensusERC20, mongo.TypeCommunityCensusNFT, mongo.TypeCommunityCensusERC20Votes:
 		censusAddresses = []*CensusAddress{}
 		if len(dbCensus.Addresses) > 0 {
 			for _, addr := range dbCensus.Addresses {
 				censusAddresses = append(censusAddresses, &CensusAddress{
 					Address:    addr.Address,
 					Blockchain: addr.Blockchain,
+					Standard:   addr.Standard,
+					TokenIDs:   addr.TokenIDs,
 				})
 			}
 		}
+	case mongo.TypeCommunityCensusMembers:
+		members, err := v.db.CommunityMembers(communityID)
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("error getting community members: %w", err)
+		}
+		censusMembers = members
+	case mongo.TypeCommunityCensusEngaged:
+		// the engaged census has no channels, addresses or user reference
 	default:
-		return nil, nil, nil, fmt.Errorf("invalid census type")
+		return nil, nil, nil, nil, fmt.Errorf("invalid census type")
 	}
-	return censusAddresses, censusChannel, user, nil
+	return censusAddresses, censusChannels, censusMembers, user, nil
+}
+
+// firstCensusChannel returns the first channel of the list provided or nil if
+// it is empty.
+func firstCensusChannel(channels []*Channel) *Channel {
+	if len(channels) == 0 {
+		return nil
+	}
+	return channels[0]
 }
 
 func (v *vocdoniHandler) listCommunitiesHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
@@ -207,7 +241,7 @@ func (v *vocdoniHandler) listCommunitiesHandler(msg *apirest.APIdata, ctx *httpr
 			})
 		}
 		// get census channel, addresses or user reference based on the type
-		cAddresses, cChannel, userRef, err := v.censusChannelOrAddresses(ctx.Request.Context(), c.Census)
+		cAddresses, cChannels, cMembers, userRef, err := v.censusChannelOrAddresses(ctx.Request.Context(), c.ID, c.Census)
 		if err != nil && err != farcasterapi.ErrChannelNotFound {
 			return ctx.Send([]byte(err.Error()), http.StatusInternalServerError)
 		}
@@ -221,10 +255,15 @@ func (v *vocdoniHandler) listCommunitiesHandler(msg *apirest.APIdata, ctx *httpr
 			Notifications:   c.Notifications,
 			CensusType:      c.Census.Type,
 			CensusAddresses: cAddresses,
-			CensusChannel:   cChannel,
+			CensusChannel:   firstCensusChannel(cChannels),
+			CensusChannels:  cChannels,
+			CensusEngaged:   c.Census.Engaged,
+			CensusMembers:   cMembers,
 			UserRef:         userRef,
 			Channels:        c.Channels,
 			Disabled:        c.Disabled,
+			CensusCacheTTL:  c.CensusCacheTTL,
+			CensusWeighting: c.CensusWeighting,
 		})
 	}
 	res, err := json.Marshal(communities)
@@ -270,7 +309,7 @@ func (v *vocdoniHandler) communityHandler(msg *apirest.APIdata, ctx *httprouter.
 		})
 	}
 	// get census channel or addresses based on the type
-	cAddresses, cChannel, userRef, err := v.censusChannelOrAddresses(ctx.Request.Context(), dbCommunity.Census)
+	cAddresses, cChannels, cMembers, userRef, err := v.censusChannelOrAddresses(ctx.Request.Context(), dbCommunity.ID, dbCommunity.Census)
 	if err != nil && err != farcasterapi.ErrChannelNotFound {
 		return ctx.Send([]byte(err.Error()), http.StatusInternalServerError)
 	}
@@ -284,10 +323,15 @@ func (v *vocdoniHandler) communityHandler(msg *apirest.APIdata, ctx *httprouter.
 		Notifications:   dbCommunity.Notifications,
 		CensusType:      dbCommunity.Census.Type,
 		CensusAddresses: cAddresses,
-		CensusChannel:   cChannel,
+		CensusChannel:   firstCensusChannel(cChannels),
+		CensusChannels:  cChannels,
+		CensusEngaged:   dbCommunity.Census.Engaged,
+		CensusMembers:   cMembers,
 		UserRef:         userRef,
 		Channels:        dbCommunity.Channels,
 		Disabled:        dbCommunity.Disabled,
+		CensusCacheTTL:  dbCommunity.CensusCacheTTL,
+		CensusWeighting: dbCommunity.CensusWeighting,
 	})
 	if err != nil {
 		return ctx.Send([]byte("error encoding community"), http.StatusInternalServerError)
@@ -295,8 +339,10 @@ func (v *vocdoniHandler) communityHandler(msg *apirest.APIdata, ctx *httprouter.
 	return ctx.Send(res, http.StatusOK)
 }
 
-// communitySettingsHandler allows to an admin of a community to update the
-// community information.
+// communitySettingsHandler allows to the members of a community with a role
+// to update the community information. Every part of the information is only
+// updated if the role of the user includes the permission required to change
+// it, otherwise it is kept as it is.
 func (v *vocdoniHandler) communitySettingsHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
 	// extract userFID from auth token
 	userFID, err := v.db.UserFromAuthToken(msg.AuthToken)
@@ -320,15 +366,9 @@ func (v *vocdoniHandler) communitySettingsHandler(msg *apirest.APIdata, ctx *htt
 	if dbCommunity == nil {
 		return ctx.Send([]byte("community not found"), http.StatusNotFound)
 	}
-	// check if the current user is an admin of the community
-	var authorized bool
-	for _, admin := range dbCommunity.Admins {
-		if admin == userFID {
-			authorized = true
-			break
-		}
-	}
-	if !authorized {
+	// check if the current user has a role in the community
+	role := dbCommunity.RoleOf(userFID)
+	if role == "" {
 		return ctx.Send([]byte("you are not an admin of this community"), http.StatusUnauthorized)
 	}
 	var typedCommunity Community
@@ -341,77 +381,84 @@ func (v *vocdoniHandler) communitySettingsHandler(msg *apirest.APIdata, ctx *htt
 	if err := json.Unmarshal(msg.Data, &mapCommunity); err != nil {
 		return ctx.Send([]byte("error decoding community data"), http.StatusBadRequest)
 	}
-	notification := &dbCommunity.Notifications
-	if _, ok := mapCommunity["notifications"]; ok {
-		*notification = typedCommunity.Notifications
-	}
-	disabled := &dbCommunity.Disabled
-	if _, ok := mapCommunity["disabled"]; ok {
-		*disabled = typedCommunity.Disabled
-	}
-	// parse the admins and census addresses
-	admins := []uint64{}
-	for _, user := range typedCommunity.Admins {
-		admins = append(admins, user.FID)
-	}
-	// parse the census channel or addresses based on the census type
-	var censusChannel string
-	censusAddresses := []*communityhub.ContractAddress{}
-	switch communityhub.CensusType(typedCommunity.CensusType) {
-	case communityhub.CensusTypeERC20, communityhub.CensusTypeNFT:
-		for _, addr := range typedCommunity.CensusAddresses {
-			censusAddresses = append(censusAddresses, &communityhub.ContractAddress{
-				Blockchain: addr.Blockchain,
-				Address:    common.HexToAddress(addr.Address),
-			})
-		}
-	case communityhub.CensusTypeFollowers:
-		censusChannel = communityhub.EncodeUserChannelFID(userFID)
-	case communityhub.CensusTypeChannel:
-		if typedCommunity.CensusChannel != nil {
-			censusChannel = typedCommunity.CensusChannel.ID
+	// the group chat and the channels are always overwritten by the
+	// community hub, so keep the current ones unless they can be changed
+	update := &communityhub.HubCommunity{
+		CommunityID:  communityID,
+		ContractID:   contractID,
+		ChainID:      chainID,
+		GroupChatURL: dbCommunity.GroupChatURL,
+		Channels:     dbCommunity.Channels,
+	}
+	if _, ok := mapCommunity["notifications"]; ok && role.Can(mongo.PermissionToggleNotifications) {
+		update.Notifications = &typedCommunity.Notifications
+	}
+	if _, ok := mapCommunity["disabled"]; ok && role.Can(mongo.PermissionDisableCommunity) {
+		update.Disabled = &typedCommunity.Disabled
+	}
+	if role.Can(mongo.PermissionEditCensus) {
+		// the census cache TTL is not stored in the community hub, so update
+		// it directly in the database if it is provided
+		if _, ok := mapCommunity["censusCacheTTL"]; ok {
+			if err := v.db.SetCommunityCensusCacheTTL(communityID, typedCommunity.CensusCacheTTL); err != nil {
+				return fmt.Errorf("error updating community census cache TTL: %w", err)
+			}
 		}
-	default:
-		return ctx.Send([]byte("invalid census type"), http.StatusBadRequest)
-	}
-	// update the community image
-	if typedCommunity.LogoURL != "" && typedCommunity.LogoURL != dbCommunity.ImageURL {
-		// check if the current avatar is an internal image
-		avatarID, isInternalAvatar := avatarIDfromURL(dbCommunity.ImageURL)
-		// if is internal delete the current avatar from the database after
-		// uploading the new one
-		if isInternalAvatar {
-			if err := v.db.RemoveAvatar(avatarID); err != nil {
-				log.Warnw("error deleting avatar", "err", err, "avatarID", avatarID)
+		// the census weighting is not stored in the community hub either, it
+		// can be removed setting it to null
+		if _, ok := mapCommunity["censusWeighting"]; ok {
+			if err := validateCensusWeighting(typedCommunity.CensusWeighting); err != nil {
+				return ctx.Send([]byte(err.Error()), http.StatusBadRequest)
+			}
+			if err := v.db.SetCommunityCensusWeighting(communityID, typedCommunity.CensusWeighting); err != nil {
+				return fmt.Errorf("error updating community census weighting: %w", err)
 			}
 		}
-		// upload the new avatar if it is base64 encoded
-		if isBase64Image(typedCommunity.LogoURL) {
-			// empty the avatarID to generate a new one based on the data
-			avatarURL, err := v.uploadAvatar("", userFID, communityID, typedCommunity.LogoURL)
-			if err != nil {
-				return fmt.Errorf("cannot upload avatar: %w", err)
+		// parse the census channel or addresses based on the census type
+		if err := setHubCommunityCensus(update, userFID, &typedCommunity); err != nil {
+			return ctx.Send([]byte(err.Error()), http.StatusBadRequest)
+		}
+	}
+	// the admins are the owners and admins roles of the community, so they
+	// can only be changed by the users that can manage the roles
+	if role.Can(mongo.PermissionManageRoles) {
+		for _, user := range typedCommunity.Admins {
+			update.Admins = append(update.Admins, user.FID)
+		}
+	}
+	if role.Can(mongo.PermissionEditSettings) {
+		// update the community image
+		if typedCommunity.LogoURL != "" && typedCommunity.LogoURL != dbCommunity.ImageURL {
+			// check if the current avatar is an internal image
+			avatarID, isInternalAvatar := avatarIDfromURL(dbCommunity.ImageURL)
+			// if is internal delete the current avatar from the database after
+			// uploading the new one
+			if isInternalAvatar {
+				if err := v.db.RemoveAvatar(avatarID); err != nil {
+					log.Warnw("error deleting avatar", "err", err, "avatarID", avatarID)
+				}
+			}
+			// upload the new avatar if it is base64 encoded
+			if isBase64Image(typedCommunity.LogoURL) {
+				// empty the avatarID to generate a new one based on the data
+				avatarURL, err := v.uploadAvatar("", userFID, communityID, typedCommunity.LogoURL)
+				if err != nil {
+					return fmt.Errorf("cannot upload avatar: %w", err)
+				}
+				// set the new avatar URL
+				typedCommunity.LogoURL = avatarURL
 			}
-			// set the new avatar URL
-			typedCommunity.LogoURL = avatarURL
 		}
+		update.Name = typedCommunity.Name
+		update.ImageURL = typedCommunity.LogoURL
+		update.GroupChatURL = typedCommunity.GroupChatURL
+		update.Channels = typedCommunity.Channels
 	}
 	// update the community in the community hub
-	if err := v.comhub.UpdateCommunity(&communityhub.HubCommunity{
-		CommunityID:    communityID,
-		ContractID:     contractID,
-		ChainID:        chainID,
-		Name:           typedCommunity.Name,
-		ImageURL:       typedCommunity.LogoURL,
-		GroupChatURL:   typedCommunity.GroupChatURL,
-		CensusType:     communityhub.CensusType(typedCommunity.CensusType),
-		CensusAddesses: censusAddresses,
-		CensusChannel:  censusChannel,
-		Channels:       typedCommunity.Channels,
-		Admins:         admins,
-		Notifications:  notification,
-		Disabled:       disabled,
-	}); err != nil {
+	if status, err := v.relayCommunityUpdate(userFID, update); err != nil {
+		if status != http.StatusInternalServerError {
+			return ctx.Send([]byte(err.Error()), status)
+		}
 		return fmt.Errorf("error updating community: %w", err)
 	}
 	return ctx.Send([]byte("ok"), http.StatusOK)
@@ -436,3 +483,285 @@ func (v *vocdoniHandler) communityDelegationsHandler(msg *apirest.APIdata, ctx *
 	}
 	return ctx.Send(res, http.StatusOK)
 }
+
+func (v *vocdoniHandler) communityDelegationsHistoryHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	// get community id from the URL
+	communityID, _, _, err := v.parseCommunityIDFromURL(ctx)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), http.StatusBadRequest)
+	}
+	events, err := v.db.DelegationHistory(communityID)
+	if err != nil {
+		return ctx.Send([]byte("error getting delegations history"), http.StatusInternalServerError)
+	}
+	if len(events) == 0 {
+		return ctx.Send(nil, http.StatusNoContent)
+	}
+	res, err := json.Marshal(events)
+	if err != nil {
+		return ctx.Send([]byte("error encoding delegations history"), http.StatusInternalServerError)
+	}
+	return ctx.Send(res, http.StatusOK)
+}
+
+func (v *vocdoniHandler) communityDelegationsGraphHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	// get community id from the URL
+	communityID, _, _, err := v.parseCommunityIDFromURL(ctx)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), http.StatusBadRequest)
+	}
+	delegations, err := v.db.FinalDelegationsByCommunity(communityID)
+	if err != nil {
+		return ctx.Send([]byte("error getting delegations"), http.StatusInternalServerError)
+	}
+	if len(delegations) == 0 {
+		return ctx.Send(nil, http.StatusNoContent)
+	}
+	res, err := json.Marshal(v.delegationGraph(delegations))
+	if err != nil {
+		return ctx.Send([]byte("error encoding delegations graph"), http.StatusInternalServerError)
+	}
+	return ctx.Send(res, http.StatusOK)
+}
+
+// delegationGraph returns the graph of the final delegations provided with the
+// effective voting power of every delegate. The voting power of a delegate is
+// the share of its own vote that it has not delegated plus the shares received
+// from its delegators. The delegates are sorted by voting power.
+func (v *vocdoniHandler) delegationGraph(delegations []mongo.Delegation) *DelegationGraph {
+	delegates := map[uint64]*DelegatePower{}
+	delegators := map[uint64]map[uint64]bool{}
+	for _, delegation := range delegations {
+		delegate, ok := delegates[delegation.To]
+		if !ok {
+			delegate = &DelegatePower{
+				FID: delegation.To,
+				VotingPower: uint64(mongo.DelegationFullShare -
+					mongo.DelegatedShare(delegations, delegation.To)),
+			}
+			if user, err := v.db.User(delegation.To); err == nil {
+				delegate.Username = user.Username
+			}
+			delegates[delegation.To] = delegate
+			delegators[delegation.To] = map[uint64]bool{}
+		}
+		delegate.VotingPower += uint64(delegation.Shared())
+		delegators[delegation.To][delegation.From] = true
+	}
+	graph := &DelegationGraph{Delegations: delegations}
+	for fid, delegate := range delegates {
+		delegate.Delegators = len(delegators[fid])
+		graph.Delegates = append(graph.Delegates, delegate)
+	}
+	sort.Slice(graph.Delegates, func(i, j int) bool {
+		if graph.Delegates[i].VotingPower != graph.Delegates[j].VotingPower {
+			return graph.Delegates[i].VotingPower > graph.Delegates[j].VotingPower
+		}
+		return graph.Delegates[i].FID < graph.Delegates[j].FID
+	})
+	return graph
+}
+
+// communityRolesHandler returns the current role of every member of the
+// community with a role.
+func (v *vocdoniHandler) communityRolesHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	// get community id from the URL
+	communityID, _, _, err := v.parseCommunityIDFromURL(ctx)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), http.StatusBadRequest)
+	}
+	community, err := v.db.Community(communityID)
+	if err != nil {
+		return ctx.Send([]byte("error getting community"), http.StatusInternalServerError)
+	}
+	if community == nil {
+		return ctx.Send([]byte("community not found"), http.StatusNotFound)
+	}
+	res, err := json.Marshal(community.MemberRoles())
+	if err != nil {
+		return ctx.Send([]byte("error encoding community roles"), http.StatusInternalServerError)
+	}
+	return ctx.Send(res, http.StatusOK)
+}
+
+// communitySetRolesHandler allows to the members of a community that can
+// manage its roles to replace the roles of the community. The members with
+// the owner or admin roles are set as the admins of the community in the
+// community hub, while the rest of the roles are only stored in the database.
+func (v *vocdoniHandler) communitySetRolesHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	// extract userFID from auth token
+	userFID, err := v.db.UserFromAuthToken(msg.AuthToken)
+	if err != nil {
+		return fmt.Errorf("cannot get user from auth token: %w", err)
+	}
+	// get community id from the URL
+	communityID, chainAlias, contractID, err := v.parseCommunityIDFromURL(ctx)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), http.StatusBadRequest)
+	}
+	chainID, ok := v.comhub.ChainIDFromAlias(chainAlias)
+	if !ok {
+		return ctx.Send([]byte("invalid community chain alias provided"), http.StatusBadRequest)
+	}
+	dbCommunity, err := v.db.Community(communityID)
+	if err != nil {
+		return ctx.Send([]byte("error getting community"), http.StatusInternalServerError)
+	}
+	if dbCommunity == nil {
+		return ctx.Send([]byte("community not found"), http.StatusNotFound)
+	}
+	if !dbCommunity.Can(userFID, mongo.PermissionManageRoles) {
+		return ctx.Send([]byte("you are not allowed to manage the roles of this community"), http.StatusForbidden)
+	}
+	roles := []mongo.CommunityMemberRole{}
+	if err := json.Unmarshal(msg.Data, &roles); err != nil {
+		return ctx.Send([]byte("error decoding community roles"), http.StatusBadRequest)
+	}
+	// validate the roles and get the admins of the community from them, the
+	// creator is always the first admin
+	admins := []uint64{dbCommunity.Creator}
+	included := map[uint64]bool{}
+	for _, member := range roles {
+		if member.FID == 0 || !member.Role.Valid() {
+			return ctx.Send([]byte("invalid community role"), http.StatusBadRequest)
+		}
+		if included[member.FID] {
+			return ctx.Send([]byte("duplicated community member"), http.StatusBadRequest)
+		}
+		included[member.FID] = true
+		if member.FID == dbCommunity.Creator {
+			if member.Role != mongo.CommunityRoleOwner {
+				return ctx.Send([]byte("the creator of the community must be an owner"), http.StatusBadRequest)
+			}
+			continue
+		}
+		if member.Role == mongo.CommunityRoleOwner || member.Role == mongo.CommunityRoleAdmin {
+			admins = append(admins, member.FID)
+		}
+	}
+	// update the admins in the community hub if they have changed
+	currentAdmins := map[uint64]bool{dbCommunity.Creator: true}
+	for _, admin := range dbCommunity.Admins {
+		currentAdmins[admin] = true
+	}
+	adminsChanged := len(admins) != len(currentAdmins)
+	for _, admin := range admins {
+		if !currentAdmins[admin] {
+			adminsChanged = true
+			break
+		}
+	}
+	if adminsChanged {
+		status, err := v.relayCommunityUpdate(userFID, &communityhub.HubCommunity{
+			CommunityID:  communityID,
+			ContractID:   contractID,
+			ChainID:      chainID,
+			GroupChatURL: dbCommunity.GroupChatURL,
+			Channels:     dbCommunity.Channels,
+			Admins:       admins,
+		})
+		if err != nil {
+			if status != http.StatusInternalServerError {
+				return ctx.Send([]byte(err.Error()), status)
+			}
+			return fmt.Errorf("error updating community: %w", err)
+		}
+	}
+	if err := v.db.SetCommunityRoles(communityID, roles); err != nil {
+		return fmt.Errorf("error updating community roles: %w", err)
+	}
+	return ctx.Send([]byte("ok"), http.StatusOK)
+}
+
+// communityAnalyticsHandler returns the statistics of the polls of the
+// community grouped by the interval of the 'interval' query parameter (day,
+// week or month, by default month). It is only available to the members of the
+// community with a role.
+func (v *vocdoniHandler) communityAnalyticsHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	// extract userFID from auth token
+	userFID, err := v.db.UserFromAuthToken(msg.AuthToken)
+	if err != nil {
+		return fmt.Errorf("cannot get user from auth token: %w", err)
+	}
+	// get community id from the URL
+	communityID, _, _, err := v.parseCommunityIDFromURL(ctx)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), http.StatusBadRequest)
+	}
+	community, err := v.db.Community(communityID)
+	if err != nil {
+		return ctx.Send([]byte("error getting community"), http.StatusInternalServerError)
+	}
+	if community == nil {
+		return ctx.Send([]byte("community not found"), http.StatusNotFound)
+	}
+	if community.RoleOf(userFID) == "" && userFID != v.adminFID {
+		return ctx.Send([]byte("you are not an admin of this community"), http.StatusForbidden)
+	}
+	interval := ctx.Request.URL.Query().Get("interval")
+	switch interval {
+	case "":
+		interval = mongo.AnalyticsIntervalMonth
+	case mongo.AnalyticsIntervalDay, mongo.AnalyticsIntervalWeek, mongo.AnalyticsIntervalMonth:
+	default:
+		return ctx.Send([]byte("invalid analytics interval"), http.StatusBadRequest)
+	}
+	buckets, err := v.db.CommunityAnalytics(communityID, interval)
+	if err != nil {
+		return ctx.Send([]byte("error getting community analytics"), http.StatusInternalServerError)
+	}
+	if len(buckets) == 0 {
+		return ctx.Send(nil, http.StatusNoContent)
+	}
+	res, err := json.Marshal(buckets)
+	if err != nil {
+		return ctx.Send([]byte("error encoding community analytics"), http.StatusInternalServerError)
+	}
+	return ctx.Send(res, http.StatusOK)
+}
+
+// setHubCommunityCensus sets the census of the community hub data provided
+// from the census type, channels, addresses or engaged rule of the community
+// provided. The followers census is based on the user provided. It returns an
+// error if the census type or the engaged rule are not valid.
+func setHubCommunityCensus(hub *communityhub.HubCommunity, userFID uint64, community *Community) error {
+	censusAddresses := []*communityhub.ContractAddress{}
+	switch communityhub.CensusType(community.CensusType) {
+	case communityhub.CensusTypeERC20, communityhub.CensusTypeNFT, communityhub.CensusTypeERC20Votes:
+		for _, addr := range community.CensusAddresses {
+			censusAddresses = append(censusAddresses, &communityhub.ContractAddress{
+				Blockchain: addr.Blockchain,
+				Address:    common.HexToAddress(addr.Address),
+				Standard:   addr.Standard,
+				TokenIDs:   addr.TokenIDs,
+			})
+		}
+	case communityhub.CensusTypeFollowers:
+		hub.CensusChannel = communityhub.EncodeUserChannelFID(userFID)
+	case communityhub.CensusTypeChannel:
+		// the census can be based on several channels, if they are not
+		// provided, the single census channel is used
+		channels := []string{}
+		for _, channel := range community.CensusChannels {
+			if channel != nil {
+				channels = append(channels, channel.ID)
+			}
+		}
+		if len(channels) == 0 && community.CensusChannel != nil {
+			channels = append(channels, community.CensusChannel.ID)
+		}
+		hub.CensusChannel = communityhub.EncodeCensusChannels(channels)
+	case communityhub.CensusTypeEngaged:
+		if err := communityhub.ValidateEngagedRule(community.CensusEngaged); err != nil {
+			return err
+		}
+		hub.CensusChannel = communityhub.EncodeEngagedRule(community.CensusEngaged)
+	case communityhub.CensusTypeMembers:
+		// the members are managed with the community members endpoints
+	default:
+		return fmt.Errorf("invalid census type")
+	}
+	hub.CensusType = communityhub.CensusType(community.CensusType)
+	hub.CensusAddesses = censusAddresses
+	return nil
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vocdoni/vote-frame/mongo"
+	"go.vocdoni.io/dvote/httprouter"
+	"go.vocdoni.io/dvote/httprouter/apirest"
+)
+
+// membersCommunity gets the community of the URL and checks that its census
+// is based on its members list. If a permission is provided, it also checks
+// that the authenticated user has a role in the community that includes it.
+// It returns the community, the FID of the authenticated user and, if
+// something fails, the HTTP status and the error to send.
+func (v *vocdoniHandler) membersCommunity(msg *apirest.APIdata, ctx *httprouter.HTTPContext,
+	permission mongo.CommunityPermission,
+) (*mongo.Community, uint64, int, error) {
+	communityID, _, _, err := v.parseCommunityIDFromURL(ctx)
+	if err != nil {
+		return nil, 0, http.StatusBadRequest, err
+	}
+	community, err := v.db.Community(communityID)
+	if err != nil {
+		return nil, 0, http.StatusInternalServerError, fmt.Errorf("error getting community")
+	}
+	if community == nil {
+		return nil, 0, http.StatusNotFound, fmt.Errorf("community not found")
+	}
+	if community.Census.Type != mongo.TypeCommunityCensusMembers {
+		return nil, 0, http.StatusBadRequest, fmt.Errorf("the community census is not based on members")
+	}
+	var userFID uint64
+	if msg.AuthToken != "" {
+		if userFID, err = v.db.UserFromAuthToken(msg.AuthToken); err != nil {
+			return nil, 0, http.StatusUnauthorized, fmt.Errorf("cannot get user from auth token")
+		}
+	}
+	if permission != "" && !community.Can(userFID, permission) {
+		return nil, 0, http.StatusForbidden, fmt.Errorf("you are not allowed to manage the members of this community")
+	}
+	return community, userFID, 0, nil
+}
+
+// usersFIDs returns the FIDs of the users provided by FID or by username,
+// without duplicates. It also returns the usernames that were not found.
+func (v *vocdoniHandler) usersFIDs(fids []uint64, usernames []string) ([]uint64, []string) {
+	result := []uint64{}
+	notFound := []string{}
+	included := map[uint64]bool{}
+	for _, fid := range fids {
+		if fid != 0 && !included[fid] {
+			included[fid] = true
+			result = append(result, fid)
+		}
+	}
+	for _, username := range usernames {
+		user, err := v.db.UserByUsername(strings.TrimPrefix(username, "@"))
+		if err != nil {
+			notFound = append(notFound, username)
+			continue
+		}
+		if !included[user.UserID] {
+			included[user.UserID] = true
+			result = append(result, user.UserID)
+		}
+	}
+	return result, notFound
+}
+
+func (v *vocdoniHandler) communityMembersHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	community, _, status, err := v.membersCommunity(msg, ctx, "")
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), status)
+	}
+	members, err := v.db.CommunityMembers(community.ID)
+	if err != nil {
+		return ctx.Send([]byte("error getting community members"), http.StatusInternalServerError)
+	}
+	if len(members) == 0 {
+		return ctx.Send(nil, http.StatusNoContent)
+	}
+	res, err := json.Marshal(&CommunityMembers{Members: members})
+	if err != nil {
+		return ctx.Send([]byte("error encoding community members"), http.StatusInternalServerError)
+	}
+	return ctx.Send(res, http.StatusOK)
+}
+
+// communityAddMembersHandler adds the users of the request to the members
+// list of the community. It responds with the FIDs of the users added and the
+// usernames that were not found.
+func (v *vocdoniHandler) communityAddMembersHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	community, userFID, status, err := v.membersCommunity(msg, ctx, mongo.PermissionEditCensus)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), status)
+	}
+	req := &CommunityMembersRequest{}
+	if err := json.Unmarshal(msg.Data, req); err != nil {
+		return ctx.Send([]byte("error decoding community members"), http.StatusBadRequest)
+	}
+	fids, notFound := v.usersFIDs(req.FIDs, req.Usernames)
+	return v.sendAddedCommunityMembers(ctx, community.ID, userFID, fids, notFound)
+}
+
+// communityImportMembersHandler adds the users of the CSV provided in the
+// body of the request to the members list of the community. The first column
+// of every record is the FID or the username of the user, and the rest of
+// columns are ignored, so the CSV can include a header with the 'fid' or
+// 'username' column.
+func (v *vocdoniHandler) communityImportMembersHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	community, userFID, status, err := v.membersCommunity(msg, ctx, mongo.PermissionEditCensus)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), status)
+	}
+	r := csv.NewReader(strings.NewReader(string(msg.Data)))
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = -1
+	fids := []uint64{}
+	usernames := []string{}
+	for first := true; ; first = false {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return ctx.Send([]byte(fmt.Sprintf("error parsing csv: %v", err)), http.StatusBadRequest)
+		}
+		value := strings.TrimSpace(record[0])
+		if value == "" {
+			continue
+		}
+		if first && (strings.EqualFold(value, "fid") || strings.EqualFold(value, "username")) {
+			continue
+		}
+		if fid, err := strconv.ParseUint(value, 10, 64); err == nil {
+			fids = append(fids, fid)
+			continue
+		}
+		usernames = append(usernames, value)
+	}
+	if len(fids) == 0 && len(usernames) == 0 {
+		return ctx.Send([]byte("empty csv"), http.StatusBadRequest)
+	}
+	fids, notFound := v.usersFIDs(fids, usernames)
+	return v.sendAddedCommunityMembers(ctx, community.ID, userFID, fids, notFound)
+}
+
+// sendAddedCommunityMembers adds the users provided to the members list of
+// the community provided and sends them with the usernames not found.
+func (v *vocdoniHandler) sendAddedCommunityMembers(ctx *httprouter.HTTPContext, communityID string, userFID uint64,
+	fids []uint64, notFound []string,
+) error {
+	if err := v.db.AddCommunityMembers(communityID, fids, userFID); err != nil {
+		return ctx.Send([]byte("error adding community members"), http.StatusInternalServerError)
+	}
+	res, err := json.Marshal(&CommunityMembers{Members: fids, NotFound: notFound})
+	if err != nil {
+		return ctx.Send([]byte("error encoding community members"), http.StatusInternalServerError)
+	}
+	return ctx.Send(res, http.StatusOK)
+}
+
+func (v *vocdoniHandler) communityRemoveMemberHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	community, _, status, err := v.membersCommunity(msg, ctx, mongo.PermissionEditCensus)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), status)
+	}
+	fid, err := strconv.ParseUint(ctx.URLParam("fid"), 10, 64)
+	if err != nil {
+		return ctx.Send([]byte("invalid fid"), http.StatusBadRequest)
+	}
+	if err := v.db.RemoveCommunityMember(community.ID, fid); err != nil {
+		return ctx.Send([]byte("error removing community member"), http.StatusInternalServerError)
+	}
+	return ctx.Send([]byte("ok"), http.StatusOK)
+}
+
+// communityJoinHandler registers a request of the authenticated user to join
+// the community, that must be approved by its admins.
+func (v *vocdoniHandler) communityJoinHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	community, userFID, status, err := v.membersCommunity(msg, ctx, "")
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), status)
+	}
+	if community.Disabled {
+		return ctx.Send([]byte("community is disabled"), http.StatusBadRequest)
+	}
+	if err := v.db.AddCommunityJoinRequest(community.ID, userFID); err != nil {
+		if errors.Is(err, mongo.ErrAlreadyCommunityMember) {
+			return ctx.Send([]byte(err.Error()), http.StatusBadRequest)
+		}
+		return ctx.Send([]byte("error requesting to join the community"), http.StatusInternalServerError)
+	}
+	return ctx.Send([]byte("ok"), http.StatusOK)
+}
+
+func (v *vocdoniHandler) communityJoinRequestsHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	community, _, status, err := v.membersCommunity(msg, ctx, mongo.PermissionEditCensus)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), status)
+	}
+	requests, err := v.db.CommunityJoinRequests(community.ID)
+	if err != nil {
+		return ctx.Send([]byte("error getting join requests"), http.StatusInternalServerError)
+	}
+	if len(requests) == 0 {
+		return ctx.Send(nil, http.StatusNoContent)
+	}
+	res, err := json.Marshal(requests)
+	if err != nil {
+		return ctx.Send([]byte("error encoding join requests"), http.StatusInternalServerError)
+	}
+	return ctx.Send(res, http.StatusOK)
+}
+
+// pendingJoinRequest gets the community and the user of the URL and checks
+// that the user has a pending request to join the community and that the
+// authenticated user can manage its members. It returns the community, the
+// FID of the authenticated user, the FID of the user of the request and, if
+// something fails, the HTTP status and the error to send.
+func (v *vocdoniHandler) pendingJoinRequest(msg *apirest.APIdata, ctx *httprouter.HTTPContext,
+) (*mongo.Community, uint64, uint64, int, error) {
+	community, userFID, status, err := v.membersCommunity(msg, ctx, mongo.PermissionEditCensus)
+	if err != nil {
+		return nil, 0, 0, status, err
+	}
+	fid, err := strconv.ParseUint(ctx.URLParam("fid"), 10, 64)
+	if err != nil {
+		return nil, 0, 0, http.StatusBadRequest, fmt.Errorf("invalid fid")
+	}
+	pending, err := v.db.IsCommunityJoinRequest(community.ID, fid)
+	if err != nil {
+		return nil, 0, 0, http.StatusInternalServerError, fmt.Errorf("error getting join request")
+	}
+	if !pending {
+		return nil, 0, 0, http.StatusNotFound, fmt.Errorf("join request not found")
+	}
+	return community, userFID, fid, 0, nil
+}
+
+func (v *vocdoniHandler) communityApproveJoinHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	community, userFID, fid, status, err := v.pendingJoinRequest(msg, ctx)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), status)
+	}
+	if err := v.db.AddCommunityMembers(community.ID, []uint64{fid}, userFID); err != nil {
+		return ctx.Send([]byte("error approving join request"), http.StatusInternalServerError)
+	}
+	return ctx.Send([]byte("ok"), http.StatusOK)
+}
+
+func (v *vocdoniHandler) communityRejectJoinHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	community, _, fid, status, err := v.pendingJoinRequest(msg, ctx)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), status)
+	}
+	if err := v.db.RemoveCommunityMember(community.ID, fid); err != nil {
+		return ctx.Send([]byte("error rejecting join request"), http.StatusInternalServerError)
+	}
+	return ctx.Send([]byte("ok"), http.StatusOK)
+}
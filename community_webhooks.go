@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/vocdoni/vote-frame/mongo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.vocdoni.io/dvote/httprouter"
+	"go.vocdoni.io/dvote/httprouter/apirest"
+	"go.vocdoni.io/dvote/util"
+)
+
+const (
+	// defaultWebhookDeliveriesLimit is the number of delivery logs returned
+	// if no limit is provided.
+	defaultWebhookDeliveriesLimit = 50
+	// maxWebhookDeliveriesLimit is the maximum number of delivery logs
+	// returned at once.
+	maxWebhookDeliveriesLimit = 200
+)
+
+// webhooksCommunity gets the community of the URL and checks that the
+// authenticated user is allowed to edit its settings. It returns the
+// community, the FID of the authenticated user and, if something fails, the
+// HTTP status and the error to send.
+func (v *vocdoniHandler) webhooksCommunity(msg *apirest.APIdata, ctx *httprouter.HTTPContext) (*mongo.Community, uint64, int, error) {
+	userFID, err := v.db.UserFromAuthToken(msg.AuthToken)
+	if err != nil {
+		return nil, 0, http.StatusUnauthorized, fmt.Errorf("cannot get user from auth token")
+	}
+	communityID, _, _, err := v.parseCommunityIDFromURL(ctx)
+	if err != nil {
+		return nil, 0, http.StatusBadRequest, err
+	}
+	community, err := v.db.Community(communityID)
+	if err != nil {
+		return nil, 0, http.StatusInternalServerError, fmt.Errorf("error getting community")
+	}
+	if community == nil {
+		return nil, 0, http.StatusNotFound, fmt.Errorf("community not found")
+	}
+	if !community.Can(userFID, mongo.PermissionEditSettings) && userFID != v.adminFID {
+		return nil, 0, http.StatusForbidden, fmt.Errorf("you are not allowed to manage the webhooks of this community")
+	}
+	return community, userFID, 0, nil
+}
+
+func (v *vocdoniHandler) communityWebhooksHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	community, _, status, err := v.webhooksCommunity(msg, ctx)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), status)
+	}
+	webhooks, err := v.db.Webhooks(community.ID)
+	if err != nil {
+		return ctx.Send([]byte("error getting community webhooks"), http.StatusInternalServerError)
+	}
+	if len(webhooks) == 0 {
+		return ctx.Send(nil, http.StatusNoContent)
+	}
+	res, err := json.Marshal(CommunityWebhooks{Webhooks: webhooks})
+	if err != nil {
+		return ctx.Send([]byte("error encoding community webhooks"), http.StatusInternalServerError)
+	}
+	return ctx.Send(res, http.StatusOK)
+}
+
+// communityAddWebhookHandler subscribes the URL of the request to the events
+// of the request of the community. It returns the webhook created including
+// the secret used to sign its deliveries, which is not returned anymore.
+func (v *vocdoniHandler) communityAddWebhookHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	community, userFID, status, err := v.webhooksCommunity(msg, ctx)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), status)
+	}
+	req := &CommunityWebhookRequest{}
+	if err := json.Unmarshal(msg.Data, req); err != nil {
+		return ctx.Send([]byte("could not parse request"), http.StatusBadRequest)
+	}
+	resolveCtx, cancel := context.WithTimeout(context.Background(), webhookRequestTimeout)
+	defer cancel()
+	webhookURL, err := validateWebhookURL(resolveCtx, req.URL)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), http.StatusBadRequest)
+	}
+	if len(req.Events) == 0 {
+		return ctx.Send([]byte("no events provided"), http.StatusBadRequest)
+	}
+	events := []string{}
+	for _, event := range req.Events {
+		if !slices.Contains(mongo.WebhookEvents, event) {
+			return ctx.Send([]byte(fmt.Sprintf("unknown event %s", event)), http.StatusBadRequest)
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	webhook := &mongo.Webhook{
+		CommunityID: community.ID,
+		URL:         webhookURL.String(),
+		Secret:      util.RandomHex(32),
+		Events:      events,
+		CreatedBy:   userFID,
+	}
+	if err := v.db.AddWebhook(webhook); err != nil {
+		return ctx.Send([]byte("error adding community webhook"), http.StatusInternalServerError)
+	}
+	res, err := json.Marshal(CommunityWebhook{Webhook: webhook, Secret: webhook.Secret})
+	if err != nil {
+		return ctx.Send([]byte("error encoding community webhook"), http.StatusInternalServerError)
+	}
+	return ctx.Send(res, http.StatusOK)
+}
+
+func (v *vocdoniHandler) communityDeleteWebhookHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	community, _, status, err := v.webhooksCommunity(msg, ctx)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), status)
+	}
+	webhookID, err := primitive.ObjectIDFromHex(ctx.URLParam("webhookID"))
+	if err != nil {
+		return ctx.Send([]byte("invalid webhook ID"), http.StatusBadRequest)
+	}
+	if err := v.db.DeleteWebhook(community.ID, webhookID); err != nil {
+		if errors.Is(err, mongo.ErrWebhookUnknown) {
+			return ctx.Send([]byte("webhook not found"), http.StatusNotFound)
+		}
+		return ctx.Send([]byte("error deleting community webhook"), http.StatusInternalServerError)
+	}
+	return ctx.Send([]byte("Ok"), http.StatusOK)
+}
+
+// communityWebhookDeliveriesHandler returns the delivery logs of the webhooks
+// of the community, from the newest to the oldest. They can be filtered by
+// webhook with the 'webhookId' query parameter and limited with the 'limit'
+// one.
+func (v *vocdoniHandler) communityWebhookDeliveriesHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	community, _, status, err := v.webhooksCommunity(msg, ctx)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), status)
+	}
+	query := ctx.Request.URL.Query()
+	var webhookID primitive.ObjectID
+	if strWebhookID := query.Get("webhookId"); strWebhookID != "" {
+		if webhookID, err = primitive.ObjectIDFromHex(strWebhookID); err != nil {
+			return ctx.Send([]byte("invalid webhook ID"), http.StatusBadRequest)
+		}
+	}
+	limit := int64(defaultWebhookDeliveriesLimit)
+	if strLimit := query.Get("limit"); strLimit != "" {
+		if limit, err = strconv.ParseInt(strLimit, 10, 64); err != nil || limit <= 0 {
+			return ctx.Send([]byte("invalid limit"), http.StatusBadRequest)
+		}
+		limit = min(limit, maxWebhookDeliveriesLimit)
+	}
+	deliveries, err := v.db.WebhookDeliveries(community.ID, webhookID, limit)
+	if err != nil {
+		return ctx.Send([]byte("error getting webhook deliveries"), http.StatusInternalServerError)
+	}
+	if len(deliveries) == 0 {
+		return ctx.Send(nil, http.StatusNoContent)
+	}
+	res, err := json.Marshal(CommunityWebhookDeliveries{Deliveries: deliveries})
+	if err != nil {
+		return ctx.Send([]byte("error encoding webhook deliveries"), http.StatusInternalServerError)
+	}
+	return ctx.Send(res, http.StatusOK)
+}
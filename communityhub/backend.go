@@ -0,0 +1,62 @@
+package communityhub
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	comhub "github.com/vocdoni/vote-frame/communityhub/contracts/communityhubtoken"
+)
+
+const (
+	// BackendOnchain is the name of the backend that interacts with the
+	// CommunityHub contracts deployed in the chains configured, using their
+	// web3 endpoints
+	BackendOnchain = "onchain"
+	// BackendMemory is the name of the backend that emulates the CommunityHub
+	// contracts in memory, so the community flows can be used without network
+	// access. Its data is lost when the service stops.
+	BackendMemory = "memory"
+)
+
+// Backend interface defines the operations over a CommunityHub contract
+// deployed in a chain that the HubContract needs. It works with the types of
+// the contract bindings, so the conversions from and to the types of this
+// package are done once by the HubContract for every backend.
+type Backend interface {
+	// NextCommunityID returns the ID that the next community created in the
+	// contract will get.
+	NextCommunityID() (*big.Int, error)
+	// Community returns the data of the community with the ID provided. If the
+	// community does not exist, it returns an empty community, like the
+	// contract does.
+	Community(communityID *big.Int) (comhub.ICommunityHubCommunity, error)
+	// CreateCommunityPrice returns the value that must be sent to the
+	// contract to create a community.
+	CreateCommunityPrice() (*big.Int, error)
+	// CreateCommunity creates a community with the data provided sending the
+	// value provided, and returns the transaction sent. The ID of the new
+	// community is included in the receipt of the transaction.
+	CreateCommunity(community comhub.ICommunityHubCommunity, value *big.Int) (*HubTx, error)
+	// ManageCommunity sets the data of the community with the ID provided,
+	// including its funds, using the admin permissions of the contract, and
+	// returns the transaction sent.
+	ManageCommunity(communityID *big.Int, community comhub.ICommunityHubCommunity) (*HubTx, error)
+	// Result returns the results of the poll with the ID provided of the
+	// community with the ID provided. If they are not set, it returns empty
+	// results, like the contract does.
+	Result(communityID *big.Int, electionID [32]byte) (comhub.IResultResult, error)
+	// SetResult sets the results of the poll with the ID provided of the
+	// community with the ID provided and returns the transaction sent. If a
+	// transaction to replace is provided, the new one replaces it.
+	SetResult(communityID *big.Int, electionID [32]byte, result comhub.IResultResult, replace *HubTx) (*HubTx, error)
+	// Receipt returns the receipt of the transaction with the hash provided.
+	// If the transaction is not mined yet, it returns nil and no error.
+	Receipt(hash common.Hash) (*HubReceipt, error)
+	// LastBlock returns the number of the latest block of the chain.
+	LastBlock(ctx context.Context) (uint64, error)
+	// Events returns the events of the contract that create or update a
+	// community or set the results of a poll, emitted between the blocks
+	// provided (both included), in the order they were emitted.
+	Events(ctx context.Context, fromBlock, toBlock uint64) ([]*HubEvent, error)
+}
@@ -2,72 +2,65 @@ package communityhub
 
 import (
 	"context"
-	"crypto/ecdsa"
 	"errors"
-	"fmt"
 	"math/big"
 	"strconv"
 	"time"
 
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
 	c3web3 "github.com/vocdoni/census3/helpers/web3"
 	comhub "github.com/vocdoni/vote-frame/communityhub/contracts/communityhubtoken"
-	"go.vocdoni.io/dvote/log"
 )
 
 // HubContract struct represents the CommunityHub contract with in a specific
-// chain. It contains the chain ID, the contract address, the web3 client, the
-// contract, the private key, and the private address. It provides a set of
-// methods to interact with the contract, such as getting the next community ID,
-// getting and setting the community data, getting and setting the election
-// results of a community.
+// chain. It contains the chain ID, the contract address, and the backend used
+// to interact with the contract (see Backend). It provides a set of methods to
+// interact with the contract, such as getting the next community ID, getting
+// and setting the community data, getting and setting the election results of
+// a community.
 type HubContract struct {
-	ChainID     uint64
-	ChainAlias  string
-	Address     common.Address
-	privKey     *ecdsa.PrivateKey
-	privAddress common.Address
+	ChainID    uint64
+	ChainAlias string
+	Address    common.Address
+	// Confirmations is the number of blocks that the events of the contract
+	// must be behind the latest block to be scanned.
+	Confirmations uint64
 
-	w3cli    *c3web3.Client
-	contract *comhub.CommunityHubToken
+	backend Backend
 }
 
-// LoadContract method initializes the CommunityHub struct with the chain ID,
-// contract address, web3 pool, and private key provided. If something goes
-// wrong initializing the web3 client, the contract, or the private key, it
-// returns an error.
-func LoadContract(chainID uint64, chainAlias string, addr common.Address, w3p *c3web3.Web3Pool, pk string) (*HubContract, error) {
-	hc := &HubContract{
+// NewContract function initializes the CommunityHub contract deployed with
+// the chain ID, alias and address provided, which is accessed through the
+// backend provided.
+func NewContract(chainID uint64, chainAlias string, addr common.Address, backend Backend) *HubContract {
+	return &HubContract{
 		ChainID:    chainID,
-		Address:    addr,
 		ChainAlias: chainAlias,
+		Address:    addr,
+		backend:    backend,
 	}
-	// initialize the web3 client for the chain
-	var err error
-	hc.w3cli, err = w3p.Client(chainID)
-	if err != nil {
-		return nil, fmt.Errorf("%w: %w", ErrWeb3Client, err)
-	}
-	// initialize the contract with the web3 client and the contract addr
-	hc.contract, err = comhub.NewCommunityHubToken(addr, hc.w3cli)
+}
+
+// LoadContract method initializes the CommunityHub struct with the chain ID,
+// contract address, web3 pool, and private key provided, using the on-chain
+// backend. If something goes wrong initializing the web3 client or the
+// contract, it returns an error.
+func LoadContract(chainID uint64, chainAlias string, addr common.Address, w3p *c3web3.Web3Pool, pk string) (*HubContract, error) {
+	backend, err := newOnchainBackend(chainID, addr, w3p, pk)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %w", ErrInitContract, err)
-	}
-	// loading the private key
-	if err := hc.initiPrivateKey(pk); err != nil {
-		log.Warn(err)
+		return nil, err
 	}
-	// get the next community ID
-	return hc, nil
+	return NewContract(chainID, chainAlias, addr, backend), nil
 }
 
 // NextID method gets the next community ID from the contract and returns it as
 // a uint64. If something goes wrong getting the next community ID from the
 // contract, it returns an error.
 func (hc *HubContract) NextContractID() (uint64, error) {
-	nextID, err := hc.contract.GetNextCommunityId(nil)
+	if hc.backend == nil {
+		return 0, ErrInitContract
+	}
+	nextID, err := hc.backend.NextCommunityID()
 	if err != nil {
 		return 0, err
 	}
@@ -82,7 +75,7 @@ func (hc *HubContract) NextContractID() (uint64, error) {
 // contract and returns it as a HubCommunity struct. If something goes wrong
 // getting the community data from the contract, it returns an error.
 func (hc *HubContract) Community(communityID string) (*HubCommunity, error) {
-	if hc.contract == nil {
+	if hc.backend == nil {
 		return nil, ErrInitContract
 	}
 	chainAlias, strID, ok := DecodePrefix(communityID)
@@ -103,7 +96,7 @@ func (hc *HubContract) Community(communityID string) (*HubCommunity, error) {
 	// convert the community ID to a *big.Int
 	bCommunityID := new(big.Int).SetUint64(id)
 	// get the community data from the contract
-	cc, err := hc.contract.GetCommunity(nil, bCommunityID)
+	cc, err := hc.backend.Community(bCommunityID)
 	if err != nil {
 		return nil, errors.Join(ErrGettingCommunity, err)
 	}
@@ -118,44 +111,78 @@ func (hc *HubContract) Community(communityID string) (*HubCommunity, error) {
 	return community, nil
 }
 
-// SetCommunity method sets the community data provided in the contract. If
-// something goes wrong setting the community data in the contract, it returns
-// an error.
-func (hc *HubContract) SetCommunity(community *HubCommunity) error {
-	if hc.contract == nil {
-		return ErrInitContract
+// CreateCommunity method creates the community provided in the contract,
+// paying the price of the contract, and waits until the transaction is mined.
+// It returns the community created, read again from the contract, the
+// transaction sent and its receipt. If something goes wrong sending the
+// transaction, it is reverted or the context provided is done before it is
+// mined, it returns an error.
+func (hc *HubContract) CreateCommunity(ctx context.Context, community *HubCommunity) (
+	*HubCommunity, *HubTx, *HubReceipt, error,
+) {
+	if hc.backend == nil {
+		return nil, nil, nil, ErrInitContract
+	}
+	cc, err := HubToContract(community)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	price, err := hc.backend.CreateCommunityPrice()
+	if err != nil {
+		return nil, nil, nil, errors.Join(ErrCreatingCommunity, err)
+	}
+	tx, err := hc.backend.CreateCommunity(cc, price)
+	if err != nil {
+		return nil, nil, nil, errors.Join(ErrCreatingCommunity, err)
+	}
+	receipt, err := hc.WaitReceipt(ctx, tx.Hash)
+	if err != nil {
+		return nil, tx, nil, err
+	}
+	if !receipt.Success {
+		return nil, tx, receipt, errors.Join(ErrCreatingCommunity, ErrTxReverted)
+	}
+	if receipt.ContractID == 0 {
+		return nil, tx, receipt, errors.Join(ErrCreatingCommunity, ErrDecodingEvent)
+	}
+	created, err := hc.Community(EncodePrefix(hc.ChainAlias, strconv.FormatUint(receipt.ContractID, 10)))
+	if err != nil {
+		return nil, tx, receipt, err
 	}
-	if hc.privKey == nil {
-		return ErrNoPrivKeyConfigured
+	return created, tx, receipt, nil
+}
+
+// SetCommunity method sets the community data provided in the contract and
+// returns the transaction sent. If something goes wrong setting the community
+// data in the contract, it returns an error.
+func (hc *HubContract) SetCommunity(community *HubCommunity) (*HubTx, error) {
+	if hc.backend == nil {
+		return nil, ErrInitContract
 	}
 	// set the community data in the contract
 	cc, err := HubToContract(community)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// convert the community ID to a *big.Int
 	bCommunityID := new(big.Int).SetUint64(community.ContractID)
-	// get auth opts and set the community data in the contract
-	transactOpts, err := hc.authTransactOpts()
-	if err != nil {
-		return err
-	}
-	funds := big.NewInt(0)
+	// keep the current funds of the community
+	cc.Funds = big.NewInt(0)
 	if currentData, err := hc.Community(community.CommunityID); err == nil {
-		funds = currentData.funds
+		cc.Funds = currentData.funds
 	}
-	if _, err := hc.contract.AdminManageCommunity(transactOpts, bCommunityID, cc.Metadata,
-		cc.Census, cc.Guardians, cc.CreateElectionPermission, cc.Disabled, funds); err != nil {
-		return errors.Join(ErrSettingCommunity, err)
+	tx, err := hc.backend.ManageCommunity(bCommunityID, cc)
+	if err != nil {
+		return nil, errors.Join(ErrSettingCommunity, err)
 	}
-	return nil
+	return tx, nil
 }
 
 // Results method gets the election results using the community and elections
 // IDs from the contract and returns them as a HubResults struct. If something
 // goes wrong getting the results from the contract, it returns an error.
 func (hc *HubContract) Results(communityID uint64, electionID []byte) (*HubResults, error) {
-	if hc.contract == nil {
+	if hc.backend == nil {
 		return nil, ErrInitContract
 	}
 	// convert the community ID to a *big.Int
@@ -164,7 +191,7 @@ func (hc *HubContract) Results(communityID uint64, electionID []byte) (*HubResul
 	bElectionID := [32]byte{}
 	copy(bElectionID[:], electionID)
 	// get the election results from the contract
-	contractResults, err := hc.contract.GetResult(nil, bCommunityID, bElectionID)
+	contractResults, err := hc.backend.Result(bCommunityID, bElectionID)
 	if err != nil {
 		return nil, errors.Join(ErrGettingResults, err)
 	}
@@ -176,24 +203,20 @@ func (hc *HubContract) Results(communityID uint64, electionID []byte) (*HubResul
 		Turnout:          contractResults.Turnout,
 		TotalVotingPower: contractResults.TotalVotingPower,
 		Participants:     contractResults.Participants,
+		Tally:            contractResults.Tally,
 		CensusRoot:       contractResults.CensusRoot[:],
 		CensusURI:        contractResults.CensusURI,
 	}, nil
 }
 
 // SetResults method sets the election results provided to the community and
-// election IDs provided. If something goes wrong setting the results in the
-// contract, it returns an error.
-func (hc *HubContract) SetResults(community *HubCommunity, results *HubResults) error {
-	if hc.contract == nil {
-		return ErrInitContract
-	}
-	if hc.privKey == nil {
-		return ErrNoPrivKeyConfigured
-	}
-	transactOpts, err := hc.authTransactOpts()
-	if err != nil {
-		return err
+// election IDs provided. It returns the transaction sent to the contract. If
+// a transaction to replace is provided, the new one is sent with its nonce
+// and higher fees, so the stuck one is replaced. If something goes wrong
+// setting the results in the contract, it returns an error.
+func (hc *HubContract) SetResults(community *HubCommunity, results *HubResults, replace *HubTx) (*HubTx, error) {
+	if hc.backend == nil {
+		return nil, ErrInitContract
 	}
 	// convert the community ID to a *big.Int
 	bCommunityID := new(big.Int).SetUint64(community.ContractID)
@@ -204,7 +227,7 @@ func (hc *HubContract) SetResults(community *HubCommunity, results *HubResults)
 	bCensusRoot := [32]byte{}
 	copy(bCensusRoot[:], results.CensusRoot)
 	// set the election results in the contract
-	if _, err := hc.contract.SetResult(transactOpts, bCommunityID, bElectionID,
+	tx, err := hc.backend.SetResult(bCommunityID, bElectionID,
 		comhub.IResultResult{
 			Question:         results.Question,
 			Options:          results.Options,
@@ -215,61 +238,60 @@ func (hc *HubContract) SetResults(community *HubCommunity, results *HubResults)
 			Participants:     results.Participants,
 			CensusRoot:       bCensusRoot,
 			CensusURI:        results.CensusURI,
-		}); err != nil {
-		return errors.Join(ErrSettingResults, err)
+		}, replace)
+	if err != nil {
+		return nil, errors.Join(ErrSettingResults, err)
 	}
-	return nil
+	return tx, nil
 }
 
-// initiPrivateKey helper method initializes the private key in the CommunityHub
-// struct. If the private key is not defined, it returns nil. If the private key
-// is defined, it parses it and sets the private key and the private address in
-// the struct. If something goes wrong parsing the private key, it returns an
+// Receipt method gets the receipt of the transaction with the hash provided.
+// It includes if the transaction succeeded, the block where it was mined and
+// its gas cost. If the transaction is not mined yet, it returns nil and no
 // error.
-func (hc *HubContract) initiPrivateKey(privKey string) error {
-	// parse the private key if it is defined
-	if privKey == "" {
-		return nil
-	}
-	var err error
-	hc.privKey, err = crypto.HexToECDSA(privKey)
-	if err != nil {
-		return fmt.Errorf("%w: %w", ErrInitializingPrivateKey, err)
+func (hc *HubContract) Receipt(hash common.Hash) (*HubReceipt, error) {
+	if hc.backend == nil {
+		return nil, ErrInitContract
 	}
-	hc.privAddress = crypto.PubkeyToAddress(hc.privKey.PublicKey)
-	return nil
+	return hc.backend.Receipt(hash)
 }
 
-// authTransactOpts helper method creates the transact options with the private
-// key configured in the CommunityHub. It sets the nonce, gas price, and gas
-// limit. If something goes wrong creating the signer, getting the nonce, or
-// getting the gas price, it returns an error.
-func (hc *HubContract) authTransactOpts() (*bind.TransactOpts, error) {
-	if hc.privKey == nil {
-		return nil, ErrNoPrivKeyConfigured
-	}
-	if hc.contract == nil {
-		return nil, ErrInitContract
-	}
-	bChainID := new(big.Int).SetUint64(hc.ChainID)
-	auth, err := bind.NewKeyedTransactorWithChainID(hc.privKey, bChainID)
-	if err != nil {
-		return nil, errors.Join(ErrCreatingSigner, err)
+// WaitReceipt method waits until the transaction with the hash provided is
+// mined and returns its receipt. It checks the transaction every
+// TxReceiptPollInterval until the context provided is done.
+func (hc *HubContract) WaitReceipt(ctx context.Context, hash common.Hash) (*HubReceipt, error) {
+	for {
+		receipt, err := hc.Receipt(hash)
+		if err != nil {
+			return nil, err
+		}
+		if receipt != nil {
+			return receipt, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Join(ErrGettingTx, ctx.Err())
+		case <-time.After(TxReceiptPollInterval):
+		}
 	}
-	// create the context with a timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	// set the nonce
-	nonce, err := hc.w3cli.PendingNonceAt(ctx, hc.privAddress)
-	if err != nil {
-		return nil, errors.Join(ErrSendingTx, err)
+}
+
+// LastBlock method returns the number of the latest block of the chain where
+// the contract is deployed.
+func (hc *HubContract) LastBlock(ctx context.Context) (uint64, error) {
+	if hc.backend == nil {
+		return 0, ErrInitContract
 	}
-	auth.Nonce = new(big.Int).SetUint64(nonce)
-	// set the gas tip cap
-	if auth.GasTipCap, err = hc.w3cli.SuggestGasTipCap(ctx); err != nil {
-		return nil, errors.Join(ErrSendingTx, err)
+	return hc.backend.LastBlock(ctx)
+}
+
+// Events method gets the events of the contract that create or update a
+// community or set the results of a poll, emitted between the blocks provided
+// (both included), in the order they were emitted. If something goes wrong
+// getting or decoding the events, it returns an error.
+func (hc *HubContract) Events(ctx context.Context, fromBlock, toBlock uint64) ([]*HubEvent, error) {
+	if hc.backend == nil {
+		return nil, ErrInitContract
 	}
-	// set the gas limit
-	auth.GasLimit = 10000000
-	return auth, nil
+	return hc.backend.Events(ctx, fromBlock, toBlock)
 }
@@ -74,6 +74,9 @@ var (
 	// ErrBadCensusAddressees is returned when the census addressees are not
 	// provided in the correct format or are empty
 	ErrBadCensusAddressees = fmt.Errorf("bad community census addressees")
+	// ErrBadEngagedRule is returned when the rule of an engaged census is not
+	// provided in the correct format or its values are not valid
+	ErrBadEngagedRule = fmt.Errorf("bad community engaged census rule")
 	// ErrNoAdminCreator is returned when the provided admin list does not
 	// contain the creator of the community
 	ErrNoAdminCreator = fmt.Errorf("the creator must be an admin")
@@ -89,4 +92,25 @@ var (
 	// ErrDecodeCommunityID is returned when the ID and the chain
 	// short name cannot be decoded from the community ID
 	ErrDecodeCommunityID = fmt.Errorf("error decoding chain community ID")
+	// ErrGettingEvents is returned when an error occurs while getting the
+	// events of the community hub contract
+	ErrGettingEvents = fmt.Errorf("error getting events from the community hub contract")
+	// ErrDecodingEvent is returned when an event of the community hub
+	// contract cannot be decoded
+	ErrDecodingEvent = fmt.Errorf("error decoding event from the community hub contract")
+	// ErrGettingCursor is returned when an error occurs while getting or
+	// storing the cursor of the events of a chain in the database
+	ErrGettingCursor = fmt.Errorf("error getting the events cursor from the database")
+	// ErrCreatingCommunity is returned when an error occurs while creating a
+	// community in the community hub contract
+	ErrCreatingCommunity = fmt.Errorf("error creating community in the community hub contract")
+	// ErrTxReverted is returned when a transaction sent to the community hub
+	// contract is mined but reverted
+	ErrTxReverted = fmt.Errorf("transaction reverted")
+	// ErrUnknownBackend is returned when the backend provided during
+	// CommunityHub initialization is not supported
+	ErrUnknownBackend = fmt.Errorf("unknown community hub backend")
+	// ErrGettingTx is returned when an error occurs while getting the
+	// receipt of a transaction sent to the community hub contract
+	ErrGettingTx = fmt.Errorf("error getting transaction receipt")
 )
@@ -0,0 +1,159 @@
+package communityhub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	comhub "github.com/vocdoni/vote-frame/communityhub/contracts/communityhubtoken"
+)
+
+// Events method gets the logs of the contract that create or update a
+// community or set the results of a poll, emitted between the blocks provided
+// (both included), and decodes them in the order they were emitted. If
+// something goes wrong getting or decoding the logs, it returns an error.
+func (ob *onchainBackend) Events(ctx context.Context, fromBlock, toBlock uint64) ([]*HubEvent, error) {
+	topics, err := hubEventsTopics()
+	if err != nil {
+		return nil, err
+	}
+	query := ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(fromBlock),
+		ToBlock:   new(big.Int).SetUint64(toBlock),
+		Addresses: []common.Address{ob.address},
+		Topics:    [][]common.Hash{make([]common.Hash, 0, len(topics))},
+	}
+	for topic := range topics {
+		query.Topics[0] = append(query.Topics[0], topic)
+	}
+	logs, err := ob.w3cli.FilterLogs(ctx, query)
+	if err != nil {
+		return nil, errors.Join(ErrGettingEvents, err)
+	}
+	events := []*HubEvent{}
+	for _, l := range logs {
+		if l.Removed || len(l.Topics) == 0 {
+			continue
+		}
+		name, ok := topics[l.Topics[0]]
+		if !ok {
+			continue
+		}
+		event, err := ob.parseEvent(name, l)
+		if err != nil {
+			return nil, errors.Join(ErrDecodingEvent, err)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// hubEventsTopics helper function returns the names of the events of the
+// contract that create or update a community or set results, indexed by
+// their topic.
+func hubEventsTopics() (map[common.Hash]string, error) {
+	contractABI, err := comhub.CommunityHubTokenMetaData.GetAbi()
+	if err != nil {
+		return nil, errors.Join(ErrInitContract, err)
+	}
+	topics := map[common.Hash]string{}
+	for _, name := range append(communityEvents, EventResultsSet) {
+		event, ok := contractABI.Events[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown event %s", ErrInitContract, name)
+		}
+		topics[event.ID] = name
+	}
+	return topics, nil
+}
+
+// parseEvent helper method decodes the log provided as the event of the
+// contract with the name provided.
+func (ob *onchainBackend) parseEvent(name string, l types.Log) (*HubEvent, error) {
+	var communityID *big.Int
+	var electionID []byte
+	switch name {
+	case EventCommunityCreated:
+		event, err := ob.contract.ParseCommunityCreated(l)
+		if err != nil {
+			return nil, err
+		}
+		communityID = event.CommunityId
+	case "AdminCommunityManaged":
+		event, err := ob.contract.ParseAdminCommunityManaged(l)
+		if err != nil {
+			return nil, err
+		}
+		communityID = event.CommunityId
+	case "MetadataSet":
+		event, err := ob.contract.ParseMetadataSet(l)
+		if err != nil {
+			return nil, err
+		}
+		communityID = event.CommunityId
+	case "CensusSet":
+		event, err := ob.contract.ParseCensusSet(l)
+		if err != nil {
+			return nil, err
+		}
+		communityID = event.CommunityId
+	case "GuardianAdded":
+		event, err := ob.contract.ParseGuardianAdded(l)
+		if err != nil {
+			return nil, err
+		}
+		communityID = event.CommunityId
+	case "GuardianRemoved":
+		event, err := ob.contract.ParseGuardianRemoved(l)
+		if err != nil {
+			return nil, err
+		}
+		communityID = event.CommunityId
+	case "CreateElectionPermissionSet":
+		event, err := ob.contract.ParseCreateElectionPermissionSet(l)
+		if err != nil {
+			return nil, err
+		}
+		communityID = event.CommunityId
+	case "NotifiableElectionsSet":
+		event, err := ob.contract.ParseNotifiableElectionsSet(l)
+		if err != nil {
+			return nil, err
+		}
+		communityID = event.CommunityId
+	case "CommunityDisabled":
+		event, err := ob.contract.ParseCommunityDisabled(l)
+		if err != nil {
+			return nil, err
+		}
+		communityID = event.CommunityId
+	case "CommunityEnabled":
+		event, err := ob.contract.ParseCommunityEnabled(l)
+		if err != nil {
+			return nil, err
+		}
+		communityID = event.CommunityId
+	case EventResultsSet:
+		event, err := ob.contract.ParseResultsSet(l)
+		if err != nil {
+			return nil, err
+		}
+		communityID = event.CommunityId
+		electionID = event.ElectionId[:]
+	default:
+		return nil, fmt.Errorf("unknown event %s", name)
+	}
+	if communityID == nil {
+		return nil, fmt.Errorf("no community ID in event %s", name)
+	}
+	return &HubEvent{
+		Name:       name,
+		ContractID: communityID.Uint64(),
+		ElectionID: electionID,
+		Block:      l.BlockNumber,
+	}, nil
+}
@@ -20,6 +20,24 @@ const (
 	// chainPrefixSeparator is the separator used to encode a chain prefixed
 	// content.
 	chainPrefixSeparator = ":"
+	// tokenBlockchainSeparator is the separator used to encode the standard
+	// and the token IDs of a census token with its blockchain.
+	tokenBlockchainSeparator = ":"
+	// tokenIDsSeparator is the separator used to encode the token IDs of a
+	// census token.
+	tokenIDsSeparator = ","
+	// censusChannelsSeparator is the separator used to encode the channels of
+	// a census based on several channels.
+	censusChannelsSeparator = ","
+	// engagedRulePrefix is the prefix used to encode the rule of an engaged
+	// census as the census channel.
+	engagedRulePrefix = "engaged:"
+	// engagedRuleFormat is the format used to encode the rule of an engaged
+	// census following the format "engaged:<minVotes>:<lastPolls>:<window>".
+	engagedRuleFormat = "engaged:%d:%d:%d"
+	// MaxEngagedLastPolls is the maximum number of previous polls of a
+	// community that an engaged census can take into account.
+	MaxEngagedLastPolls = 50
 )
 
 // EncodeUserChannelFID encodes a user FID to a user reference from farcaster
@@ -42,6 +60,109 @@ func DecodeUserChannelFID(channelFID string) (uint64, error) {
 	return 0, fmt.Errorf("invalid user reference: %s", channelFID)
 }
 
+// EncodeCensusChannels encodes the channels of a census based on channels
+// following the format "<channel>,<channel>" to be stored in the census
+// channel field of the contract, which only supports a single channel. A
+// census with a single channel keeps the same format.
+func EncodeCensusChannels(channels []string) string {
+	return strings.Join(channels, censusChannelsSeparator)
+}
+
+// DecodeCensusChannels decodes the channels of a census based on channels
+// from the census channel field of the contract, following the format
+// "<channel>,<channel>". Empty and duplicated channels are skipped.
+func DecodeCensusChannels(encoded string) []string {
+	channels := []string{}
+	included := map[string]bool{}
+	for _, channel := range strings.Split(encoded, censusChannelsSeparator) {
+		channel = strings.TrimSpace(channel)
+		if channel == "" || included[channel] {
+			continue
+		}
+		included[channel] = true
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+// EncodeEngagedRule encodes the rule of an engaged census following the format
+// "engaged:<minVotes>:<lastPolls>:<window>" to be stored in the census channel
+// field of the contract, which does not support the engaged census type.
+func EncodeEngagedRule(rule *dbmongo.CommunityEngagedRule) string {
+	return fmt.Sprintf(engagedRuleFormat, rule.MinVotes, rule.LastPolls, rule.Window)
+}
+
+// DecodeEngagedRule decodes the rule of an engaged census from the census
+// channel field of the contract following the format
+// "engaged:<minVotes>:<lastPolls>:<window>". It returns an error if the rule
+// is not encoded in the correct format or it is not valid.
+func DecodeEngagedRule(encoded string) (*dbmongo.CommunityEngagedRule, error) {
+	if !IsEngagedRule(encoded) {
+		return nil, fmt.Errorf("%w: %s", ErrBadEngagedRule, encoded)
+	}
+	rule := &dbmongo.CommunityEngagedRule{}
+	if _, err := fmt.Sscanf(encoded, engagedRuleFormat, &rule.MinVotes, &rule.LastPolls, &rule.Window); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBadEngagedRule, encoded)
+	}
+	if err := ValidateEngagedRule(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// IsEngagedRule returns true if the census channel provided encodes the rule
+// of an engaged census.
+func IsEngagedRule(censusChannel string) bool {
+	return strings.HasPrefix(censusChannel, engagedRulePrefix)
+}
+
+// ValidateEngagedRule checks that the rule of an engaged census provided is
+// valid: it must take into account between 1 and MaxEngagedLastPolls previous
+// polls and require at least 1 vote, but no more votes than polls.
+func ValidateEngagedRule(rule *dbmongo.CommunityEngagedRule) error {
+	if rule == nil {
+		return fmt.Errorf("%w: no rule provided", ErrBadEngagedRule)
+	}
+	if rule.LastPolls == 0 || rule.LastPolls > MaxEngagedLastPolls {
+		return fmt.Errorf("%w: the number of polls must be between 1 and %d", ErrBadEngagedRule, MaxEngagedLastPolls)
+	}
+	if rule.MinVotes == 0 || rule.MinVotes > rule.LastPolls {
+		return fmt.Errorf("%w: the minimum number of votes must be between 1 and the number of polls", ErrBadEngagedRule)
+	}
+	return nil
+}
+
+// EncodeTokenBlockchain encodes the blockchain, the standard and the token IDs
+// of a census token following the format "<blockchain>:<standard>:<id>,<id>"
+// to be stored in the blockchain field of the census token in the contract,
+// which does not support the token standard and IDs. If no standard and token
+// IDs are provided, the blockchain is returned as it is, so the tokens without
+// them keep the same format.
+func EncodeTokenBlockchain(blockchain, standard string, tokenIDs []string) string {
+	if standard == "" && len(tokenIDs) == 0 {
+		return blockchain
+	}
+	return strings.Join([]string{
+		blockchain, standard, strings.Join(tokenIDs, tokenIDsSeparator),
+	}, tokenBlockchainSeparator)
+}
+
+// DecodeTokenBlockchain decodes the blockchain, the standard and the token IDs
+// of a census token from the blockchain field of the census token in the
+// contract, following the format "<blockchain>:<standard>:<id>,<id>". If the
+// field only contains the blockchain, the standard and the token IDs are empty.
+func DecodeTokenBlockchain(encoded string) (string, string, []string) {
+	parts := strings.SplitN(encoded, tokenBlockchainSeparator, 3)
+	if len(parts) != 3 {
+		return encoded, "", nil
+	}
+	var tokenIDs []string
+	if parts[2] != "" {
+		tokenIDs = strings.Split(parts[2], tokenIDsSeparator)
+	}
+	return parts[0], parts[1], tokenIDs
+}
+
 // ContractToHub converts a contract community struct (ICommunityHubCommunity)
 // to a internal community struct (HubCommunity)
 func ContractToHub(contractID, chainID uint64, communityID string, cc comhub.ICommunityHubCommunity) (*HubCommunity, error) {
@@ -70,18 +191,36 @@ func ContractToHub(contractID, chainID uint64, communityID string, cc comhub.ICo
 	// decode census data according to the census type
 	switch community.CensusType {
 	case CensusTypeChannel, CensusTypeFollowers:
-		// if the census type is a channel, set the channel
+		// if the census type is a channel, set the channel, which can include
+		// several channels encoded (see EncodeCensusChannels)
 		community.CensusChannel = cc.Census.Channel
+		// the engaged censuses are stored as channel censuses with the rule
+		// encoded as the census channel
+		if community.CensusType == CensusTypeChannel && IsEngagedRule(cc.Census.Channel) {
+			community.CensusType = CensusTypeEngaged
+		}
 	case CensusTypeERC20, CensusTypeNFT:
 		// if the census type is an erc20 or nft, decode every census network
 		// address to get the contract address and blockchain
 		community.CensusAddesses = []*ContractAddress{}
 		for _, addr := range cc.Census.Tokens {
+			blockchain, standard, tokenIDs := DecodeTokenBlockchain(addr.Blockchain)
 			community.CensusAddesses = append(community.CensusAddesses, &ContractAddress{
-				Blockchain: addr.Blockchain,
+				Blockchain: blockchain,
 				Address:    addr.ContractAddress,
+				Standard:   standard,
+				TokenIDs:   tokenIDs,
 			})
 		}
+		// the ERC20Votes censuses are stored as ERC20 censuses with the
+		// ERC20Votes standard encoded in the census tokens
+		if community.CensusType == CensusTypeERC20 && len(community.CensusAddesses) > 0 &&
+			community.CensusAddesses[0].Standard == dbmongo.TokenStandardERC20Votes {
+			community.CensusType = CensusTypeERC20Votes
+		}
+	case CensusTypeMembers:
+		// the members censuses have no census data in the contract, the
+		// members are stored in the database
 	default:
 		return nil, ErrUnknownCensusType
 	}
@@ -94,10 +233,10 @@ func HubToContract(hcommunity *HubCommunity) (comhub.ICommunityHubCommunity, err
 	// check the census type
 	switch hcommunity.CensusType {
 	case CensusTypeChannel:
-		if hcommunity.CensusChannel == "" {
+		if len(DecodeCensusChannels(hcommunity.CensusChannel)) == 0 {
 			return comhub.ICommunityHubCommunity{}, ErrNoChannelProvided
 		}
-	case CensusTypeERC20, CensusTypeNFT:
+	case CensusTypeERC20, CensusTypeNFT, CensusTypeERC20Votes:
 		if len(hcommunity.CensusAddesses) == 0 {
 			return comhub.ICommunityHubCommunity{}, ErrBadCensusAddressees
 		}
@@ -105,17 +244,36 @@ func HubToContract(hcommunity *HubCommunity) (comhub.ICommunityHubCommunity, err
 		if hcommunity.CensusChannel == "" {
 			return comhub.ICommunityHubCommunity{}, ErrNoUserRefProvided
 		}
+	case CensusTypeEngaged:
+		if _, err := DecodeEngagedRule(hcommunity.CensusChannel); err != nil {
+			return comhub.ICommunityHubCommunity{}, err
+		}
+	case CensusTypeMembers:
+		// the members of the census are not stored in the contract
 	default:
 		return comhub.ICommunityHubCommunity{}, ErrUnknownCensusType
 	}
 	// convert the census addresses to a []*comhub.ICommunityHubTokenCensusToken
 	censusTokens := []comhub.ICommunityHubToken{}
 	for _, addr := range hcommunity.CensusAddesses {
+		standard := addr.Standard
+		if hcommunity.CensusType == CensusTypeERC20Votes {
+			standard = dbmongo.TokenStandardERC20Votes
+		}
 		censusTokens = append(censusTokens, comhub.ICommunityHubToken{
 			ContractAddress: addr.Address,
-			Blockchain:      addr.Blockchain,
+			Blockchain:      EncodeTokenBlockchain(addr.Blockchain, standard, addr.TokenIDs),
 		})
 	}
+	// the contract does not support the ERC20Votes and the engaged census
+	// types, so they are stored as ERC20 and channel censuses respectively
+	censusType := hcommunity.CensusType
+	switch censusType {
+	case CensusTypeERC20Votes:
+		censusType = CensusTypeERC20
+	case CensusTypeEngaged:
+		censusType = CensusTypeChannel
+	}
 	// convert the admins to a []*big.Int
 	guardians := []*big.Int{}
 	for _, admin := range hcommunity.Admins {
@@ -131,7 +289,7 @@ func HubToContract(hcommunity *HubCommunity) (comhub.ICommunityHubCommunity, err
 		},
 		Guardians: guardians,
 		Census: comhub.ICommunityHubCensus{
-			CensusType: contractCensusTypes[hcommunity.CensusType],
+			CensusType: contractCensusTypes[censusType],
 			Channel:    hcommunity.CensusChannel,
 			Tokens:     censusTokens,
 		},
@@ -162,12 +320,27 @@ func HubToDB(hcommunity *HubCommunity) (*dbmongo.Community, error) {
 	}
 	// if the census type is a channel, set the channel
 	switch hcommunity.CensusType {
-	case CensusTypeChannel, CensusTypeFollowers:
+	case CensusTypeChannel:
+		// decode the channels of the census, the first one is also stored as
+		// the census channel
+		channels := DecodeCensusChannels(hcommunity.CensusChannel)
+		if len(channels) == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrNoChannelProvided, hcommunity.Name)
+		}
+		dbCensus.Channel = channels[0]
+		dbCensus.Channels = channels
+	case CensusTypeFollowers:
 		if hcommunity.CensusChannel == "" {
 			return nil, fmt.Errorf("%w: %s", ErrNoChannelProvided, hcommunity.Name)
 		}
 		dbCensus.Channel = hcommunity.CensusChannel
-	case CensusTypeERC20, CensusTypeNFT:
+	case CensusTypeEngaged:
+		rule, err := DecodeEngagedRule(hcommunity.CensusChannel)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, hcommunity.Name)
+		}
+		dbCensus.Engaged = rule
+	case CensusTypeERC20, CensusTypeNFT, CensusTypeERC20Votes:
 		// if the census type is an erc20 or nft, decode every census
 		// network address to get the contract address and blockchain
 		dbCensus.Addresses = []dbmongo.CommunityCensusAddresses{}
@@ -176,6 +349,8 @@ func HubToDB(hcommunity *HubCommunity) (*dbmongo.Community, error) {
 				dbmongo.CommunityCensusAddresses{
 					Address:    addr.Address.String(),
 					Blockchain: addr.Blockchain,
+					Standard:   addr.Standard,
+					TokenIDs:   addr.TokenIDs,
 				})
 		}
 		// if no valid addresses were found, skip the community and log
@@ -183,6 +358,8 @@ func HubToDB(hcommunity *HubCommunity) (*dbmongo.Community, error) {
 		if len(dbCensus.Addresses) == 0 {
 			return nil, fmt.Errorf("%w: %s", ErrBadCensusAddressees, hcommunity.Name)
 		}
+	case CensusTypeMembers:
+		// the members of the census are managed directly in the database
 	default:
 		return nil, fmt.Errorf("%w: %s", ErrUnknownCensusType, hcommunity.CensusType)
 	}
@@ -215,14 +392,25 @@ func HubToDB(hcommunity *HubCommunity) (*dbmongo.Community, error) {
 // blockchain. It returns an error if the census type is unknown.
 func DBToHub(dbCommunity *dbmongo.Community, contractID, chainID uint64) (*HubCommunity, error) {
 	censusAddresses := []*ContractAddress{}
-	if ct := CensusType(dbCommunity.Census.Type); ct == CensusTypeERC20 || ct == CensusTypeNFT {
+	if ct := CensusType(dbCommunity.Census.Type); ct == CensusTypeERC20 || ct == CensusTypeNFT || ct == CensusTypeERC20Votes {
 		for _, addr := range dbCommunity.Census.Addresses {
 			censusAddresses = append(censusAddresses, &ContractAddress{
 				Blockchain: addr.Blockchain,
 				Address:    common.HexToAddress(addr.Address),
+				Standard:   addr.Standard,
+				TokenIDs:   addr.TokenIDs,
 			})
 		}
 	}
+	censusChannel := dbCommunity.Census.Channel
+	switch dbCommunity.Census.Type {
+	case dbmongo.TypeCommunityCensusChannel:
+		censusChannel = EncodeCensusChannels(dbCommunity.Census.CensusChannels())
+	case dbmongo.TypeCommunityCensusEngaged:
+		if dbCommunity.Census.Engaged != nil {
+			censusChannel = EncodeEngagedRule(dbCommunity.Census.Engaged)
+		}
+	}
 	admins := []uint64{dbCommunity.Creator}
 	for _, admin := range dbCommunity.Admins {
 		if admin == dbCommunity.Creator {
@@ -239,7 +427,7 @@ func DBToHub(dbCommunity *dbmongo.Community, contractID, chainID uint64) (*HubCo
 		GroupChatURL:   dbCommunity.GroupChatURL,
 		CensusType:     CensusType(dbCommunity.Census.Type),
 		CensusAddesses: censusAddresses,
-		CensusChannel:  dbCommunity.Census.Channel,
+		CensusChannel:  censusChannel,
 		Channels:       dbCommunity.Channels,
 		Admins:         admins,
 		Notifications:  &dbCommunity.Notifications,
@@ -0,0 +1,129 @@
+package communityhub
+
+import (
+	"reflect"
+	"testing"
+
+	dbmongo "github.com/vocdoni/vote-frame/mongo"
+)
+
+// contractRoundTrip encodes the community provided to the contract format and
+// decodes it back.
+func contractRoundTrip(t *testing.T, community *HubCommunity) *HubCommunity {
+	t.Helper()
+	cc, err := HubToContract(community)
+	if err != nil {
+		t.Fatalf("unexpected error encoding community: %v", err)
+	}
+	decoded, err := ContractToHub(1, 1, "eth:1", cc)
+	if err != nil {
+		t.Fatalf("unexpected error decoding community: %v", err)
+	}
+	return decoded
+}
+
+func TestCensusChannelsRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		channels []string
+		expected []string
+	}{
+		{
+			name:     "single channel",
+			channels: []string{"vocdoni"},
+			expected: []string{"vocdoni"},
+		},
+		{
+			name:     "several channels",
+			channels: []string{"vocdoni", "farcaster", "dev"},
+			expected: []string{"vocdoni", "farcaster", "dev"},
+		},
+		{
+			name:     "empty and duplicated channels",
+			channels: []string{"vocdoni", "", "farcaster", "vocdoni"},
+			expected: []string{"vocdoni", "farcaster"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			decoded := contractRoundTrip(t, &HubCommunity{
+				Name:          "community",
+				CensusType:    CensusTypeChannel,
+				CensusChannel: EncodeCensusChannels(tc.channels),
+				Admins:        []uint64{1},
+			})
+			if decoded.CensusType != CensusTypeChannel {
+				t.Fatalf("unexpected census type: %s", decoded.CensusType)
+			}
+			if got := DecodeCensusChannels(decoded.CensusChannel); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("unexpected channels: expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+	// a census without channels cannot be encoded
+	if _, err := HubToContract(&HubCommunity{CensusType: CensusTypeChannel, CensusChannel: ","}); err == nil {
+		t.Error("expected error encoding a census without channels")
+	}
+}
+
+func TestEngagedCensusRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		rule *dbmongo.CommunityEngagedRule
+	}{
+		{
+			name: "without window",
+			rule: &dbmongo.CommunityEngagedRule{MinVotes: 1, LastPolls: 1},
+		},
+		{
+			name: "with window",
+			rule: &dbmongo.CommunityEngagedRule{MinVotes: 3, LastPolls: 5, Window: 86400},
+		},
+		{
+			name: "max polls",
+			rule: &dbmongo.CommunityEngagedRule{MinVotes: MaxEngagedLastPolls, LastPolls: MaxEngagedLastPolls},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			community := &HubCommunity{
+				Name:          "community",
+				CensusType:    CensusTypeEngaged,
+				CensusChannel: EncodeEngagedRule(tc.rule),
+				Admins:        []uint64{1},
+			}
+			// the contract does not support the engaged census type, so it
+			// is stored as a channel census
+			cc, err := HubToContract(community)
+			if err != nil {
+				t.Fatalf("unexpected error encoding community: %v", err)
+			}
+			if cc.Census.CensusType != contractCensusTypes[CensusTypeChannel] {
+				t.Errorf("unexpected contract census type: %d", cc.Census.CensusType)
+			}
+			decoded := contractRoundTrip(t, community)
+			if decoded.CensusType != CensusTypeEngaged {
+				t.Fatalf("unexpected census type: %s", decoded.CensusType)
+			}
+			rule, err := DecodeEngagedRule(decoded.CensusChannel)
+			if err != nil {
+				t.Fatalf("unexpected error decoding rule: %v", err)
+			}
+			if !reflect.DeepEqual(rule, tc.rule) {
+				t.Errorf("unexpected rule: expected %+v, got %+v", tc.rule, rule)
+			}
+		})
+	}
+	// invalid rules cannot be encoded
+	for _, encoded := range []string{
+		"engaged:0:5:0",
+		"engaged:6:5:0",
+		"engaged:1:51:0",
+		"engaged:a:b",
+		"vocdoni",
+	} {
+		if _, err := HubToContract(&HubCommunity{CensusType: CensusTypeEngaged, CensusChannel: encoded}); err == nil {
+			t.Errorf("expected error encoding the engaged rule %q", encoded)
+		}
+	}
+}
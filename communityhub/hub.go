@@ -18,17 +18,33 @@ import (
 // scan iterations
 const DefaultScannerCooldown = time.Second * 20
 
+const (
+	// eventsBlocksWindow is the maximum number of blocks whose events are
+	// requested at once to the web3 endpoint
+	eventsBlocksWindow = 5000
+	// minEventsBlocksWindow is the minimum number of blocks whose events are
+	// requested at once, if the endpoint rejects it, the events are resynced
+	minEventsBlocksWindow = 100
+	// maxEventsCatchUpBlocks is the maximum number of blocks that the cursor
+	// of a chain can be behind the latest block to catch up with its events,
+	// if it is further behind, the communities are fully resynced instead
+	maxEventsCatchUpBlocks = 500000
+)
+
 // CommunityHubConfig struct defines the configuration for the CommunityHub.
 // It includes the contract address, the chain ID where the contract is
-// deployed, a database instance, and the scanner cooldown (by default 10s
-// (DefaultScannerCooldown)).
+// deployed, the confirmations that the events of every chain need to be
+// scanned, a database instance, the scanner cooldown (by default 10s
+// (DefaultScannerCooldown)) and the backend used to interact with the
+// contracts (by default BackendOnchain).
 type CommunityHubConfig struct {
 	ChainAliases      map[string]uint64
 	ContractAddresses map[string]common.Address
+	Confirmations     map[string]uint64
 	DB                *dbmongo.MongoStorage
 	PrivKey           string
 	DiscoverCooldown  time.Duration
-	SyncCooldown      time.Duration
+	Backend           string
 }
 
 // CommunityHub struct defines the CommunityHub wrapper. It includes the
@@ -42,7 +58,6 @@ type CommunityHub struct {
 	cancel           context.CancelFunc
 	w3pool           *c3web3.Web3Pool
 	discoverCooldown time.Duration
-	syncCooldown     time.Duration
 
 	ChainAliases map[string]uint64
 	contracts    map[string]*HubContract
@@ -74,6 +89,15 @@ func NewCommunityHub(
 	if len(conf.ChainAliases) == 0 {
 		return nil, ErrMissingContracts
 	}
+	// check that the backend is supported, using the on-chain one by default
+	backend := conf.Backend
+	switch backend {
+	case "":
+		backend = BackendOnchain
+	case BackendOnchain, BackendMemory:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownBackend, backend)
+	}
 	// initialize the context and the listener
 	ctx, cancel := context.WithCancel(goblalCtx)
 	communityHub := &CommunityHub{
@@ -85,14 +109,11 @@ func NewCommunityHub(
 		ChainAliases: conf.ChainAliases,
 		contracts:    map[string]*HubContract{},
 	}
-	// set the scanner cooldowns from the configuration if they are defined, or
-	// use the default one
+	// set the scanner cooldown from the configuration if it is defined, or use
+	// the default one
 	if communityHub.discoverCooldown = DefaultScannerCooldown; conf.DiscoverCooldown > 0 {
 		communityHub.discoverCooldown = conf.DiscoverCooldown
 	}
-	if communityHub.syncCooldown = DefaultScannerCooldown * 2; conf.SyncCooldown > 0 {
-		communityHub.syncCooldown = conf.SyncCooldown
-	}
 	// initialize contracts
 	failed := 0
 	for chainAlias, addr := range conf.ContractAddresses {
@@ -102,13 +123,20 @@ func NewCommunityHub(
 			failed++
 			continue
 		}
-		// load contract and add it to the contracts map
+		// the in-memory contracts do not need the web3 endpoints of the chains
+		if backend == BackendMemory {
+			communityHub.contracts[chainAlias] = NewContract(chainID, chainAlias, addr, NewMemoryBackend(chainID))
+			continue
+		}
+		// load contract and add it to the contracts map, the in-memory ones do
+		// not need confirmations since their blocks are never reorganized
 		contract, err := LoadContract(chainID, chainAlias, addr, communityHub.w3pool, conf.PrivKey)
 		if err != nil {
 			log.Warnw("failed to load contract", "error", err)
 			failed++
 			continue
 		}
+		contract.Confirmations = conf.Confirmations[chainAlias]
 		communityHub.contracts[chainAlias] = contract
 	}
 	if failed == len(conf.ContractAddresses) {
@@ -117,134 +145,214 @@ func NewCommunityHub(
 	return communityHub, nil
 }
 
-// ScanNewCommunities method starts the listener to scan for new communities
-// in the contract and create them in the database in background. It gets
-// the next community from the contract and, if it exists, it is created it
-// in the database. If something goes wrong getting the community data or
-// creating the community in the database, it logs an error and retries in
-// the next iteration. It sleeps if no communities are found in the contract.
-func (l *CommunityHub) ScanNewCommunities() {
-	// scan for new logs in background
-	l.waiter.Add(1)
+// ScanNewCommunities method starts the listener of the events of the
+// contracts in background. For every contract, it gets the events emitted
+// since the last block scanned, which is stored in the database, and applies
+// them incrementally: the communities created or updated are read again from
+// the contract and stored in the database, and the polls whose results are
+// set are marked as settled. If there is a gap in the events, it falls back to
+// a full resync of the communities of the contract. It sleeps the discover
+// cooldown between iterations.
+func (ch *CommunityHub) ScanNewCommunities() {
+	ch.waiter.Add(1)
 	go func() {
-		defer l.waiter.Done()
+		defer ch.waiter.Done()
 		for {
-			for _, contract := range l.contracts {
+			for _, contract := range ch.contracts {
 				select {
-				case <-l.ctx.Done():
+				case <-ch.ctx.Done():
 					return
 				default:
-					nextID, err := contract.NextContractID()
-					if err != nil {
-						log.Warnw("failed to get next community ID", "error", err)
-						continue
-					}
-					// get the community from the contract
-					communityID, ok := l.CommunityIDByChainAlias(nextID, contract.ChainAlias)
-					if !ok {
-						log.Warnw("failed to get community ID by chain alias", "chainAlias", contract.ChainAlias, "ID", nextID)
-						continue
-					}
-					onchainCommunity, err := contract.Community(communityID)
-					if err != nil {
-						if err != ErrCommunityNotFound {
-							log.Warnw("failed to get community data", "error", err)
+					if err := ch.scanEvents(contract); err != nil {
+						if errors.Is(err, ErrClosedDB) {
+							return
 						}
-						continue
-					}
-					if err := l.validateData(onchainCommunity); err != nil {
-						log.Warnw("failed to validate community data", "error", err)
-						continue
-					}
-					// store the community in the database
-					if err := l.addCommunityToDB(onchainCommunity); err != nil {
-						log.Warnw("failed to add community to database", "error", err)
-						continue
+						log.Warnw("failed to scan community hub events", "chainAlias", contract.ChainAlias, "error", err)
 					}
 				}
 			}
-			time.Sleep(l.discoverCooldown)
+			select {
+			case <-ch.ctx.Done():
+				return
+			case <-time.After(ch.discoverCooldown):
+			}
 		}
 	}()
 }
 
-// SyncCommunities method starts the listener to sync the communities in the
-// database with the contract. It gets the community data from the contract
-// and updates it in the database. It iterates from the first community (id: 1)
-// to the last one (next - 1) in the contract updating the community data in
-// the database. If something goes wrong it logs an error and continues with
-// the next iteration. It sleeps between iterations the sync cooldown time.
-func (ch *CommunityHub) SyncCommunities() {
-	ch.waiter.Add(1)
-	go func() {
-		defer ch.waiter.Done()
-		for {
-			select {
-			case <-ch.ctx.Done():
-				return
-			default:
-				// iterate over all the community contracts and sync them,
-				// getting the info of the communities stored in the database
-				// from the contract and updating them in the database
-				for _, contract := range ch.contracts {
-					log.Debugw("syncing communities", "chainAlias", contract.ChainAlias, "contract", contract.Address.String())
-					nextID, err := contract.NextContractID()
-					if err != nil {
-						log.Warnw("failed to get next community ID", "error", err)
-						continue
-					}
-					// iterate from 1 to the last inserted ID in the database
-					// getting community data from the contract and updating it
-					// in the database
-					for id := uint64(1); id < nextID; id++ {
-						select {
-						case <-ch.ctx.Done():
-							return
-						default:
-							// get the community from the contract
-							communityID, ok := ch.CommunityIDByChainAlias(id, contract.ChainAlias)
-							if !ok {
-								log.Warnw("failed to get community ID by chain alias", "chainAlias", contract.ChainAlias)
-								continue
-							}
-							onchainCommunity, err := contract.Community(communityID)
-							if err != nil {
-								log.Warnw("failed to get community data", "error", err, "communityID", communityID)
-								continue
-							}
-							// get the community from the database
-							dbCommunity, err := ch.communityFromDB(communityID)
-							if err != nil {
-								if errors.Is(err, ErrClosedDB) {
-									return
-								}
-								if !errors.Is(err, ErrCommunityNotFound) {
-									log.Warnw("failed to get community from database", "error", err)
-								}
-								if err := ch.addCommunityToDB(onchainCommunity); err != nil {
-									log.Warnw("failed to add community to database", "error", err)
-								}
-								continue
-							}
-							// join the community data from the contract with the
-							// community data from the database
-							community, err := ch.joinCommunityData(dbCommunity, onchainCommunity)
-							if err != nil {
-								log.Warnw("failed to join community data", "error", err)
-								continue
-							}
-							// update the community in the database
-							if err := ch.updateCommunityToDB(community); err != nil {
-								log.Warnw("failed to update community in database", "error", err)
-								continue
-							}
-						}
-					}
+// scanEvents method gets the events of the contract provided emitted since
+// the cursor of its chain up to the latest confirmed block, which is the
+// number of confirmations of the contract behind the latest block, and
+// applies them to the database, moving the cursor forward after every window
+// of blocks. So the events of the blocks that can still be reorganized are
+// not applied until they are confirmed. There is a gap if the cursor does not
+// exist, belongs to other contract or is too far behind, or if the events of
+// a window cannot be retrieved at all. In that case, the communities of the
+// contract are fully resynced and the cursor is moved to the latest confirmed
+// block. It also happens if the latest block is behind the cursor, because
+// the chain has been reset (like the in-memory backend after a restart).
+func (ch *CommunityHub) scanEvents(contract *HubContract) error {
+	cursor, err := ch.db.CommunityHubCursor(contract.ChainAlias)
+	if err != nil {
+		if dbmongo.IsDBClosed(err) {
+			return ErrClosedDB
+		}
+		return errors.Join(ErrGettingCursor, err)
+	}
+	lastBlock, err := contract.LastBlock(ch.ctx)
+	if err != nil {
+		return err
+	}
+	confirmedBlock := uint64(0)
+	if lastBlock > contract.Confirmations {
+		confirmedBlock = lastBlock - contract.Confirmations
+	}
+	if cursor == nil || cursor.Contract != contract.Address.Hex() || lastBlock < cursor.LastBlock ||
+		lastBlock-cursor.LastBlock > maxEventsCatchUpBlocks {
+		log.Infow("resyncing community hub contract", "chainAlias", contract.ChainAlias,
+			"lastBlock", lastBlock, "confirmedBlock", confirmedBlock)
+		return ch.resyncCommunities(contract, confirmedBlock)
+	}
+	window := uint64(eventsBlocksWindow)
+	for from := cursor.LastBlock + 1; from <= confirmedBlock; {
+		to := min(from+window-1, confirmedBlock)
+		events, err := contract.Events(ch.ctx, from, to)
+		if err != nil {
+			if ch.ctx.Err() != nil {
+				return ch.ctx.Err()
+			}
+			// the endpoint can reject the request if the range has too many
+			// logs, so retry with a smaller window before giving up
+			if window > minEventsBlocksWindow {
+				window /= 2
+				continue
+			}
+			log.Warnw("failed to get community hub events, resyncing", "chainAlias", contract.ChainAlias,
+				"fromBlock", from, "toBlock", to, "error", err)
+			return ch.resyncCommunities(contract, confirmedBlock)
+		}
+		if err := ch.applyEvents(contract, events); err != nil {
+			return err
+		}
+		cursor.LastBlock = to
+		if err := ch.db.SetCommunityHubCursor(cursor); err != nil {
+			if dbmongo.IsDBClosed(err) {
+				return ErrClosedDB
+			}
+			return errors.Join(ErrGettingCursor, err)
+		}
+		from = to + 1
+	}
+	return nil
+}
+
+// applyEvents method applies the events provided of the contract provided to
+// the database. Every community created or updated is read again from the
+// contract only once and stored in the database. The polls whose results are
+// set are marked as settled.
+func (ch *CommunityHub) applyEvents(contract *HubContract, events []*HubEvent) error {
+	synced := map[uint64]bool{}
+	for _, event := range events {
+		if event.Name == EventResultsSet {
+			if err := ch.db.SetResultsSettled(event.ElectionID, event.Block); err != nil {
+				if dbmongo.IsDBClosed(err) {
+					return ErrClosedDB
 				}
-				time.Sleep(ch.syncCooldown)
+				log.Warnw("failed to set results as settled", "electionID", fmt.Sprintf("%x", event.ElectionID), "error", err)
 			}
+			continue
 		}
-	}()
+		if synced[event.ContractID] {
+			continue
+		}
+		synced[event.ContractID] = true
+		communityID, ok := ch.CommunityIDByChainAlias(event.ContractID, contract.ChainAlias)
+		if !ok {
+			log.Warnw("failed to get community ID by chain alias", "chainAlias", contract.ChainAlias, "ID", event.ContractID)
+			continue
+		}
+		if err := ch.syncCommunity(contract, communityID); err != nil {
+			if errors.Is(err, ErrClosedDB) {
+				return err
+			}
+			log.Warnw("failed to sync community", "communityID", communityID, "event", event.Name, "error", err)
+		}
+	}
+	return nil
+}
+
+// resyncCommunities method gets the data of every community of the contract
+// provided, from the first one (id: 1) to the last one (next - 1), and stores
+// it in the database. Then, it moves the cursor of the chain of the contract
+// to the block provided, which must be the latest confirmed block before the
+// resync started, so the events emitted during the resync or not confirmed
+// yet are applied again.
+func (ch *CommunityHub) resyncCommunities(contract *HubContract, lastBlock uint64) error {
+	log.Debugw("syncing communities", "chainAlias", contract.ChainAlias, "contract", contract.Address.String())
+	nextID, err := contract.NextContractID()
+	if err != nil {
+		return err
+	}
+	for id := uint64(1); id < nextID; id++ {
+		select {
+		case <-ch.ctx.Done():
+			return ch.ctx.Err()
+		default:
+			communityID, ok := ch.CommunityIDByChainAlias(id, contract.ChainAlias)
+			if !ok {
+				log.Warnw("failed to get community ID by chain alias", "chainAlias", contract.ChainAlias)
+				continue
+			}
+			if err := ch.syncCommunity(contract, communityID); err != nil {
+				if errors.Is(err, ErrClosedDB) {
+					return err
+				}
+				log.Warnw("failed to sync community", "communityID", communityID, "error", err)
+			}
+		}
+	}
+	if err := ch.db.SetCommunityHubCursor(&dbmongo.CommunityHubCursor{
+		ChainAlias: contract.ChainAlias,
+		Contract:   contract.Address.Hex(),
+		LastBlock:  lastBlock,
+	}); err != nil {
+		if dbmongo.IsDBClosed(err) {
+			return ErrClosedDB
+		}
+		return errors.Join(ErrGettingCursor, err)
+	}
+	return nil
+}
+
+// syncCommunity method gets the data of the community provided from the
+// contract provided and stores it in the database, creating the community if
+// it does not exist yet or joining the data with the current one if it does.
+func (ch *CommunityHub) syncCommunity(contract *HubContract, communityID string) error {
+	onchainCommunity, err := contract.Community(communityID)
+	if err != nil {
+		return err
+	}
+	// get the community from the database
+	dbCommunity, err := ch.communityFromDB(communityID)
+	if err != nil {
+		if errors.Is(err, ErrClosedDB) {
+			return err
+		}
+		if !errors.Is(err, ErrCommunityNotFound) {
+			log.Warnw("failed to get community from database", "error", err)
+		}
+		if err := ch.validateData(onchainCommunity); err != nil {
+			return err
+		}
+		return ch.addCommunityToDB(onchainCommunity)
+	}
+	// join the community data from the contract with the community data from
+	// the database
+	community, err := ch.joinCommunityData(dbCommunity, onchainCommunity)
+	if err != nil {
+		return err
+	}
+	return ch.updateCommunityToDB(community)
 }
 
 // Stop method stops the listener and waits for the goroutines to finish.
@@ -254,6 +362,16 @@ func (l *CommunityHub) Stop() {
 	l.waiter.Wait()
 }
 
+// Contract method gets the contract deployed in the chain with the alias
+// provided. If the contract is not found, it returns an error.
+func (ch *CommunityHub) Contract(chainAlias string) (*HubContract, error) {
+	contract, ok := ch.contracts[chainAlias]
+	if !ok {
+		return nil, ErrContractNotFound
+	}
+	return contract, nil
+}
+
 // CommunityContract method gets the contract of a community by the community ID.
 // It decodes the chain ID from the community ID and gets the contract from the
 // contracts map. If the contract is not found, it returns an error.
@@ -269,31 +387,65 @@ func (ch *CommunityHub) CommunityContract(communityID string) (*HubContract, err
 	return contract, nil
 }
 
+// CreateCommunity method creates the community provided in the contract of
+// its chain and stores it in the database. The data of the community is
+// validated before sending the transaction, which is sent with the private
+// key configured, so the creation is relayed by the server. It waits until the
+// transaction is mined or the context provided is done, and returns the
+// community created, the transaction sent and its receipt.
+func (ch *CommunityHub) CreateCommunity(ctx context.Context, community *HubCommunity) (
+	*HubCommunity, *HubTx, *HubReceipt, error,
+) {
+	chainAlias, ok := ch.ChainAliasFromID(community.ChainID)
+	if !ok {
+		return nil, nil, nil, ErrContractNotFound
+	}
+	contract, ok := ch.contracts[chainAlias]
+	if !ok {
+		return nil, nil, nil, ErrContractNotFound
+	}
+	if err := ch.validateData(community); err != nil {
+		return nil, nil, nil, err
+	}
+	created, tx, receipt, err := contract.CreateCommunity(ctx, community)
+	if err != nil {
+		return nil, tx, receipt, err
+	}
+	// the community is also stored by the events scanner, so if it fails
+	// here it will be stored later
+	if err := ch.addCommunityToDB(created); err != nil {
+		log.Warnw("failed to add created community to database", "communityID", created.CommunityID, "error", err)
+	}
+	return created, tx, receipt, nil
+}
+
 // UpdateCommunity method updates a community in the contract and the database.
 // It merges the new data with the current data of the community and updates it
-// in the contract and the database. If something goes wrong updating the
-// community in the contract or the database, it returns an error.
-func (ch *CommunityHub) UpdateCommunity(newData *HubCommunity) error {
+// in the contract and the database. It returns the transaction sent to the
+// contract. If something goes wrong updating the community in the contract or
+// the database, it returns an error.
+func (ch *CommunityHub) UpdateCommunity(newData *HubCommunity) (*HubTx, error) {
 	chainAlias, _, ok := ch.ChainAliasAndContractIDFromCommunityID(newData.CommunityID)
 	if !ok {
-		return ErrDecodeCommunityID
+		return nil, ErrDecodeCommunityID
 	}
 	contract, ok := ch.contracts[chainAlias]
 	if !ok {
-		return ErrContractNotFound
+		return nil, ErrContractNotFound
 	}
 	currentData, err := ch.communityFromDB(newData.CommunityID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	resultData, err := ch.joinCommunityData(currentData, newData)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if err := contract.SetCommunity(resultData); err != nil {
-		return errors.Join(ErrSettingCommunity, err)
+	tx, err := contract.SetCommunity(resultData)
+	if err != nil {
+		return nil, errors.Join(ErrSettingCommunity, err)
 	}
-	return ch.updateCommunityToDB(resultData)
+	return tx, ch.updateCommunityToDB(resultData)
 }
 
 // CommunityIDByChainID method gets the community ID by the chain ID and the
@@ -374,6 +526,14 @@ func (ch *CommunityHub) ChainAliasFromID(chainID uint64) (string, bool) {
 	return "", false
 }
 
+// ValidateCommunity method validates the data of the community provided, as
+// it is validated before creating it, so it can be checked before doing any
+// other work for the creation. If something is wrong, it returns an
+// ErrInvalidCommunityData error.
+func (ch *CommunityHub) ValidateCommunity(data *HubCommunity) error {
+	return ch.validateData(data)
+}
+
 // validateData method validates the data of a community. It checks that the
 // chain ID, the ID, the community ID, the name, the census type, the channel,
 // the addresses, the admins, the notifications, and the disabled fields are
@@ -386,11 +546,21 @@ func (ch *CommunityHub) validateData(data *HubCommunity) error {
 		return fmt.Errorf("%w: invalid community name", ErrInvalidCommunityData)
 	}
 	switch data.CensusType {
-	case CensusTypeChannel, CensusTypeFollowers:
+	case CensusTypeChannel:
+		if len(DecodeCensusChannels(data.CensusChannel)) == 0 {
+			return fmt.Errorf("%w: invalid channel", ErrInvalidCommunityData)
+		}
+	case CensusTypeFollowers:
 		if data.CensusChannel == "" {
 			return fmt.Errorf("%w: invalid channel", ErrInvalidCommunityData)
 		}
-	case CensusTypeERC20, CensusTypeNFT:
+	case CensusTypeEngaged:
+		if _, err := DecodeEngagedRule(data.CensusChannel); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidCommunityData, err)
+		}
+	case CensusTypeMembers:
+		// the members of the census are managed directly in the database
+	case CensusTypeERC20, CensusTypeNFT, CensusTypeERC20Votes:
 		if len(data.CensusAddesses) == 0 {
 			return fmt.Errorf("%w: invalid addresses", ErrInvalidCommunityData)
 		}
@@ -442,11 +612,11 @@ func (ch *CommunityHub) joinCommunityData(data, newData *HubCommunity) (*HubComm
 		data.CensusType = newData.CensusType
 	}
 	switch data.CensusType {
-	case CensusTypeChannel, CensusTypeFollowers:
+	case CensusTypeChannel, CensusTypeFollowers, CensusTypeEngaged:
 		if newData.CensusChannel != "" && data.CensusChannel != newData.CensusChannel {
 			data.CensusChannel = newData.CensusChannel
 		}
-	case CensusTypeERC20, CensusTypeNFT:
+	case CensusTypeERC20, CensusTypeNFT, CensusTypeERC20Votes:
 		if len(newData.CensusAddesses) > 0 {
 			data.CensusAddesses = newData.CensusAddesses
 		}
@@ -0,0 +1,194 @@
+package communityhub
+
+import (
+	"context"
+	"encoding/binary"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	comhub "github.com/vocdoni/vote-frame/communityhub/contracts/communityhubtoken"
+)
+
+// memoryResultKey identifies the results of a poll of a community in the
+// MemoryBackend
+type memoryResultKey struct {
+	communityID uint64
+	electionID  [32]byte
+}
+
+// MemoryBackend struct implements the Backend interface emulating a
+// CommunityHub contract in memory, so the community flows can be used in
+// development and tests without network access. Every transaction is mined
+// instantly in a new block and emits the same events that the contract does.
+// The data is not persisted, so it is lost when the backend is discarded.
+type MemoryBackend struct {
+	mtx         sync.RWMutex
+	chainID     uint64
+	block       uint64
+	nonce       uint64
+	nextID      uint64
+	communities map[uint64]comhub.ICommunityHubCommunity
+	results     map[memoryResultKey]comhub.IResultResult
+	receipts    map[common.Hash]*HubReceipt
+	events      []*HubEvent
+}
+
+// NewMemoryBackend function initializes an empty MemoryBackend for the chain
+// ID provided. The first community created gets the ID 1, like in the
+// contract.
+func NewMemoryBackend(chainID uint64) *MemoryBackend {
+	return &MemoryBackend{
+		chainID:     chainID,
+		nextID:      1,
+		communities: map[uint64]comhub.ICommunityHubCommunity{},
+		results:     map[memoryResultKey]comhub.IResultResult{},
+		receipts:    map[common.Hash]*HubReceipt{},
+	}
+}
+
+// NextCommunityID method returns the ID of the next community to be created.
+func (mb *MemoryBackend) NextCommunityID() (*big.Int, error) {
+	mb.mtx.RLock()
+	defer mb.mtx.RUnlock()
+	return new(big.Int).SetUint64(mb.nextID), nil
+}
+
+// Community method returns the data of the community with the ID provided. If
+// it does not exist, it returns an empty community without funds.
+func (mb *MemoryBackend) Community(communityID *big.Int) (comhub.ICommunityHubCommunity, error) {
+	mb.mtx.RLock()
+	defer mb.mtx.RUnlock()
+	community, ok := mb.communities[communityID.Uint64()]
+	if !ok {
+		return comhub.ICommunityHubCommunity{Funds: big.NewInt(0)}, nil
+	}
+	return community, nil
+}
+
+// CreateCommunityPrice method returns the price to create a community, which
+// is always zero.
+func (mb *MemoryBackend) CreateCommunityPrice() (*big.Int, error) {
+	return big.NewInt(0), nil
+}
+
+// CreateCommunity method creates a community with the data provided and the
+// value provided as its funds, and emits the CommunityCreated event.
+func (mb *MemoryBackend) CreateCommunity(community comhub.ICommunityHubCommunity, value *big.Int) (*HubTx, error) {
+	mb.mtx.Lock()
+	defer mb.mtx.Unlock()
+	id := mb.nextID
+	mb.nextID++
+	community.Funds = big.NewInt(0)
+	if value != nil {
+		community.Funds.Set(value)
+	}
+	community.Guardians = append([]*big.Int{}, community.Guardians...)
+	mb.communities[id] = community
+	tx := mb.mine(&HubEvent{Name: EventCommunityCreated, ContractID: id})
+	mb.receipts[tx.Hash].ContractID = id
+	tx.Value = new(big.Int).Set(community.Funds)
+	return tx, nil
+}
+
+// ManageCommunity method sets the data of the community with the ID provided,
+// creating it if it does not exist, and emits the AdminCommunityManaged event.
+func (mb *MemoryBackend) ManageCommunity(communityID *big.Int, community comhub.ICommunityHubCommunity) (*HubTx, error) {
+	mb.mtx.Lock()
+	defer mb.mtx.Unlock()
+	id := communityID.Uint64()
+	if community.Funds == nil {
+		community.Funds = big.NewInt(0)
+	}
+	community.Guardians = append([]*big.Int{}, community.Guardians...)
+	mb.communities[id] = community
+	if id >= mb.nextID {
+		mb.nextID = id + 1
+	}
+	return mb.mine(&HubEvent{Name: "AdminCommunityManaged", ContractID: id}), nil
+}
+
+// Result method returns the results of the poll with the ID provided of the
+// community with the ID provided. If they are not set, it returns empty
+// results.
+func (mb *MemoryBackend) Result(communityID *big.Int, electionID [32]byte) (comhub.IResultResult, error) {
+	mb.mtx.RLock()
+	defer mb.mtx.RUnlock()
+	return mb.results[memoryResultKey{communityID.Uint64(), electionID}], nil
+}
+
+// SetResult method sets the results of the poll with the ID provided of the
+// community with the ID provided and emits the ResultsSet event. The
+// transaction is mined instantly, so the transaction to replace is ignored.
+func (mb *MemoryBackend) SetResult(communityID *big.Int, electionID [32]byte,
+	result comhub.IResultResult, _ *HubTx,
+) (*HubTx, error) {
+	mb.mtx.Lock()
+	defer mb.mtx.Unlock()
+	id := communityID.Uint64()
+	mb.results[memoryResultKey{id, electionID}] = result
+	return mb.mine(&HubEvent{Name: EventResultsSet, ContractID: id, ElectionID: electionID[:]}), nil
+}
+
+// Receipt method returns the receipt of the transaction with the hash
+// provided. Every transaction of the backend succeeds without gas cost. If the
+// transaction does not exist, it returns nil.
+func (mb *MemoryBackend) Receipt(hash common.Hash) (*HubReceipt, error) {
+	mb.mtx.RLock()
+	defer mb.mtx.RUnlock()
+	receipt, ok := mb.receipts[hash]
+	if !ok {
+		return nil, nil
+	}
+	copied := *receipt
+	return &copied, nil
+}
+
+// LastBlock method returns the number of the latest block mined.
+func (mb *MemoryBackend) LastBlock(_ context.Context) (uint64, error) {
+	mb.mtx.RLock()
+	defer mb.mtx.RUnlock()
+	return mb.block, nil
+}
+
+// Events method returns the events emitted between the blocks provided (both
+// included), in the order they were emitted.
+func (mb *MemoryBackend) Events(_ context.Context, fromBlock, toBlock uint64) ([]*HubEvent, error) {
+	mb.mtx.RLock()
+	defer mb.mtx.RUnlock()
+	events := []*HubEvent{}
+	for _, event := range mb.events {
+		if event.Block >= fromBlock && event.Block <= toBlock {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// mine helper method mines a new block with a transaction that emits the
+// event provided and returns the transaction. It must be called with the
+// lock held.
+func (mb *MemoryBackend) mine(event *HubEvent) *HubTx {
+	mb.block++
+	mb.nonce++
+	seed := make([]byte, 16)
+	binary.BigEndian.PutUint64(seed[:8], mb.chainID)
+	binary.BigEndian.PutUint64(seed[8:], mb.nonce)
+	tx := &HubTx{
+		Hash:      crypto.Keccak256Hash(seed),
+		Nonce:     mb.nonce,
+		GasTipCap: big.NewInt(0),
+		GasFeeCap: big.NewInt(0),
+		Value:     big.NewInt(0),
+	}
+	mb.receipts[tx.Hash] = &HubReceipt{
+		Hash:     tx.Hash,
+		Block:    mb.block,
+		Success:  true,
+		GasPrice: big.NewInt(0),
+	}
+	event.Block = mb.block
+	mb.events = append(mb.events, event)
+	return tx
+}
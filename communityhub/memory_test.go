@@ -0,0 +1,126 @@
+package communityhub
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestMemoryBackendContract(t *testing.T) {
+	ctx := context.Background()
+	contract := NewContract(1, "eth", common.Address{}, NewMemoryBackend(1))
+	if nextID, err := contract.NextContractID(); err != nil || nextID != 1 {
+		t.Fatalf("unexpected next community ID: %d (%v)", nextID, err)
+	}
+
+	// create a community
+	notifications, disabled := true, false
+	community := &HubCommunity{
+		ChainID:       1,
+		Name:          "community",
+		ImageURL:      "https://example.com/logo.png",
+		CensusType:    CensusTypeChannel,
+		CensusChannel: EncodeCensusChannels([]string{"vocdoni", "dev"}),
+		Channels:      []string{"vocdoni"},
+		Admins:        []uint64{1, 2},
+		Notifications: &notifications,
+		Disabled:      &disabled,
+	}
+	created, tx, receipt, err := contract.CreateCommunity(ctx, community)
+	if err != nil {
+		t.Fatalf("unexpected error creating community: %v", err)
+	}
+	if !receipt.Success || receipt.ContractID != 1 || receipt.Hash != tx.Hash {
+		t.Errorf("unexpected receipt of community creation: %+v", receipt)
+	}
+	if created.CommunityID != "eth:1" || created.ContractID != 1 || created.Name != community.Name ||
+		created.CensusChannel != community.CensusChannel || !reflect.DeepEqual(created.Admins, community.Admins) {
+		t.Errorf("unexpected community created: %+v", created)
+	}
+	if nextID, err := contract.NextContractID(); err != nil || nextID != 2 {
+		t.Errorf("unexpected next community ID after creation: %d (%v)", nextID, err)
+	}
+	if _, err := contract.Community("eth:5"); !errors.Is(err, ErrCommunityNotFound) {
+		t.Errorf("expected community not found, got %v", err)
+	}
+
+	// update the community
+	created.Name = "updated community"
+	created.Admins = []uint64{1, 2, 3}
+	if _, err := contract.SetCommunity(created); err != nil {
+		t.Fatalf("unexpected error setting community: %v", err)
+	}
+	updated, err := contract.Community("eth:1")
+	if err != nil {
+		t.Fatalf("unexpected error getting community: %v", err)
+	}
+	if updated.Name != created.Name || !reflect.DeepEqual(updated.Admins, created.Admins) {
+		t.Errorf("unexpected updated community: %+v", updated)
+	}
+	if updated.Funds().Cmp(big.NewInt(0)) != 0 {
+		t.Errorf("unexpected funds of updated community: %s", updated.Funds())
+	}
+
+	// set the results of a poll of the community
+	electionID := common.Hex2Bytes("c5d2460186f7000000000000000000000000000000000000000000000000001a")
+	results := &HubResults{
+		ElectionID:       electionID,
+		Question:         "question",
+		Options:          []string{"yes", "no"},
+		Date:             "2024-05-01 00:00:00",
+		Tally:            [][]*big.Int{{big.NewInt(10), big.NewInt(5)}},
+		Turnout:          big.NewInt(15),
+		TotalVotingPower: big.NewInt(20),
+		Participants:     []*big.Int{big.NewInt(1), big.NewInt(2)},
+		CensusRoot:       bytes.Repeat([]byte{1}, 32),
+		CensusURI:        "ipfs://census",
+	}
+	if _, err := contract.SetResults(updated, results, nil); err != nil {
+		t.Fatalf("unexpected error setting results: %v", err)
+	}
+	settled, err := contract.Results(updated.ContractID, electionID)
+	if err != nil {
+		t.Fatalf("unexpected error getting results: %v", err)
+	}
+	if settled.Question != results.Question || !reflect.DeepEqual(settled.Options, results.Options) ||
+		!reflect.DeepEqual(settled.Tally, results.Tally) || !bytes.Equal(settled.CensusRoot, results.CensusRoot) ||
+		settled.CensusURI != results.CensusURI || settled.Turnout.Cmp(results.Turnout) != 0 {
+		t.Errorf("unexpected settled results: %+v", settled)
+	}
+	if empty, err := contract.Results(2, electionID); err != nil || empty.Question != "" {
+		t.Errorf("unexpected results of other community: %+v (%v)", empty, err)
+	}
+
+	// every transaction is mined in a new block and emits the event that
+	// the scanner of the community hub consumes
+	lastBlock, err := contract.LastBlock(ctx)
+	if err != nil || lastBlock != 3 {
+		t.Fatalf("unexpected last block: %d (%v)", lastBlock, err)
+	}
+	events, err := contract.Events(ctx, 0, lastBlock)
+	if err != nil {
+		t.Fatalf("unexpected error getting events: %v", err)
+	}
+	expected := []*HubEvent{
+		{Name: EventCommunityCreated, ContractID: 1, Block: 1},
+		{Name: "AdminCommunityManaged", ContractID: 1, Block: 2},
+		{Name: EventResultsSet, ContractID: 1, ElectionID: electionID, Block: 3},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	for _, event := range events[:2] {
+		if !slices.Contains(communityEvents, event.Name) {
+			t.Errorf("event %s is not a community event", event.Name)
+		}
+	}
+	if events, err := contract.Events(ctx, 2, 2); err != nil || len(events) != 1 || events[0].Block != 2 {
+		t.Errorf("unexpected events of block 2: %+v (%v)", events, err)
+	}
+}
@@ -0,0 +1,281 @@
+package communityhub
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	c3web3 "github.com/vocdoni/census3/helpers/web3"
+	comhub "github.com/vocdoni/vote-frame/communityhub/contracts/communityhubtoken"
+	"go.vocdoni.io/dvote/log"
+)
+
+// onchainBackend struct implements the Backend interface with the
+// CommunityHub contract deployed in a chain. It contains the chain ID, the
+// contract address, the web3 client, the contract bindings, and the private
+// key and address used to send the transactions.
+type onchainBackend struct {
+	chainID     uint64
+	address     common.Address
+	privKey     *ecdsa.PrivateKey
+	privAddress common.Address
+
+	w3cli    *c3web3.Client
+	contract *comhub.CommunityHubToken
+}
+
+// newOnchainBackend function initializes the backend of the contract deployed
+// in the chain ID and the address provided, using the web3 pool and the
+// private key provided. If something goes wrong initializing the web3 client
+// or the contract, it returns an error. If the private key is not valid, the
+// backend is returned without it, so it cannot send transactions.
+func newOnchainBackend(chainID uint64, addr common.Address, w3p *c3web3.Web3Pool, pk string) (*onchainBackend, error) {
+	ob := &onchainBackend{
+		chainID: chainID,
+		address: addr,
+	}
+	// initialize the web3 client for the chain
+	var err error
+	ob.w3cli, err = w3p.Client(chainID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrWeb3Client, err)
+	}
+	// initialize the contract with the web3 client and the contract addr
+	ob.contract, err = comhub.NewCommunityHubToken(addr, ob.w3cli)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInitContract, err)
+	}
+	// loading the private key
+	if err := ob.initiPrivateKey(pk); err != nil {
+		log.Warn(err)
+	}
+	return ob, nil
+}
+
+// NextCommunityID method gets the next community ID from the contract.
+func (ob *onchainBackend) NextCommunityID() (*big.Int, error) {
+	return ob.contract.GetNextCommunityId(nil)
+}
+
+// Community method gets the community data from the contract.
+func (ob *onchainBackend) Community(communityID *big.Int) (comhub.ICommunityHubCommunity, error) {
+	return ob.contract.GetCommunity(nil, communityID)
+}
+
+// CreateCommunityPrice method gets the price to create a community from the
+// contract.
+func (ob *onchainBackend) CreateCommunityPrice() (*big.Int, error) {
+	return ob.contract.GetCreateCommunityPrice(nil)
+}
+
+// CreateCommunity method creates a community in the contract with the
+// private key configured, paying the value provided.
+func (ob *onchainBackend) CreateCommunity(cc comhub.ICommunityHubCommunity, value *big.Int) (*HubTx, error) {
+	transactOpts, err := ob.authTransactOpts()
+	if err != nil {
+		return nil, err
+	}
+	transactOpts.Value = value
+	tx, err := ob.contract.CreateCommunity(transactOpts, cc.Metadata, cc.Census,
+		cc.Guardians, cc.CreateElectionPermission)
+	if err != nil {
+		return nil, err
+	}
+	return txToHub(tx), nil
+}
+
+// ManageCommunity method sets the community data in the contract with the
+// private key configured, which must be an admin of the contract.
+func (ob *onchainBackend) ManageCommunity(communityID *big.Int, cc comhub.ICommunityHubCommunity) (*HubTx, error) {
+	transactOpts, err := ob.authTransactOpts()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := ob.contract.AdminManageCommunity(transactOpts, communityID, cc.Metadata,
+		cc.Census, cc.Guardians, cc.CreateElectionPermission, cc.Disabled, cc.Funds)
+	if err != nil {
+		return nil, err
+	}
+	return txToHub(tx), nil
+}
+
+// Result method gets the results of a poll from the contract.
+func (ob *onchainBackend) Result(communityID *big.Int, electionID [32]byte) (comhub.IResultResult, error) {
+	return ob.contract.GetResult(nil, communityID, electionID)
+}
+
+// SetResult method sets the results of a poll in the contract with the
+// private key configured. If a transaction to replace is provided, the new one
+// is sent with its nonce and higher fees, so the stuck one is replaced.
+func (ob *onchainBackend) SetResult(communityID *big.Int, electionID [32]byte,
+	result comhub.IResultResult, replace *HubTx,
+) (*HubTx, error) {
+	transactOpts, err := ob.authTransactOpts()
+	if err != nil {
+		return nil, err
+	}
+	if replace != nil {
+		if err := ob.bumpTransactOpts(transactOpts, replace); err != nil {
+			return nil, err
+		}
+	}
+	tx, err := ob.contract.SetResult(transactOpts, communityID, electionID, result)
+	if err != nil {
+		return nil, err
+	}
+	return txToHub(tx), nil
+}
+
+// Receipt method gets the receipt of the transaction with the hash provided.
+// If the transaction created a community, the ID of the community is decoded
+// from its CommunityCreated log. If the transaction is not mined yet, it
+// returns nil and no error.
+func (ob *onchainBackend) Receipt(hash common.Hash) (*HubReceipt, error) {
+	client, err := ob.w3cli.EthClient()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrWeb3Client, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	receipt, err := client.TransactionReceipt(ctx, hash)
+	if err != nil {
+		if errors.Is(err, ethereum.NotFound) {
+			return nil, nil
+		}
+		return nil, errors.Join(ErrGettingTx, err)
+	}
+	hubReceipt := &HubReceipt{
+		Hash:     hash,
+		Block:    receipt.BlockNumber.Uint64(),
+		Success:  receipt.Status == types.ReceiptStatusSuccessful,
+		GasUsed:  receipt.GasUsed,
+		GasPrice: receipt.EffectiveGasPrice,
+	}
+	contractABI, err := comhub.CommunityHubTokenMetaData.GetAbi()
+	if err != nil {
+		return nil, errors.Join(ErrInitContract, err)
+	}
+	createdID := contractABI.Events[EventCommunityCreated].ID
+	for _, l := range receipt.Logs {
+		if l.Address != ob.address || len(l.Topics) == 0 || l.Topics[0] != createdID {
+			continue
+		}
+		event, err := ob.contract.ParseCommunityCreated(*l)
+		if err != nil {
+			return nil, errors.Join(ErrDecodingEvent, err)
+		}
+		hubReceipt.ContractID = event.CommunityId.Uint64()
+	}
+	return hubReceipt, nil
+}
+
+// LastBlock method returns the number of the latest block of the chain.
+func (ob *onchainBackend) LastBlock(ctx context.Context) (uint64, error) {
+	return ob.w3cli.BlockNumber(ctx)
+}
+
+// txToHub helper function converts the transaction provided to a HubTx.
+func txToHub(tx *types.Transaction) *HubTx {
+	return &HubTx{
+		Hash:      tx.Hash(),
+		Nonce:     tx.Nonce(),
+		GasTipCap: tx.GasTipCap(),
+		GasFeeCap: tx.GasFeeCap(),
+		Value:     tx.Value(),
+	}
+}
+
+// initiPrivateKey helper method initializes the private key of the backend.
+// If the private key is not defined, it returns nil. If the private key is
+// defined, it parses it and sets the private key and the private address. If
+// something goes wrong parsing the private key, it returns an error.
+func (ob *onchainBackend) initiPrivateKey(privKey string) error {
+	// parse the private key if it is defined
+	if privKey == "" {
+		return nil
+	}
+	var err error
+	ob.privKey, err = crypto.HexToECDSA(privKey)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInitializingPrivateKey, err)
+	}
+	ob.privAddress = crypto.PubkeyToAddress(ob.privKey.PublicKey)
+	return nil
+}
+
+// authTransactOpts helper method creates the transact options with the private
+// key configured in the backend. It sets the nonce, gas price, and gas limit.
+// If something goes wrong creating the signer, getting the nonce, or getting
+// the gas price, it returns an error.
+func (ob *onchainBackend) authTransactOpts() (*bind.TransactOpts, error) {
+	if ob.privKey == nil {
+		return nil, ErrNoPrivKeyConfigured
+	}
+	bChainID := new(big.Int).SetUint64(ob.chainID)
+	auth, err := bind.NewKeyedTransactorWithChainID(ob.privKey, bChainID)
+	if err != nil {
+		return nil, errors.Join(ErrCreatingSigner, err)
+	}
+	// create the context with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// set the nonce
+	nonce, err := ob.w3cli.PendingNonceAt(ctx, ob.privAddress)
+	if err != nil {
+		return nil, errors.Join(ErrSendingTx, err)
+	}
+	auth.Nonce = new(big.Int).SetUint64(nonce)
+	// set the gas tip cap
+	if auth.GasTipCap, err = ob.w3cli.SuggestGasTipCap(ctx); err != nil {
+		return nil, errors.Join(ErrSendingTx, err)
+	}
+	// set the gas limit
+	auth.GasLimit = 10000000
+	return auth, nil
+}
+
+// bumpTransactOpts helper method sets the nonce of the transaction to replace
+// provided in the transact options provided, and increases its fees by
+// GasBumpPercent at least, because the nodes reject the replacements that do
+// not increase them enough. The fee cap also covers the current base fee
+// if it is higher.
+func (ob *onchainBackend) bumpTransactOpts(auth *bind.TransactOpts, replace *HubTx) error {
+	bump := func(fee *big.Int) *big.Int {
+		if fee == nil {
+			return big.NewInt(0)
+		}
+		bumped := new(big.Int).Mul(fee, big.NewInt(100+GasBumpPercent))
+		return bumped.Add(bumped.Div(bumped, big.NewInt(100)), big.NewInt(1))
+	}
+	auth.Nonce = new(big.Int).SetUint64(replace.Nonce)
+	if minTipCap := bump(replace.GasTipCap); auth.GasTipCap == nil || auth.GasTipCap.Cmp(minTipCap) < 0 {
+		auth.GasTipCap = minTipCap
+	}
+	feeCap := bump(replace.GasFeeCap)
+	// create the context with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	head, err := ob.w3cli.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return errors.Join(ErrSendingTx, err)
+	}
+	if head.BaseFee != nil {
+		currentFeeCap := new(big.Int).Add(auth.GasTipCap, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+		if currentFeeCap.Cmp(feeCap) > 0 {
+			feeCap = currentFeeCap
+		}
+	}
+	if feeCap.Cmp(auth.GasTipCap) < 0 {
+		feeCap = new(big.Int).Set(auth.GasTipCap)
+	}
+	auth.GasFeeCap = feeCap
+	return nil
+}
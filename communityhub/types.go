@@ -2,6 +2,7 @@ package communityhub
 
 import (
 	"math/big"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -23,6 +24,20 @@ const (
 	// CensusTypeFollowers represents the census that includes all the followers
 	// of an user in a source (farcaster or other like alfafrens)
 	CensusTypeFollowers CensusType = "followers"
+	// CensusTypeERC20Votes represents the census that includes all the
+	// delegatees of an ERC20Votes token weighted by their voting power. The
+	// contract does not support it, so it is stored as an ERC20 census with
+	// the ERC20Votes standard encoded in the census tokens.
+	CensusTypeERC20Votes CensusType = "erc20votes"
+	// CensusTypeEngaged represents the census that includes the voters of the
+	// previous polls of the community that match an engaged rule. The contract
+	// does not support it, so it is stored as a channel census with the rule
+	// encoded as the census channel (see EncodeEngagedRule).
+	CensusTypeEngaged CensusType = "engaged"
+	// CensusTypeMembers represents the census that includes the members of
+	// the list curated by the community admins, which is stored in the
+	// database. It is stored in the contract as a CSV census.
+	CensusTypeMembers CensusType = "members"
 )
 
 const (
@@ -54,6 +69,7 @@ var internalCensusTypes = map[uint8]CensusType{
 	CONTRACT_CENSUS_TYPE_ERC20:     CensusTypeERC20,
 	CONTRACT_CENSUS_TYPE_NFT:       CensusTypeNFT,
 	CONTRACT_CENSUS_TYPE_FOLLOWERS: CensusTypeFollowers,
+	CONTRACT_CENSUS_TYPE_CSV:       CensusTypeMembers,
 }
 
 // contractCensusTypes is the reverse of internalCensusTypes
@@ -62,13 +78,18 @@ var contractCensusTypes = map[CensusType]uint8{
 	CensusTypeERC20:     CONTRACT_CENSUS_TYPE_ERC20,
 	CensusTypeNFT:       CONTRACT_CENSUS_TYPE_NFT,
 	CensusTypeFollowers: CONTRACT_CENSUS_TYPE_FOLLOWERS,
+	CensusTypeMembers:   CONTRACT_CENSUS_TYPE_CSV,
 }
 
 // ContractAddress represents the address of a contract in a certain blockchain,
-// which is included in this struct
+// which is included in this struct. It also includes the standard of the token
+// and the token IDs, if the census only includes the holders of some token IDs
+// of the contract.
 type ContractAddress struct {
 	Blockchain string
 	Address    common.Address
+	Standard   string
+	TokenIDs   []string
 }
 
 // HubCommunity represents a community in the CommunityHub package
@@ -86,7 +107,7 @@ type HubCommunity struct {
 	GroupChatURL   string
 	CensusType     CensusType
 	CensusAddesses []*ContractAddress
-	CensusChannel  string   // channels id or user reference (for follower census type)
+	CensusChannel  string   // channels ids (see EncodeCensusChannels), user reference (for follower census type) or engaged rule (see EncodeEngagedRule)
 	Channels       []string // warpcast channels ids
 	Admins         []uint64 // farcaster users fids
 	Notifications  *bool
@@ -96,6 +117,57 @@ type HubCommunity struct {
 	funds                    *big.Int
 }
 
+// Funds returns the funds of the community in the CommunityHub contract. If
+// they are not defined, it returns zero.
+func (hc *HubCommunity) Funds() *big.Int {
+	if hc.funds == nil {
+		return big.NewInt(0)
+	}
+	return new(big.Int).Set(hc.funds)
+}
+
+// TxReceiptPollInterval is the time between checks of the receipt of a
+// transaction sent to the CommunityHub contract while waiting for it to be
+// mined
+const TxReceiptPollInterval = 2 * time.Second
+
+// GasBumpPercent is the minimum percentage that the fees of a transaction sent
+// to the CommunityHub contract are increased to replace a stuck one
+const GasBumpPercent = 15
+
+// HubTx represents a transaction sent to the CommunityHub contract. It
+// includes its nonce and fees, so it can be replaced by a new one with the
+// same nonce and higher fees if it gets stuck.
+type HubTx struct {
+	Hash      common.Hash
+	Nonce     uint64
+	GasTipCap *big.Int
+	GasFeeCap *big.Int
+	Value     *big.Int
+}
+
+// HubReceipt represents the receipt of a mined transaction sent to the
+// CommunityHub contract. It includes the gas used and its effective price, so
+// the cost of the transaction can be accounted.
+type HubReceipt struct {
+	Hash     common.Hash
+	Block    uint64
+	Success  bool
+	GasUsed  uint64
+	GasPrice *big.Int
+	// ContractID is only defined for the transactions that create a
+	// community, it is the ID assigned to the new community
+	ContractID uint64
+}
+
+// GasCost returns the cost of the gas used by the transaction.
+func (hr *HubReceipt) GasCost() *big.Int {
+	if hr.GasPrice == nil {
+		return big.NewInt(0)
+	}
+	return new(big.Int).Mul(new(big.Int).SetUint64(hr.GasUsed), hr.GasPrice)
+}
+
 // HubResult represents the result of a poll in the CommunityHub
 type HubResults struct {
 	ElectionID       []byte
@@ -111,3 +183,39 @@ type HubResults struct {
 	Disabled         bool
 	VoteCount        *big.Int
 }
+
+const (
+	// EventCommunityCreated is the name of the event emitted by the
+	// CommunityHub contract when a community is created
+	EventCommunityCreated = "CommunityCreated"
+	// EventResultsSet is the name of the event emitted by the CommunityHub
+	// contract when the results of a poll of a community are set
+	EventResultsSet = "ResultsSet"
+)
+
+// communityEvents is the list of the events of the CommunityHub contract that
+// create or update the data of a community
+var communityEvents = []string{
+	EventCommunityCreated,
+	"AdminCommunityManaged",
+	"MetadataSet",
+	"CensusSet",
+	"GuardianAdded",
+	"GuardianRemoved",
+	"CreateElectionPermissionSet",
+	"NotifiableElectionsSet",
+	"CommunityDisabled",
+	"CommunityEnabled",
+}
+
+// HubEvent represents an event of the CommunityHub contract that creates or
+// updates a community, or sets the results of one of its polls
+type HubEvent struct {
+	Name string
+	// ContractID is the unique identifier of the community in the
+	// CommunityHub contract
+	ContractID uint64
+	// ElectionID is only defined for the EventResultsSet events
+	ElectionID []byte
+	Block      uint64
+}
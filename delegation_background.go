@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vocdoni/vote-frame/mongo"
+	"go.vocdoni.io/dvote/log"
+)
+
+const (
+	// delegationExpirySweepInterval is the time between checks for delegations
+	// expired or about to expire.
+	delegationExpirySweepInterval = 10 * time.Minute
+	// delegationExpiryNotice is how long before the expiration of a delegation
+	// both parties of it are notified.
+	delegationExpiryNotice = 24 * time.Hour
+)
+
+// sweepDelegationsExpiryAtBackground periodically registers the expiration of
+// the delegations whose validity period has ended, notifying the webhooks of
+// their communities. If notify is true, it also enqueues a notification for
+// both the delegator and the delegate of the delegations that are about to
+// expire. Every delegation is notified only once. It must run in the
+// background.
+func sweepDelegationsExpiryAtBackground(ctx context.Context, v *vocdoniHandler, notify bool) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delegationExpirySweepInterval):
+			v.registerExpiredDelegations()
+			if notify {
+				v.notifyExpiringDelegations()
+			}
+		}
+	}
+}
+
+// registerExpiredDelegations registers the expiration of the delegations whose
+// validity period has ended in the delegations history and notifies it to the
+// webhooks of their communities.
+func (v *vocdoniHandler) registerExpiredDelegations() {
+	delegations, err := v.db.ExpiredDelegations()
+	if err != nil {
+		log.Warnw("failed to get expired delegations", "error", err)
+		return
+	}
+	for _, delegation := range delegations {
+		registered, err := v.db.SetDelegationExpired(delegation)
+		if err != nil {
+			log.Warnw("failed to register delegation expiration",
+				"delegation", delegation.ID.Hex(), "error", err)
+			continue
+		}
+		if registered {
+			v.emitDelegationChanged(mongo.DelegationEventExpire, delegation.ID.Hex(), delegation, 0)
+		}
+	}
+}
+
+// notifyExpiringDelegations enqueues a notification for both parties of the
+// delegations that are about to expire and have not been notified yet.
+func (v *vocdoniHandler) notifyExpiringDelegations() {
+	delegations, err := v.db.DelegationsExpiringBefore(time.Now().Add(delegationExpiryNotice))
+	if err != nil {
+		log.Warnw("failed to get expiring delegations", "error", err)
+		return
+	}
+	for _, delegation := range delegations {
+		if err := v.notifyDelegationExpiry(delegation); err != nil {
+			log.Warnw("failed to notify delegation expiry",
+				"delegation", delegation.ID.Hex(), "error", err)
+			continue
+		}
+		if err := v.db.SetDelegationExpiryNotified(delegation.ID); err != nil {
+			log.Warnw("failed to mark delegation expiry as notified",
+				"delegation", delegation.ID.Hex(), "error", err)
+		}
+	}
+}
+
+// notifyDelegationExpiry enqueues a notification for both parties of the
+// delegation provided about its upcoming expiration.
+func (v *vocdoniHandler) notifyDelegationExpiry(delegation mongo.Delegation) error {
+	from, err := v.db.User(delegation.From)
+	if err != nil {
+		return fmt.Errorf("failed to get delegator: %w", err)
+	}
+	to, err := v.db.User(delegation.To)
+	if err != nil {
+		return fmt.Errorf("failed to get delegate: %w", err)
+	}
+	community, err := v.db.Community(delegation.CommuniyID)
+	if err != nil {
+		return fmt.Errorf("failed to get community: %w", err)
+	}
+	if community == nil {
+		return fmt.Errorf("community not found")
+	}
+	expiry := delegation.ValidUntil.UTC().Format(time.RFC1123)
+	// the notifications are kept until the delegation expires, after that
+	// they are useless
+	deadline := *delegation.ValidUntil
+	fromText := fmt.Sprintf("Your vote delegation to %s for the community \"%s\" expires on %s. "+
+		"Delegate again if you want %s to keep voting on your behalf.",
+		to.Username, community.Name, expiry, to.Username)
+	if _, err := v.db.AddNotifications(mongo.NotificationTypeDelegationExpiry, "",
+		from.UserID, to.UserID, community.ID, from.Username, to.Username, community.Name,
+		"", fromText, deadline,
+	); err != nil {
+		return fmt.Errorf("failed to add delegator notification: %w", err)
+	}
+	toText := fmt.Sprintf("The vote delegation you received from %s for the community \"%s\" expires on %s.",
+		from.Username, community.Name, expiry)
+	if _, err := v.db.AddNotifications(mongo.NotificationTypeDelegationExpiry, "",
+		to.UserID, from.UserID, community.ID, to.Username, from.Username, community.Name,
+		"", toText, deadline,
+	); err != nil {
+		return fmt.Errorf("failed to add delegate notification: %w", err)
+	}
+	return nil
+}
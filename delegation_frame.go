@@ -0,0 +1,352 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vocdoni/vote-frame/imageframe"
+	"github.com/vocdoni/vote-frame/mongo"
+	"go.vocdoni.io/dvote/httprouter"
+	"go.vocdoni.io/dvote/httprouter/apirest"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/vochain/transaction/proofs/farcasterproof"
+	farcasterpb "go.vocdoni.io/dvote/vochain/transaction/proofs/farcasterproof/proto"
+)
+
+const (
+	// delegationFrameStatePrefix is the prefix of the state of the
+	// delegation frames, followed by the path that identifies the frame
+	delegationFrameStatePrefix = "delegation:"
+	// delegationFrameMaxAge is the maximum time between the signature of an
+	// action of the delegation frame and its processing
+	delegationFrameMaxAge = 10 * time.Minute
+	// farcasterEpoch is the unix time of the farcaster epoch, the timestamps
+	// of the farcaster messages are relative to it
+	farcasterEpoch int64 = 1609459200
+)
+
+// delegationFrameTarget gets the community and the delegate of a delegation
+// frame from the URL. It also returns the path that identifies the frame, to
+// be used in the post URLs of the frames.
+func (v *vocdoniHandler) delegationFrameTarget(ctx *httprouter.HTTPContext) (*mongo.Community, *mongo.User, string, error) {
+	communityID, _, _, err := v.parseCommunityIDFromURL(ctx)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	delegateFID, err := strconv.ParseUint(ctx.URLParam("delegateFID"), 10, 64)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("invalid delegate FID: %w", err)
+	}
+	community, err := v.db.Community(communityID)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to get community: %w", err)
+	}
+	if community == nil || community.Disabled {
+		return nil, nil, "", fmt.Errorf("community not found")
+	}
+	delegate, err := v.db.User(delegateFID)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to get delegate: %w", err)
+	}
+	path := fmt.Sprintf("%s:%s/%d", ctx.URLParam("chainAlias"), ctx.URLParam("communityID"), delegateFID)
+	return community, delegate, path, nil
+}
+
+// delegationFrameHandler shows the frame to delegate the voting power in a
+// community to the user provided in the URL.
+func (v *vocdoniHandler) delegationFrameHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	community, delegate, path, err := v.delegationFrameTarget(ctx)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), apirest.HTTPstatusBadRequest)
+	}
+	png, err := imageframe.InfoImage([]string{
+		fmt.Sprintf("Delegate your vote to @%s", delegate.Username),
+		fmt.Sprintf("Community: %s", community.Name),
+		fmt.Sprintf("@%s will vote on your behalf in the community polls", delegate.Username),
+		"You can still vote by yourself to override the delegation",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create image: %w", err)
+	}
+	response := strings.ReplaceAll(frame(frameDelegation), "{image}", imageLink(png))
+	response = strings.ReplaceAll(response, "{title}",
+		fmt.Sprintf("Delegate to @%s in %s", delegate.Username, community.Name))
+	response = strings.ReplaceAll(response, "{delegation}", path)
+	response = strings.ReplaceAll(response, "{state}", delegationFrameState(path))
+	ctx.SetResponseContentType("text/html; charset=utf-8")
+	return ctx.Send([]byte(response), http.StatusOK)
+}
+
+// delegationFrameState returns the state of the delegation frame with the
+// path provided, which identifies its community and delegate.
+func delegationFrameState(path string) string {
+	return delegationFrameStatePrefix + path
+}
+
+// verifyDelegationFrameAction verifies the signature of the frame action
+// provided and checks that it was signed in the delegation frame with the
+// path provided, so the actions signed in other frames cannot be replayed to
+// delegate the voting power of the signer. Both, the state and the URL of the
+// frame must match the path, and the action must be signed up to
+// delegationFrameMaxAge before the time provided. Since anyone can sign a
+// message that claims any FID, the key that signed it must also be one of the
+// signers of the FID according to the isSigner function provided. It returns
+// the action and the FID of the signer.
+func verifyDelegationFrameAction(messageBytes []byte, path string, now time.Time,
+	isSigner func(fid uint64, signer []byte) (bool, error),
+) (*farcasterpb.FrameActionBody, uint64, error) {
+	actionMessage, pubkey, fid, err := farcasterproof.VerifyFrameSignature(messageBytes)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to verify frame signature: %w", err)
+	}
+	_, message, err := farcasterproof.DecodeMessage(messageBytes)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode frame message: %w", err)
+	}
+	if string(actionMessage.State) != delegationFrameState(path) {
+		return nil, 0, fmt.Errorf("frame action not signed in this delegation frame")
+	}
+	frameURL, err := url.Parse(string(actionMessage.Url))
+	if err != nil || !strings.HasSuffix(frameURL.Path, "/delegation/"+path) {
+		return nil, 0, fmt.Errorf("frame action not signed in this delegation frame")
+	}
+	signedAt := time.Unix(farcasterEpoch+int64(message.Data.Timestamp), 0)
+	if now.Sub(signedAt) > delegationFrameMaxAge || signedAt.Sub(now) > delegationFrameMaxAge {
+		return nil, 0, fmt.Errorf("frame action expired")
+	}
+	signer, err := isSigner(fid, pubkey)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to check frame signer: %w", err)
+	}
+	if !signer {
+		return nil, 0, fmt.Errorf("frame action not signed by a signer of the user")
+	}
+	return actionMessage, fid, nil
+}
+
+// isUserSigner returns true if the signer provided is one of the signers (app
+// keys) of the user with the FID provided. If it is not one of the signers
+// stored in the database, they are refreshed from the farcaster API, since
+// the user could have added it recently.
+func (v *vocdoniHandler) isUserSigner(fid uint64, signer []byte) (bool, error) {
+	user, err := v.db.User(fid)
+	if err != nil && !errors.Is(err, mongo.ErrUserUnknown) {
+		return false, err
+	}
+	if user != nil && containsSigner(user.Signers, signer) {
+		return true, nil
+	}
+	if v.fcapi == nil {
+		return false, nil
+	}
+	signers, err := v.fcapi.SignersFromFID(fid)
+	if err != nil {
+		return false, fmt.Errorf("cannot get signers of the user: %w", err)
+	}
+	if user != nil {
+		user.Signers = signers
+		if err := v.db.UpdateUser(user); err != nil {
+			log.Warnw("cannot update signers of the user", "fid", fid, "error", err)
+		}
+	}
+	return containsSigner(signers, signer), nil
+}
+
+// containsSigner returns true if the signer provided is in the list of hex
+// encoded signers provided, with or without the 0x prefix.
+func containsSigner(signers []string, signer []byte) bool {
+	for _, s := range signers {
+		if strings.EqualFold(strings.TrimPrefix(s, "0x"), hex.EncodeToString(signer)) {
+			return true
+		}
+	}
+	return false
+}
+
+// delegationFrameResponseHandler handles the buttons of the delegation frame.
+// The first button delegates the voting power of the user that signed the
+// frame action to the delegate of the frame, the second one revokes that
+// delegation and the third one only shows the current delegates of the user.
+func (v *vocdoniHandler) delegationFrameResponseHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	community, delegate, path, err := v.delegationFrameTarget(ctx)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), apirest.HTTPstatusBadRequest)
+	}
+	packet := &FrameSignaturePacket{}
+	if err := json.Unmarshal(msg.Data, packet); err != nil {
+		return fmt.Errorf("failed to unmarshal frame signature packet: %w", err)
+	}
+	messageBytes, err := hex.DecodeString(packet.TrustedData.MessageBytes)
+	if err != nil {
+		return fmt.Errorf("failed to decode message bytes: %w", err)
+	}
+	actionMessage, fid, err := verifyDelegationFrameAction(messageBytes, path, time.Now(), v.isUserSigner)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), apirest.HTTPstatusBadRequest)
+	}
+
+	sendResponse := func(png string) error {
+		response := strings.ReplaceAll(frame(frameDelegationResponse), "{image}", imageLink(png))
+		response = strings.ReplaceAll(response, "{title}",
+			fmt.Sprintf("Delegate to @%s in %s", delegate.Username, community.Name))
+		response = strings.ReplaceAll(response, "{delegation}", path)
+		ctx.SetResponseContentType("text/html; charset=utf-8")
+		return ctx.Send([]byte(response), http.StatusOK)
+	}
+	sendError := func(err error) error {
+		log.Warnw("failed to handle delegation frame", "fid", fid, "delegate", delegate.UserID,
+			"community", community.ID, "error", err)
+		png, err := imageframe.ErrorImage(err.Error())
+		if err != nil {
+			return fmt.Errorf("failed to create image: %w", err)
+		}
+		return sendResponse(png)
+	}
+
+	var outcome string
+	switch actionMessage.ButtonIndex {
+	case 1:
+		if err := v.delegateFromFrame(fid, delegate.UserID, community.ID); err != nil {
+			return sendError(err)
+		}
+		log.Infow("vote delegated from frame", "fid", fid, "delegate", delegate.UserID, "community", community.ID)
+		outcome = fmt.Sprintf("You delegated your vote to @%s", delegate.Username)
+	case 2:
+		if err := v.revokeDelegationFromFrame(fid, delegate.UserID, community.ID); err != nil {
+			return sendError(err)
+		}
+		log.Infow("vote delegation revoked from frame", "fid", fid, "delegate", delegate.UserID, "community", community.ID)
+		outcome = fmt.Sprintf("You revoked your delegation to @%s", delegate.Username)
+	}
+	status, err := v.delegationStatusLines(fid, community)
+	if err != nil {
+		return sendError(err)
+	}
+	if outcome != "" {
+		status = append([]string{outcome}, status...)
+	}
+	png, err := imageframe.InfoImage(status)
+	if err != nil {
+		return fmt.Errorf("failed to create image: %w", err)
+	}
+	return sendResponse(png)
+}
+
+// currentDelegationsFrom returns the delegations of the user provided in the
+// community provided that are not expired yet.
+func (v *vocdoniHandler) currentDelegationsFrom(userFID uint64, communityID string) ([]mongo.Delegation, error) {
+	delegations, err := v.db.DelegationsFrom(userFID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get delegations")
+	}
+	current := []mongo.Delegation{}
+	for _, delegation := range delegations {
+		if delegation.CommuniyID == communityID &&
+			(delegation.ValidUntil == nil || delegation.ValidUntil.After(time.Now())) {
+			current = append(current, delegation)
+		}
+	}
+	return current, nil
+}
+
+// delegateFromFrame delegates the weight that the user provided has not
+// delegated yet in the community provided to the delegate provided.
+func (v *vocdoniHandler) delegateFromFrame(userFID, delegateFID uint64, communityID string) error {
+	if userFID == delegateFID {
+		return fmt.Errorf("cannot delegate to yourself")
+	}
+	delegations, err := v.currentDelegationsFrom(userFID, communityID)
+	if err != nil {
+		return err
+	}
+	delegatedShare := uint32(0)
+	for _, delegation := range delegations {
+		if delegation.To == delegateFID {
+			return fmt.Errorf("vote already delegated to this user")
+		}
+		delegatedShare += delegation.Shared()
+	}
+	if delegatedShare >= mongo.DelegationFullShare {
+		return fmt.Errorf("all your voting power is already delegated in this community")
+	}
+	delegation := mongo.Delegation{
+		From:       userFID,
+		To:         delegateFID,
+		CommuniyID: communityID,
+	}
+	// delegate only the remaining weight if the user already delegated part
+	// of it to someone else
+	if delegatedShare > 0 {
+		delegation.Share = mongo.DelegationFullShare - delegatedShare
+	}
+	delegationID, err := v.db.SetDelegation(delegation)
+	if err != nil {
+		if errors.Is(err, mongo.ErrSelfDelegation) || errors.Is(err, mongo.ErrDelegationCycle) ||
+			errors.Is(err, mongo.ErrDelegationTooDeep) {
+			return err
+		}
+		return fmt.Errorf("could not delegate vote")
+	}
+	go v.emitDelegationChanged(mongo.DelegationEventCreate, delegationID, delegation, userFID)
+	return nil
+}
+
+// revokeDelegationFromFrame removes the delegations of the user provided to
+// the delegate provided in the community provided.
+func (v *vocdoniHandler) revokeDelegationFromFrame(userFID, delegateFID uint64, communityID string) error {
+	delegations, err := v.currentDelegationsFrom(userFID, communityID)
+	if err != nil {
+		return err
+	}
+	revoked := false
+	for _, delegation := range delegations {
+		if delegation.To != delegateFID {
+			continue
+		}
+		if err := v.db.DeleteDelegation(delegation.ID.Hex(), userFID); err != nil {
+			return fmt.Errorf("could not remove delegation")
+		}
+		go v.emitDelegationChanged(mongo.DelegationEventRevoke, delegation.ID.Hex(), delegation, userFID)
+		revoked = true
+	}
+	if !revoked {
+		return fmt.Errorf("vote not delegated to this user")
+	}
+	return nil
+}
+
+// delegationStatusLines returns the lines of the status image of the
+// delegation frame, that lists the current delegates of the user provided in
+// the community provided.
+func (v *vocdoniHandler) delegationStatusLines(userFID uint64, community *mongo.Community) ([]string, error) {
+	delegations, err := v.currentDelegationsFrom(userFID, community.ID)
+	if err != nil {
+		return nil, err
+	}
+	lines := []string{fmt.Sprintf("Community: %s", community.Name)}
+	if len(delegations) == 0 {
+		return append(lines, "You have not delegated your vote"), nil
+	}
+	for _, delegation := range delegations {
+		delegateName := fmt.Sprintf("FID %d", delegation.To)
+		if delegate, err := v.db.User(delegation.To); err == nil {
+			delegateName = "@" + delegate.Username
+		}
+		line := fmt.Sprintf("Your delegate: %s (%.2f%%)", delegateName,
+			float64(delegation.Shared())*100/float64(mongo.DelegationFullShare))
+		if !delegation.Active(time.Now()) {
+			line += fmt.Sprintf(", from %s", delegation.ValidFrom.UTC().Format(time.DateOnly))
+		} else if delegation.ValidUntil != nil {
+			line += fmt.Sprintf(", until %s", delegation.ValidUntil.UTC().Format(time.DateOnly))
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
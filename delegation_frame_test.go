@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/zeebo/blake3"
+	farcasterpb "go.vocdoni.io/dvote/vochain/transaction/proofs/farcasterproof/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// signFrameAction builds a frame action message of the fid provided, pressing
+// the first button of the frame with the URL and the state provided at the
+// time provided, and signs it with the key provided.
+func signFrameAction(t *testing.T, privateKey ed25519.PrivateKey, fid uint64, frameURL, state string,
+	at time.Time,
+) []byte {
+	t.Helper()
+	msgData := &farcasterpb.MessageData{
+		Type:      farcasterpb.MessageType_MESSAGE_TYPE_FRAME_ACTION,
+		Fid:       fid,
+		Timestamp: uint32(at.Unix() - farcasterEpoch),
+		Network:   farcasterpb.FarcasterNetwork_FARCASTER_NETWORK_MAINNET,
+		Body: &farcasterpb.MessageData_FrameActionBody{FrameActionBody: &farcasterpb.FrameActionBody{
+			Url:         []byte(frameURL),
+			ButtonIndex: 1,
+			State:       []byte(state),
+		}},
+	}
+	msgDataBytes, err := proto.Marshal(msgData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasher := blake3.New()
+	hasher.Write(msgDataBytes)
+	hash := hasher.Sum(nil)[:20]
+	msgBytes, err := proto.Marshal(&farcasterpb.Message{
+		Data:            msgData,
+		Hash:            hash,
+		HashScheme:      farcasterpb.HashScheme_HASH_SCHEME_BLAKE3,
+		Signature:       ed25519.Sign(privateKey, hash),
+		SignatureScheme: farcasterpb.SignatureScheme_SIGNATURE_SCHEME_ED25519,
+		Signer:          privateKey.Public().(ed25519.PublicKey),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msgBytes
+}
+
+func Test_verifyDelegationFrameAction(t *testing.T) {
+	const path = "base:12/345"
+	now := time.Now()
+	frameURL := "https://farcaster.vote/delegation/" + path
+	_, userKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, foreignKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the key of the user is a signer of its FID
+	signers := map[uint64][]string{
+		100: {"0x" + hex.EncodeToString(userKey.Public().(ed25519.PublicKey))},
+	}
+	isSigner := func(fid uint64, signer []byte) (bool, error) {
+		return containsSigner(signers[fid], signer), nil
+	}
+	tests := []struct {
+		name     string
+		key      ed25519.PrivateKey
+		url      string
+		state    string
+		at       time.Time
+		expectOk bool
+	}{
+		{
+			name:     "signed in the delegation frame",
+			url:      frameURL,
+			state:    delegationFrameState(path),
+			at:       now.Add(-time.Minute),
+			expectOk: true,
+		},
+		{
+			name:  "signed by a key that is not a signer of the user",
+			key:   foreignKey,
+			url:   frameURL,
+			state: delegationFrameState(path),
+			at:    now.Add(-time.Minute),
+		},
+		{
+			name:  "signed in a poll frame",
+			url:   "https://farcaster.vote/0123456789abcdef",
+			state: "",
+			at:    now.Add(-time.Minute),
+		},
+		{
+			name:  "signed in the delegation frame of another delegate",
+			url:   "https://farcaster.vote/delegation/base:12/678",
+			state: delegationFrameState("base:12/678"),
+			at:    now.Add(-time.Minute),
+		},
+		{
+			name:  "state of the frame in another frame",
+			url:   "https://farcaster.vote/0123456789abcdef",
+			state: delegationFrameState(path),
+			at:    now.Add(-time.Minute),
+		},
+		{
+			name:  "url of the frame without its state",
+			url:   frameURL,
+			state: "",
+			at:    now.Add(-time.Minute),
+		},
+		{
+			name:  "stale action",
+			url:   frameURL,
+			state: delegationFrameState(path),
+			at:    now.Add(-delegationFrameMaxAge - time.Minute),
+		},
+		{
+			name:  "action from the future",
+			url:   frameURL,
+			state: delegationFrameState(path),
+			at:    now.Add(delegationFrameMaxAge + time.Minute),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			key := userKey
+			if tc.key != nil {
+				key = tc.key
+			}
+			msg := signFrameAction(t, key, 100, tc.url, tc.state, tc.at)
+			action, fid, err := verifyDelegationFrameAction(msg, path, now, isSigner)
+			if !tc.expectOk {
+				if err == nil {
+					t.Fatal("expected the frame action to be rejected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fid != 100 || action.ButtonIndex != 1 {
+				t.Errorf("unexpected action: fid %d, button %d", fid, action.ButtonIndex)
+			}
+		})
+	}
+}
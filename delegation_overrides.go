@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/vocdoni/vote-frame/mongo"
+	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/types"
+)
+
+// attributedCensusDelegations returns the weight that every delegate of the
+// unique participants provided received from each of its delegators, sorted by
+// delegate and delegator. It also returns the total of that weight delegated
+// by delegators that are participants of the census too, which is counted
+// twice in the census since the delegators keep their own weight.
+func attributedCensusDelegations(participants []*FarcasterParticipant) ([]mongo.CensusDelegation, *big.Int) {
+	included := map[uint64]bool{}
+	for _, p := range participants {
+		included[p.FID] = true
+	}
+	delegations := []mongo.CensusDelegation{}
+	doubleCounted := new(big.Int)
+	for _, p := range participants {
+		for from, weight := range p.Delegated {
+			delegations = append(delegations, mongo.CensusDelegation{
+				From:   from,
+				To:     p.FID,
+				Weight: weight.String(),
+			})
+			if included[from] {
+				doubleCounted.Add(doubleCounted, weight)
+			}
+		}
+	}
+	sort.Slice(delegations, func(i, j int) bool {
+		if delegations[i].To != delegations[j].To {
+			return delegations[i].To < delegations[j].To
+		}
+		return delegations[i].From < delegations[j].From
+	})
+	return delegations, doubleCounted
+}
+
+// voteDelegated returns true if the user provided can not vote in the
+// election provided because it has delegated all its weight in the community
+// of the election. Only the censuses built before the delegations were
+// attributed block the delegators, since in the rest of them the delegators
+// can override their delegations voting by themselves.
+func (v *vocdoniHandler) voteDelegated(electionID types.HexBytes, communityID string, userFID uint64) bool {
+	census, err := v.db.CensusFromElection(electionID)
+	if err == nil && census.DelegationsAttributed {
+		return false
+	}
+	delegations, err := v.db.DelegationsByCommunityFrom(communityID, userFID)
+	if err != nil {
+		log.Warnw("failed to fetch delegations", "error", err)
+		return false
+	}
+	return mongo.DelegatedShare(delegations, userFID) >= mongo.DelegationFullShare
+}
+
+// recordDelegationVote stores the choices of the voter provided if it is a
+// delegator or a delegate in the census of the election provided, so the
+// delegation overrides can be solved when the results are computed.
+func (v *vocdoniHandler) recordDelegationVote(electionID types.HexBytes, userFID uint64, choices []int) {
+	census, err := v.db.CensusFromElection(electionID)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrElectionUnknown) {
+			log.Warnw("failed to fetch election census", "error", err)
+		}
+		return
+	}
+	for _, delegation := range census.Delegations {
+		if delegation.From == userFID || delegation.To == userFID {
+			if err := v.db.SetVoterChoices(electionID, userFID, choices); err != nil {
+				log.Warnw("failed to store voter choices", "error", err)
+			}
+			return
+		}
+	}
+}
+
+// delegationOverrideCorrections returns the weight that must be substracted
+// from every choice of every question of the results because of the
+// delegation overrides, and from the casted weight of the election. When both
+// the delegator and the delegate of a delegation have voted, the weight
+// delegated has been counted twice, so it is substracted from the choices of
+// the delegate and from the casted weight. The number of choices of every
+// question is provided. Since it only depends on the final choices, the
+// result is the same regardless of who voted first.
+func delegationOverrideCorrections(delegations []mongo.CensusDelegation, choices map[uint64][]int,
+	numChoices []int,
+) ([][]*big.Int, *big.Int) {
+	corrections := make([][]*big.Int, len(numChoices))
+	for question, n := range numChoices {
+		corrections[question] = make([]*big.Int, n)
+		for i := range corrections[question] {
+			corrections[question][i] = new(big.Int)
+		}
+	}
+	castedWeight := new(big.Int)
+	for _, delegation := range delegations {
+		if _, ok := choices[delegation.From]; !ok {
+			continue
+		}
+		delegateChoices, ok := choices[delegation.To]
+		if !ok {
+			continue
+		}
+		weight, ok := new(big.Int).SetString(delegation.Weight, 10)
+		if !ok {
+			continue
+		}
+		castedWeight.Add(castedWeight, weight)
+		for question, choice := range delegateChoices {
+			if question >= len(corrections) || choice < 0 || choice >= len(corrections[question]) {
+				continue
+			}
+			corrections[question][choice].Add(corrections[question][choice], weight)
+		}
+	}
+	return corrections, castedWeight
+}
+
+// electionDelegationOverrides returns the attributed delegations of the
+// census of the election provided and the choices stored of the voters
+// involved in them. If there are no delegations or no choices, it returns
+// false, since there is nothing to correct.
+func (v *vocdoniHandler) electionDelegationOverrides(electionID types.HexBytes) (
+	[]mongo.CensusDelegation, map[uint64][]int, bool,
+) {
+	census, err := v.db.CensusFromElection(electionID)
+	if err != nil || len(census.Delegations) == 0 {
+		return nil, nil, false
+	}
+	choices, err := v.db.VoterChoices(electionID)
+	if err != nil || len(choices) == 0 {
+		return nil, nil, false
+	}
+	return census.Delegations, choices, true
+}
+
+// applyDelegationOverrides returns a copy of the election provided with the
+// results of every question corrected by the delegation overrides of its
+// census. If the census has no attributed delegations or no delegator has
+// overridden its delegation, the election is returned as it is. The corrected
+// results are the authoritative ones: they are displayed, stored and settled
+// in the community hub, while the results of the Vocdoni chain keep counting
+// twice the overridden weight, so they are only published as the raw ones.
+func (v *vocdoniHandler) applyDelegationOverrides(election *api.Election) *api.Election {
+	if election == nil || len(election.Results) == 0 {
+		return election
+	}
+	delegations, choices, ok := v.electionDelegationOverrides(election.ElectionID)
+	if !ok {
+		return election
+	}
+	numChoices := make([]int, len(election.Results))
+	for question, results := range election.Results {
+		numChoices[question] = len(results)
+	}
+	corrections, _ := delegationOverrideCorrections(delegations, choices, numChoices)
+	corrected := *election
+	corrected.Results = correctDelegationOverrides(election.Results, corrections)
+	return &corrected
+}
+
+// correctDelegationOverrides returns a copy of the results provided with the
+// corrections provided substracted. The results never get negative.
+func correctDelegationOverrides(results [][]*types.BigInt, corrections [][]*big.Int) [][]*types.BigInt {
+	corrected := make([][]*types.BigInt, len(results))
+	for question, questionResults := range results {
+		corrected[question] = make([]*types.BigInt, len(questionResults))
+		for i, result := range questionResults {
+			value := new(big.Int)
+			if result != nil {
+				value.Set(result.MathBigInt())
+			}
+			value.Sub(value, corrections[question][i])
+			if value.Sign() < 0 {
+				value.SetUint64(0)
+			}
+			corrected[question][i] = (*types.BigInt)(value)
+		}
+	}
+	return corrected
+}
+
+// applyCastedWeightOverrides returns a copy of the election provided with its
+// casted weight corrected by the delegation overrides of its census, since
+// the weight delegated is counted twice if both the delegator and the
+// delegate vote. If there is nothing to correct, the election is returned as
+// it is.
+func (v *vocdoniHandler) applyCastedWeightOverrides(electiondb *mongo.Election) *mongo.Election {
+	if electiondb == nil {
+		return nil
+	}
+	electionID, err := hex.DecodeString(electiondb.ElectionID)
+	if err != nil {
+		return electiondb
+	}
+	delegations, choices, ok := v.electionDelegationOverrides(electionID)
+	if !ok {
+		return electiondb
+	}
+	_, correction := delegationOverrideCorrections(delegations, choices, nil)
+	castedWeight, ok := new(big.Int).SetString(electiondb.CastedWeight, 10)
+	if !ok || correction.Sign() == 0 {
+		return electiondb
+	}
+	castedWeight.Sub(castedWeight, correction)
+	if castedWeight.Sign() < 0 {
+		castedWeight.SetUint64(0)
+	}
+	corrected := *electiondb
+	corrected.CastedWeight = castedWeight.String()
+	return &corrected
+}
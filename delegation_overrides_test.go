@@ -0,0 +1,169 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/vocdoni/vote-frame/mongo"
+	"go.vocdoni.io/dvote/types"
+)
+
+// overrideVote is a vote of a participant of the tests of the delegation
+// overrides, with its choice for every question
+type overrideVote struct {
+	fid     uint64
+	choices []int
+}
+
+func Test_delegationOverrideCorrections(t *testing.T) {
+	tests := []struct {
+		name string
+		// own weight of every participant
+		weights map[uint64]int64
+		// attributed delegations, already solved if they are chained
+		delegations  []mongo.CensusDelegation
+		numChoices   []int
+		votes        []overrideVote
+		expected     [][]int64
+		castedWeight int64
+	}{
+		{
+			name:    "delegate votes first",
+			weights: map[uint64]int64{1: 10, 2: 5},
+			delegations: []mongo.CensusDelegation{
+				{From: 1, To: 2, Weight: "10"},
+			},
+			numChoices:   []int{2},
+			votes:        []overrideVote{{2, []int{0}}, {1, []int{1}}},
+			expected:     [][]int64{{5, 10}},
+			castedWeight: 15,
+		},
+		{
+			name:    "delegator votes first",
+			weights: map[uint64]int64{1: 10, 2: 5},
+			delegations: []mongo.CensusDelegation{
+				{From: 1, To: 2, Weight: "10"},
+			},
+			numChoices:   []int{2},
+			votes:        []overrideVote{{1, []int{1}}, {2, []int{0}}},
+			expected:     [][]int64{{5, 10}},
+			castedWeight: 15,
+		},
+		{
+			name:    "delegator does not vote",
+			weights: map[uint64]int64{1: 10, 2: 5},
+			delegations: []mongo.CensusDelegation{
+				{From: 1, To: 2, Weight: "10"},
+			},
+			numChoices:   []int{2},
+			votes:        []overrideVote{{2, []int{0}}},
+			expected:     [][]int64{{15, 0}},
+			castedWeight: 15,
+		},
+		{
+			name:    "partial share",
+			weights: map[uint64]int64{1: 10, 2: 5},
+			delegations: []mongo.CensusDelegation{
+				{From: 1, To: 2, Weight: "5"},
+			},
+			numChoices:   []int{2},
+			votes:        []overrideVote{{1, []int{1}}, {2, []int{0}}},
+			expected:     [][]int64{{5, 10}},
+			castedWeight: 15,
+		},
+		{
+			name:    "split delegation",
+			weights: map[uint64]int64{1: 10, 2: 5, 3: 1},
+			delegations: []mongo.CensusDelegation{
+				{From: 1, To: 2, Weight: "4"},
+				{From: 1, To: 3, Weight: "6"},
+			},
+			numChoices:   []int{3},
+			votes:        []overrideVote{{2, []int{0}}, {3, []int{1}}, {1, []int{2}}},
+			expected:     [][]int64{{5, 1, 10}},
+			castedWeight: 16,
+		},
+		{
+			name:    "chained delegations",
+			weights: map[uint64]int64{1: 10, 2: 5, 3: 1},
+			// 1 delegates to 2, which delegates to 3
+			delegations: []mongo.CensusDelegation{
+				{From: 1, To: 3, Weight: "10"},
+				{From: 2, To: 3, Weight: "5"},
+			},
+			numChoices:   []int{3},
+			votes:        []overrideVote{{3, []int{0}}, {1, []int{1}}, {2, []int{2}}},
+			expected:     [][]int64{{1, 10, 5}},
+			castedWeight: 16,
+		},
+		{
+			name:    "chained delegations with intermediate delegate not voting",
+			weights: map[uint64]int64{1: 10, 2: 5, 3: 1},
+			delegations: []mongo.CensusDelegation{
+				{From: 1, To: 3, Weight: "10"},
+				{From: 2, To: 3, Weight: "5"},
+			},
+			numChoices:   []int{2},
+			votes:        []overrideVote{{1, []int{1}}, {3, []int{0}}},
+			expected:     [][]int64{{6, 10}},
+			castedWeight: 16,
+		},
+		{
+			name:    "several questions",
+			weights: map[uint64]int64{1: 10, 2: 5},
+			delegations: []mongo.CensusDelegation{
+				{From: 1, To: 2, Weight: "10"},
+			},
+			numChoices:   []int{2, 3},
+			votes:        []overrideVote{{2, []int{0, 2}}, {1, []int{1, 2}}},
+			expected:     [][]int64{{5, 10}, {0, 0, 15}},
+			castedWeight: 15,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// the delegates receive the weight of their delegators, which
+			// keep their own weight too
+			leafWeights := map[uint64]int64{}
+			for fid, weight := range tc.weights {
+				leafWeights[fid] += weight
+			}
+			for _, delegation := range tc.delegations {
+				weight, _ := new(big.Int).SetString(delegation.Weight, 10)
+				leafWeights[delegation.To] += weight.Int64()
+			}
+			// cast the votes in order
+			results := make([][]*types.BigInt, len(tc.numChoices))
+			for question, n := range tc.numChoices {
+				results[question] = make([]*types.BigInt, n)
+				for i := range results[question] {
+					results[question][i] = new(types.BigInt)
+				}
+			}
+			castedWeight := new(big.Int)
+			choices := map[uint64][]int{}
+			for _, vote := range tc.votes {
+				weight := big.NewInt(leafWeights[vote.fid])
+				for question, choice := range vote.choices {
+					value := new(big.Int).Add(results[question][choice].MathBigInt(), weight)
+					results[question][choice] = (*types.BigInt)(value)
+				}
+				castedWeight.Add(castedWeight, weight)
+				choices[vote.fid] = vote.choices
+			}
+			corrections, castedCorrection := delegationOverrideCorrections(tc.delegations, choices, tc.numChoices)
+			corrected := correctDelegationOverrides(results, corrections)
+			for question, expected := range tc.expected {
+				for i, value := range expected {
+					if got := corrected[question][i].MathBigInt().Int64(); got != value {
+						t.Errorf("unexpected result of choice %d of question %d: expected %d, got %d",
+							i, question, value, got)
+					}
+				}
+			}
+			if got := castedWeight.Sub(castedWeight, castedCorrection).Int64(); got != tc.castedWeight {
+				t.Errorf("unexpected casted weight: expected %d, got %d", tc.castedWeight, got)
+			}
+		})
+	}
+}
@@ -76,9 +76,10 @@ func (v *vocdoniHandler) createElection(msg *apirest.APIdata, ctx *httprouter.HT
 	// if the poll is for a community, check if the user is an admin of the
 	// community and if the community is disabled
 	if req.CommunityID != nil {
-		// check if the user is an admin of the community
-		if !v.db.IsCommunityAdmin(fid, *req.CommunityID) {
-			return fmt.Errorf("user is not an admin of the community")
+		// check if the user has a role in the community that allows to
+		// create polls
+		if !v.db.HasCommunityPermission(fid, *req.CommunityID, mongo.PermissionCreatePoll) {
+			return ctx.Send([]byte("user is not allowed to create polls in the community"), http.StatusForbidden)
 		}
 		// check if the community is disabled
 		if v.db.IsCommunityDisabled(*req.CommunityID) {
@@ -99,10 +100,21 @@ func (v *vocdoniHandler) createElection(msg *apirest.APIdata, ctx *httprouter.HT
 			return fmt.Errorf("community does not allow notifications")
 		}
 	}
-	// use the request census or use the one hardcoded for all farcaster users
+	// use the request census or use the one hardcoded for all farcaster users,
+	// the census of all farcaster users is limited to the maximum census size
+	// of the user or the community, the rest of censuses are rejected if they
+	// are larger than it
+	communityID := ""
+	if req.CommunityID != nil {
+		communityID = *req.CommunityID
+	}
+	maxCensusSize := v.maxCensusSize(fid, communityID)
 	census := req.Census
 	if census == nil {
-		census = v.defaultCensus
+		census = cappedCensus(v.defaultCensus, maxCensusSize)
+	} else if census.Size > maxCensusSize {
+		return ctx.Send([]byte(fmt.Sprintf("%s: the census size is %d and the maximum allowed is %d",
+			ErrCensusTooLarge, census.Size, maxCensusSize)), http.StatusBadRequest)
 	}
 	// if no duration is provided, set it to 24 hours, otherwise, set it to the
 	// provided duration in hours unless it is greater than the maximum allowed
@@ -134,9 +146,9 @@ func (v *vocdoniHandler) createElection(msg *apirest.APIdata, ctx *httprouter.HT
 	if err != nil {
 		return fmt.Errorf("failed to create election: %v", err)
 	}
-	// set the electionID for the census root previously stored on the database (if any).
+	// set the electionID for the census previously stored on the database (if any).
 	if req.Census != nil && req.Census.Root != nil {
-		if err := v.db.SetElectionIdForCensusRoot(req.Census.Root, electionID); err != nil {
+		if err := v.db.SetElectionIdForCensus(req.Census.CensusID, req.Census.Root, electionID); err != nil {
 			log.Errorw(err, fmt.Sprintf("failed to set electionID for census root %s", req.Census.Root))
 		}
 	}
@@ -181,11 +193,7 @@ func (v *vocdoniHandler) showElection(msg *apirest.APIdata, ctx *httprouter.HTTP
 		return ctx.Send([]byte(response), http.StatusOK)
 	}
 	if dbElection.Community != nil {
-		delegations, err := v.db.DelegationsByCommunityFrom(dbElection.Community.ID, uint64(packet.UntrustedData.FID))
-		if err != nil {
-			log.Warnw("failed to fetch delegations", "error", err)
-		}
-		if len(delegations) > 0 {
+		if v.voteDelegated(electionIDbytes, dbElection.Community.ID, uint64(packet.UntrustedData.FID)) {
 			if response, err := handleVoteError(ErrVoteDelegated, &voteData{
 				FID: uint64(packet.UntrustedData.FID),
 			}, electionIDbytes); err != nil {
@@ -348,6 +356,8 @@ func (v *vocdoniHandler) electionFullInfo(msg *apirest.APIdata, ctx *httprouter.
 	if err != nil {
 		return fmt.Errorf("could not fetch election %x: %w", electionID, err)
 	}
+	// correct the casted weight with the delegation overrides
+	dbElection = v.applyCastedWeightOverrides(dbElection)
 
 	var username, displayname string
 	user, err := v.db.User(dbElection.UserID)
@@ -409,6 +419,7 @@ func (v *vocdoniHandler) electionFullInfo(msg *apirest.APIdata, ctx *httprouter.
 		Participants:            participantFIDS,
 		Choices:                 results.Choices,
 		Votes:                   results.Votes,
+		RawVotes:                results.RawVotes,
 		Finalized:               results.Finalized,
 		Community:               dbElection.Community,
 	}
@@ -423,6 +434,43 @@ func (v *vocdoniHandler) electionFullInfo(msg *apirest.APIdata, ctx *httprouter.
 	return ctx.Send(jresponse, http.StatusOK)
 }
 
+// electionDelegations returns the snapshot of the resolved delegations used
+// to build the census of the election and the weight that every delegate
+// received from each of its delegators, to audit the delegated weight.
+func (v *vocdoniHandler) electionDelegations(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	electionID, err := hex.DecodeString(ctx.URLParam("electionID"))
+	if err != nil {
+		return ctx.Send([]byte("invalid electionID"), http.StatusBadRequest)
+	}
+	census, err := v.db.CensusFromElection(electionID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrElectionUnknown) {
+			return ctx.Send([]byte("census not found for the election"), http.StatusNotFound)
+		}
+		return fmt.Errorf("could not fetch election census: %w", err)
+	}
+	if census.DelegationSnapshotID == "" {
+		return ctx.Send([]byte("no delegations snapshot for the election"), http.StatusNotFound)
+	}
+	snapshot, err := v.db.DelegationSnapshot(census.DelegationSnapshotID)
+	if err != nil {
+		return fmt.Errorf("could not fetch delegations snapshot: %w", err)
+	}
+	jresponse, err := json.Marshal(&ElectionDelegations{
+		ElectionID:  hex.EncodeToString(electionID),
+		CensusID:    census.CensusID,
+		Snapshot:    snapshot,
+		Weights:     census.Delegations,
+		Attributed:  census.DelegationsAttributed,
+		CommunityID: snapshot.CommunityID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal response: %w", err)
+	}
+	ctx.SetResponseContentType("application/json")
+	return ctx.Send(jresponse, http.StatusOK)
+}
+
 func newElectionDescription(description *ElectionDescription, census *CensusInfo) *api.ElectionDescription {
 	choices := []api.ChoiceMetadata{}
 
@@ -433,11 +481,6 @@ func newElectionDescription(description *ElectionDescription, census *CensusInfo
 		})
 	}
 
-	size := census.Size
-	if size > uint64(maxElectionSize) {
-		size = uint64(maxElectionSize)
-	}
-
 	return &api.ElectionDescription{
 		Title:       map[string]string{"default": description.Question},
 		Description: map[string]string{"default": "this is a farcaster frame poll"},
@@ -467,7 +510,7 @@ func newElectionDescription(description *ElectionDescription, census *CensusInfo
 			Type:     api.CensusTypeFarcaster,
 			RootHash: census.Root,
 			URL:      census.Url,
-			Size:     size,
+			Size:     census.Size,
 		},
 	}
 }
@@ -652,6 +695,15 @@ func (v *vocdoniHandler) saveElectionAndProfile(
 		community); err != nil {
 		return fmt.Errorf("failed to add election to database: %w", err)
 	}
+	if community != nil {
+		go v.emitCommunityEvent(community.ID, mongo.WebhookEventPollCreated, &webhookPollCreated{
+			ElectionID: election.ElectionID.String(),
+			Question:   metadata.Title["default"],
+			CreatedBy:  profile.FID,
+			EndTime:    election.EndDate,
+			CensusSize: usersCount,
+		})
+	}
 	u, err := v.db.User(profile.FID)
 	if err != nil {
 		if !errors.Is(err, mongo.ErrUserUnknown) {
@@ -702,8 +754,9 @@ func (v *vocdoniHandler) remindersHandler(msg *apirest.APIdata, ctx *httprouter.
 	if election.Community == nil || election.Community.ID == "" {
 		return fmt.Errorf("election is not a community election")
 	}
-	if !v.db.IsCommunityAdmin(auth.UserID, election.Community.ID) && auth.UserID != v.adminFID {
-		return ctx.Send([]byte("user is not an admin of the community"), http.StatusForbidden)
+	if !v.db.HasCommunityPermission(auth.UserID, election.Community.ID, mongo.PermissionSendReminders) &&
+		auth.UserID != v.adminFID {
+		return ctx.Send([]byte("user is not allowed to send reminders in the community"), http.StatusForbidden)
 	}
 	// get the remindable users and the number of alredy reminded users from the
 	// database
@@ -59,6 +59,9 @@ type API interface {
 	// UserFollowers method returns the FIDs of the followers of the user with
 	// the given id. If something goes wrong, it returns an error.
 	UserFollowers(ctx context.Context, fid uint64) ([]uint64, error)
+	// UserFollowing method returns the FIDs of the users followed by the user
+	// with the given id. If something goes wrong, it returns an error.
+	UserFollowing(ctx context.Context, fid uint64) ([]uint64, error)
 	// Channel method returns the channel with the given id. If something goes
 	// wrong, it returns an error.
 	Channel(ctx context.Context, channelID string) (*Channel, error)
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -25,6 +26,7 @@ const (
 	ENDPOINT_USERDATA              = "userDataByFid?fid=%d"
 	ENDPOINT_CUSTODY_ADDRESS       = "userNameProofsByFid?fid=%d"
 	ENDPOINT_USER_FOLLOWERs        = "linksByTargetFid?target_fid=%d"
+	ENDPOINT_USER_FOLLOWING        = "linksByFid?fid=%d&link_type=follow&pageToken=%s"
 	ENDPOINT_VERIFICATIONS         = "verificationsByFid?fid=%d"
 	ENDPOINT_IDREGISTRY_BY_ADDRESS = "onChainIdRegistryEventByAddress?address=%s"
 	// timeouts
@@ -33,6 +35,7 @@ const (
 	submitMessageTimeout    = 5 * time.Minute
 	userdataTimeout         = 15 * time.Second
 	userFollowersTimeout    = 15 * time.Second
+	userFollowingTimeout    = 15 * time.Second
 	// message types
 	MESSAGE_TYPE_CAST_ADD     = "MESSAGE_TYPE_CAST_ADD"
 	MESSAGE_TYPE_USERPROOF    = "USERNAME_TYPE_FNAME"
@@ -41,6 +44,8 @@ const (
 	MESSAGE_TYPE_USERDATA_ADD = "MESSAGE_TYPE_USER_DATA_ADD"
 	MESSAGE_TYPE_REACTION_ADD = "MESSAGE_TYPE_REACTION_ADD"
 	MESSAGE_TYPE_RECAST       = "REACTION_TYPE_RECAST"
+	// link types
+	LINK_TYPE_FOLLOW = "follow"
 	// user data types
 	USERDATA_TYPE_USERNAME = "USER_DATA_TYPE_USERNAME"
 	// other constants
@@ -560,6 +565,60 @@ func (h *Hub) UserFollowers(ctx context.Context, fid uint64) ([]uint64, error) {
 	return followersFids, nil
 }
 
+// UserFollowing method returns the FIDs of the users followed by the user with
+// the given id, iterating over the pages of links of the user. If something
+// goes wrong, it returns an error.
+func (h *Hub) UserFollowing(ctx context.Context, fid uint64) ([]uint64, error) {
+	followingFids := []uint64{}
+	pageToken := ""
+	for {
+		// create a intenal context with a timeout for every page
+		internalCtx, cancel := context.WithTimeout(ctx, userFollowingTimeout)
+		// prepare the request to get the following users from the API
+		uri := fmt.Sprintf(ENDPOINT_USER_FOLLOWING, fid, url.QueryEscape(pageToken))
+		req, err := h.newRequest(internalCtx, http.MethodGet, uri, nil)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("error creating user following request: %w", err)
+		}
+		// download the following users from the API and check for errors
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("error downloading user following: %w", err)
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		cancel()
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("error downloading user following: %s", res.Status)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading user following response body: %w", err)
+		}
+		// unmarshal the json
+		followingResponse := &hubMessageResponse{}
+		if err := json.Unmarshal(body, followingResponse); err != nil {
+			return nil, fmt.Errorf("error unmarshalling user following: %w", err)
+		}
+		// filter the target FIDs of the follow links
+		for _, msg := range followingResponse.Messages {
+			if msg.Data == nil || msg.Data.Type != MESSAGE_TYPE_LINK || msg.Data.LinkBody == nil {
+				continue
+			}
+			if msg.Data.LinkBody.Type != LINK_TYPE_FOLLOW {
+				continue
+			}
+			followingFids = append(followingFids, msg.Data.LinkBody.TargetFID)
+		}
+		if followingResponse.NextPageToken == "" {
+			break
+		}
+		pageToken = followingResponse.NextPageToken
+	}
+	return followingFids, nil
+}
+
 // Channel method is not supported by the Hub API. It returns an error.
 func (h *Hub) Channel(ctx context.Context, channelID string) (*farcasterapi.Channel, error) {
 	return nil, fmt.Errorf("hub api does not support channels yet")
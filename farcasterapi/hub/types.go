@@ -18,11 +18,17 @@ type hubCastAddBody struct {
 	ParentCast        *hubParentCast   `json:"parentCastId"`
 }
 
+type hubLinkBody struct {
+	Type      string `json:"type"`
+	TargetFID uint64 `json:"targetFid"`
+}
+
 type hubMessageData struct {
 	Type        string          `json:"type"`
 	From        uint64          `json:"fid"`
 	Timestamp   uint64          `json:"timestamp"`
 	CastAddBody *hubCastAddBody `json:"castAddBody,omitempty"`
+	LinkBody    *hubLinkBody    `json:"linkBody,omitempty"`
 }
 
 type hubMessage struct {
@@ -31,7 +37,8 @@ type hubMessage struct {
 }
 
 type hubMessageResponse struct {
-	Messages []*hubMessage `json:"messages"`
+	Messages      []*hubMessage `json:"messages"`
+	NextPageToken string        `json:"nextPageToken,omitempty"`
 }
 
 type hubReactionBody struct {
@@ -36,6 +36,7 @@ const (
 	neynarReplyEndpoint       = NeynarAPIEndpoint + "/v2/farcaster/cast"
 	neynarUserByEthAddresses  = NeynarAPIEndpoint + "/v2/farcaster/user/bulk-by-address?addresses=%s"
 	neynarUserFollowers       = NeynarAPIEndpoint + "/v1/farcaster/followers?fid=%d&limit=150&cursor=%s"
+	neynarUserFollowing       = NeynarAPIEndpoint + "/v1/farcaster/following?fid=%d&limit=150&cursor=%s"
 	neynarChannelDataByID     = NeynarAPIEndpoint + "/v2/farcaster/channel?id=%s"
 	neynarSuggestChannels     = NeynarAPIEndpoint + "/v2/farcaster/channel/search?q=%s"
 	neynarUsersByChannelID    = NeynarAPIEndpoint + "/v2/farcaster/channel/followers?id=%s&limit=1000&cursor=%s"
@@ -374,6 +375,35 @@ func (n *NeynarAPI) UserFollowers(ctx context.Context, fid uint64) ([]uint64, er
 	return userFIDs, nil
 }
 
+// UserFollowing method returns the FIDs of the users followed by the user with
+// the given id. If something goes wrong, it returns an error.
+func (n *NeynarAPI) UserFollowing(ctx context.Context, fid uint64) ([]uint64, error) {
+	cursor := ""
+	userFIDs := []uint64{}
+	for {
+		url := fmt.Sprintf(neynarUserFollowing, fid, cursor)
+		body, err := n.neynarReq(ctx, url, http.MethodGet, nil, defaultRequestTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %w", err)
+		}
+		usersResponse := &UsersdataV1Response{}
+		if err := json.Unmarshal(body, &usersResponse); err != nil {
+			return nil, fmt.Errorf("error unmarshalling response body: %w", err)
+		}
+		if usersResponse.Result.Users == nil {
+			return nil, farcasterapi.ErrNoDataFound
+		}
+		for _, user := range usersResponse.Result.Users {
+			userFIDs = append(userFIDs, user.Fid)
+		}
+		if usersResponse.Result.NextCursor == nil || usersResponse.Result.NextCursor.Cursor == "" {
+			break
+		}
+		cursor = usersResponse.Result.NextCursor.Cursor
+	}
+	return userFIDs, nil
+}
+
 // Channel method returns the details of a channel given its channelID. If
 // something goes wrong it returns an error.
 func (n *NeynarAPI) Channel(ctx context.Context, channelID string) (*farcasterapi.Channel, error) {
@@ -61,3 +61,67 @@ func IsAllowed(f Feature, userReputation uint32) bool {
 func SetReputation(f Feature, reputation uint32) {
 	reputationThresholdsByFeature[f] = reputation
 }
+
+// censusSizeTier defines the maximum size of the censuses that a user can
+// create from the reputation provided.
+type censusSizeTier struct {
+	reputation uint32
+	size       uint64
+}
+
+// censusSizeTiers is the list of census size tiers sorted by reputation. The
+// maximum census size of a user is the size of the highest tier that the
+// user reputation reaches.
+var censusSizeTiers = []censusSizeTier{
+	{reputation: 0, size: 5000},
+	{reputation: 10, size: 50000},
+	{reputation: 25, size: 200000},
+}
+
+// communityMaxCensusSize is the maximum size of the censuses of the
+// communities, regardless of the reputation of their admins.
+var communityMaxCensusSize uint64 = 200000
+
+// censusSizeLimit is the maximum size of any census, which depends on the
+// environment where the service runs. If it is zero, there is no limit
+// other than the census size tiers.
+var censusSizeLimit uint64
+
+// MaxCensusSize returns the maximum size of the censuses that a user with the
+// reputation provided can create, limited by the census size limit (if any).
+func MaxCensusSize(userReputation uint32) uint64 {
+	var size uint64
+	for _, tier := range censusSizeTiers {
+		if userReputation >= tier.reputation {
+			size = tier.size
+		}
+	}
+	return limitCensusSize(size)
+}
+
+// CommunityMaxCensusSize returns the maximum size of the censuses of the
+// communities, limited by the census size limit (if any).
+func CommunityMaxCensusSize() uint64 {
+	return limitCensusSize(communityMaxCensusSize)
+}
+
+// CensusSizeLimit returns the maximum size of any census, or zero if there is
+// no limit.
+func CensusSizeLimit() uint64 {
+	return censusSizeLimit
+}
+
+// SetCensusSizeLimit sets the maximum size of any census. If it is zero, there
+// is no limit other than the census size tiers.
+func SetCensusSizeLimit(limit uint64) {
+	censusSizeLimit = limit
+}
+
+// limitCensusSize returns the size provided limited by the census size limit
+// (if any).
+func limitCensusSize(size uint64) uint64 {
+	if censusSizeLimit > 0 && size > censusSizeLimit {
+		return censusSizeLimit
+	}
+	return size
+}
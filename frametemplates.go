@@ -212,3 +212,22 @@ var frameNotificationsManager = header + `
     <meta property="fc:frame:button:1" content="✅ Allow" />
     <meta property="fc:frame:button:2" content="🤐 Mute" />
 ` + body
+
+var frameDelegation = header + `
+    <meta property="fc:frame" content="vNext" />
+    <meta property="fc:frame:image" content="{image}" />
+    <meta name="fc:frame:image:aspect_ratio" content="1:1" />
+    <meta property="fc:frame:post_url" content="{server}/delegation/{delegation}/set" />
+    <meta property="fc:frame:state" content="{state}" />
+    <meta property="fc:frame:button:1" content="🤝 Delegate" />
+    <meta property="fc:frame:button:2" content="❌ Revoke" />
+    <meta property="fc:frame:button:3" content="🔍 My delegate" />
+` + body
+
+var frameDelegationResponse = header + `
+    <meta property="fc:frame" content="vNext" />
+    <meta property="fc:frame:image" content="{image}" />
+    <meta name="fc:frame:image:aspect_ratio" content="1:1" />
+    <meta property="fc:frame:post_url" content="{server}/delegation/{delegation}" />
+    <meta property="fc:frame:button:1" content="⬅️ Back" />
+` + body
@@ -8,6 +8,7 @@ require (
 	github.com/Khan/genqlient v0.6.0
 	github.com/ethereum/go-ethereum v1.14.3
 	github.com/frankban/quicktest v1.14.6
+	github.com/go-chi/chi/v5 v5.0.12
 	github.com/google/uuid v1.6.0
 	github.com/hashicorp/golang-lru/v2 v2.0.7
 	github.com/spf13/pflag v1.0.5
@@ -89,7 +90,6 @@ require (
 	github.com/getsentry/sentry-go v0.18.0 // indirect
 	github.com/glendc/go-external-ip v0.1.0 // indirect
 	github.com/go-chi/chi v4.1.2+incompatible // indirect
-	github.com/go-chi/chi/v5 v5.0.12 // indirect
 	github.com/go-chi/cors v1.2.1 // indirect
 	github.com/go-kit/kit v0.13.0 // indirect
 	github.com/go-kit/log v0.2.1 // indirect
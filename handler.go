@@ -18,9 +18,11 @@ import (
 	"github.com/vocdoni/vote-frame/airstack"
 	"github.com/vocdoni/vote-frame/communityhub"
 	"github.com/vocdoni/vote-frame/farcasterapi"
+	"github.com/vocdoni/vote-frame/features"
 	"github.com/vocdoni/vote-frame/helpers"
 	"github.com/vocdoni/vote-frame/imageframe"
 	"github.com/vocdoni/vote-frame/mongo"
+	"github.com/vocdoni/vote-frame/onchain"
 	"github.com/vocdoni/vote-frame/reputation"
 	"github.com/vocdoni/vote-frame/shortener"
 	"go.vocdoni.io/dvote/api"
@@ -43,12 +45,19 @@ type vocdoniHandler struct {
 	electionLRU   *lru.Cache[string, *api.Election]
 	fcapi         farcasterapi.API
 	airstack      *airstack.Airstack
+	onchain       *onchain.Client
 	comhub        *communityhub.CommunityHub
 	repUpdater    *reputation.Updater
 
 	backgroundQueue  sync.Map
 	addAuthTokenFunc func(uint64, string)
 	adminFID         uint64
+	// instanceID identifies this instance as the owner of the census jobs
+	// that it is building
+	instanceID string
+
+	censusProviders     map[string]CensusProvider
+	censusProvidersLock sync.RWMutex
 }
 
 func NewVocdoniHandler(
@@ -61,6 +70,7 @@ func NewVocdoniHandler(
 	fcapi farcasterapi.API,
 	token *uuid.UUID,
 	airstack *airstack.Airstack,
+	onchain *onchain.Client,
 	comhub *communityhub.CommunityHub,
 	repUpdater *reputation.Updater,
 	adminFID uint64,
@@ -100,9 +110,11 @@ func NewVocdoniHandler(
 		db:            db,
 		fcapi:         fcapi,
 		airstack:      airstack,
+		onchain:       onchain,
 		comhub:        comhub,
 		repUpdater:    repUpdater,
 		adminFID:      adminFID,
+		instanceID:    util.RandomHex(16),
 		electionLRU: func() *lru.Cache[string, *api.Election] {
 			lru, err := lru.New[string, *api.Election](100)
 			if err != nil {
@@ -112,9 +124,20 @@ func NewVocdoniHandler(
 		}(),
 	}
 
+	// Register the census providers of the supported sources
+	if err := vh.registerDefaultCensusProviders(); err != nil {
+		return nil, fmt.Errorf("failed to register census providers: %w", err)
+	}
+
 	// Add the election callback to the mongo database to fetch the election information
 	db.AddElectionCallback(vh.election)
 	go finalizeElectionsAtBackround(ctx, vh)
+	go resumeCensusJobsAtBackground(ctx, vh)
+	go retryWebhookDeliveriesAtBackground(ctx, vh)
+	if comhub != nil {
+		go settleResultsAtBackground(ctx, vh)
+		go reconcileSettlementsAtBackground(ctx, vh)
+	}
 	return vh, ensureAccountExist(cli)
 }
 
@@ -191,7 +214,7 @@ func (v *vocdoniHandler) info(msg *apirest.APIdata, ctx *httprouter.HTTPContext)
 		text = append(text, fmt.Sprintf("Poll id %x...", election.ElectionID[:16]))
 		text = append(text, fmt.Sprintf("Executed on network %s", v.cli.ChainID()))
 		text = append(text, fmt.Sprintf("Census hash %x...", election.Census.CensusRoot[:12]))
-		if censusUserCount >= uint64(maxElectionSize) {
+		if limit := features.CensusSizeLimit(); limit > 0 && censusUserCount >= limit {
 			text = append(text, fmt.Sprintf("Allowed voters %d", censusUserCount))
 		} else {
 			text = append(text, fmt.Sprintf("Census size %d", censusUserCount))
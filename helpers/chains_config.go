@@ -8,14 +8,16 @@ import (
 )
 
 // ChainConfig represents the configuration of a chain, including the chain ID,
-// the chain alias, the chain name, the endpoints, and the address of the
-// CommunityHub contract.
+// the chain alias, the chain name, the endpoints, the address of the
+// CommunityHub contract and the number of confirmations that a block needs
+// to be considered final.
 type ChainConfig struct {
 	ChainID             uint64
 	ChainAlias          string
 	Name                string
 	Endpoints           []string
 	CommunityHubAddress string
+	Confirmations       uint64
 }
 
 // ChainsConfig is a slice of ChainConfig.
@@ -35,6 +37,7 @@ type chainsConfigFile map[string]struct {
 			Address string `json:"address"`
 		} `json:"communityHub"`
 	} `json:"contracts"`
+	Confirmations uint64 `json:"confirmations"`
 }
 
 // LoadChainsConfig loads the chains configuration from the file at the given
@@ -60,10 +63,11 @@ func LoadChainsConfig(path string, availableAliases []string) (ChainsConfig, err
 			endpoints = append(endpoints, rpcs.HTTP...)
 		}
 		config := &ChainConfig{
-			ChainID:    info.ID,
-			ChainAlias: alias,
-			Name:       info.Name,
-			Endpoints:  endpoints,
+			ChainID:       info.ID,
+			ChainAlias:    alias,
+			Name:          info.Name,
+			Endpoints:     endpoints,
+			Confirmations: info.Confirmations,
 		}
 		if info.Contracts.CommunityHub.Address != "" {
 			config.CommunityHubAddress = info.Contracts.CommunityHub.Address
@@ -93,6 +97,16 @@ func (c ChainsConfig) ChainChainIDByAlias() map[string]uint64 {
 	return aliases
 }
 
+// ConfirmationsByChainAlias returns a map with the chain alias as the key and
+// the number of confirmations of the chain as the value.
+func (c ChainsConfig) ConfirmationsByChainAlias() map[string]uint64 {
+	confirmations := map[string]uint64{}
+	for _, config := range c {
+		confirmations[config.ChainAlias] = config.Confirmations
+	}
+	return confirmations
+}
+
 func sliceContains(slice []string, value string) bool {
 	for _, v := range slice {
 		if v == value {
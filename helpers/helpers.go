@@ -2,7 +2,9 @@ package helpers
 
 import (
 	"encoding/json"
+	"fmt"
 	"math/big"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
 	"go.vocdoni.io/dvote/api"
@@ -145,3 +147,46 @@ func NormalizeAddressStringSlice(addresses []string) []string {
 	}
 	return normalizedAddresses
 }
+
+// MaxTokenIDs is the maximum number of token IDs of a contract that can be
+// included in a census token, once the ranges are expanded.
+const MaxTokenIDs = 1000
+
+// ExpandTokenIDs parses a list of token IDs, where every item can be a single
+// ID ("7") or an inclusive range of IDs ("100-200"), and returns the list of
+// unique IDs in the order they appear. It returns an error if any item is not
+// valid or if the resulting list has more than max IDs.
+func ExpandTokenIDs(ids []string, max int) ([]*big.Int, error) {
+	expanded := []*big.Int{}
+	seen := map[string]bool{}
+	add := func(id *big.Int) error {
+		if seen[id.String()] {
+			return nil
+		}
+		if len(expanded) >= max {
+			return fmt.Errorf("too many token IDs, maximum allowed is %d", max)
+		}
+		seen[id.String()] = true
+		expanded = append(expanded, new(big.Int).Set(id))
+		return nil
+	}
+	for _, item := range ids {
+		first, last, isRange := strings.Cut(strings.TrimSpace(item), "-")
+		from, ok := new(big.Int).SetString(strings.TrimSpace(first), 10)
+		if !ok || from.Sign() < 0 {
+			return nil, fmt.Errorf("invalid token ID: %s", item)
+		}
+		to := from
+		if isRange {
+			if to, ok = new(big.Int).SetString(strings.TrimSpace(last), 10); !ok || to.Cmp(from) < 0 {
+				return nil, fmt.Errorf("invalid token ID range: %s", item)
+			}
+		}
+		for id := new(big.Int).Set(from); id.Cmp(to) <= 0; id.Add(id, big.NewInt(1)) {
+			if err := add(id); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return expanded, nil
+}
@@ -140,3 +140,56 @@ func TestExtractResults(t *testing.T) {
 		})
 	}
 }
+
+func TestExpandTokenIDs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ids      []string
+		max      int
+		expected []*big.Int
+		err      bool
+	}{
+		{
+			name:     "single IDs",
+			ids:      []string{"3", "7"},
+			max:      10,
+			expected: []*big.Int{big.NewInt(3), big.NewInt(7)},
+		},
+		{
+			name:     "ranges and duplicates",
+			ids:      []string{"1-3", "2", " 5 - 6 "},
+			max:      10,
+			expected: []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3), big.NewInt(5), big.NewInt(6)},
+		},
+		{
+			name: "invalid ID",
+			ids:  []string{"0x10"},
+			max:  10,
+			err:  true,
+		},
+		{
+			name: "inverted range",
+			ids:  []string{"10-1"},
+			max:  10,
+			err:  true,
+		},
+		{
+			name: "too many IDs",
+			ids:  []string{"1-100"},
+			max:  10,
+			err:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ids, err := ExpandTokenIDs(tc.ids, tc.max)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, ids)
+		})
+	}
+}
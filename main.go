@@ -29,6 +29,7 @@ import (
 	"github.com/vocdoni/vote-frame/helpers"
 	"github.com/vocdoni/vote-frame/mongo"
 	"github.com/vocdoni/vote-frame/notifications"
+	"github.com/vocdoni/vote-frame/onchain"
 	"github.com/vocdoni/vote-frame/reputation"
 	urlapi "go.vocdoni.io/dvote/api"
 	"go.vocdoni.io/dvote/httprouter"
@@ -64,6 +65,8 @@ func main() {
 	flag.Uint64("adminFID", 7548, "The FID of the admin farcaster account with superuser powers")
 	flag.Int("pollSize", 0, "The maximum votes allowed per poll (the more votes, the more expensive) (0 for default)")
 	flag.Int("pprofPort", 0, "The port to use for the pprof http endpoints")
+	flag.Duration("censusCacheTTL", defaultCensusCacheTTL, "The default time that a census can be reused by other polls with the same source")
+	flag.Int("delegationMaxDepth", mongo.DefaultMaxDelegationDepth, "The maximum number of vote delegations that can be chained in a community")
 	flag.String("web3",
 		"https://rpc.degen.tips,https://eth.llamarpc.com,https://rpc.ankr.com/eth,https://ethereum-rpc.publicnode.com,https://mainnet.optimism.io,https://optimism.llamarpc.com,https://optimism-mainnet.public.blastapi.io,https://rpc.ankr.com/optimism",
 		"Web3 RPCs")
@@ -74,6 +77,10 @@ func main() {
 	flag.String("chains", "base-sep,degen-dev", "The chains to use for the community hub")
 	flag.String("communityHubChainsConfig", "./chains_config.json", "The JSON configuration file for the community hub networks")
 	flag.String("communityHubAdminPrivKey", "", "The private key of a wallet admin of the CommunityHub contract in hex format")
+	flag.Bool("communityRelayBudget", false,
+		"Refuse the relayed updates of the communities once their gas exceeds the funds of the community in the CommunityHub contract")
+	flag.String("communityHubBackend", communityhub.BackendOnchain,
+		"The backend of the CommunityHub contracts: 'onchain' to use the web3 endpoints of the chains or 'memory' to emulate them in memory without network access")
 	// bot flags
 	// DISCLAMER: Currently the bot needs a HUB with write permissions to work.
 	// It also needs a FID to impersonate to it and its private key to sign the
@@ -131,6 +138,8 @@ func main() {
 	adminToken := viper.GetString("adminToken")
 	pollSize := viper.GetInt("pollSize")
 	pprofPort := viper.GetInt("pprofPort")
+	censusCacheTTL := viper.GetDuration("censusCacheTTL")
+	delegationMaxDepth := viper.GetInt("delegationMaxDepth")
 	web3endpointStr := viper.GetString("web3")
 	web3endpoint := strings.Split(web3endpointStr, ",")
 	neynarAPIKey := viper.GetString("neynarAPIKey")
@@ -142,6 +151,8 @@ func main() {
 	availableChains := strings.Split(viper.GetString("chains"), ",")
 	communityHubChainsConfigPath := viper.GetString("communityHubChainsConfig")
 	communityHubAdminPrivKey := viper.GetString("communityHubAdminPrivKey")
+	communityHubBackend := viper.GetString("communityHubBackend")
+	communityRelayBudget := viper.GetBool("communityRelayBudget")
 
 	// bot vars
 	botFid := viper.GetUint64("botFid")
@@ -198,9 +209,13 @@ func main() {
 		"mongoDB", mongoDB,
 		"pollSize", pollSize,
 		"pprofPort", pprofPort,
+		"censusCacheTTL", censusCacheTTL,
+		"delegationMaxDepth", delegationMaxDepth,
 		"communityHubChainsConfig", communityHubChainsConfigPath,
 		"census3APIEndpoint", census3APIEndpoint,
 		"communityHubAdmin", communityHubAdminPrivKey != "",
+		"communityHubBackend", communityHubBackend,
+		"communityRelayBudget", communityRelayBudget,
 		"botFid", botFid,
 		"botHubEndpoint", botHubEndpoint,
 		"neynarSignerUUID", neynarSignerUUID,
@@ -236,15 +251,30 @@ func main() {
 	domain := strings.Split(serverURL, "/")[2]
 	log.Infow("server URL", "URL", serverURL, "domain", domain)
 
-	// Set the maximum election size based on the API endpoint (try to guess the environment)
+	// Set the default census cache TTL
+	if censusCacheTTL > 0 {
+		defaultCensusCacheTTL = censusCacheTTL
+	}
+
+	// Enable the budget of the communities for the relayed updates
+	relayCommunityBudget = communityRelayBudget
+
+	// Set the maximum depth of the vote delegation chains
+	mongo.SetMaxDelegationDepth(delegationMaxDepth)
+
+	// Set the census size limit based on the API endpoint (try to guess the
+	// environment), the maximum census size of every user depends on its
+	// reputation but it cannot be greater than this limit
 	if pollSize > 0 {
-		maxElectionSize = pollSize
+		features.SetCensusSizeLimit(uint64(pollSize))
 	} else {
 		if strings.Contains(apiEndpoint, "stg") {
-			maxElectionSize = stageMaxElectionSize
+			features.SetCensusSizeLimit(stageMaxElectionSize)
 		} else {
 			if strings.Contains(apiEndpoint, "dev") {
-				maxElectionSize = devMaxElectionSize
+				features.SetCensusSizeLimit(devMaxElectionSize)
+			} else {
+				features.SetCensusSizeLimit(defaultMaxElectionSize)
 			}
 		}
 	}
@@ -296,6 +326,12 @@ func main() {
 	}
 	log.Infow("chain info loaded", "info", chainsConfs, "endpoints", web3pool.String())
 
+	// Create the on-chain client to query the token holders to the contracts
+	onchainClient, err := onchain.NewClient(web3pool, chainsConfs.ChainChainIDByAlias())
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// create census3 client
 	c3url, err := url.Parse(census3APIEndpoint)
 	if err != nil {
@@ -321,16 +357,18 @@ func main() {
 		comHub, err = communityhub.NewCommunityHub(mainCtx, web3pool, &communityhub.CommunityHubConfig{
 			ChainAliases:      chainsConfs.ChainChainIDByAlias(),
 			ContractAddresses: chainsConfs.ContractsAddressesByChainAlias(),
+			Confirmations:     chainsConfs.ConfirmationsByChainAlias(),
 			DB:                db,
 			PrivKey:           communityHubAdminPrivKey,
+			Backend:           communityHubBackend,
 		})
 		if err != nil {
 			log.Warnw("failed to create community hub", "error", err)
+		} else {
+			comHub.ScanNewCommunities()
+			defer comHub.Stop()
+			log.Infow("community hub service started", "backend", communityHubBackend)
 		}
-		comHub.ScanNewCommunities()
-		comHub.SyncCommunities()
-		defer comHub.Stop()
-		log.Info("community hub service started")
 	}
 
 	// Create Airstack artifact
@@ -360,7 +398,7 @@ func main() {
 	// Create the Vocdoni handler
 	apiTokenUUID := uuid.MustParse(apiToken)
 	handler, err := NewVocdoniHandler(apiEndpoint, vocdoniPrivKey, censusInfo,
-		webAppDir, db, mainCtx, neynarcli, &apiTokenUUID, as, comHub, repUpdater, adminFID)
+		webAppDir, db, mainCtx, neynarcli, &apiTokenUUID, as, onchainClient, comHub, repUpdater, adminFID)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -558,6 +596,10 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if err := uAPI.Endpoint.RegisterMethod("/poll/info/{electionID}/delegations", http.MethodGet, "public", handler.electionDelegations); err != nil {
+		log.Fatal(err)
+	}
+
 	if err := uAPI.Endpoint.RegisterMethod("/poll/{electionID}", http.MethodPost, "public", handler.showElection); err != nil {
 		log.Fatal(err)
 	}
@@ -597,6 +639,9 @@ func main() {
 	if err := uAPI.Endpoint.RegisterMethod("/census/followers/{userFid}", http.MethodPost, "private", handler.censusFollowersHandler); err != nil {
 		log.Fatal(err)
 	}
+	if err := uAPI.Endpoint.RegisterMethod("/census/following/{userFid}", http.MethodPost, "private", handler.censusFollowingHandler); err != nil {
+		log.Fatal(err)
+	}
 
 	if err := uAPI.Endpoint.RegisterMethod("/census/alfafrens", http.MethodPost, "private", handler.censusAlfafrensChannelHandler); err != nil {
 		log.Fatal(err)
@@ -618,6 +663,21 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if err := uAPI.Endpoint.RegisterMethod("/census/providers", http.MethodGet, "public", handler.censusProvidersHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/census/{censusID}/participant/{fidOrUsername}", http.MethodGet, "public", handler.censusParticipantHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	// the census export is a raw handler to stream the participants
+	router.AddRawHTTPHandler("/census/{censusID}/export", http.MethodGet, handler.censusExportHandler)
+
+	if err := uAPI.Endpoint.RegisterMethod("/census/{provider}", http.MethodPost, "private", handler.censusProviderHandler); err != nil {
+		log.Fatal(err)
+	}
+
 	if err := uAPI.Endpoint.RegisterMethod("/census/exists/nft", http.MethodPost, "public", handler.checkNFTContractHandler); err != nil {
 		log.Fatal(err)
 	}
@@ -683,6 +743,18 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if err := uAPI.Endpoint.RegisterMethod("/delegation/{chainAlias}:{communityID}/{delegateFID}", http.MethodGet, "public", handler.delegationFrameHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/delegation/{chainAlias}:{communityID}/{delegateFID}", http.MethodPost, "public", handler.delegationFrameHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/delegation/{chainAlias}:{communityID}/{delegateFID}/set", http.MethodPost, "public", handler.delegationFrameResponseHandler); err != nil {
+		log.Fatal(err)
+	}
+
 	if err := uAPI.Endpoint.RegisterMethod("/profile", http.MethodGet, "private", handler.profileHandler); err != nil {
 		log.Fatal(err)
 	}
@@ -727,6 +799,10 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if err := uAPI.Endpoint.RegisterMethod("/communities", http.MethodPost, "private", handler.createCommunityHandler); err != nil {
+		log.Fatal(err)
+	}
+
 	if err := uAPI.Endpoint.RegisterMethod("/communities/{chainAlias}:{communityID}", http.MethodGet, "public", handler.communityHandler); err != nil {
 		log.Fatal(err)
 	}
@@ -739,11 +815,92 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if err := uAPI.Endpoint.RegisterMethod("/communities/{chainAlias}:{communityID}/delegations/graph", http.MethodGet, "public", handler.communityDelegationsGraphHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/communities/{chainAlias}:{communityID}/analytics", http.MethodGet, "private", handler.communityAnalyticsHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/communities/{chainAlias}:{communityID}/relay", http.MethodGet, "private", handler.communityRelayHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/communities/{chainAlias}:{communityID}/webhooks", http.MethodGet, "private", handler.communityWebhooksHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/communities/{chainAlias}:{communityID}/webhooks", http.MethodPost, "private", handler.communityAddWebhookHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/communities/{chainAlias}:{communityID}/webhooks/deliveries", http.MethodGet, "private", handler.communityWebhookDeliveriesHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/communities/{chainAlias}:{communityID}/webhooks/{webhookID}", http.MethodDelete, "private", handler.communityDeleteWebhookHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/admin/settlements", http.MethodGet, "admin", handler.resultsSettlementsHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/admin/settlements/{electionID}/retry", http.MethodPost, "admin", handler.retryResultsSettlementHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/communities/{chainAlias}:{communityID}/roles", http.MethodGet, "public", handler.communityRolesHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/communities/{chainAlias}:{communityID}/roles", http.MethodPut, "private", handler.communitySetRolesHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/communities/{chainAlias}:{communityID}/members", http.MethodGet, "public", handler.communityMembersHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/communities/{chainAlias}:{communityID}/members", http.MethodPost, "private", handler.communityAddMembersHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/communities/{chainAlias}:{communityID}/members/csv", http.MethodPost, "private", handler.communityImportMembersHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/communities/{chainAlias}:{communityID}/members/{fid}", http.MethodDelete, "private", handler.communityRemoveMemberHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/communities/{chainAlias}:{communityID}/join", http.MethodPost, "private", handler.communityJoinHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/communities/{chainAlias}:{communityID}/join", http.MethodGet, "private", handler.communityJoinRequestsHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/communities/{chainAlias}:{communityID}/join/{fid}", http.MethodPost, "private", handler.communityApproveJoinHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/communities/{chainAlias}:{communityID}/join/{fid}", http.MethodDelete, "private", handler.communityRejectJoinHandler); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := uAPI.Endpoint.RegisterMethod("/communities/{chainAlias}:{communityID}/delegations/history", http.MethodGet, "public", handler.communityDelegationsHistoryHandler); err != nil {
+		log.Fatal(err)
+	}
+
 	if err := uAPI.Endpoint.RegisterMethod("/short", http.MethodGet, "private", handler.shortURLHanlder); err != nil {
 		log.Fatal(err)
 	}
 
 	// if a bot FID is provided, start the bot background process
+	notificationsEnabled := false
 	if botFid > 0 {
 		var botAPI farcasterapi.API
 		if botPrivKey != "" && botHubEndpoint != "" {
@@ -790,7 +947,11 @@ func main() {
 		}
 		notificationManager.Start()
 		defer notificationManager.Stop()
+		notificationsEnabled = true
 	}
+	// register the expired delegations and, if the notifications are enabled,
+	// notify the parties of the delegations about to expire
+	go sweepDelegationsExpiryAtBackground(mainCtx, handler, notificationsEnabled)
 
 	// close if interrupt received
 	log.Infof("startup complete at %s", time.Now().Format(time.RFC850))
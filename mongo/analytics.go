@@ -0,0 +1,255 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/types"
+)
+
+// analyticsProfilesBatchSize is the maximum number of users whose access
+// profiles are queried at once to count the notifications acceptance.
+const analyticsProfilesBatchSize = 1000
+
+// CommunityAnalytics returns the statistics of the polls of the community
+// provided grouped by the interval provided (see AnalyticsIntervalDay,
+// AnalyticsIntervalWeek and AnalyticsIntervalMonth), sorted from the oldest
+// to the newest. The statistics of every poll are pre-aggregated and stored,
+// so only the polls that have not finished yet are computed again.
+func (ms *MongoStorage) CommunityAnalytics(communityID, interval string) ([]*CommunityAnalyticsBucket, error) {
+	elections, err := ms.ElectionsByCommunity(communityID)
+	if err != nil {
+		return nil, err
+	}
+	cached, err := ms.communityElectionsAnalytics(communityID)
+	if err != nil {
+		return nil, err
+	}
+	stats := []*ElectionAnalytics{}
+	for _, election := range elections {
+		if electionStats, ok := cached[election.ElectionID]; ok && electionStats.Final {
+			stats = append(stats, electionStats)
+			continue
+		}
+		electionStats, err := ms.computeElectionAnalytics(election)
+		if err != nil {
+			log.Warnw("failed to compute election analytics", "electionID", election.ElectionID, "error", err)
+			continue
+		}
+		if err := ms.setElectionAnalytics(electionStats); err != nil {
+			log.Warnw("failed to store election analytics", "electionID", election.ElectionID, "error", err)
+		}
+		stats = append(stats, electionStats)
+	}
+	return bucketElectionsAnalytics(stats, interval), nil
+}
+
+// communityElectionsAnalytics returns the stored statistics of the polls of
+// the community provided indexed by election ID.
+func (ms *MongoStorage) communityElectionsAnalytics(communityID string) (map[string]*ElectionAnalytics, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := ms.electionAnalytics.Find(ctx, bson.M{"communityId": communityID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	stats := []*ElectionAnalytics{}
+	if err := cursor.All(ctx, &stats); err != nil {
+		return nil, err
+	}
+	indexed := make(map[string]*ElectionAnalytics, len(stats))
+	for _, electionStats := range stats {
+		indexed[electionStats.ElectionID] = electionStats
+	}
+	return indexed, nil
+}
+
+// computeElectionAnalytics computes the statistics of the community poll
+// provided from its voters, its census and the access profiles of the census
+// participants. The statistics are final if the poll has already finished.
+func (ms *MongoStorage) computeElectionAnalytics(election *Election) (*ElectionAnalytics, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	electionID := types.HexStringToHexBytes(election.ElectionID)
+	stats := &ElectionAnalytics{
+		ElectionID:   election.ElectionID,
+		CommunityID:  election.Community.ID,
+		CreatedTime:  election.CreatedTime,
+		CensusSize:   uint64(election.FarcasterUserCount),
+		Votes:        election.CastedVotes,
+		CastedWeight: election.CastedWeight,
+		Voters:       []uint64{},
+		Final:        !election.EndTime.IsZero() && election.EndTime.Before(time.Now()),
+		UpdatedAt:    time.Now(),
+	}
+	voters, err := ms.votersOfElection(electionID)
+	if err != nil && !errors.Is(err, ErrElectionUnknown) {
+		return nil, err
+	}
+	if voters != nil {
+		stats.Voters = voters.Voters
+	}
+	census, err := ms.censusFromElection(electionID)
+	if err != nil && !errors.Is(err, ErrElectionUnknown) {
+		return nil, err
+	}
+	if census != nil {
+		fids := make([]uint64, 0, len(census.ParticipantFIDs))
+		for _, fid := range census.ParticipantFIDs {
+			fids = append(fids, fid)
+		}
+		if stats.CensusSize == 0 {
+			stats.CensusSize = uint64(len(fids))
+		}
+		if stats.NotificationsRequested, stats.NotificationsAccepted, err = ms.notificationsAcceptance(fids); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// notificationsAcceptance returns the number of users of the list provided
+// that were asked to allow the notifications and the number of them that
+// accepted them. It does not adquire the keysLock.
+func (ms *MongoStorage) notificationsAcceptance(fids []uint64) (uint64, uint64, error) {
+	var requested, accepted int64
+	for start := 0; start < len(fids); start += analyticsProfilesBatchSize {
+		end := min(start+analyticsProfilesBatchSize, len(fids))
+		batch := fids[start:end]
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		batchRequested, err := ms.userAccessProfiles.CountDocuments(ctx, bson.M{
+			"_id":                    bson.M{"$in": batch},
+			"notificationsRequested": true,
+		})
+		if err != nil {
+			cancel()
+			return 0, 0, err
+		}
+		batchAccepted, err := ms.userAccessProfiles.CountDocuments(ctx, bson.M{
+			"_id":                   bson.M{"$in": batch},
+			"notificationsAccepted": true,
+		})
+		cancel()
+		if err != nil {
+			return 0, 0, err
+		}
+		requested += batchRequested
+		accepted += batchAccepted
+	}
+	return uint64(requested), uint64(accepted), nil
+}
+
+// setElectionAnalytics stores the statistics of a community poll provided,
+// replacing the previous ones.
+func (ms *MongoStorage) setElectionAnalytics(stats *ElectionAnalytics) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Replace().SetUpsert(true)
+	_, err := ms.electionAnalytics.ReplaceOne(ctx, bson.M{"_id": stats.ElectionID}, stats, opts)
+	return err
+}
+
+// analyticsBucketStart returns the start of the interval provided that
+// includes the time provided, in UTC. The weeks start on monday. If the
+// interval is unknown, the month is used.
+func analyticsBucketStart(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch interval {
+	case AnalyticsIntervalDay:
+		return day
+	case AnalyticsIntervalWeek:
+		// time.Sunday is 0, so shift the weekdays to start on monday
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// bucketElectionsAnalytics groups the statistics of the polls provided by the
+// interval provided. The voters are new in the interval of the first poll of
+// the community they voted in and returning in the next ones.
+func bucketElectionsAnalytics(stats []*ElectionAnalytics, interval string) []*CommunityAnalyticsBucket {
+	sorted := make([]*ElectionAnalytics, len(stats))
+	copy(sorted, stats)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedTime.Before(sorted[j].CreatedTime)
+	})
+	type bucketData struct {
+		bucket     *CommunityAnalyticsBucket
+		voters     map[uint64]bool
+		censusSize uint64
+		votes      uint64
+		weight     *big.Int
+	}
+	buckets := []*bucketData{}
+	byStart := map[time.Time]*bucketData{}
+	firstSeen := map[uint64]time.Time{}
+	for _, electionStats := range sorted {
+		start := analyticsBucketStart(electionStats.CreatedTime, interval)
+		data, ok := byStart[start]
+		if !ok {
+			data = &bucketData{
+				bucket: &CommunityAnalyticsBucket{Start: start},
+				voters: map[uint64]bool{},
+				weight: new(big.Int),
+			}
+			byStart[start] = data
+			buckets = append(buckets, data)
+		}
+		data.bucket.Polls++
+		data.bucket.NotificationsRequested += electionStats.NotificationsRequested
+		data.bucket.NotificationsAccepted += electionStats.NotificationsAccepted
+		data.censusSize += electionStats.CensusSize
+		data.votes += electionStats.Votes
+		if weight, ok := new(big.Int).SetString(electionStats.CastedWeight, 10); ok {
+			data.weight.Add(data.weight, weight)
+		}
+		for _, voter := range electionStats.Voters {
+			if data.voters[voter] {
+				continue
+			}
+			data.voters[voter] = true
+			if _, ok := firstSeen[voter]; !ok {
+				firstSeen[voter] = start
+			}
+		}
+	}
+	result := make([]*CommunityAnalyticsBucket, 0, len(buckets))
+	for _, data := range buckets {
+		bucket := data.bucket
+		bucket.UniqueVoters = uint64(len(data.voters))
+		for voter := range data.voters {
+			if firstSeen[voter].Equal(bucket.Start) {
+				bucket.NewVoters++
+			}
+		}
+		bucket.ReturningVoters = bucket.UniqueVoters - bucket.NewVoters
+		if data.censusSize > 0 {
+			bucket.Turnout = float64(data.votes) / float64(data.censusSize) * 100
+		}
+		bucket.CastedWeight = data.weight.String()
+		if bucket.NotificationsRequested > 0 {
+			bucket.NotificationsAcceptanceRate = float64(bucket.NotificationsAccepted) /
+				float64(bucket.NotificationsRequested) * 100
+		}
+		result = append(result, bucket)
+	}
+	return result
+}
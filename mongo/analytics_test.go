@@ -0,0 +1,63 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_bucketElectionsAnalytics(t *testing.T) {
+	// 2024-05-06 is a monday
+	monday := time.Date(2024, 5, 6, 10, 0, 0, 0, time.UTC)
+	stats := []*ElectionAnalytics{
+		{
+			// created in the second week, but provided first
+			CreatedTime:            monday.AddDate(0, 0, 8),
+			CensusSize:             10,
+			Votes:                  4,
+			CastedWeight:           "40",
+			Voters:                 []uint64{2, 3, 4, 5},
+			NotificationsRequested: 4,
+			NotificationsAccepted:  1,
+		},
+		{
+			CreatedTime:            monday,
+			CensusSize:             10,
+			Votes:                  2,
+			CastedWeight:           "20",
+			Voters:                 []uint64{1, 2},
+			NotificationsRequested: 4,
+			NotificationsAccepted:  3,
+		},
+		{
+			// sunday of the first week
+			CreatedTime:  monday.AddDate(0, 0, 6),
+			CensusSize:   10,
+			Votes:        3,
+			CastedWeight: "30",
+			Voters:       []uint64{1, 2, 3},
+		},
+	}
+	buckets := bucketElectionsAnalytics(stats, AnalyticsIntervalWeek)
+	if len(buckets) != 2 {
+		t.Fatalf("expected 2 buckets, got %d", len(buckets))
+	}
+	first, second := buckets[0], buckets[1]
+	if !first.Start.Equal(time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected start of the first bucket: %s", first.Start)
+	}
+	if first.Polls != 2 || first.UniqueVoters != 3 || first.NewVoters != 3 || first.ReturningVoters != 0 {
+		t.Errorf("unexpected voters of the first bucket: %+v", first)
+	}
+	if first.Turnout != 25 || first.CastedWeight != "50" || first.NotificationsAcceptanceRate != 75 {
+		t.Errorf("unexpected stats of the first bucket: %+v", first)
+	}
+	if second.Polls != 1 || second.UniqueVoters != 4 || second.NewVoters != 2 || second.ReturningVoters != 2 {
+		t.Errorf("unexpected voters of the second bucket: %+v", second)
+	}
+	if second.Turnout != 40 || second.CastedWeight != "40" || second.NotificationsAcceptanceRate != 25 {
+		t.Errorf("unexpected stats of the second bucket: %+v", second)
+	}
+	if monthly := bucketElectionsAnalytics(stats, AnalyticsIntervalMonth); len(monthly) != 1 || monthly[0].NewVoters != 5 {
+		t.Errorf("unexpected monthly buckets: %+v", monthly)
+	}
+}
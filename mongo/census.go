@@ -30,8 +30,9 @@ func (ms *MongoStorage) AddCensus(censusID types.HexBytes, userFID uint64) error
 }
 
 // AddParticipantsToCensus updates a census document with participants and their associated values.
+// The participants FIDs are indexed by username, as the participants, and are optional.
 func (ms *MongoStorage) AddParticipantsToCensus(censusID types.HexBytes, participants map[string]*big.Int,
-	fromTotalParticipants uint32, totalWeight *big.Int, censusURI string,
+	participantFIDs map[string]uint64, fromTotalParticipants uint32, totalWeight *big.Int, censusURI string,
 ) error {
 	ms.keysLock.Lock()
 	defer ms.keysLock.Unlock()
@@ -46,6 +47,7 @@ func (ms *MongoStorage) AddParticipantsToCensus(censusID types.HexBytes, partici
 	update := bson.M{
 		"$set": bson.M{
 			"participants":       participantsString,
+			"participantFids":    participantFIDs,
 			"fromTotalAddresses": fromTotalParticipants,
 			"totalWeight":        totalWeight.String(),
 			"url":                censusURI,
@@ -60,6 +62,77 @@ func (ms *MongoStorage) AddParticipantsToCensus(censusID types.HexBytes, partici
 	return nil
 }
 
+// CloneCensus copies the root, the participants and the rest of the details
+// of the census with the source ID provided into the census with the
+// destination ID provided, which must already exist. It allows to reuse a
+// census for different elections keeping a census document for each one.
+func (ms *MongoStorage) CloneCensus(fromCensusID, toCensusID types.HexBytes) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var census Census
+	if err := ms.census.FindOne(ctx, bson.M{"_id": fromCensusID.String()}).Decode(&census); err != nil {
+		return fmt.Errorf("cannot find census: %w", err)
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"root":                  census.Root,
+			"participants":          census.Participants,
+			"participantFids":       census.ParticipantFIDs,
+			"fromTotalAddresses":    census.FromTotalAddresses,
+			"totalWeight":           census.TotalWeight,
+			"url":                   census.URL,
+			"delegations":           census.Delegations,
+			"delegationsAttributed": census.DelegationsAttributed,
+			"delegationSnapshotId":  census.DelegationSnapshotID,
+		},
+	}
+	if _, err := ms.census.UpdateOne(ctx, bson.M{"_id": toCensusID.String()}, update); err != nil {
+		return fmt.Errorf("cannot update census: %w", err)
+	}
+	return nil
+}
+
+// SetCensusDelegations stores the weight received by every delegate of the
+// census with the ID provided and marks its delegations as attributed.
+func (ms *MongoStorage) SetCensusDelegations(censusID types.HexBytes, delegations []CensusDelegation) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{
+			"delegations":           delegations,
+			"delegationsAttributed": true,
+		},
+	}
+	if _, err := ms.census.UpdateOne(ctx, bson.M{"_id": censusID.String()}, update); err != nil {
+		return fmt.Errorf("cannot update census: %w", err)
+	}
+	return nil
+}
+
+// SetCensusDelegationSnapshot links the snapshot of delegations with the ID
+// provided to the census with the ID provided.
+func (ms *MongoStorage) SetCensusDelegationSnapshot(censusID types.HexBytes, snapshotID string) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"delegationSnapshotId": snapshotID}}
+	if _, err := ms.census.UpdateOne(ctx, bson.M{"_id": censusID.String()}, update); err != nil {
+		return fmt.Errorf("cannot update census: %w", err)
+	}
+	return nil
+}
+
 // Census retrieves a census document based on its ID.
 func (ms *MongoStorage) Census(censusID types.HexBytes) (Census, error) {
 	ms.keysLock.RLock()
@@ -162,28 +235,43 @@ func (ms *MongoStorage) censusFromElection(electionID types.HexBytes) (*Census,
 	return &census, nil
 }
 
-// SetElectionIdForCensusRoot updates the ElectionID for a given census document by its root.
-// If the root is not found, it returns nil, indicating no error occurred.
-func (ms *MongoStorage) SetElectionIdForCensusRoot(root, electionID types.HexBytes) error {
+// SetElectionIdForCensus links the election provided to the census document
+// with the ID and the root provided. Since the censuses reused from the cache
+// share their root, if the census ID is not provided, the first census
+// document with the root provided that is not linked to any election yet is
+// used. A census document that is already linked to an election is never
+// linked again, so the census of the first election is not lost, in that case
+// it returns ErrCensusAlreadyLinked. If the census is not found, it returns
+// nil, indicating no error occurred.
+func (ms *MongoStorage) SetElectionIdForCensus(censusID, root, electionID types.HexBytes) error {
 	ms.keysLock.Lock()
 	defer ms.keysLock.Unlock()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// Prepare the update document to set the new ElectionID
-	update := bson.M{"$set": bson.M{"electionId": electionID.String()}}
-
-	// Execute the update operation
-	result, err := ms.census.UpdateOne(ctx, bson.M{"root": root.String()}, update)
+	filter := bson.M{"root": root.String()}
+	if len(censusID) > 0 {
+		filter["_id"] = censusID.String()
+	}
+	unlinked := bson.M{"electionId": bson.M{"$in": []any{"", nil}}}
+	for key, value := range filter {
+		unlinked[key] = value
+	}
+	result, err := ms.census.UpdateOne(ctx, unlinked, bson.M{"$set": bson.M{"electionId": electionID.String()}})
 	if err != nil {
 		return fmt.Errorf("cannot update ElectionID for Census with root %s: %w", root.String(), err)
 	}
-
-	// If the root is not found, MatchedCount will be 0. We treat this as a no-op success.
-	if result.MatchedCount == 0 {
+	if result.MatchedCount > 0 {
 		return nil
 	}
-
+	// check if the census exists but is already linked to other election
+	count, err := ms.census.CountDocuments(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("cannot find Census with root %s: %w", root.String(), err)
+	}
+	if count > 0 {
+		return ErrCensusAlreadyLinked
+	}
 	return nil
 }
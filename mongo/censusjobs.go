@@ -0,0 +1,182 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AddCensusJob creates a new census job in the database. The job is stored as
+// running and owned by the instance provided, with a lease that expires after
+// the duration provided. If the owner does not renew the lease before it
+// expires, any other instance can claim the job.
+func (ms *MongoStorage) AddCensusJob(job *CensusJob, owner string, lease time.Duration) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	now := time.Now()
+	job.Status = CensusJobStatusRunning
+	job.Owner = owner
+	job.LeaseUntil = now.Add(lease)
+	job.Attempts = 1
+	job.CreatedAt = now
+	job.UpdatedAt = now
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := ms.censusJobs.InsertOne(ctx, job); err != nil {
+		return fmt.Errorf("cannot insert census job: %w", err)
+	}
+	return nil
+}
+
+// CensusJob retrieves the census job with the given census ID. It returns
+// ErrCensusJobUnknown if the job does not exist.
+func (ms *MongoStorage) CensusJob(censusID string) (*CensusJob, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job := &CensusJob{}
+	if err := ms.censusJobs.FindOne(ctx, bson.M{"_id": censusID}).Decode(job); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrCensusJobUnknown
+		}
+		return nil, fmt.Errorf("cannot find census job: %w", err)
+	}
+	return job, nil
+}
+
+// FreshCensusJob returns the most recent census job with the cache key
+// provided that has been built successfully in the last period of time
+// provided. It returns ErrCensusJobUnknown if there is no fresh job.
+func (ms *MongoStorage) FreshCensusJob(cacheKey string, maxAge time.Duration) (*CensusJob, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	filter := bson.M{
+		"cacheKey":  cacheKey,
+		"status":    CensusJobStatusDone,
+		"updatedAt": bson.M{"$gte": time.Now().Add(-maxAge)},
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "updatedAt", Value: -1}})
+	job := &CensusJob{}
+	if err := ms.censusJobs.FindOne(ctx, filter, opts).Decode(job); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrCensusJobUnknown
+		}
+		return nil, fmt.Errorf("cannot find fresh census job: %w", err)
+	}
+	return job, nil
+}
+
+// ClaimNextCensusJob atomically takes the ownership of the oldest unfinished
+// census job whose lease has expired, which means that it was never started
+// or that the instance that was building it has stopped. The attempts counter
+// of the job is increased. It returns ErrCensusJobUnknown if there is no job
+// to claim.
+func (ms *MongoStorage) ClaimNextCensusJob(owner string, lease time.Duration) (*CensusJob, error) {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	now := time.Now()
+	filter := bson.M{
+		"status":     bson.M{"$in": []string{CensusJobStatusPending, CensusJobStatusRunning}},
+		"leaseUntil": bson.M{"$lt": now},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":     CensusJobStatusRunning,
+			"owner":      owner,
+			"leaseUntil": now.Add(lease),
+			"updatedAt":  now,
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+		SetReturnDocument(options.After)
+	job := &CensusJob{}
+	if err := ms.censusJobs.FindOneAndUpdate(ctx, filter, update, opts).Decode(job); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrCensusJobUnknown
+		}
+		return nil, fmt.Errorf("cannot claim census job: %w", err)
+	}
+	return job, nil
+}
+
+// UpdateCensusJobProgress updates the current step and progress of the census
+// job and renews its lease. It only succeeds if the job is still owned by the
+// owner provided, otherwise it returns ErrCensusJobUnknown.
+func (ms *MongoStorage) UpdateCensusJobProgress(censusID, owner string, step, totalSteps int,
+	progress uint32, lease time.Duration,
+) error {
+	return ms.updateOwnedCensusJob(censusID, owner, bson.M{
+		"step":       step,
+		"totalSteps": totalSteps,
+		"progress":   progress,
+		"leaseUntil": time.Now().Add(lease),
+	})
+}
+
+// RenewCensusJobLease extends the lease of the census job owned by the owner
+// provided. It returns ErrCensusJobUnknown if the job is not owned by it
+// anymore.
+func (ms *MongoStorage) RenewCensusJobLease(censusID, owner string, lease time.Duration) error {
+	return ms.updateOwnedCensusJob(censusID, owner, bson.M{
+		"leaseUntil": time.Now().Add(lease),
+	})
+}
+
+// FinishCensusJob marks the census job as done and stores its result.
+func (ms *MongoStorage) FinishCensusJob(censusID, owner string, result *CensusJobResult) error {
+	return ms.updateOwnedCensusJob(censusID, owner, bson.M{
+		"status":   CensusJobStatusDone,
+		"progress": 100,
+		"result":   result,
+		"error":    "",
+	})
+}
+
+// FailCensusJob marks the census job as failed and stores the error message.
+func (ms *MongoStorage) FailCensusJob(censusID, owner, errMsg string) error {
+	return ms.updateOwnedCensusJob(censusID, owner, bson.M{
+		"status": CensusJobStatusFailed,
+		"error":  errMsg,
+	})
+}
+
+// updateOwnedCensusJob sets the fields provided in the census job with the
+// given ID if it is owned by the owner provided and it is not finished yet.
+// It returns ErrCensusJobUnknown if no job matches.
+func (ms *MongoStorage) updateOwnedCensusJob(censusID, owner string, fields bson.M) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	fields["updatedAt"] = time.Now()
+	filter := bson.M{
+		"_id":    censusID,
+		"owner":  owner,
+		"status": bson.M{"$in": []string{CensusJobStatusPending, CensusJobStatusRunning}},
+	}
+	res, err := ms.censusJobs.UpdateOne(ctx, filter, bson.M{"$set": fields})
+	if err != nil {
+		return fmt.Errorf("cannot update census job: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrCensusJobUnknown
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/types"
@@ -217,7 +218,8 @@ func (ms *MongoStorage) CommunitiesByVoter(userID uint64) ([]Community, error) {
 // database.
 func (ms *MongoStorage) addCommunity(community *Community) error {
 	switch community.Census.Type {
-	case TypeCommunityCensusChannel, TypeCommunityCensusERC20, TypeCommunityCensusNFT, TypeCommunityCensusFollowers:
+	case TypeCommunityCensusChannel, TypeCommunityCensusERC20, TypeCommunityCensusNFT, TypeCommunityCensusFollowers,
+		TypeCommunityCensusERC20Votes, TypeCommunityCensusEngaged, TypeCommunityCensusMembers:
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		_, err := ms.communities.InsertOne(ctx, community)
@@ -327,3 +329,89 @@ func (ms *MongoStorage) SetCommunityNotifications(communityID string, enabled bo
 	_, err := ms.communities.UpdateOne(ctx, bson.M{"_id": communityID}, bson.M{"$set": bson.M{"notifications": enabled}})
 	return err
 }
+
+// SetCommunityCensusWeighting sets the weighting applied to the censuses built
+// for the community with the given ID. If the weighting is nil, it is removed.
+func (ms *MongoStorage) SetCommunityCensusWeighting(communityID string, weighting *CensusWeighting) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	update := bson.M{"$set": bson.M{"censusWeighting": weighting}}
+	if weighting == nil {
+		update = bson.M{"$unset": bson.M{"censusWeighting": ""}}
+	}
+	_, err := ms.communities.UpdateOne(ctx, bson.M{"_id": communityID}, update)
+	return err
+}
+
+// SetCommunityCensusCacheTTL sets the time in seconds that a census built for
+// the community with the given ID can be reused.
+func (ms *MongoStorage) SetCommunityCensusCacheTTL(communityID string, ttl uint64) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := ms.communities.UpdateOne(ctx, bson.M{"_id": communityID}, bson.M{"$set": bson.M{"censusCacheTTL": ttl}})
+	return err
+}
+
+// HasCommunityPermission checks if the user has a role in the community with
+// the given ID that includes the permission provided.
+func (ms *MongoStorage) HasCommunityPermission(userID uint64, communityID string, permission CommunityPermission) bool {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+	community, err := ms.community(communityID)
+	if err != nil {
+		log.Warnw("error getting community", "community", communityID, "error", err)
+		return false
+	}
+	if community == nil {
+		return false
+	}
+	return community.Can(userID, permission)
+}
+
+// SetCommunityRoles sets the roles of the members of the community with the
+// given ID, replacing the previous ones.
+func (ms *MongoStorage) SetCommunityRoles(communityID string, roles []CommunityMemberRole) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := ms.communities.UpdateOne(ctx, bson.M{"_id": communityID}, bson.M{"$set": bson.M{"roles": roles}})
+	return err
+}
+
+// CommunityHubCursor returns the cursor of the events of the community hub
+// contract of the chain provided. It returns nil if the events of the chain
+// have not been scanned yet.
+func (ms *MongoStorage) CommunityHubCursor(chainAlias string) (*CommunityHubCursor, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor := &CommunityHubCursor{}
+	if err := ms.communityHubCursors.FindOne(ctx, bson.M{"_id": chainAlias}).Decode(cursor); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return cursor, nil
+}
+
+// SetCommunityHubCursor stores the cursor of the events of the community hub
+// contract provided, replacing the previous one of its chain.
+func (ms *MongoStorage) SetCommunityHubCursor(cursor *CommunityHubCursor) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor.UpdatedAt = time.Now()
+	opts := options.Replace().SetUpsert(true)
+	_, err := ms.communityHubCursors.ReplaceOne(ctx, bson.M{"_id": cursor.ChainAlias}, cursor, opts)
+	return err
+}
@@ -0,0 +1,43 @@
+package mongo
+
+import "testing"
+
+func Test_communityRoleOf(t *testing.T) {
+	community := &Community{
+		Creator: 1,
+		Admins:  []uint64{1, 2, 3},
+		Roles: []CommunityMemberRole{
+			{FID: 3, Role: CommunityRoleOwner},
+			{FID: 4, Role: CommunityRolePollCreator},
+			{FID: 5, Role: CommunityRoleModerator},
+			// stale admin role of a user that is not an admin anymore
+			{FID: 6, Role: CommunityRoleAdmin},
+		},
+	}
+	expected := map[uint64]CommunityRole{
+		1: CommunityRoleOwner,
+		2: CommunityRoleAdmin,
+		3: CommunityRoleOwner,
+		4: CommunityRolePollCreator,
+		5: CommunityRoleModerator,
+		6: "",
+		7: "",
+	}
+	for fid, role := range expected {
+		if got := community.RoleOf(fid); got != role {
+			t.Errorf("unexpected role of %d: expected %q, got %q", fid, role, got)
+		}
+	}
+	if !community.Can(4, PermissionCreatePoll) || community.Can(4, PermissionSendReminders) {
+		t.Error("unexpected permissions of the poll creator")
+	}
+	if !community.Can(5, PermissionSendReminders) || community.Can(5, PermissionCreatePoll) {
+		t.Error("unexpected permissions of the moderator")
+	}
+	if community.Can(2, PermissionManageRoles) || !community.Can(3, PermissionManageRoles) {
+		t.Error("only the owners can manage the roles")
+	}
+	if roles := community.MemberRoles(); len(roles) != 5 {
+		t.Errorf("unexpected number of member roles: %d", len(roles))
+	}
+}
@@ -2,15 +2,40 @@ package mongo
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// DefaultMaxDelegationDepth is the default maximum number of delegations that
+// can be chained in a community.
+const DefaultMaxDelegationDepth = 5
+
+// maxDelegationDepth is the maximum number of delegations that can be chained
+// in a community.
+var maxDelegationDepth = DefaultMaxDelegationDepth
+
+// SetMaxDelegationDepth sets the maximum number of delegations that can be
+// chained in a community. If it is zero or negative, the default value is
+// used.
+func SetMaxDelegationDepth(depth int) {
+	if depth <= 0 {
+		depth = DefaultMaxDelegationDepth
+	}
+	maxDelegationDepth = depth
+}
+
 // SetDelegation inserts a delegation into the database and returns the ID of
-// the inserted delegation
+// the inserted delegation. The delegation is validated against the rest of
+// delegations of the community, it returns ErrSelfDelegation,
+// ErrDelegationCycle or ErrDelegationTooDeep if it is not valid. Since other
+// instances can insert delegations of the same community concurrently, the
+// delegation is validated again once it is inserted, and removed if it is not
+// valid anymore.
 func (ms *MongoStorage) SetDelegation(delegation Delegation) (string, error) {
 	// Insert the delegation into the database and retrieve the ID
 	ms.keysLock.Lock()
@@ -19,13 +44,53 @@ func (ms *MongoStorage) SetDelegation(delegation Delegation) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	// validate the delegation graph of the community with the new delegation,
+	// including the delegations that are not active yet
+	if err := ms.validateCommunityDelegation(ctx, delegation); err != nil {
+		return "", err
+	}
 	delegation.ID = primitive.NewObjectID()
 	if _, err := ms.delegations.InsertOne(ctx, delegation); err != nil {
 		return "", err
 	}
+	// check the graph again including the delegations inserted meanwhile,
+	// if two conflicting delegations are inserted at the same time, both
+	// are removed
+	if err := ms.validateCommunityDelegation(ctx, delegation); err != nil {
+		if _, delErr := ms.delegations.DeleteOne(ctx, bson.M{"_id": delegation.ID}); delErr != nil {
+			return "", fmt.Errorf("%w: %w", err, delErr)
+		}
+		return "", err
+	}
+	// register the creation in the delegations history, the delegator is
+	// the only one that can create a delegation
+	if err := ms.addDelegationEvent(ctx, DelegationEventCreate, delegation, delegation.From); err != nil {
+		return "", err
+	}
 	return delegation.ID.Hex(), nil
 }
 
+// validateCommunityDelegation checks that the delegation provided is valid
+// with the rest of delegations of its community that have not expired. The
+// delegation itself is excluded if it is already stored. It must be called
+// with the lock held.
+func (ms *MongoStorage) validateCommunityDelegation(ctx context.Context, delegation Delegation) error {
+	communityDelegations, err := ms.filterDelegations(ctx, bson.M{"communityId": delegation.CommuniyID})
+	if err != nil {
+		return err
+	}
+	current := []Delegation{}
+	for _, d := range communityDelegations {
+		if d.ID == delegation.ID {
+			continue
+		}
+		if d.ValidUntil == nil || d.ValidUntil.After(time.Now()) {
+			current = append(current, d)
+		}
+	}
+	return validateDelegation(current, delegation, maxDelegationDepth)
+}
+
 // Delegation retrieves a delegation from the database by its ID
 func (ms *MongoStorage) Delegation(id string) (Delegation, error) {
 	ms.keysLock.RLock()
@@ -81,17 +146,20 @@ func (ms *MongoStorage) DelegationsByCommunity(communityID string) ([]Delegation
 }
 
 // FinalDelegationsByCommunity retrieves all delegations to a community by the
-// community ID provided, solving nested delegations
+// community ID provided, solving nested delegations. The delegations that are
+// not active at the moment of the call are ignored.
 func (ms *MongoStorage) FinalDelegationsByCommunity(communityID string) ([]Delegation, error) {
 	communityDelegations, err := ms.DelegationsByCommunity(communityID)
 	if err != nil {
 		return nil, err
 	}
+	communityDelegations = activeDelegations(communityDelegations, time.Now())
 	return solveNestedDelegations(communityDelegations, nil), nil
 }
 
 // DelegationsByCommunityFrom retrieves all delegations from a user to a
-// community by the community ID and user ID provided
+// community by the community ID and user ID provided. The delegations that are
+// not active at the moment of the call are ignored.
 func (ms *MongoStorage) DelegationsByCommunityFrom(communityID string, userID uint64) ([]Delegation, error) {
 	ms.keysLock.RLock()
 	defer ms.keysLock.RUnlock()
@@ -103,6 +171,7 @@ func (ms *MongoStorage) DelegationsByCommunityFrom(communityID string, userID ui
 	if err != nil {
 		return nil, err
 	}
+	communityDelegations = activeDelegations(communityDelegations, time.Now())
 	userDelegations := []Delegation{}
 	for _, delegation := range communityDelegations {
 		if delegation.From == userID {
@@ -112,8 +181,9 @@ func (ms *MongoStorage) DelegationsByCommunityFrom(communityID string, userID ui
 	return solveNestedDelegations(communityDelegations, userDelegations), nil
 }
 
-// DeleteDelegation deletes a delegation from the database by its ID
-func (ms *MongoStorage) DeleteDelegation(id string) error {
+// DeleteDelegation deletes a delegation from the database by its ID and
+// registers its revocation in the delegations history by the actor provided.
+func (ms *MongoStorage) DeleteDelegation(id string, actorFID uint64) error {
 	ms.keysLock.Lock()
 	defer ms.keysLock.Unlock()
 
@@ -125,10 +195,166 @@ func (ms *MongoStorage) DeleteDelegation(id string) error {
 		return err
 	}
 
-	_, err = ms.delegations.DeleteOne(ctx, bson.M{"_id": _id})
+	var delegation Delegation
+	if err := ms.delegations.FindOneAndDelete(ctx, bson.M{"_id": _id}).Decode(&delegation); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		return err
+	}
+	return ms.addDelegationEvent(ctx, DelegationEventRevoke, delegation, actorFID)
+}
+
+// DelegationHistory retrieves the events of the delegations of the community
+// provided, sorted from the newest to the oldest.
+func (ms *MongoStorage) DelegationHistory(communityID string) ([]DelegationEvent, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}})
+	cursor, err := ms.delegationEvents.Find(ctx, bson.M{"communityId": communityID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	events := []DelegationEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// AddDelegationSnapshot stores the resolved delegations provided as a
+// snapshot of the delegations of the community provided and returns its ID.
+func (ms *MongoStorage) AddDelegationSnapshot(communityID string, delegations []Delegation) (string, error) {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if delegations == nil {
+		delegations = []Delegation{}
+	}
+	snapshot := DelegationSnapshot{
+		ID:          primitive.NewObjectID(),
+		CommunityID: communityID,
+		Delegations: delegations,
+		CreatedAt:   time.Now(),
+	}
+	if _, err := ms.delegationSnapshots.InsertOne(ctx, snapshot); err != nil {
+		return "", err
+	}
+	return snapshot.ID.Hex(), nil
+}
+
+// DelegationSnapshot retrieves a snapshot of delegations by its ID.
+func (ms *MongoStorage) DelegationSnapshot(id string) (*DelegationSnapshot, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_id, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot DelegationSnapshot
+	if err := ms.delegationSnapshots.FindOne(ctx, bson.M{"_id": _id}).Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// addDelegationEvent registers an event of the type provided about the
+// delegation provided, performed by the actor provided. It does not adquire
+// the keysLock.
+func (ms *MongoStorage) addDelegationEvent(ctx context.Context, eventType string, delegation Delegation,
+	actorFID uint64,
+) error {
+	event := DelegationEvent{
+		ID:           primitive.NewObjectID(),
+		Type:         eventType,
+		DelegationID: delegation.ID.Hex(),
+		Delegation:   delegation,
+		CommunityID:  delegation.CommuniyID,
+		ActorFID:     actorFID,
+		Timestamp:    time.Now(),
+	}
+	_, err := ms.delegationEvents.InsertOne(ctx, event)
 	return err
 }
 
+// DelegationsExpiringBefore retrieves the active delegations that expire
+// before the time provided and whose parties have not been notified about
+// the expiration yet.
+func (ms *MongoStorage) DelegationsExpiringBefore(deadline time.Time) ([]Delegation, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return ms.filterDelegations(ctx, bson.M{
+		"validUntil":     bson.M{"$gt": time.Now(), "$lte": deadline},
+		"expiryNotified": bson.M{"$ne": true},
+	})
+}
+
+// SetDelegationExpiryNotified marks the delegation with the ID provided as
+// notified about its expiration, so its parties are not notified again.
+func (ms *MongoStorage) SetDelegationExpiryNotified(id primitive.ObjectID) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := ms.delegations.UpdateOne(ctx, bson.M{"_id": id},
+		bson.M{"$set": bson.M{"expiryNotified": true}})
+	return err
+}
+
+// ExpiredDelegations retrieves the delegations whose validity period has
+// ended and whose expiration has not been registered yet.
+func (ms *MongoStorage) ExpiredDelegations() ([]Delegation, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return ms.filterDelegations(ctx, bson.M{
+		"validUntil": bson.M{"$lte": time.Now()},
+		"expired":    bson.M{"$ne": true},
+	})
+}
+
+// SetDelegationExpired marks the delegation provided as expired and registers
+// its expiration in the delegations history. It returns false if the
+// expiration was already registered, for example by other instance.
+func (ms *MongoStorage) SetDelegationExpired(delegation Delegation) (bool, error) {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := ms.delegations.UpdateOne(ctx,
+		bson.M{"_id": delegation.ID, "expired": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"expired": true}})
+	if err != nil {
+		return false, err
+	}
+	if res.ModifiedCount == 0 {
+		return false, nil
+	}
+	return true, ms.addDelegationEvent(ctx, DelegationEventExpire, delegation, 0)
+}
+
 func (ms *MongoStorage) filterDelegations(ctx context.Context, filter bson.M) ([]Delegation, error) {
 	cursor, err := ms.delegations.Find(ctx, filter)
 	if err != nil {
@@ -148,22 +374,115 @@ func (ms *MongoStorage) filterDelegations(ctx context.Context, filter bson.M) ([
 	return delegations, nil
 }
 
+// validateDelegation checks that the delegation provided can be added to the
+// delegations provided. It returns ErrSelfDelegation if the delegator is also
+// the delegate, ErrDelegationCycle if the delegate already delegates, directly
+// or through other users, to the delegator and ErrDelegationTooDeep if the
+// delegation makes a chain of delegations longer than the maximum depth.
+func validateDelegation(delegations []Delegation, delegation Delegation, maxDepth int) error {
+	if delegation.From == delegation.To {
+		return ErrSelfDelegation
+	}
+	outgoing := map[uint64][]uint64{}
+	incoming := map[uint64][]uint64{}
+	for _, d := range delegations {
+		outgoing[d.From] = append(outgoing[d.From], d.To)
+		incoming[d.To] = append(incoming[d.To], d.From)
+	}
+	// the delegation creates a cycle if the delegator can be reached from
+	// the delegate
+	if reachable(outgoing, delegation.To, delegation.From) {
+		return ErrDelegationCycle
+	}
+	// the longest chain that includes the new delegation is the longest chain
+	// that ends in the delegator, plus the new delegation, plus the longest
+	// chain that starts in the delegate
+	depth := longestChain(incoming, delegation.From, map[uint64]bool{}) + 1 +
+		longestChain(outgoing, delegation.To, map[uint64]bool{})
+	if depth > maxDepth {
+		return fmt.Errorf("%w: %d delegations chained, the maximum is %d", ErrDelegationTooDeep, depth, maxDepth)
+	}
+	return nil
+}
+
+// reachable returns true if the target user can be reached from the user
+// provided following the edges of the graph provided.
+func reachable(edges map[uint64][]uint64, from, target uint64) bool {
+	visited := map[uint64]bool{}
+	pending := []uint64{from}
+	for len(pending) > 0 {
+		current := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if current == target {
+			return true
+		}
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		pending = append(pending, edges[current]...)
+	}
+	return false
+}
+
+// longestChain returns the number of edges of the longest path that starts in
+// the user provided following the edges of the graph provided. The users of
+// the current path are tracked to stop on cycles.
+func longestChain(edges map[uint64][]uint64, from uint64, path map[uint64]bool) int {
+	path[from] = true
+	defer delete(path, from)
+	longest := 0
+	for _, next := range edges[from] {
+		if path[next] {
+			continue
+		}
+		longest = max(longest, longestChain(edges, next, path)+1)
+	}
+	return longest
+}
+
+// activeDelegations returns the delegations of the list provided that are
+// active at the time provided.
+func activeDelegations(delegations []Delegation, at time.Time) []Delegation {
+	active := []Delegation{}
+	for _, delegation := range delegations {
+		if delegation.Active(at) {
+			active = append(active, delegation)
+		}
+	}
+	return active
+}
+
 // solveNestedDelegations itereates over the list of delegations and solves
 // chains of delegations, for example, if user A delegates to user B and user B
 // delegates to user C, the function will return a list of delegations where
-// user A delegates to user C and user B delegates to user C.
+// user A delegates to user C and user B delegates to user C. The shares of the
+// chained delegations are multiplied, so if user B only delegates a part of
+// its weight, the rest of the share of user A is kept by user B. The shares
+// are rounded down in favour of the intermediate delegate, so the result is
+// deterministic. The shares of the resulting delegations are always relative
+// to the weight of their delegator. If the delegations contain a cycle, the
+// chain is cut at the delegation that closes it.
 func solveNestedDelegations(original, filtered []Delegation) []Delegation {
 	if filtered == nil {
 		filtered = append([]Delegation{}, original...)
 	}
+	return solveDelegationChains(original, filtered, map[uint64]bool{})
+}
+
+// solveDelegationChains solves the chains of the filtered delegations, the
+// path contains the users already visited in the current chain.
+func solveDelegationChains(original, filtered []Delegation, path map[uint64]bool) []Delegation {
 	finalDelegations := []Delegation{}
 	for _, delegation := range filtered {
 		// check if the delegation is to a user that has already delegated to
-		// another user
+		// another user, unless the user is already in the current chain
 		delegateDelegations := []Delegation{}
-		for _, originalDelegation := range original {
-			if originalDelegation.From == delegation.To {
-				delegateDelegations = append(delegateDelegations, originalDelegation)
+		if !path[delegation.To] {
+			for _, originalDelegation := range original {
+				if originalDelegation.From == delegation.To {
+					delegateDelegations = append(delegateDelegations, originalDelegation)
+				}
 			}
 		}
 		if len(delegateDelegations) == 0 {
@@ -171,13 +490,35 @@ func solveNestedDelegations(original, filtered []Delegation) []Delegation {
 			continue
 		}
 		// solve the nested delegations for the current delegation and append
-		// them to the final list
-		for _, nestedDelegation := range solveNestedDelegations(original, delegateDelegations) {
+		// them to the final list with the share of the current delegation
+		// that they move
+		share := delegation.Shared()
+		moved := uint32(0)
+		path[delegation.From] = true
+		nestedDelegations := solveDelegationChains(original, delegateDelegations, path)
+		delete(path, delegation.From)
+		for _, nestedDelegation := range nestedDelegations {
+			// skip the chains that return to the delegator, its share is
+			// kept by the intermediate delegate
+			if nestedDelegation.To == delegation.From {
+				continue
+			}
+			nestedShare := uint32(uint64(share) * uint64(nestedDelegation.Shared()) / DelegationFullShare)
+			if nestedShare == 0 {
+				continue
+			}
+			moved += nestedShare
 			// keep the original delegation ID and from user
 			nestedDelegation.ID = delegation.ID
 			nestedDelegation.From = delegation.From
+			nestedDelegation.Share = nestedShare
 			finalDelegations = append(finalDelegations, nestedDelegation)
 		}
+		// the rest of the share is kept by the intermediate delegate
+		if moved < share {
+			delegation.Share = share - moved
+			finalDelegations = append(finalDelegations, delegation)
+		}
 	}
 	return finalDelegations
 }
@@ -1,7 +1,10 @@
 package mongo
 
 import (
+	"errors"
+	"math/big"
 	"testing"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -111,3 +114,179 @@ func Test_solveNestedDelegations(t *testing.T) {
 		}
 	}
 }
+
+func Test_activeDelegations(t *testing.T) {
+	var (
+		now    = time.Now()
+		past   = now.Add(-time.Hour)
+		future = now.Add(time.Hour)
+	)
+	delegations := []Delegation{
+		{From: 1, To: 2, CommuniyID: "a"},
+		{From: 2, To: 3, CommuniyID: "a", ValidFrom: &past, ValidUntil: &future},
+		{From: 3, To: 4, CommuniyID: "a", ValidUntil: &past},
+		{From: 4, To: 5, CommuniyID: "a", ValidFrom: &future},
+		{From: 5, To: 6, CommuniyID: "a", ValidUntil: &now},
+	}
+	results := activeDelegations(delegations, now)
+	if len(results) != 2 {
+		t.Fatalf("expected len 2, got %d", len(results))
+	}
+	if results[0].From != 1 || results[1].From != 2 {
+		t.Errorf("unexpected active delegations %v", results)
+	}
+	// expired delegations must break the chains of nested delegations
+	nested := solveNestedDelegations(results, nil)
+	for _, delegation := range nested {
+		if delegation.To > 3 {
+			t.Errorf("expected inactive delegations to be ignored, got %v", delegation)
+		}
+	}
+}
+
+func Test_solveNestedPartialDelegations(t *testing.T) {
+	// user 1 delegates half of its weight to user 2 and the other half to
+	// user 3, user 2 delegates a quarter of its weight to user 4
+	delegations := []Delegation{
+		{From: 1, To: 2, CommuniyID: "a", Share: 5000},
+		{From: 1, To: 3, CommuniyID: "a", Share: 5000},
+		{From: 2, To: 4, CommuniyID: "a", Share: 2500},
+	}
+	expected := map[[2]uint64]uint32{
+		{1, 2}: 3750,
+		{1, 3}: 5000,
+		{1, 4}: 1250,
+		{2, 4}: 2500,
+	}
+	results := solveNestedDelegations(delegations, nil)
+	if len(results) != len(expected) {
+		t.Fatalf("expected len %d, got %d", len(expected), len(results))
+	}
+	for _, result := range results {
+		share, ok := expected[[2]uint64{result.From, result.To}]
+		if !ok {
+			t.Errorf("unexpected delegation %v", result)
+			continue
+		}
+		if result.Shared() != share {
+			t.Errorf("expected share %d for %d->%d, got %d", share, result.From, result.To, result.Shared())
+		}
+	}
+	if share := DelegatedShare(results, 1); share != DelegationFullShare {
+		t.Errorf("expected user 1 to delegate the full share, got %d", share)
+	}
+	if share := DelegatedShare(results, 2); share != 2500 {
+		t.Errorf("expected user 2 to delegate 2500, got %d", share)
+	}
+	// the portions are rounded down
+	portion := results[0].Portion(big.NewInt(3))
+	if expected := int64(3) * int64(results[0].Shared()) / DelegationFullShare; portion.Int64() != expected {
+		t.Errorf("expected portion %d, got %d", expected, portion.Int64())
+	}
+}
+
+func Test_solveNestedDelegationsCycle(t *testing.T) {
+	// a cycle must not hang the resolution of the delegations
+	delegations := []Delegation{
+		{From: 1, To: 2, CommuniyID: "a"},
+		{From: 2, To: 3, CommuniyID: "a"},
+		{From: 3, To: 1, CommuniyID: "a"},
+	}
+	results := solveNestedDelegations(delegations, nil)
+	if len(results) != len(delegations) {
+		t.Fatalf("expected len %d, got %d", len(delegations), len(results))
+	}
+	for _, result := range results {
+		if result.From == result.To {
+			t.Errorf("unexpected self delegation %v", result)
+		}
+	}
+}
+
+func Test_validateDelegation(t *testing.T) {
+	chain := []Delegation{
+		{From: 1, To: 2, CommuniyID: "a"},
+		{From: 2, To: 3, CommuniyID: "a"},
+		{From: 3, To: 4, CommuniyID: "a"},
+	}
+	tests := []struct {
+		name       string
+		delegation Delegation
+		maxDepth   int
+		err        error
+	}{
+		{"self delegation", Delegation{From: 5, To: 5}, 5, ErrSelfDelegation},
+		{"direct cycle", Delegation{From: 2, To: 1}, 5, ErrDelegationCycle},
+		{"indirect cycle", Delegation{From: 4, To: 1}, 5, ErrDelegationCycle},
+		{"chain end", Delegation{From: 4, To: 5}, 4, nil},
+		{"chain start", Delegation{From: 5, To: 1}, 4, nil},
+		{"too deep at the end", Delegation{From: 4, To: 5}, 3, ErrDelegationTooDeep},
+		{"too deep at the start", Delegation{From: 5, To: 1}, 3, ErrDelegationTooDeep},
+		{"new branch", Delegation{From: 5, To: 6}, 1, nil},
+	}
+	for _, tc := range tests {
+		err := validateDelegation(chain, tc.delegation, tc.maxDepth)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.err, err)
+		}
+	}
+}
+
+func Test_delegationOverlaps(t *testing.T) {
+	at := func(day int) *time.Time {
+		date := time.Date(2026, time.January, day, 0, 0, 0, 0, time.UTC)
+		return &date
+	}
+	tests := []struct {
+		name     string
+		a, b     Delegation
+		expected bool
+	}{
+		{
+			name:     "unbounded",
+			a:        Delegation{},
+			b:        Delegation{ValidFrom: at(1), ValidUntil: at(2)},
+			expected: true,
+		},
+		{
+			name:     "consecutive periods",
+			a:        Delegation{ValidFrom: at(1), ValidUntil: at(10)},
+			b:        Delegation{ValidFrom: at(10), ValidUntil: at(20)},
+			expected: false,
+		},
+		{
+			name:     "overlapping periods",
+			a:        Delegation{ValidFrom: at(1), ValidUntil: at(11)},
+			b:        Delegation{ValidFrom: at(10), ValidUntil: at(20)},
+			expected: true,
+		},
+		{
+			name:     "future delegation",
+			a:        Delegation{ValidFrom: at(20)},
+			b:        Delegation{ValidFrom: at(1), ValidUntil: at(10)},
+			expected: false,
+		},
+		{
+			name:     "expired delegation",
+			a:        Delegation{ValidUntil: at(5)},
+			b:        Delegation{ValidFrom: at(5)},
+			expected: false,
+		},
+		{
+			name:     "contained period",
+			a:        Delegation{ValidFrom: at(1), ValidUntil: at(30)},
+			b:        Delegation{ValidFrom: at(10), ValidUntil: at(20)},
+			expected: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.a.Overlaps(tc.b); got != tc.expected {
+				t.Errorf("unexpected overlap: expected %v, got %v", tc.expected, got)
+			}
+			if got := tc.b.Overlaps(tc.a); got != tc.expected {
+				t.Errorf("unexpected reverse overlap: expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
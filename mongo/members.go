@@ -0,0 +1,154 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AddCommunityMembers adds the users provided to the members list of the
+// community provided. The users that requested to join the community are
+// approved and the users that are already members are kept as they are.
+func (ms *MongoStorage) AddCommunityMembers(communityID string, fids []uint64, addedBy uint64) error {
+	if len(fids) == 0 {
+		return nil
+	}
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	models := []mongo.WriteModel{}
+	for _, fid := range fids {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"communityId": communityID, "fid": fid}).
+			SetUpdate(bson.M{"$set": bson.M{
+				"status":    CommunityMemberStatusMember,
+				"addedBy":   addedBy,
+				"updatedAt": time.Now(),
+			}}).
+			SetUpsert(true))
+	}
+	_, err := ms.communityMembers.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// RemoveCommunityMember removes the user provided from the members list of
+// the community provided, or rejects its request to join it.
+func (ms *MongoStorage) RemoveCommunityMember(communityID string, fid uint64) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := ms.communityMembers.DeleteOne(ctx, bson.M{"communityId": communityID, "fid": fid})
+	return err
+}
+
+// AddCommunityJoinRequest registers a request of the user provided to join
+// the community provided. It returns ErrAlreadyCommunityMember if the user is
+// already a member of the community. Repeated requests are ignored.
+func (ms *MongoStorage) AddCommunityJoinRequest(communityID string, fid uint64) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var member CommunityMember
+	err := ms.communityMembers.FindOne(ctx, bson.M{"communityId": communityID, "fid": fid}).Decode(&member)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	if err := joinRequestAllowed(member.Status); err != nil || member.Status != "" {
+		return err
+	}
+	_, err = ms.communityMembers.InsertOne(ctx, CommunityMember{
+		CommunityID: communityID,
+		FID:         fid,
+		Status:      CommunityMemberStatusPending,
+		UpdatedAt:   time.Now(),
+	})
+	return err
+}
+
+// CommunityMembers returns the FIDs of the members of the community provided.
+func (ms *MongoStorage) CommunityMembers(communityID string) ([]uint64, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	members, err := ms.communityMembersByStatus(communityID, CommunityMemberStatusMember)
+	if err != nil {
+		return nil, err
+	}
+	fids := []uint64{}
+	for _, member := range members {
+		fids = append(fids, member.FID)
+	}
+	return fids, nil
+}
+
+// CommunityJoinRequests returns the pending requests to join the community
+// provided.
+func (ms *MongoStorage) CommunityJoinRequests(communityID string) ([]CommunityMember, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	return ms.communityMembersByStatus(communityID, CommunityMemberStatusPending)
+}
+
+// IsCommunityJoinRequest returns true if the user provided has a pending
+// request to join the community provided.
+func (ms *MongoStorage) IsCommunityJoinRequest(communityID string, fid uint64) (bool, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	count, err := ms.communityMembers.CountDocuments(ctx, bson.M{
+		"communityId": communityID,
+		"fid":         fid,
+		"status":      CommunityMemberStatusPending,
+	})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// joinRequestAllowed checks if a user with the status provided in the members
+// list of a community can request to join it. The status is empty if the user
+// is not in the list. The members cannot request to join again, and the users
+// with a pending request can, but their request is kept as it is.
+func joinRequestAllowed(status string) error {
+	if status == CommunityMemberStatusMember {
+		return ErrAlreadyCommunityMember
+	}
+	return nil
+}
+
+// communityMembersByStatus returns the entries of the members list of the
+// community provided with the status provided, sorted by FID. It does not
+// adquire the keysLock.
+func (ms *MongoStorage) communityMembersByStatus(communityID, status string) ([]CommunityMember, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "fid", Value: 1}})
+	cursor, err := ms.communityMembers.Find(ctx, bson.M{"communityId": communityID, "status": status}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	members := []CommunityMember{}
+	if err := cursor.All(ctx, &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
@@ -0,0 +1,58 @@
+package mongo
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_communityJoinFlow(t *testing.T) {
+	// every step updates the status of the user in the members list as the
+	// storage does: the join requests insert a pending entry if the user is
+	// not in the list, the approvals set it as member and the rejections and
+	// removals delete it
+	tests := []struct {
+		name        string
+		action      string
+		expected    string
+		expectedErr error
+	}{
+		{name: "request to join", action: "join", expected: CommunityMemberStatusPending},
+		{name: "repeated request", action: "join", expected: CommunityMemberStatusPending},
+		{name: "rejected request", action: "remove", expected: ""},
+		{name: "request after rejection", action: "join", expected: CommunityMemberStatusPending},
+		{name: "approved request", action: "approve", expected: CommunityMemberStatusMember},
+		{
+			name:        "request of a member",
+			action:      "join",
+			expected:    CommunityMemberStatusMember,
+			expectedErr: ErrAlreadyCommunityMember,
+		},
+		{name: "removed member", action: "remove", expected: ""},
+		{name: "request after removal", action: "join", expected: CommunityMemberStatusPending},
+	}
+	status := ""
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			switch tc.action {
+			case "join":
+				err := joinRequestAllowed(status)
+				if !errors.Is(err, tc.expectedErr) {
+					t.Fatalf("unexpected error: expected %v, got %v", tc.expectedErr, err)
+				}
+				if err == nil && status == "" {
+					status = CommunityMemberStatusPending
+				}
+			case "approve":
+				if status != CommunityMemberStatusPending {
+					t.Fatalf("cannot approve a user with status %q", status)
+				}
+				status = CommunityMemberStatusMember
+			case "remove":
+				status = ""
+			}
+			if status != tc.expected {
+				t.Errorf("unexpected status: expected %q, got %q", tc.expected, status)
+			}
+		})
+	}
+}
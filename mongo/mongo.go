@@ -32,18 +32,29 @@ type MongoStorage struct {
 	election funcGetElection
 	keysLock sync.RWMutex
 
-	users              *mongo.Collection
-	elections          *mongo.Collection
-	census             *mongo.Collection
-	results            *mongo.Collection
-	voters             *mongo.Collection
-	authentications    *mongo.Collection
-	notifications      *mongo.Collection
-	userAccessProfiles *mongo.Collection
-	communities        *mongo.Collection
-	avatars            *mongo.Collection
-	delegations        *mongo.Collection
-	reputations        *mongo.Collection
+	users               *mongo.Collection
+	elections           *mongo.Collection
+	census              *mongo.Collection
+	results             *mongo.Collection
+	voters              *mongo.Collection
+	authentications     *mongo.Collection
+	notifications       *mongo.Collection
+	userAccessProfiles  *mongo.Collection
+	communities         *mongo.Collection
+	avatars             *mongo.Collection
+	delegations         *mongo.Collection
+	reputations         *mongo.Collection
+	censusJobs          *mongo.Collection
+	delegationEvents    *mongo.Collection
+	delegationSnapshots *mongo.Collection
+	communityMembers    *mongo.Collection
+	electionAnalytics   *mongo.Collection
+	webhooks            *mongo.Collection
+	webhookDeliveries   *mongo.Collection
+	communityHubCursors *mongo.Collection
+	resultsSettlements  *mongo.Collection
+	relayedTxs          *mongo.Collection
+	relayQuotas         *mongo.Collection
 }
 
 type Options struct {
@@ -118,6 +129,17 @@ func New(url, database string) (*MongoStorage, error) {
 	ms.avatars = client.Database(database).Collection("avatars")
 	ms.delegations = client.Database(database).Collection("delegations")
 	ms.reputations = client.Database(database).Collection("reputations")
+	ms.censusJobs = client.Database(database).Collection("censusJobs")
+	ms.delegationEvents = client.Database(database).Collection("delegationEvents")
+	ms.delegationSnapshots = client.Database(database).Collection("delegationSnapshots")
+	ms.communityMembers = client.Database(database).Collection("communityMembers")
+	ms.electionAnalytics = client.Database(database).Collection("electionAnalytics")
+	ms.webhooks = client.Database(database).Collection("webhooks")
+	ms.webhookDeliveries = client.Database(database).Collection("webhookDeliveries")
+	ms.communityHubCursors = client.Database(database).Collection("communityHubCursors")
+	ms.resultsSettlements = client.Database(database).Collection("resultsSettlements")
+	ms.relayedTxs = client.Database(database).Collection("relayedTxs")
+	ms.relayQuotas = client.Database(database).Collection("relayQuotas")
 
 	// If reset flag is enabled, Reset drops the database documents and recreates indexes
 	// else, just createIndexes
@@ -322,6 +344,14 @@ func (ms *MongoStorage) createIndexes() error {
 		return fmt.Errorf("failed to create index on community ids for delegations: %w", err)
 	}
 
+	delegationsValidUntilIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "validUntil", Value: 1}}, // 1 for ascending order
+		Options: options.Index().SetSparse(true),
+	}
+	if _, err := ms.delegations.Indexes().CreateOne(ctx, delegationsValidUntilIndex); err != nil {
+		return fmt.Errorf("failed to create index on expiration for delegations: %w", err)
+	}
+
 	// Create an index for the 'userId' field on reputations
 	reputationUserIndex := mongo.IndexModel{
 		Keys:    bson.D{{Key: "userId", Value: 1}}, // 1 for ascending order
@@ -340,6 +370,135 @@ func (ms *MongoStorage) createIndexes() error {
 		return fmt.Errorf("failed to create index on community ids for reputations: %w", err)
 	}
 
+	// Create a compound index for the 'status' and 'leaseUntil' fields on
+	// census jobs to find the unfinished jobs with an expired lease
+	censusJobsLeaseIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "status", Value: 1},
+			{Key: "leaseUntil", Value: 1},
+		},
+	}
+	if _, err := ms.censusJobs.Indexes().CreateOne(ctx, censusJobsLeaseIndex); err != nil {
+		return fmt.Errorf("failed to create index on status and lease for census jobs: %w", err)
+	}
+
+	// Create a compound index for the 'cacheKey', 'status' and 'updatedAt'
+	// fields on census jobs to find the fresh censuses built from the same
+	// source
+	censusJobsCacheIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "cacheKey", Value: 1},
+			{Key: "status", Value: 1},
+			{Key: "updatedAt", Value: -1},
+		},
+	}
+	if _, err := ms.censusJobs.Indexes().CreateOne(ctx, censusJobsCacheIndex); err != nil {
+		return fmt.Errorf("failed to create index on cache key for census jobs: %w", err)
+	}
+
+	// Create a compound index for the 'communityId' and 'timestamp' fields on
+	// delegation events to list the history of a community
+	delegationEventsCommunityIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "communityId", Value: 1},
+			{Key: "timestamp", Value: -1},
+		},
+	}
+	if _, err := ms.delegationEvents.Indexes().CreateOne(ctx, delegationEventsCommunityIndex); err != nil {
+		return fmt.Errorf("failed to create index on community ids for delegation events: %w", err)
+	}
+
+	// Create a unique compound index for the 'communityId' and 'fid' fields
+	// on community members to keep a single entry per user and community
+	communityMembersIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "communityId", Value: 1},
+			{Key: "fid", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	}
+	if _, err := ms.communityMembers.Indexes().CreateOne(ctx, communityMembersIndex); err != nil {
+		return fmt.Errorf("failed to create index on community members: %w", err)
+	}
+
+	// Create an index for the 'communityId' field on election analytics to
+	// get the pre-aggregated statistics of the polls of a community
+	electionAnalyticsCommunityIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "communityId", Value: 1}},
+	}
+	if _, err := ms.electionAnalytics.Indexes().CreateOne(ctx, electionAnalyticsCommunityIndex); err != nil {
+		return fmt.Errorf("failed to create index on community ids for election analytics: %w", err)
+	}
+
+	// Create an index for the 'communityId' field on webhooks to get the
+	// subscriptions of a community
+	webhooksCommunityIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "communityId", Value: 1}},
+	}
+	if _, err := ms.webhooks.Indexes().CreateOne(ctx, webhooksCommunityIndex); err != nil {
+		return fmt.Errorf("failed to create index on community ids for webhooks: %w", err)
+	}
+
+	// Create a compound index for the 'status' and 'nextAttempt' fields on
+	// webhook deliveries to find the deliveries to retry
+	webhookDeliveriesRetryIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "status", Value: 1},
+			{Key: "nextAttempt", Value: 1},
+		},
+	}
+	if _, err := ms.webhookDeliveries.Indexes().CreateOne(ctx, webhookDeliveriesRetryIndex); err != nil {
+		return fmt.Errorf("failed to create index on webhook deliveries retries: %w", err)
+	}
+
+	// Create a compound index for the 'communityId' and 'createdAt' fields
+	// on webhook deliveries to list the delivery logs of a community
+	webhookDeliveriesCommunityIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "communityId", Value: 1},
+			{Key: "createdAt", Value: -1},
+		},
+	}
+	if _, err := ms.webhookDeliveries.Indexes().CreateOne(ctx, webhookDeliveriesCommunityIndex); err != nil {
+		return fmt.Errorf("failed to create index on community ids for webhook deliveries: %w", err)
+	}
+
+	// Create a compound index for the 'status' and 'nextAttempt' fields on
+	// results settlements to find the settlements to process
+	resultsSettlementsStatusIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "status", Value: 1},
+			{Key: "nextAttempt", Value: 1},
+		},
+	}
+	if _, err := ms.resultsSettlements.Indexes().CreateOne(ctx, resultsSettlementsStatusIndex); err != nil {
+		return fmt.Errorf("failed to create index on results settlements status: %w", err)
+	}
+
+	// Create a compound index for the 'communityId' and 'createdAt' fields
+	// on results settlements to list the settlements of a community
+	resultsSettlementsCommunityIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "communityId", Value: 1},
+			{Key: "createdAt", Value: -1},
+		},
+	}
+	if _, err := ms.resultsSettlements.Indexes().CreateOne(ctx, resultsSettlementsCommunityIndex); err != nil {
+		return fmt.Errorf("failed to create index on community ids for results settlements: %w", err)
+	}
+
+	// Create a compound index for the 'communityId' and 'createdAt' fields
+	// on relayed transactions to account the gas spent by a community
+	relayedTxsCommunityIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "communityId", Value: 1},
+			{Key: "createdAt", Value: -1},
+		},
+	}
+	if _, err := ms.relayedTxs.Indexes().CreateOne(ctx, relayedTxsCommunityIndex); err != nil {
+		return fmt.Errorf("failed to create index on community ids for relayed transactions: %w", err)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,191 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AddRelayedTx stores the relayed transaction provided, setting its creation
+// time. If it is already stored, it is replaced, so every transaction is only
+// accounted once.
+func (ms *MongoStorage) AddRelayedTx(tx *RelayedTx) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx.CreatedAt = time.Now()
+	opts := options.Replace().SetUpsert(true)
+	_, err := ms.relayedTxs.ReplaceOne(ctx, bson.M{"_id": tx.Hash}, tx, opts)
+	return err
+}
+
+// ReserveRelayQuota atomically reserves a free slot of the quota provided of
+// transactions of the action provided relayed on behalf of the user provided,
+// until the window provided expires. Every slot is reserved with a single
+// conditional upsert, which fails with a duplicated key if the slot is
+// already reserved, so concurrent requests, even to different instances,
+// cannot reserve more slots than the quota. It returns the ID of the slot
+// reserved or ErrRelayQuotaExceeded if all of them are reserved.
+func (ms *MongoStorage) ReserveRelayQuota(fid uint64, action string, quota int, window time.Duration) (string, error) {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, id := range relayQuotaSlotIDs(fid, action, quota) {
+		reserved, err := ms.reserveRelaySlot(ctx, id, bson.M{"fid": fid, "action": action}, window)
+		if err != nil {
+			return "", err
+		}
+		if reserved {
+			return id, nil
+		}
+	}
+	return "", ErrRelayQuotaExceeded
+}
+
+// ReserveCommunityRelay atomically reserves the single slot of the
+// transactions paid by the community provided during the window provided, so
+// only one of them is in flight at a time and its cost is accounted before
+// the budget of the community is checked again, even by other instances. It
+// returns the ID of the slot reserved or ErrCommunityRelayInFlight if it is
+// already reserved. The slot must be released once the cost of the
+// transaction is accounted or if it is not sent.
+func (ms *MongoStorage) ReserveCommunityRelay(communityID string, window time.Duration) (string, error) {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id := fmt.Sprintf("%s:%s", communityID, RelayActionUpdateCommunity)
+	reserved, err := ms.reserveRelaySlot(ctx, id,
+		bson.M{"communityId": communityID, "action": RelayActionUpdateCommunity}, window)
+	if err != nil {
+		return "", err
+	}
+	if !reserved {
+		return "", ErrCommunityRelayInFlight
+	}
+	return id, nil
+}
+
+// reserveRelaySlot reserves the relay slot with the ID provided, setting the
+// fields provided, during the window provided if it is free. The slot is
+// reserved with a single conditional upsert, which fails with a duplicated
+// key if the slot is already reserved. It returns false in that case. It does
+// not adquire the keysLock.
+func (ms *MongoStorage) reserveRelaySlot(ctx context.Context, id string, fields bson.M,
+	window time.Duration,
+) (bool, error) {
+	now := time.Now()
+	set := bson.M{"reservedUntil": now.Add(window)}
+	for key, value := range fields {
+		set[key] = value
+	}
+	opts := options.Update().SetUpsert(true)
+	_, err := ms.relayQuotas.UpdateOne(ctx, bson.M{
+		"_id":           id,
+		"reservedUntil": bson.M{"$lte": now},
+	}, bson.M{"$set": set}, opts)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// ReleaseRelayQuota frees the relay slot with the ID provided, for the
+// reservations whose transaction is not sent or, for the ones of the
+// communities, whose cost is already accounted.
+func (ms *MongoStorage) ReleaseRelayQuota(id string) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := ms.relayQuotas.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// relayQuotaSlotIDs returns the IDs of the slots of the quota provided of
+// transactions of the action provided relayed on behalf of the user provided.
+func relayQuotaSlotIDs(fid uint64, action string, quota int) []string {
+	ids := make([]string, 0, quota)
+	for slot := 0; slot < quota; slot++ {
+		ids = append(ids, fmt.Sprintf("%d:%s:%d", fid, action, slot))
+	}
+	return ids
+}
+
+// RelayedTxs returns up to limit transactions relayed for the community
+// provided, sorted from the newest to the oldest.
+func (ms *MongoStorage) RelayedTxs(communityID string, limit int64) ([]*RelayedTx, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(limit)
+	cursor, err := ms.relayedTxs.Find(ctx, bson.M{"communityId": communityID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	txs := []*RelayedTx{}
+	if err := cursor.All(ctx, &txs); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// CommunityRelayCost returns the total cost in wei of the transactions
+// relayed for the community provided that are paid with its funds, which are
+// the updates of its settings. The creation of the community is paid by the
+// server under the quota of its creator and the settlements of the results
+// are paid by the server too. The costs are stored as decimal strings because
+// they can overflow the numeric types of the database, so they are added up
+// here.
+func (ms *MongoStorage) CommunityRelayCost(communityID string) (*big.Int, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetProjection(bson.M{"cost": 1})
+	cursor, err := ms.relayedTxs.Find(ctx, bson.M{
+		"communityId": communityID,
+		"action":      RelayActionUpdateCommunity,
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	total := big.NewInt(0)
+	for cursor.Next(ctx) {
+		tx := &RelayedTx{}
+		if err := cursor.Decode(tx); err != nil {
+			return nil, err
+		}
+		cost, ok := new(big.Int).SetString(tx.Cost, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid cost %q of relayed transaction %s", tx.Cost, tx.Hash)
+		}
+		total.Add(total, cost)
+	}
+	return total, cursor.Err()
+}
@@ -0,0 +1,34 @@
+package mongo
+
+import "testing"
+
+func Test_relayQuotaSlotIDs(t *testing.T) {
+	slots := relayQuotaSlotIDs(1, RelayActionCreateCommunity, 3)
+	if len(slots) != 3 {
+		t.Fatalf("unexpected number of slots: %d", len(slots))
+	}
+	// the slots are stable, so every request competes for the same ones
+	for i, slot := range relayQuotaSlotIDs(1, RelayActionCreateCommunity, 3) {
+		if slots[i] != slot {
+			t.Errorf("unstable slot %d: %s != %s", i, slots[i], slot)
+		}
+	}
+	// the slots of other users or actions are independent
+	seen := map[string]bool{}
+	for _, quota := range [][]string{
+		slots,
+		relayQuotaSlotIDs(2, RelayActionCreateCommunity, 3),
+		relayQuotaSlotIDs(1, RelayActionUpdateCommunity, 3),
+		relayQuotaSlotIDs(11, RelayActionCreateCommunity, 3),
+	} {
+		for _, slot := range quota {
+			if seen[slot] {
+				t.Errorf("duplicated slot %s", slot)
+			}
+			seen[slot] = true
+		}
+	}
+	if len(relayQuotaSlotIDs(1, RelayActionCreateCommunity, 0)) != 0 {
+		t.Error("expected no slots without quota")
+	}
+}
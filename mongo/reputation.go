@@ -153,6 +153,38 @@ func (ms *MongoStorage) SetDetailedReputationForCommunity(communityID string, re
 	return ms.updateReputation(reputation)
 }
 
+// UsersReputation method returns the reputation of the users with the IDs
+// provided, indexed by user ID. The users without reputation are not
+// included.
+func (ms *MongoStorage) UsersReputation(userIDs []uint64) (map[uint64]*Reputation, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetProjection(bson.M{"userID": 1, "totalReputation": 1, "totalPoints": 1})
+	cur, err := ms.reputations.Find(ctx, bson.M{
+		"userID":      bson.M{"$in": userIDs},
+		"communityID": bson.M{"$in": []any{"", nil}},
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	reputations := make(map[uint64]*Reputation, len(userIDs))
+	for cur.Next(ctx) {
+		reputation := &Reputation{}
+		if err := cur.Decode(reputation); err != nil {
+			log.Warn(err)
+			continue
+		}
+		reputations[reputation.UserID] = reputation
+	}
+	return reputations, nil
+}
+
 func (ms *MongoStorage) userReputation(userID uint64) (*Reputation, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
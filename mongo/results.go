@@ -12,14 +12,17 @@ import (
 	"go.vocdoni.io/dvote/types"
 )
 
-// AddFinalResults adds the final results of an election in PNG format.
+// AddFinalResults adds the final results of an election in PNG format, with
+// the votes corrected by the delegation overrides and the raw ones tallied by
+// the Vocdoni chain.
 // It performs and upsert operation, so it will update the results if they already exist.
-func (ms *MongoStorage) AddFinalResults(electionID types.HexBytes, finalPNG []byte, choices, votes []string) error {
+func (ms *MongoStorage) AddFinalResults(electionID types.HexBytes, finalPNG []byte, choices, votes, rawVotes []string) error {
 	results := &Results{
 		ElectionID: electionID.String(),
 		FinalPNG:   finalPNG,
 		Choices:    choices,
 		Votes:      votes,
+		RawVotes:   rawVotes,
 		Finalized:  true,
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -109,9 +112,10 @@ func (ms *MongoStorage) ElectionsWithoutResults() ([]string, error) {
 	return electionIDs, nil
 }
 
-// SetPartialResults sets or updates the choices and votes for an election result only if it is not finalized.
+// SetPartialResults sets or updates the choices, the corrected votes and the raw votes for an election result only if
+// it is not finalized.
 // It performs an upsert operation, so it will create the result entry if it does not exist and is not finalized.
-func (ms *MongoStorage) SetPartialResults(electionID types.HexBytes, choices, votes []string) error {
+func (ms *MongoStorage) SetPartialResults(electionID types.HexBytes, choices, votes, rawVotes []string) error {
 	ms.keysLock.Lock()
 	defer ms.keysLock.Unlock()
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -122,6 +126,7 @@ func (ms *MongoStorage) SetPartialResults(electionID types.HexBytes, choices, vo
 		"$set": bson.M{
 			"title":     choices,
 			"votes":     votes,
+			"rawVotes":  rawVotes,
 			"finalized": false,
 		},
 	}
@@ -144,3 +149,17 @@ func (ms *MongoStorage) SetPartialResults(electionID types.HexBytes, choices, vo
 	}
 	return nil
 }
+
+// SetResultsSettled sets the block where the results of the election provided
+// were set in the community hub contract. It does nothing if the results of
+// the election are not stored.
+func (ms *MongoStorage) SetResultsSettled(electionID types.HexBytes, block uint64) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := ms.results.UpdateOne(ctx, bson.M{"_id": electionID.String()},
+		bson.M{"$set": bson.M{"settledBlock": block}})
+	return err
+}
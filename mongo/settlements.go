@@ -0,0 +1,265 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnqueueResultsSettlement adds a pending settlement of the results of the
+// community poll provided. If the poll already has a settlement, it does
+// nothing, so the results are only settled once.
+func (ms *MongoStorage) EnqueueResultsSettlement(electionID, communityID string) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	opts := options.Update().SetUpsert(true)
+	_, err := ms.resultsSettlements.UpdateOne(ctx, bson.M{"_id": electionID}, bson.M{
+		"$setOnInsert": bson.M{
+			"communityId": communityID,
+			"status":      SettlementPending,
+			"attempts":    0,
+			"bumps":       0,
+			"nextAttempt": now,
+			"createdAt":   now,
+			"updatedAt":   now,
+		},
+	}, opts)
+	return err
+}
+
+// ResultsSettlement returns the settlement of the results of the poll
+// provided. If it does not exist, it returns ErrSettlementUnknown.
+func (ms *MongoStorage) ResultsSettlement(electionID string) (*ResultsSettlement, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	settlement := &ResultsSettlement{}
+	if err := ms.resultsSettlements.FindOne(ctx, bson.M{"_id": electionID}).Decode(settlement); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrSettlementUnknown
+		}
+		return nil, err
+	}
+	return settlement, nil
+}
+
+// UpdateResultsSettlement replaces the stored settlement with the one
+// provided, updating its modification time.
+func (ms *MongoStorage) UpdateResultsSettlement(settlement *ResultsSettlement) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	settlement.UpdatedAt = time.Now()
+	_, err := ms.resultsSettlements.ReplaceOne(ctx, bson.M{"_id": settlement.ElectionID}, settlement)
+	return err
+}
+
+// RetryResultsSettlement triggers again the settlement of the results of the
+// poll provided, resetting its attempts and resettlements. It returns ErrSettlementUnknown if
+// the settlement does not exist and ErrSettlementInProgress if its current
+// transaction is being sent or is not mined yet.
+func (ms *MongoStorage) RetryResultsSettlement(electionID string) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	res, err := ms.resultsSettlements.UpdateOne(ctx, bson.M{
+		"_id":    electionID,
+		"status": bson.M{"$nin": []string{SettlementSubmitting, SettlementSubmitted}},
+	}, bson.M{
+		"$set": bson.M{
+			"status":      SettlementPending,
+			"attempts":    0,
+			"bumps":       0,
+			"nextAttempt": now,
+			"updatedAt":   now,
+		},
+		"$unset": bson.M{"lastError": "", "resettled": ""},
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		if err := ms.resultsSettlements.FindOne(ctx, bson.M{"_id": electionID}).Err(); err == mongo.ErrNoDocuments {
+			return ErrSettlementUnknown
+		}
+		return ErrSettlementInProgress
+	}
+	return nil
+}
+
+// ResultsSettlements returns up to limit settlements, sorted from the newest
+// to the oldest. They are filtered by the community and the status provided
+// if they are not empty.
+func (ms *MongoStorage) ResultsSettlements(communityID, status string, limit int64) ([]*ResultsSettlement, error) {
+	filter := bson.M{}
+	if communityID != "" {
+		filter["communityId"] = communityID
+	}
+	if status != "" {
+		filter["status"] = status
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(limit)
+	return ms.filterResultsSettlements(filter, opts)
+}
+
+// ClaimNextResultsSettlement atomically takes the oldest pending settlement
+// whose next attempt is due, or the oldest one whose submission was
+// interrupted because the lease of its owner expired, and sets it as
+// submitting by the owner provided during the lease provided. So the
+// transaction of a settlement is not sent by several instances at the same
+// time. It returns ErrSettlementUnknown if there is no settlement to submit.
+func (ms *MongoStorage) ClaimNextResultsSettlement(owner string, lease time.Duration) (*ResultsSettlement, error) {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	now := time.Now()
+	filter := bson.M{"$or": []bson.M{
+		{"status": SettlementPending, "nextAttempt": bson.M{"$lte": now}},
+		{"status": SettlementSubmitting, "leaseUntil": bson.M{"$lt": now}},
+	}}
+	update := bson.M{"$set": bson.M{
+		"status":     SettlementSubmitting,
+		"owner":      owner,
+		"leaseUntil": now.Add(lease),
+		"updatedAt":  now,
+	}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "nextAttempt", Value: 1}}).
+		SetReturnDocument(options.After)
+	settlement := &ResultsSettlement{}
+	if err := ms.resultsSettlements.FindOneAndUpdate(ctx, filter, update, opts).Decode(settlement); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrSettlementUnknown
+		}
+		return nil, fmt.Errorf("cannot claim results settlement: %w", err)
+	}
+	return settlement, nil
+}
+
+// ClaimNextSubmittedSettlement atomically takes the submitted settlement
+// whose lease expired the earliest and sets it as owned by the owner provided
+// during the lease provided, keeping its status. So its transactions are not
+// checked and replaced by several instances at the same time, which would
+// send several replacements with the same nonce. The lease is kept after the
+// check, so every submitted settlement is checked at most once per lease. It
+// returns ErrSettlementUnknown if there is no settlement to check.
+func (ms *MongoStorage) ClaimNextSubmittedSettlement(owner string, lease time.Duration) (*ResultsSettlement, error) {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	now := time.Now()
+	filter := bson.M{
+		"status": SettlementSubmitted,
+		"$or": []bson.M{
+			{"leaseUntil": bson.M{"$lt": now}},
+			{"leaseUntil": bson.M{"$exists": false}},
+		},
+	}
+	update := bson.M{"$set": bson.M{
+		"owner":      owner,
+		"leaseUntil": now.Add(lease),
+	}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "leaseUntil", Value: 1}}).
+		SetReturnDocument(options.After)
+	settlement := &ResultsSettlement{}
+	if err := ms.resultsSettlements.FindOneAndUpdate(ctx, filter, update, opts).Decode(settlement); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrSettlementUnknown
+		}
+		return nil, fmt.Errorf("cannot claim results settlement: %w", err)
+	}
+	return settlement, nil
+}
+
+// ResultsSettlementsToReconcile returns up to limit mined settlements that
+// have not been updated since the time provided, from the least recently
+// updated one.
+func (ms *MongoStorage) ResultsSettlementsToReconcile(before time.Time, limit int64) ([]*ResultsSettlement, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "updatedAt", Value: 1}}).SetLimit(limit)
+	return ms.filterResultsSettlements(bson.M{
+		"status":    SettlementMined,
+		"updatedAt": bson.M{"$lt": before},
+	}, opts)
+}
+
+// CommunityElectionsWithoutSettlement returns up to limit community polls with
+// final results that have no settlement of their results.
+func (ms *MongoStorage) CommunityElectionsWithoutSettlement(limit int64) ([]*Election, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cursor, err := ms.elections.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"community": bson.M{"$ne": nil}}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "results",
+			"localField":   "_id",
+			"foreignField": "_id",
+			"as":           "results",
+		}}},
+		{{Key: "$match", Value: bson.M{"results.finalized": true}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "resultsSettlements",
+			"localField":   "_id",
+			"foreignField": "_id",
+			"as":           "settlements",
+		}}},
+		{{Key: "$match", Value: bson.M{"settlements": bson.M{"$size": 0}}}},
+		{{Key: "$project", Value: bson.M{"results": 0, "settlements": 0}}},
+		{{Key: "$limit", Value: limit}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	elections := []*Election{}
+	if err := cursor.All(ctx, &elections); err != nil {
+		return nil, err
+	}
+	return elections, nil
+}
+
+func (ms *MongoStorage) filterResultsSettlements(filter bson.M, opts *options.FindOptions) ([]*ResultsSettlement, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := ms.resultsSettlements.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	settlements := []*ResultsSettlement{}
+	if err := cursor.All(ctx, &settlements); err != nil {
+		return nil, err
+	}
+	return settlements, nil
+}
@@ -3,6 +3,7 @@ package mongo
 import (
 	"context"
 	"fmt"
+	"math/big"
 	"reflect"
 	"strings"
 	"time"
@@ -14,10 +15,27 @@ import (
 )
 
 var (
-	ErrUserUnknown     = fmt.Errorf("user unknown")
-	ErrAvatarUnknown   = fmt.Errorf("avatar unknown")
-	ErrElectionUnknown = fmt.Errorf("electionID unknown")
-	ErrNoResults       = fmt.Errorf("no results found")
+	ErrUserUnknown         = fmt.Errorf("user unknown")
+	ErrAvatarUnknown       = fmt.Errorf("avatar unknown")
+	ErrElectionUnknown     = fmt.Errorf("electionID unknown")
+	ErrNoResults           = fmt.Errorf("no results found")
+	ErrCensusJobUnknown    = fmt.Errorf("census job unknown")
+	ErrCensusAlreadyLinked = fmt.Errorf("census already linked to other election")
+	// delegation graph errors
+	ErrSelfDelegation    = fmt.Errorf("cannot delegate to yourself")
+	ErrDelegationCycle   = fmt.Errorf("circular delegation")
+	ErrDelegationTooDeep = fmt.Errorf("delegation chain too deep")
+	// community members errors
+	ErrAlreadyCommunityMember = fmt.Errorf("user is already a member of the community")
+	// webhooks errors
+	ErrWebhookUnknown         = fmt.Errorf("webhook unknown")
+	ErrWebhookDeliveryUnknown = fmt.Errorf("webhook delivery unknown")
+	// results settlements errors
+	ErrSettlementUnknown    = fmt.Errorf("results settlement unknown")
+	ErrSettlementInProgress = fmt.Errorf("results settlement transaction in progress")
+	// relayed transactions errors
+	ErrRelayQuotaExceeded     = fmt.Errorf("relay quota exceeded")
+	ErrCommunityRelayInFlight = fmt.Errorf("community relayed transaction in flight")
 )
 
 // Users is the list of users.
@@ -116,10 +134,77 @@ type Census struct {
 	Root               string            `json:"root" bson:"root"`
 	ElectionID         string            `json:"electionId" bson:"electionId"`
 	Participants       map[string]string `json:"participants" bson:"participants"`
+	ParticipantFIDs    map[string]uint64 `json:"participantFids,omitempty" bson:"participantFids"`
 	FromTotalAddresses uint32            `json:"fromTotalAddresses" bson:"fromTotalAddresses"`
 	CreatedBy          uint64            `json:"createdBy" bson:"createdBy"`
 	TotalWeight        string            `json:"totalWeight" bson:"totalWeight"`
 	URL                string            `json:"url" bson:"url"`
+	// Delegations contains the weight that every delegate received from each
+	// of its delegators when the census was built. If DelegationsAttributed
+	// is set, the delegators keep their own weight in the census and can
+	// override their delegations voting by themselves.
+	Delegations           []CensusDelegation `json:"delegations,omitempty" bson:"delegations,omitempty"`
+	DelegationsAttributed bool               `json:"delegationsAttributed,omitempty" bson:"delegationsAttributed,omitempty"`
+	// DelegationSnapshotID is the ID of the snapshot of the resolved
+	// delegations of the community used to build the census.
+	DelegationSnapshotID string `json:"delegationSnapshotId,omitempty" bson:"delegationSnapshotId,omitempty"`
+}
+
+// CensusDelegation represents the weight that a delegate received from a
+// delegator in a census.
+type CensusDelegation struct {
+	From   uint64 `json:"from" bson:"from"`
+	To     uint64 `json:"to" bson:"to"`
+	Weight string `json:"weight" bson:"weight"`
+}
+
+const (
+	// CensusJobStatusPending is the status of a census job that has been
+	// registered but no instance has started to build it yet.
+	CensusJobStatusPending = "pending"
+	// CensusJobStatusRunning is the status of a census job that is being
+	// built by the instance that holds its lease.
+	CensusJobStatusRunning = "running"
+	// CensusJobStatusDone is the status of a census job that has been built
+	// and published successfully.
+	CensusJobStatusDone = "done"
+	// CensusJobStatusFailed is the status of a census job that has finished
+	// with an error.
+	CensusJobStatusFailed = "failed"
+)
+
+// CensusJob stores a census creation process that runs in background. It
+// includes the type and the parameters of the request to be able to resume or
+// retry it, the progress of the current step, the error (if any) and the
+// result once it is ready. The owner and the lease allow to share the jobs
+// between several instances of the service, only the owner of a non expired
+// lease is building the census.
+type CensusJob struct {
+	CensusID   string           `json:"censusId" bson:"_id"`
+	Type       string           `json:"type" bson:"type"`
+	Params     []byte           `json:"params" bson:"params"`
+	CacheKey   string           `json:"cacheKey" bson:"cacheKey"`
+	CreatedBy  uint64           `json:"createdBy" bson:"createdBy"`
+	Status     string           `json:"status" bson:"status"`
+	Step       int              `json:"step" bson:"step"`
+	TotalSteps int              `json:"totalSteps" bson:"totalSteps"`
+	Progress   uint32           `json:"progress" bson:"progress"`
+	Error      string           `json:"error" bson:"error"`
+	Attempts   int              `json:"attempts" bson:"attempts"`
+	Owner      string           `json:"owner" bson:"owner"`
+	LeaseUntil time.Time        `json:"leaseUntil" bson:"leaseUntil"`
+	Result     *CensusJobResult `json:"result" bson:"result"`
+	CreatedAt  time.Time        `json:"createdAt" bson:"createdAt"`
+	UpdatedAt  time.Time        `json:"updatedAt" bson:"updatedAt"`
+}
+
+// CensusJobResult stores the information of a census created by a census job.
+type CensusJobResult struct {
+	Root               string   `json:"root" bson:"root"`
+	URL                string   `json:"uri" bson:"uri"`
+	Size               uint64   `json:"size" bson:"size"`
+	Usernames          []string `json:"usernames" bson:"usernames"`
+	FromTotalAddresses uint32   `json:"fromTotalAddresses" bson:"fromTotalAddresses"`
 }
 
 // ElectionMeta stores non related election information that is useful
@@ -135,8 +220,16 @@ type Results struct {
 	ElectionID string   `json:"electionId" bson:"_id"`
 	FinalPNG   []byte   `json:"finalPNG" bson:"finalPNG"`
 	Choices    []string `json:"title" bson:"title"`
-	Votes      []string `json:"votes" bson:"votes"`
-	Finalized  bool     `json:"finalized" bson:"finalized"`
+	// Votes are the results of the election corrected by the delegation
+	// overrides, that are the ones published and settled. RawVotes are the
+	// results tallied by the Vocdoni chain, which count twice the weight
+	// delegated when both the delegator and the delegate vote.
+	Votes     []string `json:"votes" bson:"votes"`
+	RawVotes  []string `json:"rawVotes,omitempty" bson:"rawVotes,omitempty"`
+	Finalized bool     `json:"finalized" bson:"finalized"`
+	// SettledBlock is the block where the results were set in the community
+	// hub contract, if they were.
+	SettledBlock uint64 `json:"settledBlock,omitempty" bson:"settledBlock,omitempty"`
 }
 
 // VotersOfElection represents the list of voters of an election. It includes
@@ -147,6 +240,10 @@ type VotersOfElection struct {
 	Voters           []uint64          `json:"voters" bson:"voters"`
 	AlreadyReminded  map[uint64]string `json:"already_reminded" bson:"already_reminded"`
 	RemindableVoters map[uint64]string `json:"remindable_voters" bson:"remindable_voters"`
+	// Choices contains the choices, one per question, of the voters involved
+	// in a delegation of the election census, required to solve the
+	// delegation overrides.
+	Choices map[uint64][]int `json:"choices,omitempty" bson:"choices,omitempty"`
 }
 
 // Authentication represents the authentication data for a user.
@@ -161,6 +258,7 @@ type NotificationType int
 
 const (
 	NotificationTypeNewElection NotificationType = iota
+	NotificationTypeDelegationExpiry
 	// create more notification types here
 )
 
@@ -286,6 +384,172 @@ type Community struct {
 	Notifications bool            `json:"notifications" bson:"notifications"`
 	Disabled      bool            `json:"disabled" bson:"disabled"`
 	Featured      bool            `json:"featured" bson:"featured"`
+	// CensusCacheTTL is the time in seconds that a census built for the
+	// community can be reused by new polls, if zero the default is used
+	CensusCacheTTL uint64 `json:"censusCacheTTL" bson:"censusCacheTTL"`
+	// CensusWeighting is the weighting applied to the censuses built for the
+	// community, if nil the weights of the census source are kept
+	CensusWeighting *CensusWeighting `json:"censusWeighting,omitempty" bson:"censusWeighting,omitempty"`
+	// Roles are the roles assigned to the members of the community. The
+	// owner and admin roles are only valid for the admins of the community,
+	// which are synced with the community hub.
+	Roles []CommunityMemberRole `json:"roles,omitempty" bson:"roles,omitempty"`
+}
+
+const (
+	// CensusWeightingSourceReputation weights the census participants by
+	// their total reputation.
+	CensusWeightingSourceReputation = "reputation"
+	// CensusWeightingSourcePoints weights the census participants by their
+	// total points.
+	CensusWeightingSourcePoints = "points"
+	// CensusWeightingModeMultiply multiplies the weight of the census
+	// participants by the value of the weighting source.
+	CensusWeightingModeMultiply = "multiply"
+	// CensusWeightingModeReplace replaces the weight of the census
+	// participants by the value of the weighting source.
+	CensusWeightingModeReplace = "replace"
+)
+
+// CensusWeighting defines how the weight of the participants of a census is
+// modified using a value stored for every user (its reputation or points).
+type CensusWeighting struct {
+	Source string `json:"source" bson:"source"`
+	Mode   string `json:"mode" bson:"mode"`
+}
+
+// CommunityRole is the role of a user in a community, that defines what the
+// user is allowed to do in it.
+type CommunityRole string
+
+const (
+	// CommunityRoleOwner can do everything in the community, including
+	// managing the roles of the rest of the members.
+	CommunityRoleOwner CommunityRole = "owner"
+	// CommunityRoleAdmin can do everything in the community but managing
+	// the roles of its members.
+	CommunityRoleAdmin CommunityRole = "admin"
+	// CommunityRolePollCreator can only create polls in the community.
+	CommunityRolePollCreator CommunityRole = "pollCreator"
+	// CommunityRoleModerator can toggle the notifications, disable the
+	// community and send reminders to the voters of its polls.
+	CommunityRoleModerator CommunityRole = "moderator"
+)
+
+// CommunityPermission is an action that can be performed in a community by
+// the users with a role that includes it.
+type CommunityPermission string
+
+// The permissions that can be granted to the community roles.
+const (
+	PermissionCreatePoll          CommunityPermission = "createPoll"
+	PermissionEditSettings        CommunityPermission = "editSettings"
+	PermissionEditCensus          CommunityPermission = "editCensus"
+	PermissionToggleNotifications CommunityPermission = "toggleNotifications"
+	PermissionDisableCommunity    CommunityPermission = "disableCommunity"
+	PermissionSendReminders       CommunityPermission = "sendReminders"
+	PermissionManageRoles         CommunityPermission = "manageRoles"
+)
+
+// communityRolePermissions is the permission matrix of the community roles.
+var communityRolePermissions = map[CommunityRole][]CommunityPermission{
+	CommunityRoleOwner: {
+		PermissionCreatePoll, PermissionEditSettings, PermissionEditCensus, PermissionToggleNotifications,
+		PermissionDisableCommunity, PermissionSendReminders, PermissionManageRoles,
+	},
+	CommunityRoleAdmin: {
+		PermissionCreatePoll, PermissionEditSettings, PermissionEditCensus, PermissionToggleNotifications,
+		PermissionDisableCommunity, PermissionSendReminders,
+	},
+	CommunityRolePollCreator: {PermissionCreatePoll},
+	CommunityRoleModerator:   {PermissionToggleNotifications, PermissionDisableCommunity, PermissionSendReminders},
+}
+
+// Valid returns true if the role is one of the known community roles.
+func (r CommunityRole) Valid() bool {
+	_, ok := communityRolePermissions[r]
+	return ok
+}
+
+// Can returns true if the role includes the permission provided.
+func (r CommunityRole) Can(permission CommunityPermission) bool {
+	for _, p := range communityRolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// CommunityMemberRole is the role assigned to a user in a community.
+type CommunityMemberRole struct {
+	FID  uint64        `json:"fid" bson:"fid"`
+	Role CommunityRole `json:"role" bson:"role"`
+}
+
+// RoleOf returns the role of the user provided in the community. The creator
+// of the community is always an owner, and the rest of the admins are admins
+// unless they have been promoted to owners. The owner and admin roles stored
+// for users that are not admins anymore are ignored, since the admins are the
+// ones that the community hub knows about. It returns an empty role if the
+// user has no role in the community.
+func (c *Community) RoleOf(userID uint64) CommunityRole {
+	if userID == c.Creator {
+		return CommunityRoleOwner
+	}
+	isAdmin := false
+	for _, admin := range c.Admins {
+		if admin == userID {
+			isAdmin = true
+			break
+		}
+	}
+	for _, member := range c.Roles {
+		if member.FID != userID {
+			continue
+		}
+		switch member.Role {
+		case CommunityRoleOwner, CommunityRoleAdmin:
+			if isAdmin {
+				return member.Role
+			}
+		default:
+			if !isAdmin {
+				return member.Role
+			}
+		}
+	}
+	if isAdmin {
+		return CommunityRoleAdmin
+	}
+	return ""
+}
+
+// Can returns true if the user provided has a role in the community that
+// includes the permission provided.
+func (c *Community) Can(userID uint64, permission CommunityPermission) bool {
+	return c.RoleOf(userID).Can(permission)
+}
+
+// MemberRoles returns the current role of every member of the community with
+// a role, starting by its admins.
+func (c *Community) MemberRoles() []CommunityMemberRole {
+	roles := []CommunityMemberRole{}
+	included := map[uint64]bool{}
+	members := append([]uint64{c.Creator}, c.Admins...)
+	for _, member := range c.Roles {
+		members = append(members, member.FID)
+	}
+	for _, fid := range members {
+		if included[fid] {
+			continue
+		}
+		included[fid] = true
+		if role := c.RoleOf(fid); role != "" {
+			roles = append(roles, CommunityMemberRole{FID: fid, Role: role})
+		}
+	}
+	return roles
 }
 
 const (
@@ -301,22 +565,75 @@ const (
 	// TypeCommunityCensusFollowers is the type for a community census that uses
 	// followers as source.
 	TypeCommunityCensusFollowers = "followers"
+	// TypeCommunityCensusERC20Votes is the type for a community census that
+	// uses the delegated voting power of an ERC20Votes token as source.
+	TypeCommunityCensusERC20Votes = "erc20votes"
+	// TypeCommunityCensusEngaged is the type for a community census that uses
+	// the voters of the previous polls of the community as source.
+	TypeCommunityCensusEngaged = "engaged"
+	// TypeCommunityCensusMembers is the type for a community census that uses
+	// the list of members curated by the community admins as source.
+	TypeCommunityCensusMembers = "members"
 )
 
+// CommunityEngagedRule defines which voters of the previous polls of a
+// community are included in an engaged census: the voters of at least
+// MinVotes of the last LastPolls polls of the community. If Window is not
+// zero, only the polls created in the last Window seconds are considered.
+type CommunityEngagedRule struct {
+	MinVotes  uint32 `json:"minVotes" bson:"minVotes"`
+	LastPolls uint32 `json:"lastPolls" bson:"lastPolls"`
+	Window    uint64 `json:"window,omitempty" bson:"window,omitempty"`
+}
+
 // CommunityCensus represents the census of a community in the database. It
-// includes the name, type, and the census addresses (CommunityCensusAddresses)
-// or the census channel (depending on the type).
+// includes the name, type, and the census addresses (CommunityCensusAddresses),
+// the census channels or the engaged rule (depending on the type). The censuses based on
+// channels can include several channels, the first one is also stored in the
+// Channel field, which is also used to store the user reference of the
+// followers censuses.
 type CommunityCensus struct {
 	Type      string                     `json:"type" bson:"type"`
 	Addresses []CommunityCensusAddresses `json:"addresses" bson:"addresses"`
 	Channel   string                     `json:"channel" bson:"channel"`
+	Channels  []string                   `json:"channels,omitempty" bson:"channels,omitempty"`
+	Engaged   *CommunityEngagedRule      `json:"engaged,omitempty" bson:"engaged,omitempty"`
+}
+
+// CensusChannels returns the channels of a census based on channels. The
+// censuses stored before supporting several channels only have the Channel
+// field, so it is returned as the only channel.
+func (cc CommunityCensus) CensusChannels() []string {
+	if len(cc.Channels) > 0 {
+		return cc.Channels
+	}
+	if cc.Channel == "" {
+		return nil
+	}
+	return []string{cc.Channel}
 }
 
+const (
+	// TokenStandardERC20 is the standard of the ERC20 tokens.
+	TokenStandardERC20 = "erc20"
+	// TokenStandardERC721 is the standard of the ERC-721 NFTs.
+	TokenStandardERC721 = "erc721"
+	// TokenStandardERC1155 is the standard of the ERC-1155 multi tokens.
+	TokenStandardERC1155 = "erc1155"
+	// TokenStandardERC20Votes is the standard of the governance tokens that
+	// implement the OpenZeppelin ERC20Votes extension.
+	TokenStandardERC20Votes = "erc20votes"
+)
+
 // CommunityCensusAddresses represents the addresses of a contract to be used to
-// create the census of a community.
+// create the census of a community. Optionally, it includes the standard of
+// the token and the token IDs to be considered, if only the holders of some
+// token IDs of the contract are part of the census.
 type CommunityCensusAddresses struct {
-	Address    string `json:"address" bson:"address"`
-	Blockchain string `json:"blockchain" bson:"blockchain"`
+	Address    string   `json:"address" bson:"address"`
+	Blockchain string   `json:"blockchain" bson:"blockchain"`
+	Standard   string   `json:"standard,omitempty" bson:"standard,omitempty"`
+	TokenIDs   []string `json:"tokenIds,omitempty" bson:"tokenIds,omitempty"`
 }
 
 // Avatar represents an avatar image. Includes the avatar ID and the image data
@@ -330,13 +647,133 @@ type Avatar struct {
 	ContentType string    `json:"contentType" bson:"contentType"`
 }
 
+// DelegationFullShare is the share of a delegation that moves the whole
+// weight of the delegator to the delegate. The shares of the delegations are
+// expressed in basis points, so 5000 means 50% of the weight.
+const DelegationFullShare = 10000
+
 // Delegation represents a delegation of votes from one user to another for a
-// specific community.
+// specific community. A delegation can optionally be limited to a period of
+// time, it is only taken into account from ValidFrom and until ValidUntil if
+// they are set. It can also move only a share of the weight of the delegator,
+// in basis points, a zero share means the whole weight.
 type Delegation struct {
-	ID         primitive.ObjectID `json:"id" bson:"_id"`
-	From       uint64             `json:"from" bson:"from"`
-	To         uint64             `json:"to" bson:"to"`
-	CommuniyID string             `json:"communityId" bson:"communityId"`
+	ID             primitive.ObjectID `json:"id" bson:"_id"`
+	From           uint64             `json:"from" bson:"from"`
+	To             uint64             `json:"to" bson:"to"`
+	CommuniyID     string             `json:"communityId" bson:"communityId"`
+	Share          uint32             `json:"share,omitempty" bson:"share,omitempty"`
+	ValidFrom      *time.Time         `json:"validFrom,omitempty" bson:"validFrom,omitempty"`
+	ValidUntil     *time.Time         `json:"validUntil,omitempty" bson:"validUntil,omitempty"`
+	ExpiryNotified bool               `json:"-" bson:"expiryNotified,omitempty"`
+	Expired        bool               `json:"-" bson:"expired,omitempty"`
+}
+
+// Shared returns the share of the weight of the delegator that the delegation
+// moves to the delegate, in basis points.
+func (d Delegation) Shared() uint32 {
+	if d.Share == 0 || d.Share > DelegationFullShare {
+		return DelegationFullShare
+	}
+	return d.Share
+}
+
+// Portion returns the part of the weight provided that the delegation moves
+// to the delegate. The result is rounded down, so the remainder is kept by
+// the delegator.
+func (d Delegation) Portion(weight *big.Int) *big.Int {
+	portion := new(big.Int).Mul(weight, big.NewInt(int64(d.Shared())))
+	return portion.Quo(portion, big.NewInt(DelegationFullShare))
+}
+
+// Active returns true if the delegation is valid at the time provided, that
+// is, it has already started and it has not expired yet.
+func (d Delegation) Active(at time.Time) bool {
+	if d.ValidFrom != nil && at.Before(*d.ValidFrom) {
+		return false
+	}
+	if d.ValidUntil != nil && !at.Before(*d.ValidUntil) {
+		return false
+	}
+	return true
+}
+
+// Overlaps returns true if the validity period of the delegation overlaps the
+// validity period of the delegation provided. The periods without start or end
+// are unbounded on that side.
+func (d Delegation) Overlaps(other Delegation) bool {
+	if d.ValidUntil != nil && other.ValidFrom != nil && !other.ValidFrom.Before(*d.ValidUntil) {
+		return false
+	}
+	if other.ValidUntil != nil && d.ValidFrom != nil && !d.ValidFrom.Before(*other.ValidUntil) {
+		return false
+	}
+	return true
+}
+
+// DelegatedShare returns the total share of the weight of the user provided
+// that is delegated by the delegations provided, in basis points. It never
+// exceeds DelegationFullShare.
+func DelegatedShare(delegations []Delegation, userID uint64) uint32 {
+	total := uint32(0)
+	for _, delegation := range delegations {
+		if delegation.From == userID {
+			total += delegation.Shared()
+		}
+	}
+	return min(total, DelegationFullShare)
+}
+
+const (
+	// DelegationEventCreate is the type of the events registered when a
+	// delegation is created.
+	DelegationEventCreate = "create"
+	// DelegationEventRevoke is the type of the events registered when a
+	// delegation is revoked.
+	DelegationEventRevoke = "revoke"
+	// DelegationEventExpire is the type of the events registered when a
+	// delegation reaches the end of its validity period.
+	DelegationEventExpire = "expire"
+)
+
+// DelegationEvent represents an immutable record of the creation, the
+// revocation or the expiration of a delegation, including the user that performed it.
+type DelegationEvent struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id"`
+	Type         string             `json:"type" bson:"type"`
+	DelegationID string             `json:"delegationId" bson:"delegationId"`
+	Delegation   Delegation         `json:"delegation" bson:"delegation"`
+	CommunityID  string             `json:"communityId" bson:"communityId"`
+	ActorFID     uint64             `json:"actorFid" bson:"actorFid"`
+	Timestamp    time.Time          `json:"timestamp" bson:"timestamp"`
+}
+
+// DelegationSnapshot represents the resolved delegations of a community at a
+// point in time, stored when a census is built using them.
+type DelegationSnapshot struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	CommunityID string             `json:"communityId" bson:"communityId"`
+	Delegations []Delegation       `json:"delegations" bson:"delegations"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+const (
+	// CommunityMemberStatusMember is the status of the users included in the
+	// members list of a community.
+	CommunityMemberStatusMember = "member"
+	// CommunityMemberStatusPending is the status of the users that requested
+	// to join a community and are waiting for the approval of its admins.
+	CommunityMemberStatusPending = "pending"
+)
+
+// CommunityMember represents a user in the members list of a community, or a
+// user that requested to join it.
+type CommunityMember struct {
+	CommunityID string    `json:"communityId" bson:"communityId"`
+	FID         uint64    `json:"fid" bson:"fid"`
+	Status      string    `json:"status" bson:"status"`
+	AddedBy     uint64    `json:"addedBy,omitempty" bson:"addedBy,omitempty"`
+	UpdatedAt   time.Time `json:"updatedAt" bson:"updatedAt"`
 }
 
 // dynamicUpdateDocument creates a BSON update document from a struct, including only non-zero fields.
@@ -421,3 +858,226 @@ func paginatedObjects(collection *mongo.Collection, query bson.M, opts *options.
 	}
 	return total, nil
 }
+
+// ElectionAnalytics contains the pre-aggregated statistics of a community poll
+// used to build the analytics of the community. The statistics of the polls
+// that have already finished are final, so they are not computed again.
+type ElectionAnalytics struct {
+	ElectionID             string    `json:"electionId" bson:"_id"`
+	CommunityID            string    `json:"communityId" bson:"communityId"`
+	CreatedTime            time.Time `json:"createdTime" bson:"createdTime"`
+	CensusSize             uint64    `json:"censusSize" bson:"censusSize"`
+	Votes                  uint64    `json:"votes" bson:"votes"`
+	CastedWeight           string    `json:"castedWeight" bson:"castedWeight"`
+	Voters                 []uint64  `json:"voters" bson:"voters"`
+	NotificationsRequested uint64    `json:"notificationsRequested" bson:"notificationsRequested"`
+	NotificationsAccepted  uint64    `json:"notificationsAccepted" bson:"notificationsAccepted"`
+	Final                  bool      `json:"final" bson:"final"`
+	UpdatedAt              time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+const (
+	// AnalyticsIntervalDay groups the community analytics by day.
+	AnalyticsIntervalDay = "day"
+	// AnalyticsIntervalWeek groups the community analytics by week, starting
+	// on monday.
+	AnalyticsIntervalWeek = "week"
+	// AnalyticsIntervalMonth groups the community analytics by month.
+	AnalyticsIntervalMonth = "month"
+)
+
+// CommunityAnalyticsBucket contains the statistics of the polls of a
+// community created during a time interval. The turnout is the percentage of
+// votes over the census size of the polls, and the notifications acceptance
+// rate is the percentage of the census participants that were asked to allow
+// the notifications and accepted them.
+type CommunityAnalyticsBucket struct {
+	Start                       time.Time `json:"start"`
+	Polls                       uint64    `json:"polls"`
+	UniqueVoters                uint64    `json:"uniqueVoters"`
+	NewVoters                   uint64    `json:"newVoters"`
+	ReturningVoters             uint64    `json:"returningVoters"`
+	Turnout                     float64   `json:"turnout"`
+	CastedWeight                string    `json:"castedWeight"`
+	NotificationsRequested      uint64    `json:"notificationsRequested"`
+	NotificationsAccepted       uint64    `json:"notificationsAccepted"`
+	NotificationsAcceptanceRate float64   `json:"notificationsAcceptanceRate"`
+}
+
+const (
+	// WebhookEventPollCreated is the event sent when a community poll is
+	// created.
+	WebhookEventPollCreated = "poll.created"
+	// WebhookEventVoteCast is the event sent when a vote is cast in a
+	// community poll. It only includes the vote counts of the poll.
+	WebhookEventVoteCast = "vote.cast"
+	// WebhookEventPollEnded is the event sent when a community poll ends,
+	// including its final results.
+	WebhookEventPollEnded = "poll.ended"
+	// WebhookEventResultsSettled is the event sent when the results of a
+	// community poll are settled on-chain in the community hub contract.
+	WebhookEventResultsSettled = "results.settled"
+	// WebhookEventDelegationChanged is the event sent when a delegation of the
+	// community is created or revoked.
+	WebhookEventDelegationChanged = "delegation.changed"
+)
+
+// WebhookEvents is the list of the events that a webhook can subscribe to.
+var WebhookEvents = []string{
+	WebhookEventPollCreated,
+	WebhookEventVoteCast,
+	WebhookEventPollEnded,
+	WebhookEventResultsSettled,
+	WebhookEventDelegationChanged,
+}
+
+// Webhook represents the subscription of an URL to some events of a
+// community. The deliveries are signed with the secret of the webhook, which
+// is never returned after its creation.
+type Webhook struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	CommunityID string             `json:"communityId" bson:"communityId"`
+	URL         string             `json:"url" bson:"url"`
+	Secret      string             `json:"-" bson:"secret"`
+	Events      []string           `json:"events" bson:"events"`
+	CreatedBy   uint64             `json:"createdBy" bson:"createdBy"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// Subscribed returns true if the webhook is subscribed to the event provided.
+func (w *Webhook) Subscribed(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// WebhookDeliveryPending is the status of the deliveries that have not
+	// been delivered yet but will be retried.
+	WebhookDeliveryPending = "pending"
+	// WebhookDeliveryDelivered is the status of the deliveries accepted by the
+	// receiver.
+	WebhookDeliveryDelivered = "delivered"
+	// WebhookDeliveryFailed is the status of the deliveries that will not be
+	// retried anymore.
+	WebhookDeliveryFailed = "failed"
+)
+
+// WebhookDelivery represents the delivery of an event to a webhook. The
+// payload is stored as it is sent, so every retry is signed over the same
+// body.
+type WebhookDelivery struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id"`
+	WebhookID      primitive.ObjectID `json:"webhookId" bson:"webhookId"`
+	CommunityID    string             `json:"communityId" bson:"communityId"`
+	Event          string             `json:"event" bson:"event"`
+	Payload        string             `json:"payload" bson:"payload"`
+	Status         string             `json:"status" bson:"status"`
+	Attempts       int                `json:"attempts" bson:"attempts"`
+	ResponseStatus int                `json:"responseStatus,omitempty" bson:"responseStatus,omitempty"`
+	LastError      string             `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	NextAttempt    time.Time          `json:"nextAttempt" bson:"nextAttempt"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// CommunityHubCursor represents the last block of a chain whose events of the
+// community hub contract have been applied to the database. It includes the
+// address of the contract, so the cursor is discarded if it changes.
+type CommunityHubCursor struct {
+	ChainAlias string    `json:"chainAlias" bson:"_id"`
+	Contract   string    `json:"contract" bson:"contract"`
+	LastBlock  uint64    `json:"lastBlock" bson:"lastBlock"`
+	UpdatedAt  time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+const (
+	// SettlementPending is the status of the results settlements waiting to
+	// be sent to the community hub contract.
+	SettlementPending = "pending"
+	// SettlementSubmitting is the status of the results settlements claimed
+	// by an instance to send their transaction.
+	SettlementSubmitting = "submitting"
+	// SettlementSubmitted is the status of the results settlements whose
+	// transaction has been sent but is not mined yet.
+	SettlementSubmitted = "submitted"
+	// SettlementMined is the status of the results settlements whose
+	// transaction has been mined successfully.
+	SettlementMined = "mined"
+	// SettlementFailed is the status of the results settlements that will not
+	// be retried anymore unless they are triggered again.
+	SettlementFailed = "failed"
+)
+
+// ResultsSettlement represents the settlement of the results of a community
+// poll into the community hub contract. It includes the transactions sent,
+// the last one is the current one and the previous ones are the ones it
+// replaced because they got stuck. Resettled is the number of times that the
+// results have been settled again because the ones settled did not match the
+// database.
+type ResultsSettlement struct {
+	ElectionID  string    `json:"electionId" bson:"_id"`
+	CommunityID string    `json:"communityId" bson:"communityId"`
+	Status      string    `json:"status" bson:"status"`
+	TxHash      string    `json:"txHash,omitempty" bson:"txHash,omitempty"`
+	TxHashes    []string  `json:"txHashes,omitempty" bson:"txHashes,omitempty"`
+	Nonce       uint64    `json:"nonce,omitempty" bson:"nonce,omitempty"`
+	GasTipCap   string    `json:"gasTipCap,omitempty" bson:"gasTipCap,omitempty"`
+	GasFeeCap   string    `json:"gasFeeCap,omitempty" bson:"gasFeeCap,omitempty"`
+	Attempts    int       `json:"attempts" bson:"attempts"`
+	Bumps       int       `json:"bumps" bson:"bumps"`
+	Resettled   int       `json:"resettled,omitempty" bson:"resettled,omitempty"`
+	LastError   string    `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	NextAttempt time.Time `json:"nextAttempt" bson:"nextAttempt"`
+	SubmittedAt time.Time `json:"submittedAt,omitempty" bson:"submittedAt,omitempty"`
+	MinedBlock  uint64    `json:"minedBlock,omitempty" bson:"minedBlock,omitempty"`
+	Owner       string    `json:"owner,omitempty" bson:"owner,omitempty"`
+	LeaseUntil  time.Time `json:"leaseUntil,omitempty" bson:"leaseUntil,omitempty"`
+	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+const (
+	// RelayActionCreateCommunity is the action of the relayed transactions
+	// that create a community.
+	RelayActionCreateCommunity = "createCommunity"
+	// RelayActionUpdateCommunity is the action of the relayed transactions
+	// that update the settings of a community.
+	RelayActionUpdateCommunity = "updateCommunity"
+	// RelayActionSetResults is the action of the relayed transactions that
+	// settle the results of a community poll.
+	RelayActionSetResults = "setResults"
+)
+
+// RelayedTx represents a transaction sent to the community hub contract by
+// the server on behalf of a user or a community, once it is mined. The cost
+// is the gas used by its effective price plus the value sent, in wei. The FID
+// is zero if the transaction was not requested by a user.
+type RelayedTx struct {
+	Hash        string    `json:"hash" bson:"_id"`
+	FID         uint64    `json:"fid,omitempty" bson:"fid"`
+	CommunityID string    `json:"communityId" bson:"communityId"`
+	Action      string    `json:"action" bson:"action"`
+	Block       uint64    `json:"block" bson:"block"`
+	GasUsed     uint64    `json:"gasUsed" bson:"gasUsed"`
+	GasPrice    string    `json:"gasPrice" bson:"gasPrice"`
+	Value       string    `json:"value" bson:"value"`
+	Cost        string    `json:"cost" bson:"cost"`
+	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// RelayQuotaSlot represents one of the slots of the quota of transactions of
+// an action relayed on behalf of a user. A slot is reserved for every relayed
+// transaction until the end of the quota window, so the quota is exceeded
+// while all of them are reserved. The slots of the transactions paid by a
+// community have no user but the ID of the community.
+type RelayQuotaSlot struct {
+	ID            string    `json:"id" bson:"_id"`
+	FID           uint64    `json:"fid,omitempty" bson:"fid,omitempty"`
+	CommunityID   string    `json:"communityId,omitempty" bson:"communityId,omitempty"`
+	Action        string    `json:"action" bson:"action"`
+	ReservedUntil time.Time `json:"reservedUntil" bson:"reservedUntil"`
+}
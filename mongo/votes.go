@@ -6,10 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"slices"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/types"
 )
@@ -82,6 +84,69 @@ func (ms *MongoStorage) VotersOfElection(electionID types.HexBytes) ([]*User, er
 	return users, nil
 }
 
+// CommunityEngagedVoters returns the FIDs of the voters of the previous polls
+// of the community provided that match the engaged rule provided: the users
+// who voted in at least rule.MinVotes of the last rule.LastPolls polls of the
+// community, created in the last rule.Window seconds if it is not zero. The
+// FIDs are sorted to get always the same result.
+func (ms *MongoStorage) CommunityEngagedVoters(communityID string, rule *CommunityEngagedRule) ([]uint64, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// get the last polls of the community, created in the window if provided
+	filter := bson.M{"community.id": communityID}
+	if rule.Window > 0 {
+		filter["createdTime"] = bson.M{"$gte": time.Now().Add(-time.Duration(rule.Window) * time.Second)}
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdTime", Value: -1}}).
+		SetLimit(int64(rule.LastPolls)).
+		SetProjection(bson.M{"_id": 1})
+	cursor, err := ms.elections.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find elections by community ID: %w", err)
+	}
+	defer cursor.Close(ctx)
+	electionIDs := []string{}
+	for cursor.Next(ctx) {
+		var election Election
+		if err := cursor.Decode(&election); err != nil {
+			log.Warn("failed to decode election: ", err)
+			continue
+		}
+		electionIDs = append(electionIDs, election.ElectionID)
+	}
+	// count the votes of every voter in the polls
+	votes := map[uint64]uint32{}
+	for _, electionID := range electionIDs {
+		bElectionID, err := hex.DecodeString(electionID)
+		if err != nil {
+			log.Warnw("invalid election ID", "electionID", electionID, "err", err)
+			continue
+		}
+		voters, err := ms.votersOfElection(bElectionID)
+		if err != nil {
+			if errors.Is(err, ErrElectionUnknown) {
+				continue
+			}
+			return nil, err
+		}
+		for _, voter := range voters.Voters {
+			votes[voter]++
+		}
+	}
+	engaged := []uint64{}
+	for voter, count := range votes {
+		if count >= rule.MinVotes {
+			engaged = append(engaged, voter)
+		}
+	}
+	slices.Sort(engaged)
+	return engaged, nil
+}
+
 // RemindersOfElection returns the list of remindable voters of an election and
 // the number of already reminded voters.
 func (ms *MongoStorage) RemindersOfElection(electionID types.HexBytes) (map[uint64]string, uint64, error) {
@@ -141,6 +206,37 @@ func (ms *MongoStorage) RemindersSent(electionID types.HexBytes, reminders map[u
 	return nil
 }
 
+// SetVoterChoices stores the choices of the voter provided in the election
+// provided, one per question.
+func (ms *MongoStorage) SetVoterChoices(electionID types.HexBytes, userFID uint64, choices []int) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := ms.voters.UpdateOne(ctx, bson.M{"_id": electionID.String()},
+		bson.M{"$set": bson.M{fmt.Sprintf("choices.%d", userFID): choices}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+// VoterChoices returns the choices stored for the voters of the election
+// provided, indexed by the voter FID.
+func (ms *MongoStorage) VoterChoices(electionID types.HexBytes) (map[uint64][]int, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	voters, err := ms.votersOfElection(electionID)
+	if err != nil {
+		return nil, err
+	}
+	if voters.Choices == nil {
+		return map[uint64][]int{}, nil
+	}
+	return voters.Choices, nil
+}
+
 func (ms *MongoStorage) votersOfElection(electionID types.HexBytes) (*VotersOfElection, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -0,0 +1,183 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AddWebhook stores the webhook provided, assigning it a new ID and its
+// creation time.
+func (ms *MongoStorage) AddWebhook(webhook *Webhook) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	webhook.ID = primitive.NewObjectID()
+	webhook.CreatedAt = time.Now()
+	_, err := ms.webhooks.InsertOne(ctx, webhook)
+	return err
+}
+
+// Webhook returns the webhook with the ID provided. If it does not exist, it
+// returns ErrWebhookUnknown.
+func (ms *MongoStorage) Webhook(id primitive.ObjectID) (*Webhook, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	webhook := &Webhook{}
+	if err := ms.webhooks.FindOne(ctx, bson.M{"_id": id}).Decode(webhook); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrWebhookUnknown
+		}
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// Webhooks returns the webhooks of the community provided.
+func (ms *MongoStorage) Webhooks(communityID string) ([]*Webhook, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := ms.webhooks.Find(ctx, bson.M{"communityId": communityID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	webhooks := []*Webhook{}
+	if err := cursor.All(ctx, &webhooks); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook removes the webhook with the ID provided from the community
+// provided. The pending deliveries of the webhook are not retried anymore.
+// If the webhook does not exist, it returns ErrWebhookUnknown.
+func (ms *MongoStorage) DeleteWebhook(communityID string, id primitive.ObjectID) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := ms.webhooks.DeleteOne(ctx, bson.M{"_id": id, "communityId": communityID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrWebhookUnknown
+	}
+	_, err = ms.webhookDeliveries.UpdateMany(ctx,
+		bson.M{"webhookId": id, "status": WebhookDeliveryPending},
+		bson.M{"$set": bson.M{
+			"status":    WebhookDeliveryFailed,
+			"lastError": "webhook removed",
+			"updatedAt": time.Now(),
+		}})
+	return err
+}
+
+// AddWebhookDelivery stores the delivery provided, assigning it a new ID and
+// its creation time.
+func (ms *MongoStorage) AddWebhookDelivery(delivery *WebhookDelivery) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	delivery.ID = primitive.NewObjectID()
+	delivery.CreatedAt = time.Now()
+	delivery.UpdatedAt = delivery.CreatedAt
+	_, err := ms.webhookDeliveries.InsertOne(ctx, delivery)
+	return err
+}
+
+// UpdateWebhookDelivery replaces the stored delivery with the one provided,
+// updating its modification time.
+func (ms *MongoStorage) UpdateWebhookDelivery(delivery *WebhookDelivery) error {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	delivery.UpdatedAt = time.Now()
+	_, err := ms.webhookDeliveries.ReplaceOne(ctx, bson.M{"_id": delivery.ID}, delivery)
+	return err
+}
+
+// ClaimNextWebhookDelivery atomically takes the oldest pending delivery whose
+// next attempt is due, postponing its next attempt by the lease provided, so
+// no other instance retries it meanwhile. If the instance stops during the
+// attempt, the delivery is retried once the lease expires. It returns
+// ErrWebhookDeliveryUnknown if there is no delivery to retry.
+func (ms *MongoStorage) ClaimNextWebhookDelivery(lease time.Duration) (*WebhookDelivery, error) {
+	ms.keysLock.Lock()
+	defer ms.keysLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	now := time.Now()
+	filter := bson.M{
+		"status":      WebhookDeliveryPending,
+		"nextAttempt": bson.M{"$lte": now},
+	}
+	update := bson.M{"$set": bson.M{
+		"nextAttempt": now.Add(lease),
+		"updatedAt":   now,
+	}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "nextAttempt", Value: 1}}).
+		SetReturnDocument(options.After)
+	delivery := &WebhookDelivery{}
+	if err := ms.webhookDeliveries.FindOneAndUpdate(ctx, filter, update, opts).Decode(delivery); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrWebhookDeliveryUnknown
+		}
+		return nil, fmt.Errorf("cannot claim webhook delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+// WebhookDeliveries returns up to limit deliveries of the community provided,
+// sorted from the newest to the oldest. If the webhook ID provided is not
+// zero, only the deliveries of that webhook are returned.
+func (ms *MongoStorage) WebhookDeliveries(communityID string, webhookID primitive.ObjectID, limit int64) ([]*WebhookDelivery, error) {
+	ms.keysLock.RLock()
+	defer ms.keysLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"communityId": communityID}
+	if !webhookID.IsZero() {
+		filter["webhookId"] = webhookID
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(limit)
+	cursor, err := ms.webhookDeliveries.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	deliveries := []*WebhookDelivery{}
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
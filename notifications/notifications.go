@@ -38,6 +38,13 @@ To receive the Frame to vote in this poll and from others from communities you b
 
 %s
 
+(reply '@%s mute' to stop receiving alerts from %s)`
+	// Requires <userName>, <customText>, <botUserName> and <authorName>
+	// Mentions <userName> and <botUserName>
+	DefaultDelegationExpiryMessage = `👋 Hey @%s !
+
+%s
+
 (reply '@%s mute' to stop receiving alerts from %s)`
 )
 
@@ -57,6 +64,7 @@ type NotifificationManagerConfig struct {
 	PermissionMessage         string
 	NotificationMessage       string
 	CustomNotificationMessage string
+	DelegationExpiryMessage   string
 	FrameURL                  string
 }
 
@@ -72,6 +80,7 @@ type NotificationManager struct {
 	permissionMsg         string
 	notificationMsg       string
 	customNotificationMsg string
+	delegationExpiryMsg   string
 	frameURL              string
 }
 
@@ -108,6 +117,9 @@ func (conf *NotifificationManagerConfig) check() error {
 	if conf.CustomNotificationMessage == "" {
 		conf.CustomNotificationMessage = DefaultCustomNotificationMessage
 	}
+	if conf.DelegationExpiryMessage == "" {
+		conf.DelegationExpiryMessage = DefaultDelegationExpiryMessage
+	}
 	return nil
 }
 
@@ -128,6 +140,7 @@ func New(ctx context.Context, config *NotifificationManagerConfig) (*Notificatio
 		permissionMsg:         config.PermissionMessage,
 		notificationMsg:       config.NotificationMessage,
 		customNotificationMsg: config.CustomNotificationMessage,
+		delegationExpiryMsg:   config.DelegationExpiryMessage,
 		frameURL:              config.FrameURL,
 	}, nil
 }
@@ -166,7 +179,9 @@ func (nm *NotificationManager) Stop() {
 // goroutines and an error channel to return any error found. It checks if the
 // user to notify has accepted the notifications, if not, requests the
 // permission. It also purges the notifications that have not been accepted
-// after its deadline.
+// after its deadline. The permission is only requested for new election
+// notifications, the rest of notifications are only sent to the users that
+// have already accepted them.
 func (nm *NotificationManager) handleNotifications(notifications []mongo.Notification) error {
 	// create channels and waitgroup, the semaphore is used to limit the number
 	// of concurrent goroutines and the error channel is used to return any
@@ -182,7 +197,14 @@ func (nm *NotificationManager) handleNotifications(notifications []mongo.Notific
 		go func(n mongo.Notification) {
 			defer wg.Done()
 			defer func() { <-sem }()
-			allowed, err := nm.checkOrReqPermission(n.UserID, n.Username, n.AuthorUsername, n.CommunityName)
+			var allowed bool
+			var err error
+			switch n.Type {
+			case mongo.NotificationTypeNewElection:
+				allowed, err = nm.checkOrReqPermission(n.UserID, n.Username, n.AuthorUsername, n.CommunityName)
+			default:
+				allowed, err = nm.checkPermission(n.UserID)
+			}
 			if err != nil {
 				if errors.Is(err, mongo.ErrUserUnknown) {
 					log.Debugw("user not found", "user", n.UserID)
@@ -242,7 +264,12 @@ func (nm *NotificationManager) handleNotifications(notifications []mongo.Notific
 			// template or the custom template if the custom text is not empty
 			var msg string
 			var mentions []uint64
-			if n.CustomText == "" {
+			if n.Type == mongo.NotificationTypeDelegationExpiry {
+				// delegation expiry message, the custom text contains the
+				// details of the delegation
+				msg = fmt.Sprintf(nm.delegationExpiryMsg, n.Username, n.CustomText, botdata.Username, n.AuthorUsername)
+				mentions = []uint64{n.UserID, botdata.FID}
+			} else if n.CustomText == "" {
 				// default message
 				msg = fmt.Sprintf(nm.notificationMsg, n.Username, n.AuthorUsername, n.CommunityName, botdata.Username, n.AuthorUsername)
 				mentions = []uint64{n.UserID, botdata.FID}
@@ -252,7 +279,11 @@ func (nm *NotificationManager) handleNotifications(notifications []mongo.Notific
 				mentions = []uint64{n.UserID, botdata.FID}
 			}
 			// send the notification and remove it from the database
-			if err := nm.api.Reply(nm.ctx, notificationThread, msg, mentions, n.FrameUrl); err != nil {
+			embeds := []string{}
+			if n.FrameUrl != "" {
+				embeds = append(embeds, n.FrameUrl)
+			}
+			if err := nm.api.Reply(nm.ctx, notificationThread, msg, mentions, embeds...); err != nil {
 				errCh <- fmt.Errorf("error sending notification: %s", err)
 				return
 			}
@@ -307,3 +338,14 @@ func (nm *NotificationManager) checkOrReqPermission(userID uint64, username, aut
 	}
 	return false, nil
 }
+
+// checkPermission checks if the user has accepted the notifications, without
+// requesting the permission if it has not been requested yet. If an error
+// occurs, it returns the error.
+func (nm *NotificationManager) checkPermission(userID uint64) (bool, error) {
+	profile, err := nm.db.UserAccessProfile(userID)
+	if err != nil {
+		return false, err
+	}
+	return profile.NotificationsAccepted, nil
+}
@@ -0,0 +1,196 @@
+package onchain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"go.vocdoni.io/dvote/log"
+)
+
+// balanceOfBatchSize is the maximum number of account and token ID pairs
+// requested on every call to balanceOfBatch.
+const balanceOfBatchSize = 200
+
+// erc721ABI includes the methods of the ERC-721 standard used to get the
+// holders of a list of token IDs.
+var erc721ABI = mustParseABI(`[
+	{"type":"function","name":"ownerOf","stateMutability":"view",
+	 "inputs":[{"name":"tokenId","type":"uint256"}],
+	 "outputs":[{"name":"","type":"address"}]}
+]`)
+
+// erc1155ABI includes the methods and events of the ERC-1155 standard used to
+// get the holders of a list of token IDs.
+var erc1155ABI = mustParseABI(`[
+	{"type":"function","name":"balanceOfBatch","stateMutability":"view",
+	 "inputs":[{"name":"accounts","type":"address[]"},{"name":"ids","type":"uint256[]"}],
+	 "outputs":[{"name":"","type":"uint256[]"}]},
+	{"type":"event","name":"TransferSingle","anonymous":false,
+	 "inputs":[{"name":"operator","type":"address","indexed":true},
+	           {"name":"from","type":"address","indexed":true},
+	           {"name":"to","type":"address","indexed":true},
+	           {"name":"id","type":"uint256","indexed":false},
+	           {"name":"value","type":"uint256","indexed":false}]},
+	{"type":"event","name":"TransferBatch","anonymous":false,
+	 "inputs":[{"name":"operator","type":"address","indexed":true},
+	           {"name":"from","type":"address","indexed":true},
+	           {"name":"to","type":"address","indexed":true},
+	           {"name":"ids","type":"uint256[]","indexed":false},
+	           {"name":"values","type":"uint256[]","indexed":false}]}
+]`)
+
+// ERC721Holders returns the holders of the token IDs provided of an ERC-721
+// contract, querying the owner of every token ID to the contract. The balance
+// of every holder is the number of the token IDs provided that it owns. The
+// token IDs that do not exist (or have been burned) are skipped.
+func (c *Client) ERC721Holders(ctx context.Context, chainID uint64, contract common.Address,
+	tokenIDs []*big.Int,
+) ([]*Holder, error) {
+	balances := map[common.Address]*big.Int{}
+	for _, tokenID := range tokenIDs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		res, err := c.call(ctx, chainID, contract, erc721ABI, "ownerOf", tokenID)
+		if err != nil {
+			log.Debugw("cannot get owner of token ID", "contract", contract, "tokenID", tokenID, "error", err)
+			continue
+		}
+		owner, ok := res[0].(common.Address)
+		if !ok || owner == (common.Address{}) {
+			continue
+		}
+		if _, ok := balances[owner]; !ok {
+			balances[owner] = new(big.Int)
+		}
+		balances[owner].Add(balances[owner], big.NewInt(1))
+	}
+	return holdersFromBalances(balances), nil
+}
+
+// ERC1155Holders returns the holders of the token IDs provided of an ERC-1155
+// contract. The standard does not allow to list the holders of a token, so the
+// candidates are the receivers of the transfers of the token IDs provided,
+// which are found in the contract logs since its deployment. Then the current
+// balances of the candidates are queried to the contract. The balance of every
+// holder is the sum of its balances of the token IDs provided.
+func (c *Client) ERC1155Holders(ctx context.Context, chainID uint64, contract common.Address,
+	tokenIDs []*big.Int,
+) ([]*Holder, error) {
+	candidates, err := c.erc1155Receivers(ctx, chainID, contract, tokenIDs)
+	if err != nil {
+		return nil, err
+	}
+	// build the pairs of candidates and token IDs to query their balances
+	type pair struct {
+		account common.Address
+		tokenID *big.Int
+	}
+	pairs := []pair{}
+	for _, candidate := range candidates {
+		for _, tokenID := range tokenIDs {
+			pairs = append(pairs, pair{candidate, tokenID})
+		}
+	}
+	balances := map[common.Address]*big.Int{}
+	for start := 0; start < len(pairs); start += balanceOfBatchSize {
+		end := min(start+balanceOfBatchSize, len(pairs))
+		accounts := make([]common.Address, 0, end-start)
+		ids := make([]*big.Int, 0, end-start)
+		for _, p := range pairs[start:end] {
+			accounts = append(accounts, p.account)
+			ids = append(ids, p.tokenID)
+		}
+		res, err := c.call(ctx, chainID, contract, erc1155ABI, "balanceOfBatch", accounts, ids)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get balances from contract %s: %w", contract, err)
+		}
+		batchBalances, ok := res[0].([]*big.Int)
+		if !ok || len(batchBalances) != len(accounts) {
+			return nil, fmt.Errorf("invalid balances returned by contract %s", contract)
+		}
+		for i, balance := range batchBalances {
+			if balance.Sign() <= 0 {
+				continue
+			}
+			if _, ok := balances[accounts[i]]; !ok {
+				balances[accounts[i]] = new(big.Int)
+			}
+			balances[accounts[i]].Add(balances[accounts[i]], balance)
+		}
+	}
+	return holdersFromBalances(balances), nil
+}
+
+// erc1155Receivers returns the unique addresses that have received any of the
+// token IDs provided of the ERC-1155 contract, iterating over the transfer logs
+// of the contract from its deployment block to the latest one.
+func (c *Client) erc1155Receivers(ctx context.Context, chainID uint64, contract common.Address,
+	tokenIDs []*big.Int,
+) ([]common.Address, error) {
+	wanted := map[string]bool{}
+	for _, tokenID := range tokenIDs {
+		wanted[tokenID.String()] = true
+	}
+	singleTopic := erc1155ABI.Events["TransferSingle"].ID
+	batchTopic := erc1155ABI.Events["TransferBatch"].ID
+	receivers := map[common.Address]bool{}
+	err := c.scanLogs(ctx, chainID, contract, []common.Hash{singleTopic, batchTopic}, 0, func(l types.Log) {
+		if len(l.Topics) < 4 {
+			return
+		}
+		receiver := common.BytesToAddress(l.Topics[3].Bytes())
+		if receiver == (common.Address{}) {
+			return
+		}
+		var ids []*big.Int
+		switch l.Topics[0] {
+		case singleTopic:
+			values, err := erc1155ABI.Unpack("TransferSingle", l.Data)
+			if err != nil || len(values) != 2 {
+				return
+			}
+			if id, ok := values[0].(*big.Int); ok {
+				ids = []*big.Int{id}
+			}
+		case batchTopic:
+			values, err := erc1155ABI.Unpack("TransferBatch", l.Data)
+			if err != nil || len(values) != 2 {
+				return
+			}
+			ids, _ = values[0].([]*big.Int)
+		}
+		for _, id := range ids {
+			if wanted[id.String()] {
+				receivers[receiver] = true
+				return
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]common.Address, 0, len(receivers))
+	for receiver := range receivers {
+		addresses = append(addresses, receiver)
+	}
+	log.Debugw("found ERC-1155 transfer receivers",
+		"contract", contract, "chainID", chainID, "receivers", len(addresses))
+	return addresses, nil
+}
+
+// holdersFromBalances converts a map of balances by address to a list of
+// holders.
+func holdersFromBalances(balances map[common.Address]*big.Int) []*Holder {
+	holders := make([]*Holder, 0, len(balances))
+	for address, balance := range balances {
+		holders = append(holders, &Holder{
+			Address: address,
+			Balance: balance,
+		})
+	}
+	return holders
+}
@@ -0,0 +1,215 @@
+package onchain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	c3web3 "github.com/vocdoni/census3/helpers/web3"
+	"go.vocdoni.io/dvote/log"
+)
+
+const (
+	// logsBlocksWindow is the number of blocks requested on every call to get
+	// the logs of a contract.
+	logsBlocksWindow = 10000
+	// minLogsBlocksWindow is the minimum number of blocks requested on every
+	// call to get the logs of a contract. The window is reduced until
+	// this value if the web3 endpoint rejects the request.
+	minLogsBlocksWindow = 100
+)
+
+// blockchainChainIDs maps the names of the blockchains used by the token
+// censuses (the same ones that Airstack uses) to their chain IDs.
+var blockchainChainIDs = map[string]uint64{
+	"ethereum": 1,
+	"optimism": 10,
+	"polygon":  137,
+	"base":     8453,
+	"arbitrum": 42161,
+	"degen":    666666666,
+	"zora":     7777777,
+}
+
+// Holder represents a holder of a token with its address and its balance.
+type Holder struct {
+	Address common.Address
+	Balance *big.Int
+}
+
+// Client allows to query the holders of a token directly to the contracts
+// of the token, using the web3 endpoints of the pool provided.
+type Client struct {
+	w3p          *c3web3.Web3Pool
+	chainAliases map[string]uint64
+}
+
+// NewClient creates a new Client with the web3 pool provided. The chain
+// aliases (for example, the ones defined in the chains config file) can be
+// used as blockchain names, in addition to the default ones.
+func NewClient(w3p *c3web3.Web3Pool, chainAliases map[string]uint64) (*Client, error) {
+	if w3p == nil {
+		return nil, fmt.Errorf("no web3 pool provided")
+	}
+	return &Client{w3p: w3p, chainAliases: chainAliases}, nil
+}
+
+// ChainID returns the chain ID of the blockchain name provided. It supports
+// the chain aliases of the client, the blockchain names used by the token
+// censuses and the short names of the networks known by the web3 pool. It
+// returns false if the blockchain is not known or if there is no web3
+// endpoint available for it.
+func (c *Client) ChainID(blockchain string) (uint64, bool) {
+	chainID, ok := c.chainAliases[blockchain]
+	if !ok {
+		chainID, ok = blockchainChainIDs[blockchain]
+	}
+	if !ok {
+		info := c.w3p.NetworkInfoByShortName(blockchain)
+		if info == nil {
+			return 0, false
+		}
+		chainID = info.ChainID
+	}
+	if c.w3p.NumberOfEndpoints(chainID, true) == 0 {
+		return 0, false
+	}
+	return chainID, true
+}
+
+// ethClient returns the ethclient of an available web3 endpoint of the chain
+// provided. The ethclient is used instead of the web3 pool client because the
+// pool disables the endpoints that return any error, and some of the queries
+// are expected to fail (for example, when a token ID does not exist or when a
+// range of logs is too large).
+func (c *Client) ethClient(chainID uint64) (*ethclient.Client, error) {
+	client, err := c.w3p.Client(chainID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get web3 client for chain %d: %w", chainID, err)
+	}
+	return client.EthClient()
+}
+
+// call packs the method and the arguments provided using the ABI provided,
+// calls the contract in the chain provided and returns the unpacked results.
+func (c *Client) call(ctx context.Context, chainID uint64, contract common.Address,
+	contractABI *abi.ABI, method string, args ...any,
+) ([]any, error) {
+	client, err := c.ethClient(chainID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := contractABI.Pack(method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot pack %s call: %w", method, err)
+	}
+	res, err := client.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: data}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return contractABI.Unpack(method, res)
+}
+
+// BlockNumber returns the number of the latest block of the chain provided.
+func (c *Client) BlockNumber(ctx context.Context, chainID uint64) (uint64, error) {
+	client, err := c.ethClient(chainID)
+	if err != nil {
+		return 0, err
+	}
+	return client.BlockNumber(ctx)
+}
+
+// scanLogs iterates over the logs of the contract provided with any of the
+// topics provided, from its deployment block to the block provided (or the
+// latest one if it is zero), and calls the function provided with every log.
+// The logs are requested in windows of blocks, which are reduced if the web3
+// endpoint rejects the request.
+func (c *Client) scanLogs(ctx context.Context, chainID uint64, contract common.Address,
+	topics []common.Hash, toBlock uint64, fn func(types.Log),
+) error {
+	client, err := c.ethClient(chainID)
+	if err != nil {
+		return err
+	}
+	lastBlock := toBlock
+	if lastBlock == 0 {
+		if lastBlock, err = client.BlockNumber(ctx); err != nil {
+			return fmt.Errorf("cannot get last block number: %w", err)
+		}
+	}
+	fromBlock, err := c.deploymentBlock(ctx, chainID, contract, lastBlock)
+	if err != nil {
+		log.Warnw("cannot get contract deployment block, scanning from genesis",
+			"contract", contract, "chainID", chainID, "error", err)
+		fromBlock = 0
+	}
+	window := uint64(logsBlocksWindow)
+	for from := fromBlock; from <= lastBlock; {
+		to := min(from+window-1, lastBlock)
+		logs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Addresses: []common.Address{contract},
+			Topics:    [][]common.Hash{topics},
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// the endpoint can reject the request if the range has too many
+			// logs, so retry with a smaller window
+			if window > minLogsBlocksWindow {
+				window /= 2
+				continue
+			}
+			return fmt.Errorf("cannot get logs of contract %s: %w", contract, err)
+		}
+		for _, l := range logs {
+			fn(l)
+		}
+		from = to + 1
+	}
+	return nil
+}
+
+// deploymentBlock returns the block where the contract provided was deployed,
+// searching for the first block where the contract has code. It requires an
+// archive node to get the code of the contract in past blocks.
+func (c *Client) deploymentBlock(ctx context.Context, chainID uint64, contract common.Address,
+	lastBlock uint64,
+) (uint64, error) {
+	client, err := c.ethClient(chainID)
+	if err != nil {
+		return 0, err
+	}
+	low, high := uint64(0), lastBlock
+	for low < high {
+		mid := low + (high-low)/2
+		code, err := client.CodeAt(ctx, contract, new(big.Int).SetUint64(mid))
+		if err != nil {
+			return 0, err
+		}
+		if len(code) > 0 {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	return low, nil
+}
+
+// mustParseABI parses the JSON ABI provided and panics if it is not valid. It
+// is intended to be used only with constant ABIs.
+func mustParseABI(jsonABI string) *abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(jsonABI))
+	if err != nil {
+		panic(err)
+	}
+	return &parsed
+}
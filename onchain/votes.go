@@ -0,0 +1,105 @@
+package onchain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"go.vocdoni.io/dvote/log"
+)
+
+// erc20VotesABI includes the methods and events of the OpenZeppelin
+// ERC20Votes extension (and the ERC-6372 clock) used to get the voting power
+// of the delegatees of a governance token.
+var erc20VotesABI = mustParseABI(`[
+	{"type":"function","name":"getPastVotes","stateMutability":"view",
+	 "inputs":[{"name":"account","type":"address"},{"name":"timepoint","type":"uint256"}],
+	 "outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"decimals","stateMutability":"view",
+	 "inputs":[],
+	 "outputs":[{"name":"","type":"uint8"}]},
+	{"type":"function","name":"CLOCK_MODE","stateMutability":"view",
+	 "inputs":[],
+	 "outputs":[{"name":"","type":"string"}]},
+	{"type":"event","name":"DelegateVotesChanged","anonymous":false,
+	 "inputs":[{"name":"delegate","type":"address","indexed":true},
+	           {"name":"previousVotes","type":"uint256","indexed":false},
+	           {"name":"newVotes","type":"uint256","indexed":false}]}
+]`)
+
+// timestampClockMode is the ERC-6372 clock mode of the tokens that use the
+// block timestamp instead of the block number as timepoint.
+const timestampClockMode = "mode=timestamp"
+
+// TokenDecimals returns the number of decimals of the ERC20 token provided.
+func (c *Client) TokenDecimals(ctx context.Context, chainID uint64, token common.Address) (uint8, error) {
+	res, err := c.call(ctx, chainID, token, erc20VotesABI, "decimals")
+	if err != nil {
+		return 0, fmt.Errorf("cannot get decimals of token %s: %w", token, err)
+	}
+	decimals, ok := res[0].(uint8)
+	if !ok {
+		return 0, fmt.Errorf("invalid decimals returned by token %s", token)
+	}
+	return decimals, nil
+}
+
+// PastVotes returns the voting power of every delegatee of the ERC20Votes
+// token provided at the snapshot block provided, using getPastVotes. The
+// delegatees are the accounts that appear in the DelegateVotesChanged logs of
+// the token until the snapshot block, only the ones with voting power are
+// returned. The snapshot block must be lower than the latest block. If the
+// token uses timestamps as clock (ERC-6372), the timestamp of the snapshot
+// block is used as timepoint.
+func (c *Client) PastVotes(ctx context.Context, chainID uint64, token common.Address,
+	snapshotBlock uint64,
+) ([]*Holder, error) {
+	client, err := c.ethClient(chainID)
+	if err != nil {
+		return nil, err
+	}
+	timepoint := new(big.Int).SetUint64(snapshotBlock)
+	if res, err := c.call(ctx, chainID, token, erc20VotesABI, "CLOCK_MODE"); err == nil {
+		if mode, ok := res[0].(string); ok && strings.Contains(mode, timestampClockMode) {
+			header, err := client.HeaderByNumber(ctx, timepoint)
+			if err != nil {
+				return nil, fmt.Errorf("cannot get snapshot block %d: %w", snapshotBlock, err)
+			}
+			timepoint = new(big.Int).SetUint64(header.Time)
+		}
+	}
+	// find every account that has been a delegatee until the snapshot
+	delegatees := map[common.Address]bool{}
+	topic := erc20VotesABI.Events["DelegateVotesChanged"].ID
+	if err := c.scanLogs(ctx, chainID, token, []common.Hash{topic}, snapshotBlock, func(l types.Log) {
+		if len(l.Topics) < 2 {
+			return
+		}
+		delegatees[common.BytesToAddress(l.Topics[1].Bytes())] = true
+	}); err != nil {
+		return nil, err
+	}
+	// get the voting power of every delegatee at the snapshot
+	holders := []*Holder{}
+	for delegatee := range delegatees {
+		res, err := c.call(ctx, chainID, token, erc20VotesABI, "getPastVotes", delegatee, timepoint)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get past votes of %s: %w", delegatee, err)
+		}
+		votes, ok := res[0].(*big.Int)
+		if !ok || votes.Sign() <= 0 {
+			continue
+		}
+		holders = append(holders, &Holder{
+			Address: delegatee,
+			Balance: votes,
+		})
+	}
+	log.Debugw("fetched ERC20Votes voting power",
+		"token", token, "chainID", chainID, "snapshot", snapshotBlock,
+		"delegatees", len(delegatees), "holders", len(holders))
+	return holders, nil
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/vocdoni/vote-frame/communityhub"
+	"github.com/vocdoni/vote-frame/mongo"
+	"go.vocdoni.io/dvote/httprouter"
+	"go.vocdoni.io/dvote/httprouter/apirest"
+	"go.vocdoni.io/dvote/log"
+)
+
+const (
+	// relayCommunityQuota is the number of communities that a user can
+	// create with transactions relayed by the server during relayQuotaWindow
+	relayCommunityQuota = 3
+	// relayQuotaWindow is the time window of the quota of relayed community
+	// creations
+	relayQuotaWindow = 24 * time.Hour
+	// relayTxTimeout is the time that a request waits for a relayed
+	// transaction to be mined before responding
+	relayTxTimeout = 3 * time.Minute
+	// relayReceiptTimeout is the time that a relayed transaction is waited
+	// in the background to account its gas
+	relayReceiptTimeout = 30 * time.Minute
+	// relayedTxsLimit is the number of relayed transactions returned with
+	// the relay information of a community
+	relayedTxsLimit = 50
+)
+
+// relayCommunityBudget enables the budget of the communities for the relayed
+// updates of their settings, which are paid with their funds in the community
+// hub contract. If it is set, the updates are refused once the gas spent in
+// them exceeds the funds of the community.
+var relayCommunityBudget = false
+
+// createCommunityHandler creates a community in the community hub contract of
+// the chain of the request, with the authenticated user as its creator. The
+// transaction is relayed by the server, so the user does not need funds, but
+// every user can only create relayCommunityQuota communities during
+// relayQuotaWindow. The quota is reserved in the database before sending the
+// transaction, so it is not exceeded with concurrent requests. It waits until
+// the transaction is mined and returns the ID of the community created.
+func (v *vocdoniHandler) createCommunityHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	if v.comhub == nil {
+		return ctx.Send([]byte("community hub not available"), http.StatusServiceUnavailable)
+	}
+	// extract userFID from auth token
+	userFID, err := v.db.UserFromAuthToken(msg.AuthToken)
+	if err != nil {
+		return fmt.Errorf("cannot get user from auth token: %w", err)
+	}
+	req := &CreateCommunityRequest{}
+	if err := json.Unmarshal(msg.Data, req); err != nil {
+		return ctx.Send([]byte("error decoding community data"), http.StatusBadRequest)
+	}
+	chainID, ok := v.comhub.ChainIDFromAlias(req.ChainAlias)
+	if !ok {
+		return ctx.Send([]byte("invalid community chain alias provided"), http.StatusBadRequest)
+	}
+	// the creator must be the first admin of the community
+	admins := []uint64{userFID}
+	for _, admin := range req.Admins {
+		if admin != nil && admin.FID != userFID {
+			admins = append(admins, admin.FID)
+		}
+	}
+	notifications, disabled := req.Notifications, false
+	hubCommunity := &communityhub.HubCommunity{
+		ChainID:       chainID,
+		Name:          req.Name,
+		ImageURL:      req.LogoURL,
+		GroupChatURL:  req.GroupChatURL,
+		Channels:      req.Channels,
+		Admins:        admins,
+		Notifications: &notifications,
+		Disabled:      &disabled,
+	}
+	if err := setHubCommunityCensus(hubCommunity, userFID, &req.Community); err != nil {
+		return ctx.Send([]byte(err.Error()), http.StatusBadRequest)
+	}
+	// validate the community before reserving the quota and uploading the
+	// logo, so the invalid requests do not consume them
+	if err := v.comhub.ValidateCommunity(hubCommunity); err != nil {
+		return ctx.Send([]byte(err.Error()), http.StatusBadRequest)
+	}
+	contract, err := v.comhub.Contract(req.ChainAlias)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), http.StatusBadRequest)
+	}
+	// reserve a slot of the quota of the user, which is released if the
+	// transaction is not sent
+	var tx *communityhub.HubTx
+	if userFID != v.adminFID {
+		quotaSlot, err := v.db.ReserveRelayQuota(userFID, mongo.RelayActionCreateCommunity,
+			relayCommunityQuota, relayQuotaWindow)
+		if err != nil {
+			if errors.Is(err, mongo.ErrRelayQuotaExceeded) {
+				return ctx.Send([]byte("community creation quota exceeded, try again later"), http.StatusTooManyRequests)
+			}
+			return fmt.Errorf("error reserving relay quota: %w", err)
+		}
+		defer func() {
+			if tx != nil {
+				return
+			}
+			if err := v.db.ReleaseRelayQuota(quotaSlot); err != nil {
+				log.Warnw("failed to release relay quota", "slot", quotaSlot, "error", err)
+			}
+		}()
+	}
+	// upload the logo if it is base64 encoded
+	if isBase64Image(hubCommunity.ImageURL) {
+		avatarURL, err := v.uploadAvatar("", userFID, "", hubCommunity.ImageURL)
+		if err != nil {
+			return fmt.Errorf("cannot upload avatar: %w", err)
+		}
+		hubCommunity.ImageURL = avatarURL
+	}
+	txCtx, cancel := context.WithTimeout(ctx.Request.Context(), relayTxTimeout)
+	defer cancel()
+	created, tx, receipt, err := v.comhub.CreateCommunity(txCtx, hubCommunity)
+	if tx != nil {
+		if receipt != nil {
+			communityID := ""
+			if created != nil {
+				communityID = created.CommunityID
+			}
+			v.storeRelayedTx(userFID, communityID, mongo.RelayActionCreateCommunity, tx.Value, receipt)
+		} else {
+			// the transaction is sent but not mined yet, so store it now and
+			// account its gas when it is mined
+			v.storeRelayedTx(userFID, "", mongo.RelayActionCreateCommunity, tx.Value,
+				&communityhub.HubReceipt{Hash: tx.Hash})
+			go v.accountRelayedTx(contract, userFID, "", mongo.RelayActionCreateCommunity, tx)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, communityhub.ErrInvalidCommunityData) {
+			return ctx.Send([]byte(err.Error()), http.StatusBadRequest)
+		}
+		if tx != nil && receipt == nil {
+			return ctx.Send([]byte(fmt.Sprintf("transaction %s not mined yet", tx.Hash.Hex())), http.StatusGatewayTimeout)
+		}
+		return fmt.Errorf("error creating community: %w", err)
+	}
+	log.Infow("community created", "communityID", created.CommunityID, "creator", userFID, "tx", tx.Hash.Hex())
+	res, err := json.Marshal(CommunityCreated{
+		ID:     created.CommunityID,
+		TxHash: tx.Hash.Hex(),
+		Block:  receipt.Block,
+	})
+	if err != nil {
+		return ctx.Send([]byte("error encoding community"), http.StatusInternalServerError)
+	}
+	return ctx.Send(res, http.StatusOK)
+}
+
+// communityRelayHandler returns the funds of the community in the community
+// hub contract, the cost of the transactions relayed for it and the latest of
+// them. It is only available to the users that can edit its settings.
+func (v *vocdoniHandler) communityRelayHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	community, _, status, err := v.webhooksCommunity(msg, ctx)
+	if err != nil {
+		return ctx.Send([]byte(err.Error()), status)
+	}
+	funds, spent, err := v.communityRelayBudget(community.ID)
+	if err != nil {
+		return ctx.Send([]byte("error getting community relay budget"), http.StatusInternalServerError)
+	}
+	txs, err := v.db.RelayedTxs(community.ID, relayedTxsLimit)
+	if err != nil {
+		return ctx.Send([]byte("error getting relayed transactions"), http.StatusInternalServerError)
+	}
+	res, err := json.Marshal(CommunityRelay{
+		Funds:        funds.String(),
+		Spent:        spent.String(),
+		Transactions: txs,
+	})
+	if err != nil {
+		return ctx.Send([]byte("error encoding community relay"), http.StatusInternalServerError)
+	}
+	return ctx.Send(res, http.StatusOK)
+}
+
+// relayCommunityUpdate updates the community provided in the community hub
+// with a transaction relayed by the server on behalf of the user provided.
+// The gas of the transaction is accounted in the background once it is mined.
+// If relayCommunityBudget is set, the gas of the updates is paid with the
+// funds of the community in the contract, so if they are already spent, the
+// update is refused unless the user is the server admin. In that case, only
+// one update of every community is relayed at a time, reserving it in the
+// database until its gas is accounted, so concurrent updates cannot exceed
+// the budget. If something fails, it returns the HTTP status and the error to
+// send.
+func (v *vocdoniHandler) relayCommunityUpdate(userFID uint64, update *communityhub.HubCommunity) (int, error) {
+	contract, err := v.comhub.CommunityContract(update.CommunityID)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	release := func() {}
+	if relayCommunityBudget && userFID != v.adminFID {
+		slotID, err := v.db.ReserveCommunityRelay(update.CommunityID, relayReceiptTimeout)
+		if err != nil {
+			if errors.Is(err, mongo.ErrCommunityRelayInFlight) {
+				return http.StatusConflict, fmt.Errorf("other update of the community is being relayed, try again later")
+			}
+			return http.StatusInternalServerError, err
+		}
+		release = func() {
+			if err := v.db.ReleaseRelayQuota(slotID); err != nil {
+				log.Warnw("failed to release community relay", "communityID", update.CommunityID, "error", err)
+			}
+		}
+		funds, spent, err := v.communityRelayBudget(update.CommunityID)
+		if err != nil {
+			release()
+			return http.StatusInternalServerError, err
+		}
+		if spent.Cmp(funds) > 0 {
+			release()
+			return http.StatusPaymentRequired, fmt.Errorf("the community funds are spent, add funds to the community to update it")
+		}
+	}
+	tx, err := v.comhub.UpdateCommunity(update)
+	if err != nil {
+		release()
+		return http.StatusInternalServerError, err
+	}
+	go func() {
+		defer release()
+		v.accountRelayedTx(contract, userFID, update.CommunityID, mongo.RelayActionUpdateCommunity, tx)
+	}()
+	return http.StatusOK, nil
+}
+
+// communityRelayBudget returns the funds of the community provided in the
+// community hub contract and the cost of the transactions relayed for it that
+// are paid with them.
+func (v *vocdoniHandler) communityRelayBudget(communityID string) (*big.Int, *big.Int, error) {
+	contract, err := v.comhub.CommunityContract(communityID)
+	if err != nil {
+		return nil, nil, err
+	}
+	community, err := contract.Community(communityID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting community from the contract: %w", err)
+	}
+	spent, err := v.db.CommunityRelayCost(communityID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting community relay cost: %w", err)
+	}
+	return community.Funds(), spent, nil
+}
+
+// accountRelayedTx waits until the relayed transaction provided is mined in
+// the contract provided and stores its gas cost. If the community is not
+// provided, it is taken from the receipt, for the communities created by the
+// transaction. It must run in the background.
+func (v *vocdoniHandler) accountRelayedTx(contract *communityhub.HubContract, userFID uint64,
+	communityID, action string, tx *communityhub.HubTx,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), relayReceiptTimeout)
+	defer cancel()
+	receipt, err := contract.WaitReceipt(ctx, tx.Hash)
+	if err != nil {
+		log.Warnw("failed to get relayed transaction receipt", "tx", tx.Hash.Hex(), "action", action, "error", err)
+		return
+	}
+	if communityID == "" && receipt.ContractID != 0 {
+		communityID = communityhub.EncodePrefix(contract.ChainAlias, fmt.Sprint(receipt.ContractID))
+	}
+	v.storeRelayedTx(userFID, communityID, action, tx.Value, receipt)
+}
+
+// storeRelayedTx stores the transaction of the receipt provided as relayed by
+// the server on behalf of the user and the community provided.
+func (v *vocdoniHandler) storeRelayedTx(userFID uint64, communityID, action string,
+	value *big.Int, receipt *communityhub.HubReceipt,
+) {
+	if err := v.db.AddRelayedTx(relayedTxFromReceipt(userFID, communityID, action, value, receipt)); err != nil {
+		log.Warnw("failed to store relayed transaction", "tx", receipt.Hash.Hex(), "action", action, "error", err)
+	}
+}
+
+// relayedTxFromReceipt returns the relayed transaction of the receipt provided
+// sent on behalf of the user and the community provided. Its cost is the gas
+// paid, the value sent is stored apart because it is added to the funds of the
+// community.
+func relayedTxFromReceipt(userFID uint64, communityID, action string,
+	value *big.Int, receipt *communityhub.HubReceipt,
+) *mongo.RelayedTx {
+	if value == nil {
+		value = big.NewInt(0)
+	}
+	gasPrice := receipt.GasPrice
+	if gasPrice == nil {
+		gasPrice = big.NewInt(0)
+	}
+	return &mongo.RelayedTx{
+		Hash:        receipt.Hash.Hex(),
+		FID:         userFID,
+		CommunityID: communityID,
+		Action:      action,
+		Block:       receipt.Block,
+		GasUsed:     receipt.GasUsed,
+		GasPrice:    gasPrice.String(),
+		Value:       value.String(),
+		Cost:        receipt.GasCost().String(),
+	}
+}
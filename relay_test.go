@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/vote-frame/communityhub"
+	"github.com/vocdoni/vote-frame/mongo"
+)
+
+func Test_relayedTxFromReceipt(t *testing.T) {
+	hash := common.HexToHash("0x01")
+	tests := []struct {
+		name     string
+		value    *big.Int
+		receipt  *communityhub.HubReceipt
+		gasPrice string
+		cost     string
+		expValue string
+	}{
+		{
+			name: "gas paid",
+			receipt: &communityhub.HubReceipt{
+				Hash:     hash,
+				Block:    10,
+				GasUsed:  21000,
+				GasPrice: big.NewInt(2_000_000_000),
+				Success:  true,
+			},
+			gasPrice: "2000000000",
+			cost:     "42000000000000",
+			expValue: "0",
+		},
+		{
+			name:  "value sent is not a cost",
+			value: big.NewInt(1_000_000),
+			receipt: &communityhub.HubReceipt{
+				Hash:     hash,
+				GasUsed:  50000,
+				GasPrice: big.NewInt(3),
+				Success:  true,
+			},
+			gasPrice: "3",
+			cost:     "150000",
+			expValue: "1000000",
+		},
+		{
+			name: "reverted transaction also pays its gas",
+			receipt: &communityhub.HubReceipt{
+				Hash:     hash,
+				GasUsed:  30000,
+				GasPrice: big.NewInt(5),
+			},
+			gasPrice: "5",
+			cost:     "150000",
+			expValue: "0",
+		},
+		{
+			name:     "unknown gas price",
+			receipt:  &communityhub.HubReceipt{Hash: hash, GasUsed: 30000},
+			gasPrice: "0",
+			cost:     "0",
+			expValue: "0",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tx := relayedTxFromReceipt(5, "eth:1", mongo.RelayActionUpdateCommunity, tc.value, tc.receipt)
+			if tx.Hash != hash.Hex() || tx.FID != 5 || tx.CommunityID != "eth:1" ||
+				tx.Action != mongo.RelayActionUpdateCommunity || tx.Block != tc.receipt.Block ||
+				tx.GasUsed != tc.receipt.GasUsed {
+				t.Errorf("unexpected relayed transaction: %+v", tx)
+			}
+			if tx.GasPrice != tc.gasPrice || tx.Cost != tc.cost || tx.Value != tc.expValue {
+				t.Errorf("unexpected accounting: gas price %s, cost %s, value %s", tx.GasPrice, tx.Cost, tx.Value)
+			}
+		})
+	}
+
+	// the transactions of the memory backend are free
+	contract := communityhub.NewContract(1, "eth", common.Address{}, communityhub.NewMemoryBackend(1))
+	notifications, disabled := false, false
+	_, tx, receipt, err := contract.CreateCommunity(context.Background(), &communityhub.HubCommunity{
+		ChainID:       1,
+		Name:          "community",
+		CensusType:    communityhub.CensusTypeChannel,
+		CensusChannel: "vocdoni",
+		Admins:        []uint64{5},
+		Notifications: &notifications,
+		Disabled:      &disabled,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	relayed := relayedTxFromReceipt(5, "", mongo.RelayActionCreateCommunity, tx.Value, receipt)
+	if relayed.Hash != tx.Hash.Hex() || relayed.Cost != "0" || relayed.Value != "0" || relayed.Block != receipt.Block {
+		t.Errorf("unexpected relayed transaction of the memory backend: %+v", relayed)
+	}
+}
@@ -64,8 +64,9 @@ func (v *vocdoniHandler) sendRemindersHandler(msg *apirest.APIdata, ctx *httprou
 	if election.Community == nil || election.Community.ID == "" {
 		return fmt.Errorf("election is not a community election")
 	}
-	if !v.db.IsCommunityAdmin(auth.UserID, election.Community.ID) && auth.UserID != v.adminFID {
-		return ctx.Send([]byte("user is not an admin of the community"), http.StatusForbidden)
+	if !v.db.HasCommunityPermission(auth.UserID, election.Community.ID, mongo.PermissionSendReminders) &&
+		auth.UserID != v.adminFID {
+		return ctx.Send([]byte("user is not allowed to send reminders in the community"), http.StatusForbidden)
 	}
 	// decode the reminders request from the body, there are two types of
 	// reminders, one for ranked list of n users by weight and another for
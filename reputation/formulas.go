@@ -183,11 +183,11 @@ func communityYieldRate(p, cs, r float64, dao, channel bool) float64 {
 func communityTotalPoints(censusType string, m, p float64, cs, r uint64) uint64 {
 	var y float64
 	switch censusType {
-	case mongo.TypeCommunityCensusERC20, mongo.TypeCommunityCensusNFT:
+	case mongo.TypeCommunityCensusERC20, mongo.TypeCommunityCensusNFT, mongo.TypeCommunityCensusERC20Votes:
 		y = communityYieldRate(p, float64(cs), float64(r), true, false)
 	case mongo.TypeCommunityCensusChannel:
 		y = communityYieldRate(p, float64(cs), float64(r), false, true)
-	case mongo.TypeCommunityCensusFollowers:
+	case mongo.TypeCommunityCensusFollowers, mongo.TypeCommunityCensusEngaged, mongo.TypeCommunityCensusMembers:
 		y = communityYieldRate(p, float64(cs), float64(r), false, false)
 	default:
 		return 0
@@ -12,9 +12,11 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/vocdoni/census3/apiclient"
 	"github.com/vocdoni/vote-frame/airstack"
+	ac "github.com/vocdoni/vote-frame/airstack/client"
 	"github.com/vocdoni/vote-frame/alfafrens"
 	"github.com/vocdoni/vote-frame/communityhub"
 	"github.com/vocdoni/vote-frame/farcasterapi"
+	"github.com/vocdoni/vote-frame/helpers"
 	"github.com/vocdoni/vote-frame/mongo"
 	dbmongo "github.com/vocdoni/vote-frame/mongo"
 	"go.vocdoni.io/dvote/log"
@@ -611,15 +613,24 @@ func (u *Updater) communityConstants(community *dbmongo.Community) (float64, uin
 	var censusSize uint64
 	switch community.Census.Type {
 	case dbmongo.TypeCommunityCensusChannel:
-		users, err := u.fapi.ChannelFIDs(ctx, community.Census.Channel, nil)
-		if err != nil {
-			return 0, 0, fmt.Errorf("error fetching channel users: %w", err)
+		// the users who follow more than one channel are counted once
+		singleUsers := map[uint64]bool{}
+		for _, channel := range community.Census.CensusChannels() {
+			users, err := u.fapi.ChannelFIDs(ctx, channel, nil)
+			if err != nil {
+				return 0, 0, fmt.Errorf("error fetching channel users: %w", err)
+			}
+			for _, fid := range users {
+				singleUsers[fid] = true
+			}
 		}
-		censusSize = uint64(len(users))
-	case dbmongo.TypeCommunityCensusERC20, dbmongo.TypeCommunityCensusNFT:
+		censusSize = uint64(len(singleUsers))
+	case dbmongo.TypeCommunityCensusERC20, dbmongo.TypeCommunityCensusNFT, dbmongo.TypeCommunityCensusERC20Votes:
+		// the holders of the ERC20Votes tokens are used as an approximation
+		// of the size of the census of their delegatees
 		singleUsers := map[common.Address]bool{}
 		for _, token := range community.Census.Addresses {
-			holders, err := u.airstack.TokenBalances(common.HexToAddress(token.Address), token.Blockchain)
+			holders, err := u.censusTokenHolders(token)
 			if err != nil {
 				return 0, 0, fmt.Errorf("error fetching token holders: %w", err)
 			}
@@ -640,12 +651,42 @@ func (u *Updater) communityConstants(community *dbmongo.Community) (float64, uin
 			return 0, 0, fmt.Errorf("error fetching user followers: %w", err)
 		}
 		censusSize = uint64(len(users))
+	case dbmongo.TypeCommunityCensusEngaged:
+		if community.Census.Engaged == nil {
+			return 0, 0, fmt.Errorf("invalid engaged census rule")
+		}
+		users, err := u.db.CommunityEngagedVoters(community.ID, community.Census.Engaged)
+		if err != nil {
+			return 0, 0, fmt.Errorf("error fetching engaged voters: %w", err)
+		}
+		censusSize = uint64(len(users))
+	case dbmongo.TypeCommunityCensusMembers:
+		members, err := u.db.CommunityMembers(community.ID)
+		if err != nil {
+			return 0, 0, fmt.Errorf("error fetching community members: %w", err)
+		}
+		censusSize = uint64(len(members))
 	default:
 		return 0, 0, fmt.Errorf("invalid census type")
 	}
 	return participation, censusSize, nil
 }
 
+// censusTokenHolders method returns the holders of the census token provided using
+// the Airstack API. If the census token includes token IDs, only the holders
+// of those token IDs are returned.
+func (u *Updater) censusTokenHolders(token dbmongo.CommunityCensusAddresses) ([]*ac.TokenHolder, error) {
+	if len(token.TokenIDs) == 0 {
+		return u.airstack.TokenBalances(common.HexToAddress(token.Address), token.Blockchain)
+	}
+	tokenIDs, err := helpers.ExpandTokenIDs(token.TokenIDs, helpers.MaxTokenIDs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token IDs: %w", err)
+	}
+	return u.airstack.TokenIdsBalances(common.HexToAddress(token.Address),
+		helpers.BigIntsToStrings(tokenIDs), token.Blockchain)
+}
+
 // updateUserContants method updates the reputation data of a given user. It
 // fetches the activity data from the database and the boosters data from the
 // Airstack and the Census3 API. It then updates the reputation data in the
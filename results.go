@@ -99,7 +99,8 @@ func (v *vocdoniHandler) results(msg *apirest.APIdata, ctx *httprouter.HTTPConte
 	}
 
 	// if not final results, create the dynamic PNG image with the results
-	response := strings.ReplaceAll(frame(frameResults), "{image}", resultsPNGfile(election, electiondb, totalWeightStr))
+	response := strings.ReplaceAll(frame(frameResults), "{image}",
+		resultsPNGfile(v.applyDelegationOverrides(election), v.applyCastedWeightOverrides(electiondb), totalWeightStr))
 	response = strings.ReplaceAll(response, "{title}", metadata.Title["default"])
 	response = strings.ReplaceAll(response, "{processID}", electionID)
 	ctx.SetResponseContentType("text/html; charset=utf-8")
@@ -116,6 +117,12 @@ func (v *vocdoniHandler) finalizeElectionResults(election *api.Election, electio
 	if !election.FinalResults {
 		return "", fmt.Errorf("election not finalized")
 	}
+	// correct the results and the casted weight with the delegation overrides,
+	// keeping the raw ones tallied by the Vocdoni chain to publish them too
+	_, rawVotes := helpers.ExtractResults(election, 0)
+	rawElectiondb := electiondb
+	election = v.applyDelegationOverrides(election)
+	electiondb = v.applyCastedWeightOverrides(electiondb)
 	totalWeightStr := ""
 	census, err := v.db.CensusFromElection(election.ElectionID)
 	if err == nil {
@@ -127,54 +134,74 @@ func (v *vocdoniHandler) finalizeElectionResults(election *api.Election, electio
 		return "", fmt.Errorf("failed to create image: %w", err)
 	}
 	go func() {
+		// check if the results were already finalized to notify the end of
+		// the poll only once
+		alreadyFinalized := false
+		if results, err := v.db.Results(election.ElectionID); err == nil && results.Finalized {
+			alreadyFinalized = true
+		}
 		choices, votes := helpers.ExtractResults(election, 0)
-		if err := v.db.AddFinalResults(election.ElectionID, imageframe.FromCache(id), choices, helpers.BigIntsToStrings(votes)); err != nil {
+		if err := v.db.AddFinalResults(election.ElectionID, imageframe.FromCache(id), choices,
+			helpers.BigIntsToStrings(votes), helpers.BigIntsToStrings(rawVotes)); err != nil {
 			log.Errorw(err, "failed to add final results to database")
 			return
 		}
-		if electiondb != nil {
-			if err := v.settleResultsIntoCommunityHub(electiondb, choices, votes); err != nil {
-				log.Errorw(err, "failed to settle results into community hub")
+		if electiondb != nil && electiondb.Community != nil {
+			if !alreadyFinalized {
+				data := pollResultsWebhookData(electiondb, choices, votes)
+				data.RawVotes = helpers.BigIntsToStrings(rawVotes)
+				data.RawCastedWeight = rawElectiondb.CastedWeight
+				v.emitCommunityEvent(electiondb.Community.ID, mongo.WebhookEventPollEnded, data)
+			}
+			// enqueue the settlement of the results into the community hub,
+			// it is processed by settleResultsAtBackground
+			if err := v.db.EnqueueResultsSettlement(electiondb.ElectionID, electiondb.Community.ID); err != nil {
+				log.Errorw(err, "failed to enqueue results settlement")
 			}
 		}
 	}()
 	return id, nil
 }
 
-func (v *vocdoniHandler) settleResultsIntoCommunityHub(electiondb *mongo.Election, choices []string, votes []*big.Int) error {
+// communityHubResults returns the results of the community poll provided in
+// the format of the community hub, with the contract and the community where
+// they must be settled.
+func (v *vocdoniHandler) communityHubResults(electiondb *mongo.Election, choices []string, votes []*big.Int) (
+	*communityhub.HubContract, *communityhub.HubCommunity, *communityhub.HubResults, error,
+) {
 	if len(votes) == 0 || len(choices) == 0 {
-		return fmt.Errorf("invalid votes/choices")
+		return nil, nil, nil, fmt.Errorf("invalid votes/choices")
 	}
 
 	if electiondb == nil {
-		return fmt.Errorf("nil electiondb")
+		return nil, nil, nil, fmt.Errorf("nil electiondb")
 	}
 
-	// check if the election is from a community, else return silently
 	if electiondb.Community == nil {
-		return nil
+		return nil, nil, nil, fmt.Errorf("election not from a community")
 	}
-
-	log.Debugw("settling results into community hub", "electionID", electiondb.ElectionID, "communityID", electiondb.Community.ID)
+	// correct the casted weight used for the turnout with the delegation
+	// overrides, the votes are already corrected
+	electiondb = v.applyCastedWeightOverrides(electiondb)
 
 	// load the community hub contract for the community
 	contract, err := v.comhub.CommunityContract(electiondb.Community.ID)
 	if err != nil || contract == nil {
-		return fmt.Errorf("failed to fetch community contract: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to fetch community contract: %w", err)
 	}
 	// load the community from the community hub contract
 	comm, err := contract.Community(electiondb.Community.ID)
 	if err != nil || comm == nil {
-		return fmt.Errorf("failed to fetch community from the community hub: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to fetch community from the community hub: %w", err)
 	}
 	electionID, err := hex.DecodeString(electiondb.ElectionID)
 	if err != nil {
-		return fmt.Errorf("failed to decode electionID: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to decode electionID: %w", err)
 	}
 	// Extract the list of participants from the database
 	voters, err := v.db.VotersOfElection(electionID)
 	if err != nil {
-		return fmt.Errorf("failed to fetch voters from the database: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to fetch voters from the database: %w", err)
 	}
 	participants := []*big.Int{}
 	for _, voter := range voters {
@@ -187,12 +214,12 @@ func (v *vocdoniHandler) settleResultsIntoCommunityHub(electiondb *mongo.Electio
 	// We need the census to calculate the turnout
 	census, err := v.db.CensusFromElection(electionID)
 	if err != nil {
-		return fmt.Errorf("failed to fetch census from the database for election %x: %w", electionID, err)
+		return nil, nil, nil, fmt.Errorf("failed to fetch census from the database for election %x: %w", electionID, err)
 	}
 
 	root, err := hex.DecodeString(census.Root)
 	if err != nil {
-		return fmt.Errorf("failed to decode census root: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to decode census root: %w", err)
 	}
 
 	// Create a new big.Int from the truncated float
@@ -201,6 +228,7 @@ func (v *vocdoniHandler) settleResultsIntoCommunityHub(electiondb *mongo.Electio
 	// Extract the choices from the election results
 	totalVotingPower, _ := new(big.Int).SetString(census.TotalWeight, 10)
 	hubResults := &communityhub.HubResults{
+		ElectionID:       electionID,
 		Question:         electiondb.Question,
 		Options:          choices,
 		Date:             time.Now().String(),
@@ -212,14 +240,20 @@ func (v *vocdoniHandler) settleResultsIntoCommunityHub(electiondb *mongo.Electio
 		CensusURI:        census.URL,
 		VoteCount:        new(big.Int).SetUint64(electiondb.CastedVotes),
 	}
-	log.Infow("sending results transaction to community hub smart contract",
-		"electionID", electiondb.ElectionID,
-		"communityID", electiondb.Community.ID,
-		"hubResults", hubResults)
-	if err := contract.SetResults(comm, hubResults); err != nil {
-		return fmt.Errorf("failed to set results on the community hub: %w", err)
+	return contract, comm, hubResults, nil
+}
+
+// pollResultsWebhookData returns the data of the webhook events about the
+// results of the election provided.
+func pollResultsWebhookData(electiondb *mongo.Election, choices []string, votes []*big.Int) *webhookPollResults {
+	return &webhookPollResults{
+		ElectionID:   electiondb.ElectionID,
+		Question:     electiondb.Question,
+		Choices:      choices,
+		Votes:        helpers.BigIntsToStrings(votes),
+		VoteCount:    electiondb.CastedVotes,
+		CastedWeight: electiondb.CastedWeight,
 	}
-	return nil
 }
 
 func resultsPNGfile(election *api.Election, electiondb *mongo.Election, totalWeightStr string) string {
@@ -251,11 +285,13 @@ func (v *vocdoniHandler) updateAndFetchResultsFromDatabase(
 	// Update LRU cached election
 	_ = v.electionLRU.Add(fmt.Sprintf("%x", electionID), election)
 
-	// Update the results on the database
-	choices, votes := helpers.ExtractResults(election, 0)
+	// Update the results on the database, corrected with the delegation
+	// overrides, and the raw ones tallied by the Vocdoni chain
+	choices, votes := helpers.ExtractResults(v.applyDelegationOverrides(election), 0)
+	_, rawVotes := helpers.ExtractResults(election, 0)
 	votesString := helpers.BigIntsToStrings(votes)
 	log.Infow("updating partial results", "electionID", electionID.String(), "choices", choices, "votes", votesString)
-	if err := v.db.SetPartialResults(electionID, choices, votesString); err != nil {
+	if err := v.db.SetPartialResults(electionID, choices, votesString, helpers.BigIntsToStrings(rawVotes)); err != nil {
 		return nil, fmt.Errorf("failed to update results: %w", err)
 	}
 
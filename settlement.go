@@ -0,0 +1,504 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/vote-frame/communityhub"
+	"github.com/vocdoni/vote-frame/mongo"
+	"go.vocdoni.io/dvote/httprouter"
+	"go.vocdoni.io/dvote/httprouter/apirest"
+	"go.vocdoni.io/dvote/log"
+)
+
+const (
+	// settlementSweepInterval is the time between checks for results
+	// settlements to send or whose transactions are not mined yet.
+	settlementSweepInterval = 30 * time.Second
+	// settlementBatchSize is the maximum number of results settlements
+	// processed on every check.
+	settlementBatchSize = 50
+	// settlementLease is the time that an instance owns a results settlement
+	// to send its transaction. If the instance stops meanwhile, other
+	// instance can claim the settlement once the lease expires.
+	settlementLease = 2 * time.Minute
+	// settlementMaxAttempts is the number of failed attempts of a results
+	// settlement before it is marked as failed.
+	settlementMaxAttempts = 5
+	// settlementRetryBaseDelay is the time to wait before retrying a results
+	// settlement after its first failed attempt, it is doubled after every
+	// attempt.
+	settlementRetryBaseDelay = time.Minute
+	// settlementStuckTimeout is the time to wait for the transaction of a
+	// results settlement to be mined before replacing it with higher fees.
+	settlementStuckTimeout = 5 * time.Minute
+	// settlementMaxBumps is the number of times that the transaction of a
+	// results settlement is replaced with higher fees before it is marked as
+	// failed.
+	settlementMaxBumps = 5
+	// settlementReconcileInterval is the time between reconciliations of the
+	// results settled in the community hub with the database.
+	settlementReconcileInterval = time.Hour
+	// settlementReconcileAge is the minimum time between reconciliations of
+	// the same results settlement.
+	settlementReconcileAge = 24 * time.Hour
+	// settlementMaxResettles is the number of times that the results of a
+	// settlement are settled again because the ones settled do not match the
+	// database, before it is marked as failed, so a persistent difference does
+	// not spend gas forever.
+	settlementMaxResettles = 3
+	// defaultSettlementsLimit is the number of results settlements returned
+	// if no limit is provided.
+	defaultSettlementsLimit = 50
+	// maxSettlementsLimit is the maximum number of results settlements
+	// returned at once.
+	maxSettlementsLimit = 500
+)
+
+// settleResultsAtBackground processes the queue of results settlements. It
+// sends the transactions of the pending settlements and checks the ones that
+// are not mined yet, replacing them with higher fees if they get stuck. Every
+// settlement is claimed before sending or checking its transactions, so they
+// are not sent or replaced by several instances. It must run in the
+// background.
+func settleResultsAtBackground(ctx context.Context, v *vocdoniHandler) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(settlementSweepInterval):
+			for i := 0; i < settlementBatchSize && ctx.Err() == nil; i++ {
+				settlement, err := v.db.ClaimNextResultsSettlement(v.instanceID, settlementLease)
+				if err != nil {
+					if mongo.IsDBClosed(err) {
+						return
+					}
+					if !errors.Is(err, mongo.ErrSettlementUnknown) {
+						log.Warnw("failed to claim results settlement", "error", err)
+					}
+					break
+				}
+				v.submitResultsSettlement(settlement)
+			}
+			for i := 0; i < settlementBatchSize && ctx.Err() == nil; i++ {
+				settlement, err := v.db.ClaimNextSubmittedSettlement(v.instanceID, settlementLease)
+				if err != nil {
+					if mongo.IsDBClosed(err) {
+						return
+					}
+					if !errors.Is(err, mongo.ErrSettlementUnknown) {
+						log.Warnw("failed to claim submitted results settlement", "error", err)
+					}
+					break
+				}
+				v.checkResultsSettlement(settlement)
+			}
+		}
+	}
+}
+
+// reconcileSettlementsAtBackground compares the results stored in the
+// community hub contracts with the ones stored in the database. The mined
+// settlements whose results do not match are sent again up to
+// settlementMaxResettles times, and the community polls with final results
+// without settlement are enqueued. It must run in the background.
+func reconcileSettlementsAtBackground(ctx context.Context, v *vocdoniHandler) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(settlementReconcileInterval):
+			elections, err := v.db.CommunityElectionsWithoutSettlement(settlementBatchSize)
+			if err != nil {
+				if mongo.IsDBClosed(err) {
+					return
+				}
+				log.Warnw("failed to get community elections without settlement", "error", err)
+				continue
+			}
+			for _, election := range elections {
+				if err := v.db.EnqueueResultsSettlement(election.ElectionID, election.Community.ID); err != nil {
+					log.Warnw("failed to enqueue results settlement", "electionID", election.ElectionID, "error", err)
+				}
+			}
+			settlements, err := v.db.ResultsSettlementsToReconcile(time.Now().Add(-settlementReconcileAge), settlementBatchSize)
+			if err != nil {
+				log.Warnw("failed to get results settlements to reconcile", "error", err)
+				continue
+			}
+			for _, settlement := range settlements {
+				matches, err := v.reconcileResultsSettlement(settlement)
+				if err != nil {
+					log.Warnw("failed to reconcile results settlement", "electionID", settlement.ElectionID, "error", err)
+					continue
+				}
+				if !matches {
+					resettleResultsSettlement(settlement, time.Now())
+					log.Warnw("settled results do not match the database",
+						"electionID", settlement.ElectionID, "communityID", settlement.CommunityID,
+						"status", settlement.Status, "resettled", settlement.Resettled)
+				}
+				// update the settlement even if it matches to set it as
+				// reconciled now
+				if err := v.db.UpdateResultsSettlement(settlement); err != nil {
+					log.Warnw("failed to update results settlement", "electionID", settlement.ElectionID, "error", err)
+				}
+			}
+		}
+	}
+}
+
+// resettleResultsSettlement schedules the results settlement provided to be
+// sent again from the time provided, because the results settled do not match
+// the database. Once it has been sent again settlementMaxResettles times, it
+// is marked as failed instead, so an admin must trigger it again.
+func resettleResultsSettlement(settlement *mongo.ResultsSettlement, now time.Time) {
+	if settlement.Resettled >= settlementMaxResettles {
+		settlement.Status = mongo.SettlementFailed
+		settlement.LastError = fmt.Sprintf("settled results do not match the database after %d resettlements",
+			settlement.Resettled)
+		return
+	}
+	settlement.Resettled++
+	settlement.Status = mongo.SettlementPending
+	settlement.Attempts = 0
+	settlement.Bumps = 0
+	settlement.LastError = "settled results do not match the database"
+	settlement.NextAttempt = now
+}
+
+// settlementResults returns the final results of the poll of the settlement
+// provided in the format of the community hub, with the contract and the
+// community where they must be settled.
+func (v *vocdoniHandler) settlementResults(settlement *mongo.ResultsSettlement) (
+	*communityhub.HubContract, *communityhub.HubCommunity, *communityhub.HubResults, error,
+) {
+	electionID, err := hex.DecodeString(settlement.ElectionID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decode electionID: %w", err)
+	}
+	electiondb, err := v.db.Election(electionID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get election: %w", err)
+	}
+	results, err := v.db.Results(electionID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get results: %w", err)
+	}
+	if !results.Finalized {
+		return nil, nil, nil, fmt.Errorf("results not finalized")
+	}
+	votes := make([]*big.Int, 0, len(results.Votes))
+	for _, strVotes := range results.Votes {
+		optionVotes, ok := new(big.Int).SetString(strVotes, 10)
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("invalid votes %s", strVotes)
+		}
+		votes = append(votes, optionVotes)
+	}
+	return v.communityHubResults(electiondb, results.Choices, votes)
+}
+
+// submitResultsSettlement sends the transaction of the results settlement
+// provided, already claimed by the current instance, to the community hub. If
+// it fails, the next attempt is scheduled with an exponential backoff until
+// the maximum number of attempts is reached.
+func (v *vocdoniHandler) submitResultsSettlement(settlement *mongo.ResultsSettlement) {
+	err := func() error {
+		contract, community, results, err := v.settlementResults(settlement)
+		if err != nil {
+			return err
+		}
+		tx, err := contract.SetResults(community, results, nil)
+		if err != nil {
+			return err
+		}
+		settlement.Status = mongo.SettlementSubmitted
+		settlement.TxHashes = []string{}
+		settlement.Bumps = 0
+		setSettlementTx(settlement, tx)
+		return nil
+	}()
+	if err != nil {
+		retryResultsSettlement(settlement, err.Error(), time.Now())
+		log.Warnw("failed to submit results settlement", "electionID", settlement.ElectionID,
+			"attempts", settlement.Attempts, "error", err)
+	} else {
+		log.Infow("results settlement submitted", "electionID", settlement.ElectionID,
+			"communityID", settlement.CommunityID, "tx", settlement.TxHash)
+	}
+	if err := v.db.UpdateResultsSettlement(settlement); err != nil {
+		log.Warnw("failed to update results settlement", "electionID", settlement.ElectionID, "error", err)
+	}
+}
+
+// checkResultsSettlement checks if any transaction sent for the results
+// settlement provided, already claimed by the current instance, is mined,
+// since all of them share the same nonce only one can be. If it succeeded, the settlement is mined, and if it reverted,
+// the settlement is sent again. If none is mined after settlementStuckTimeout,
+// the transaction is replaced by a new one with higher fees.
+func (v *vocdoniHandler) checkResultsSettlement(settlement *mongo.ResultsSettlement) {
+	contract, err := v.comhub.CommunityContract(settlement.CommunityID)
+	if err != nil {
+		log.Warnw("failed to get community contract", "communityID", settlement.CommunityID, "error", err)
+		return
+	}
+	for _, hash := range settlement.TxHashes {
+		receipt, err := contract.Receipt(common.HexToHash(hash))
+		if err != nil {
+			log.Warnw("failed to get results settlement transaction receipt", "tx", hash, "error", err)
+			return
+		}
+		if receipt == nil {
+			continue
+		}
+		// the gas of the transaction is spent even if it reverted
+		v.storeRelayedTx(0, settlement.CommunityID, mongo.RelayActionSetResults, nil, receipt)
+		applySettlementReceipt(settlement, hash, receipt, time.Now())
+		if receipt.Success {
+			log.Infow("results settlement mined", "electionID", settlement.ElectionID, "tx", hash, "block", receipt.Block)
+		}
+		if err := v.db.UpdateResultsSettlement(settlement); err != nil {
+			log.Warnw("failed to update results settlement", "electionID", settlement.ElectionID, "error", err)
+			return
+		}
+		if receipt.Success {
+			v.notifyResultsSettled(settlement)
+		}
+		return
+	}
+	// none of the transactions is mined yet, replace the current one with
+	// higher fees if it is stuck
+	if time.Since(settlement.SubmittedAt) < settlementStuckTimeout {
+		return
+	}
+	if settlement.Bumps >= settlementMaxBumps {
+		settlement.Status = mongo.SettlementFailed
+		settlement.LastError = fmt.Sprintf("transaction not mined after %d fee bumps", settlement.Bumps)
+	} else if err := v.bumpResultsSettlement(settlement); err != nil {
+		// the nonce can be already used if one of the transactions has been
+		// mined meanwhile, so it is checked again in the next iteration
+		settlement.LastError = err.Error()
+		log.Warnw("failed to replace results settlement transaction", "electionID", settlement.ElectionID, "error", err)
+	}
+	if err := v.db.UpdateResultsSettlement(settlement); err != nil {
+		log.Warnw("failed to update results settlement", "electionID", settlement.ElectionID, "error", err)
+	}
+}
+
+// applySettlementReceipt updates the results settlement provided with the
+// receipt of its transaction with the hash provided. If it succeeded, the
+// settlement is mined, and if it reverted, the settlement is scheduled to be
+// sent again from the time provided.
+func applySettlementReceipt(settlement *mongo.ResultsSettlement, hash string,
+	receipt *communityhub.HubReceipt, now time.Time,
+) {
+	settlement.TxHash = hash
+	if receipt.Success {
+		settlement.Status = mongo.SettlementMined
+		settlement.MinedBlock = receipt.Block
+		settlement.LastError = ""
+		return
+	}
+	retryResultsSettlement(settlement, fmt.Sprintf("transaction %s reverted", hash), now)
+}
+
+// retryResultsSettlement registers a failed attempt of the results settlement
+// provided with the error provided. The settlement is sent again with an
+// exponential backoff from the time provided, until the maximum number of
+// attempts is reached and it is marked as failed.
+func retryResultsSettlement(settlement *mongo.ResultsSettlement, lastError string, now time.Time) {
+	settlement.Attempts++
+	settlement.LastError = lastError
+	if settlement.Attempts >= settlementMaxAttempts {
+		settlement.Status = mongo.SettlementFailed
+		return
+	}
+	settlement.Status = mongo.SettlementPending
+	settlement.NextAttempt = now.Add(settlementRetryBaseDelay << (settlement.Attempts - 1))
+}
+
+// bumpResultsSettlement replaces the current transaction of the results
+// settlement provided with a new one with the same nonce and higher fees.
+func (v *vocdoniHandler) bumpResultsSettlement(settlement *mongo.ResultsSettlement) error {
+	contract, community, results, err := v.settlementResults(settlement)
+	if err != nil {
+		return err
+	}
+	replace := &communityhub.HubTx{
+		Hash:  common.HexToHash(settlement.TxHash),
+		Nonce: settlement.Nonce,
+	}
+	replace.GasTipCap, _ = new(big.Int).SetString(settlement.GasTipCap, 10)
+	replace.GasFeeCap, _ = new(big.Int).SetString(settlement.GasFeeCap, 10)
+	tx, err := contract.SetResults(community, results, replace)
+	if err != nil {
+		return err
+	}
+	settlement.Bumps++
+	setSettlementTx(settlement, tx)
+	log.Infow("results settlement transaction replaced", "electionID", settlement.ElectionID,
+		"tx", settlement.TxHash, "bumps", settlement.Bumps)
+	return nil
+}
+
+// setSettlementTx sets the transaction provided as the current one of the
+// results settlement provided.
+func setSettlementTx(settlement *mongo.ResultsSettlement, tx *communityhub.HubTx) {
+	settlement.TxHash = tx.Hash.Hex()
+	settlement.TxHashes = append(settlement.TxHashes, settlement.TxHash)
+	settlement.Nonce = tx.Nonce
+	if tx.GasTipCap != nil {
+		settlement.GasTipCap = tx.GasTipCap.String()
+	}
+	if tx.GasFeeCap != nil {
+		settlement.GasFeeCap = tx.GasFeeCap.String()
+	}
+	settlement.SubmittedAt = time.Now()
+	settlement.LastError = ""
+}
+
+// notifyResultsSettled notifies the webhooks of the community of the results
+// settlement provided that its results are settled on-chain.
+func (v *vocdoniHandler) notifyResultsSettled(settlement *mongo.ResultsSettlement) {
+	electionID, err := hex.DecodeString(settlement.ElectionID)
+	if err != nil {
+		return
+	}
+	electiondb, err := v.db.Election(electionID)
+	if err != nil {
+		log.Warnw("failed to get election", "electionID", settlement.ElectionID, "error", err)
+		return
+	}
+	results, err := v.db.Results(electionID)
+	if err != nil {
+		log.Warnw("failed to get results", "electionID", settlement.ElectionID, "error", err)
+		return
+	}
+	data := pollResultsWebhookData(v.applyCastedWeightOverrides(electiondb), results.Choices, nil)
+	data.Votes = results.Votes
+	data.RawVotes = results.RawVotes
+	data.RawCastedWeight = electiondb.CastedWeight
+	v.emitCommunityEvent(settlement.CommunityID, mongo.WebhookEventResultsSettled, data)
+}
+
+// reconcileResultsSettlement compares the results of the settlement provided
+// stored in the community hub contract with the ones stored in the database.
+// It returns false if they do not match.
+func (v *vocdoniHandler) reconcileResultsSettlement(settlement *mongo.ResultsSettlement) (bool, error) {
+	contract, community, expected, err := v.settlementResults(settlement)
+	if err != nil {
+		return false, err
+	}
+	return settledResultsMatch(contract, community, expected)
+}
+
+// settledResultsMatch compares the results of the community provided stored in
+// the community hub contract provided with the expected ones. It returns false
+// if they do not match.
+func settledResultsMatch(contract *communityhub.HubContract, community *communityhub.HubCommunity,
+	expected *communityhub.HubResults,
+) (bool, error) {
+	onchain, err := contract.Results(community.ContractID, expected.ElectionID)
+	if err != nil {
+		return false, err
+	}
+	if onchain.Question != expected.Question || len(onchain.Options) != len(expected.Options) ||
+		len(onchain.Tally) != len(expected.Tally) || !bytes.Equal(onchain.CensusRoot, expected.CensusRoot) {
+		return false, nil
+	}
+	for i, option := range expected.Options {
+		if onchain.Options[i] != option {
+			return false, nil
+		}
+	}
+	for i, question := range expected.Tally {
+		if len(onchain.Tally[i]) != len(question) {
+			return false, nil
+		}
+		for j, votes := range question {
+			if onchain.Tally[i][j] == nil || onchain.Tally[i][j].Cmp(votes) != 0 {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// resultsSettlementsHandler returns the results settlements, from the newest
+// to the oldest. They can be filtered by community and status with the
+// 'communityId' and 'status' query parameters, and limited with the 'limit'
+// one.
+func (v *vocdoniHandler) resultsSettlementsHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	query := ctx.Request.URL.Query()
+	status := query.Get("status")
+	switch status {
+	case "", mongo.SettlementPending, mongo.SettlementSubmitting, mongo.SettlementSubmitted,
+		mongo.SettlementMined, mongo.SettlementFailed:
+	default:
+		return ctx.Send([]byte("invalid settlement status"), http.StatusBadRequest)
+	}
+	limit := int64(defaultSettlementsLimit)
+	if strLimit := query.Get("limit"); strLimit != "" {
+		var err error
+		if limit, err = strconv.ParseInt(strLimit, 10, 64); err != nil || limit <= 0 {
+			return ctx.Send([]byte("invalid limit"), http.StatusBadRequest)
+		}
+		limit = min(limit, maxSettlementsLimit)
+	}
+	settlements, err := v.db.ResultsSettlements(query.Get("communityId"), status, limit)
+	if err != nil {
+		return ctx.Send([]byte("error getting results settlements"), http.StatusInternalServerError)
+	}
+	if len(settlements) == 0 {
+		return ctx.Send(nil, http.StatusNoContent)
+	}
+	res, err := json.Marshal(ResultsSettlements{Settlements: settlements})
+	if err != nil {
+		return ctx.Send([]byte("error encoding results settlements"), http.StatusInternalServerError)
+	}
+	return ctx.Send(res, http.StatusOK)
+}
+
+// retryResultsSettlementHandler triggers again the results settlement of the
+// poll provided. If the poll has no settlement yet, it is enqueued if it is
+// a community poll with final results.
+func (v *vocdoniHandler) retryResultsSettlementHandler(msg *apirest.APIdata, ctx *httprouter.HTTPContext) error {
+	electionID := ctx.URLParam("electionID")
+	electionIDbytes, err := hex.DecodeString(electionID)
+	if err != nil {
+		return ctx.Send([]byte("invalid electionID"), http.StatusBadRequest)
+	}
+	err = v.db.RetryResultsSettlement(electionID)
+	switch {
+	case err == nil:
+	case errors.Is(err, mongo.ErrSettlementInProgress):
+		return ctx.Send([]byte(err.Error()), http.StatusConflict)
+	case errors.Is(err, mongo.ErrSettlementUnknown):
+		electiondb, err := v.db.Election(electionIDbytes)
+		if err != nil {
+			return ctx.Send([]byte("election not found"), http.StatusNotFound)
+		}
+		if electiondb.Community == nil {
+			return ctx.Send([]byte("election not from a community"), http.StatusBadRequest)
+		}
+		if results, err := v.db.Results(electionIDbytes); err != nil || !results.Finalized {
+			return ctx.Send([]byte("election results not finalized"), http.StatusBadRequest)
+		}
+		if err := v.db.EnqueueResultsSettlement(electiondb.ElectionID, electiondb.Community.ID); err != nil {
+			return ctx.Send([]byte("error enqueuing results settlement"), http.StatusInternalServerError)
+		}
+	default:
+		return ctx.Send([]byte("error retrying results settlement"), http.StatusInternalServerError)
+	}
+	return ctx.Send([]byte("Ok"), http.StatusOK)
+}
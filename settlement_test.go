@@ -0,0 +1,158 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/vote-frame/communityhub"
+	"github.com/vocdoni/vote-frame/mongo"
+)
+
+// settlementTestResults returns the results of a poll to settle in the tests.
+func settlementTestResults() *communityhub.HubResults {
+	return &communityhub.HubResults{
+		ElectionID:       common.Hex2Bytes("c5d2460186f7000000000000000000000000000000000000000000000000001a"),
+		Question:         "question",
+		Options:          []string{"yes", "no"},
+		Date:             "2024-05-01 00:00:00",
+		Tally:            [][]*big.Int{{big.NewInt(10), big.NewInt(5)}},
+		Turnout:          big.NewInt(15),
+		TotalVotingPower: big.NewInt(20),
+		Participants:     []*big.Int{big.NewInt(1), big.NewInt(2)},
+		CensusRoot:       common.Hex2Bytes("0000000000000000000000000000000000000000000000000000000000000abc"),
+		CensusURI:        "ipfs://census",
+	}
+}
+
+func Test_settledResultsMatch(t *testing.T) {
+	contract := communityhub.NewContract(1, "eth", common.Address{}, communityhub.NewMemoryBackend(1))
+	community := &communityhub.HubCommunity{ContractID: 1}
+	if _, err := contract.SetResults(community, settlementTestResults(), nil); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		modify   func(*communityhub.HubResults)
+		expected bool
+	}{
+		{
+			name:     "same results",
+			modify:   func(*communityhub.HubResults) {},
+			expected: true,
+		},
+		{
+			name:   "different question",
+			modify: func(r *communityhub.HubResults) { r.Question = "other question" },
+		},
+		{
+			name:   "different options",
+			modify: func(r *communityhub.HubResults) { r.Options = []string{"no", "yes"} },
+		},
+		{
+			name:   "different tally",
+			modify: func(r *communityhub.HubResults) { r.Tally[0][1] = big.NewInt(6) },
+		},
+		{
+			name: "more questions",
+			modify: func(r *communityhub.HubResults) {
+				r.Tally = append(r.Tally, []*big.Int{big.NewInt(1), big.NewInt(2)})
+			},
+		},
+		{
+			name:   "different census root",
+			modify: func(r *communityhub.HubResults) { r.CensusRoot = common.Hex2Bytes("0def") },
+		},
+		{
+			name: "results not settled",
+			modify: func(r *communityhub.HubResults) {
+				r.ElectionID = common.Hex2Bytes("c5d2460186f7000000000000000000000000000000000000000000000000001b")
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			expected := settlementTestResults()
+			tc.modify(expected)
+			matches, err := settledResultsMatch(contract, community, expected)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if matches != tc.expected {
+				t.Errorf("unexpected match: expected %t, got %t", tc.expected, matches)
+			}
+		})
+	}
+}
+
+func Test_applySettlementReceipt(t *testing.T) {
+	contract := communityhub.NewContract(1, "eth", common.Address{}, communityhub.NewMemoryBackend(1))
+	community := &communityhub.HubCommunity{ContractID: 1}
+	now := time.Now()
+	settlement := &mongo.ResultsSettlement{Status: mongo.SettlementSubmitted}
+
+	// every reverted transaction schedules a new attempt with an exponential
+	// backoff until the maximum number of attempts is reached
+	for attempt := 1; attempt <= settlementMaxAttempts; attempt++ {
+		hash := common.BigToHash(big.NewInt(int64(attempt))).Hex()
+		applySettlementReceipt(settlement, hash, &communityhub.HubReceipt{Success: false}, now)
+		if settlement.Attempts != attempt || settlement.TxHash != hash || settlement.LastError == "" {
+			t.Fatalf("unexpected settlement after reverted attempt %d: %+v", attempt, settlement)
+		}
+		if attempt == settlementMaxAttempts {
+			break
+		}
+		if settlement.Status != mongo.SettlementPending {
+			t.Fatalf("unexpected status after reverted attempt %d: %s", attempt, settlement.Status)
+		}
+		expectedDelay := settlementRetryBaseDelay << (attempt - 1)
+		if got := settlement.NextAttempt.Sub(now); got != expectedDelay {
+			t.Errorf("unexpected retry delay after attempt %d: expected %s, got %s", attempt, expectedDelay, got)
+		}
+		settlement.Status = mongo.SettlementSubmitted
+	}
+	if settlement.Status != mongo.SettlementFailed {
+		t.Fatalf("unexpected status after %d reverted attempts: %s", settlementMaxAttempts, settlement.Status)
+	}
+
+	// a retried settlement whose transaction succeeds is mined
+	settlement = &mongo.ResultsSettlement{Status: mongo.SettlementSubmitted}
+	applySettlementReceipt(settlement, "0x01", &communityhub.HubReceipt{Success: false}, now)
+	if settlement.Status != mongo.SettlementPending {
+		t.Fatalf("unexpected status after reverted attempt: %s", settlement.Status)
+	}
+	tx, err := contract.SetResults(community, settlementTestResults(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := contract.Receipt(tx.Hash)
+	if err != nil || receipt == nil {
+		t.Fatalf("cannot get the receipt of the settlement transaction: %v", err)
+	}
+	applySettlementReceipt(settlement, tx.Hash.Hex(), receipt, now)
+	if settlement.Status != mongo.SettlementMined || settlement.MinedBlock != receipt.Block ||
+		settlement.TxHash != tx.Hash.Hex() || settlement.LastError != "" {
+		t.Errorf("unexpected settlement after mined attempt: %+v", settlement)
+	}
+}
+
+func Test_resettleResultsSettlement(t *testing.T) {
+	now := time.Now()
+	settlement := &mongo.ResultsSettlement{Status: mongo.SettlementMined, Attempts: 2, Bumps: 1}
+	// the results are settled again until the maximum number of resettlements
+	// is reached, then the settlement fails until an admin triggers it again
+	for i := 1; i <= settlementMaxResettles; i++ {
+		resettleResultsSettlement(settlement, now)
+		if settlement.Status != mongo.SettlementPending || settlement.Resettled != i ||
+			settlement.Attempts != 0 || settlement.Bumps != 0 || !settlement.NextAttempt.Equal(now) {
+			t.Fatalf("unexpected settlement after resettlement %d: %+v", i, settlement)
+		}
+		settlement.Status = mongo.SettlementMined
+	}
+	resettleResultsSettlement(settlement, now)
+	if settlement.Status != mongo.SettlementFailed || settlement.Resettled != settlementMaxResettles ||
+		settlement.LastError == "" {
+		t.Errorf("unexpected settlement after %d resettlements: %+v", settlementMaxResettles, settlement)
+	}
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/vocdoni/vote-frame/mongo"
+	"go.vocdoni.io/dvote/types"
 )
 
 const (
@@ -49,6 +51,10 @@ type ElectionDescription struct {
 }
 
 // ElectionInfo defines the full details for an election, used by the API.
+// The tally is corrected by the delegation overrides and it is the one
+// published and settled in the community hub, while the raw tally is the one
+// of the Vocdoni chain, which counts twice the weight delegated when both the
+// delegator and the delegate vote.
 type ElectionInfo struct {
 	CreatedTime             time.Time                `json:"createdTime"`
 	ElectionID              string                   `json:"electionId"`
@@ -66,6 +72,7 @@ type ElectionInfo struct {
 	Participants            []uint64                 `json:"participants,omitempty"`
 	Choices                 []string                 `json:"options,omitempty"`
 	Votes                   []string                 `json:"tally,omitempty"`
+	RawVotes                []string                 `json:"rawTally,omitempty"`
 	Finalized               bool                     `json:"finalized"`
 	Community               *mongo.ElectionCommunity `json:"community,omitempty"`
 }
@@ -89,10 +96,15 @@ type RankedElections struct {
 	Pagination *Pagination       `json:"pagination,omitempty"`
 }
 
-// CensusToken defines the parameters for a census token
+// CensusToken defines the parameters for a census token. The standard and the
+// token IDs are optional, if token IDs are provided, only the holders of those
+// token IDs are included in the census. Every token ID can be a single ID or
+// a range of IDs ("100-200").
 type CensusToken struct {
-	Address    string `json:"address"`
-	Blockchain string `json:"blockchain"`
+	Address    string   `json:"address"`
+	Blockchain string   `json:"blockchain"`
+	Standard   string   `json:"standard,omitempty"`
+	TokenIDs   []string `json:"tokenIds,omitempty"`
 }
 
 // CensusTokensRequest wraps a token census creation request
@@ -100,6 +112,48 @@ type CensusTokensRequest struct {
 	Tokens []*CensusToken `json:"tokens"`
 }
 
+// CensusParticipant defines a participant of a census
+type CensusParticipant struct {
+	Username string `json:"username"`
+	FID      uint64 `json:"fid"`
+	Weight   string `json:"weight"`
+}
+
+// CensusParticipantProof defines the inclusion proof of a census participant
+// key in the census tree
+type CensusParticipantProof struct {
+	Signer    string         `json:"signer"`
+	Key       types.HexBytes `json:"key"`
+	Proof     types.HexBytes `json:"proof"`
+	Siblings  []string       `json:"siblings,omitempty"`
+	LeafValue types.HexBytes `json:"leafValue"`
+	Weight    string         `json:"weight"`
+}
+
+// CensusParticipantInfo defines the weight, the delegations and the inclusion
+// proofs of a census participant
+type CensusParticipantInfo struct {
+	CensusParticipant
+	Root        string                    `json:"root"`
+	Delegations []mongo.CensusDelegation  `json:"delegations"`
+	Proofs      []*CensusParticipantProof `json:"proofs"`
+}
+
+// CensusProviderRequest wraps a census creation request for a census provider
+// with an optional weighting to apply to the participants
+type CensusProviderRequest struct {
+	CommunityID string                 `json:"communityID,omitempty"`
+	Params      json.RawMessage        `json:"params"`
+	Weighting   *mongo.CensusWeighting `json:"weighting,omitempty"`
+}
+
+// CensusProviderInfo defines the attributes of a census provider
+type CensusProviderInfo struct {
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	Params      []CensusProviderParam `json:"params"`
+}
+
 // Channel defines the attributes of a channel
 type Channel struct {
 	ID          string `json:"id"`
@@ -126,26 +180,34 @@ type ChannelList struct {
 // CensusAddress defines the parameters of a address for a community census, is
 // also used to check if a source of a future census is valid
 type CensusAddress struct {
-	Address    string `json:"address"`
-	Blockchain string `json:"blockchain"`
+	Address    string   `json:"address"`
+	Blockchain string   `json:"blockchain"`
+	Standard   string   `json:"standard,omitempty"`
+	TokenIDs   []string `json:"tokenIds,omitempty"`
 }
 
 // Community defines the attributes of a community, including the admins
 // (FarcasterProfile), the census addresses (CensusAddress) and the channels
-// (Channel)
+// (Channel). The censuses based on several channels include all of them in
+// CensusChannels, and the first one in CensusChannel.
 type Community struct {
-	ID              string           `json:"id"`
-	Name            string           `json:"name"`
-	LogoURL         string           `json:"logoURL"`
-	GroupChatURL    string           `json:"groupChat"`
-	Admins          []*User          `json:"admins,omitempty"`
-	Notifications   bool             `json:"notifications"`
-	CensusType      string           `json:"censusType,omitempty"`
-	CensusAddresses []*CensusAddress `json:"censusAddresses,omitempty"`
-	CensusChannel   *Channel         `json:"censusChannel,omitempty"`
-	UserRef         *User            `json:"userRef,omitempty"`
-	Channels        []string         `json:"channels,omitempty"`
-	Disabled        bool             `json:"disabled"`
+	ID              string                      `json:"id"`
+	Name            string                      `json:"name"`
+	LogoURL         string                      `json:"logoURL"`
+	GroupChatURL    string                      `json:"groupChat"`
+	Admins          []*User                     `json:"admins,omitempty"`
+	Notifications   bool                        `json:"notifications"`
+	CensusType      string                      `json:"censusType,omitempty"`
+	CensusAddresses []*CensusAddress            `json:"censusAddresses,omitempty"`
+	CensusChannel   *Channel                    `json:"censusChannel,omitempty"`
+	CensusChannels  []*Channel                  `json:"censusChannels,omitempty"`
+	CensusEngaged   *mongo.CommunityEngagedRule `json:"censusEngaged,omitempty"`
+	CensusMembers   []uint64                    `json:"censusMembers,omitempty"`
+	UserRef         *User                       `json:"userRef,omitempty"`
+	Channels        []string                    `json:"channels,omitempty"`
+	Disabled        bool                        `json:"disabled"`
+	CensusCacheTTL  uint64                      `json:"censusCacheTTL"`
+	CensusWeighting *mongo.CensusWeighting      `json:"censusWeighting,omitempty"`
 }
 
 // CommunityList defines the list of communities
@@ -245,3 +307,103 @@ type ComposerActionMetadata struct {
 		Type string `json:"type"`
 	} `json:"action"`
 }
+
+// ElectionDelegations contains the snapshot of the resolved delegations used
+// to build the census of an election and the weight that every delegate
+// received from each of its delegators in that census.
+type ElectionDelegations struct {
+	ElectionID  string                    `json:"electionId"`
+	CensusID    string                    `json:"censusId"`
+	CommunityID string                    `json:"communityId"`
+	Snapshot    *mongo.DelegationSnapshot `json:"snapshot"`
+	Weights     []mongo.CensusDelegation  `json:"weights,omitempty"`
+	Attributed  bool                      `json:"attributed"`
+}
+
+// DelegationGraph is the resolved graph of the vote delegations of a
+// community. It includes the final delegations, once the chains of
+// delegations are solved, and the effective voting power of every delegate.
+type DelegationGraph struct {
+	Delegations []mongo.Delegation `json:"delegations"`
+	Delegates   []*DelegatePower   `json:"delegates"`
+}
+
+// DelegatePower defines the effective voting power of a delegate in a
+// community, in basis points of the vote of a single member, so a member that
+// has not delegated and has received two full delegations has a voting power
+// of 30000.
+type DelegatePower struct {
+	FID         uint64 `json:"fid"`
+	Username    string `json:"username,omitempty"`
+	Delegators  int    `json:"delegators"`
+	VotingPower uint64 `json:"votingPower"`
+}
+
+// CommunityMembersRequest defines the users to add to the members list of a
+// community, by FID or by username.
+type CommunityMembersRequest struct {
+	FIDs      []uint64 `json:"fids,omitempty"`
+	Usernames []string `json:"usernames,omitempty"`
+}
+
+// CommunityMembers defines the members list of a community. When users are
+// added to it, it also includes the usernames provided that were not found.
+type CommunityMembers struct {
+	Members  []uint64 `json:"members"`
+	NotFound []string `json:"notFound,omitempty"`
+}
+
+// CommunityWebhookRequest defines the URL and the events of a new webhook of
+// a community.
+type CommunityWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// CommunityWebhook defines a webhook just created, including the secret used
+// to sign its deliveries, which is not returned anymore.
+type CommunityWebhook struct {
+	*mongo.Webhook
+	Secret string `json:"secret"`
+}
+
+// CommunityWebhooks defines the list of webhooks of a community.
+type CommunityWebhooks struct {
+	Webhooks []*mongo.Webhook `json:"webhooks"`
+}
+
+// CommunityWebhookDeliveries defines the delivery logs of the webhooks of a
+// community.
+type CommunityWebhookDeliveries struct {
+	Deliveries []*mongo.WebhookDelivery `json:"deliveries"`
+}
+
+// ResultsSettlements defines the settlements of the results of the community
+// polls into the community hub.
+type ResultsSettlements struct {
+	Settlements []*mongo.ResultsSettlement `json:"settlements"`
+}
+
+// CreateCommunityRequest defines the data required to create a community in
+// the community hub contract of the chain with the alias provided.
+type CreateCommunityRequest struct {
+	ChainAlias string `json:"chainAlias"`
+	Community
+}
+
+// CommunityCreated defines the community created in the community hub and the
+// transaction relayed to create it.
+type CommunityCreated struct {
+	ID     string `json:"id"`
+	TxHash string `json:"txHash"`
+	Block  uint64 `json:"block"`
+}
+
+// CommunityRelay defines the funds of a community in the community hub, the
+// cost in wei of the transactions relayed for it that are paid with them (the
+// updates of its settings) and the latest transactions relayed for it.
+type CommunityRelay struct {
+	Funds        string             `json:"funds"`
+	Spent        string             `json:"spent"`
+	Transactions []*mongo.RelayedTx `json:"transactions"`
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/vocdoni/vote-frame/features"
 	"github.com/vocdoni/vote-frame/mongo"
 	"github.com/vocdoni/vote-frame/reputation"
 	"go.vocdoni.io/dvote/httprouter"
@@ -64,6 +66,7 @@ func (v *vocdoniHandler) profileHandler(msg *apirest.APIdata, ctx *httprouter.HT
 		"mutedUsers":         mutedUsers,
 		"delegations":        delegations,
 		"warpcastApiEnabled": accessprofile.WarpcastAPIKey != "",
+		"maxCensusSize":      features.MaxCensusSize(accessprofile.Reputation),
 	})
 	if err != nil {
 		return fmt.Errorf("could not marshal response: %v", err)
@@ -167,6 +170,40 @@ func (v *vocdoniHandler) delegateVoteHandler(msg *apirest.APIdata, ctx *httprout
 	if req.From == req.To {
 		return ctx.Send([]byte("cannot delegate to yourself"), apirest.HTTPstatusBadRequest)
 	}
+	// check the validity period of the delegation if it is limited
+	if req.ValidUntil != nil {
+		if !req.ValidUntil.After(time.Now()) {
+			return ctx.Send([]byte("delegation already expired"), apirest.HTTPstatusBadRequest)
+		}
+		if req.ValidFrom != nil && !req.ValidUntil.After(*req.ValidFrom) {
+			return ctx.Send([]byte("delegation must expire after it starts"), apirest.HTTPstatusBadRequest)
+		}
+	}
+	// check the share of the delegation, it must not exceed the weight that
+	// the user has not delegated yet in the community
+	if req.Share > mongo.DelegationFullShare {
+		return ctx.Send([]byte("invalid delegation share"), apirest.HTTPstatusBadRequest)
+	}
+	userDelegations, err := v.db.DelegationsFrom(req.From)
+	if err != nil {
+		return ctx.Send([]byte("could not get delegations"), apirest.HTTPstatusInternalErr)
+	}
+	// only the delegations valid at some point of the validity period of the
+	// new one share the weight with it, which starts now if it is not set
+	now := time.Now()
+	period := req
+	if period.ValidFrom == nil {
+		period.ValidFrom = &now
+	}
+	delegatedShare := uint32(0)
+	for _, delegation := range userDelegations {
+		if delegation.CommuniyID == req.CommuniyID && delegation.Overlaps(period) {
+			delegatedShare += delegation.Shared()
+		}
+	}
+	if delegatedShare+req.Shared() > mongo.DelegationFullShare {
+		return ctx.Send([]byte("delegation share exceeds the weight not delegated yet"), apirest.HTTPstatusBadRequest)
+	}
 	// check if the user is trying to delegate to a non-existing user
 	_, err = v.db.User(req.To)
 	if err != nil {
@@ -177,21 +214,17 @@ func (v *vocdoniHandler) delegateVoteHandler(msg *apirest.APIdata, ctx *httprout
 	if err != nil {
 		return ctx.Send([]byte("failied to get community to delegate to"), apirest.HTTPstatusInternalErr)
 	}
-	// get current delegations for the community to prevent circular delegations
-	delegations, err := v.db.DelegationsByCommunityFrom(req.CommuniyID, req.To)
+	// delegate the vote, the delegation graph of the community is validated
+	// to prevent circular and too deep delegations
+	delegationID, err := v.db.SetDelegation(req)
 	if err != nil {
-		return ctx.Send([]byte("could not get delegations"), apirest.HTTPstatusInternalErr)
-	}
-	// check if the delegation would create a circular delegation
-	for _, delegation := range delegations {
-		if delegation.To == req.From {
-			return ctx.Send([]byte("circular delegation"), apirest.HTTPstatusBadRequest)
+		if errors.Is(err, mongo.ErrSelfDelegation) || errors.Is(err, mongo.ErrDelegationCycle) ||
+			errors.Is(err, mongo.ErrDelegationTooDeep) {
+			return ctx.Send([]byte(err.Error()), apirest.HTTPstatusBadRequest)
 		}
-	}
-	// delegate the vote
-	if _, err := v.db.SetDelegation(req); err != nil {
 		return ctx.Send([]byte("could not delegate vote"), apirest.HTTPstatusInternalErr)
 	}
+	go v.emitDelegationChanged(mongo.DelegationEventCreate, delegationID, req, userFID)
 	return ctx.Send([]byte("Ok"), apirest.HTTPstatusOK)
 }
 
@@ -218,9 +251,10 @@ func (v *vocdoniHandler) removeVoteDelegationHandler(msg *apirest.APIdata, ctx *
 		return ctx.Send([]byte("delegation does not belong to user"), apirest.HTTPstatusBadRequest)
 	}
 	// remove the delegation
-	if err := v.db.DeleteDelegation(delegationID); err != nil {
+	if err := v.db.DeleteDelegation(delegationID, auth.UserID); err != nil {
 		return ctx.Send([]byte("could not remove delegation"), apirest.HTTPstatusInternalErr)
 	}
+	go v.emitDelegationChanged(mongo.DelegationEventRevoke, delegationID, delegation, auth.UserID)
 	return ctx.Send([]byte("Ok"), apirest.HTTPstatusOK)
 }
 
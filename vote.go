@@ -12,6 +12,7 @@ import (
 
 	"github.com/vocdoni/vote-frame/helpers"
 	"github.com/vocdoni/vote-frame/imageframe"
+	"github.com/vocdoni/vote-frame/mongo"
 	"go.vocdoni.io/proto/build/go/models"
 
 	"go.vocdoni.io/dvote/apiclient"
@@ -92,11 +93,7 @@ func (v *vocdoniHandler) vote(msg *apirest.APIdata, ctx *httprouter.HTTPContext)
 		return ctx.Send([]byte(response), http.StatusOK)
 	}
 	if dbElection.Community != nil {
-		delegations, err := v.db.DelegationsByCommunityFrom(dbElection.Community.ID, uint64(packet.UntrustedData.FID))
-		if err != nil {
-			log.Warnw("failed to fetch delegations", "error", err)
-		}
-		if len(delegations) > 0 {
+		if v.voteDelegated(electionIDbytes, dbElection.Community.ID, uint64(packet.UntrustedData.FID)) {
 			if response, err := handleVoteError(ErrVoteDelegated, &voteData{
 				FID: uint64(packet.UntrustedData.FID),
 			}, electionIDbytes); err != nil {
@@ -129,6 +126,20 @@ func (v *vocdoniHandler) vote(msg *apirest.APIdata, ctx *httprouter.HTTPContext)
 		if err := v.db.IncreaseVoteCount(voteData.FID, electionIDbytes, voteData.Proof.LeafWeight); err != nil {
 			log.Errorw(err, "failed to increase vote count")
 		}
+		// store the choice of the voters involved in delegations to solve
+		// the delegation overrides
+		if dbElection.Community != nil {
+			v.recordDelegationVote(electionIDbytes, voteData.FID, []int{packet.UntrustedData.ButtonIndex - 1})
+			// notify the community webhooks with the updated vote counts
+			if updated, err := v.db.Election(electionIDbytes); err == nil {
+				updated = v.applyCastedWeightOverrides(updated)
+				v.emitCommunityEvent(dbElection.Community.ID, mongo.WebhookEventVoteCast, &webhookVoteCast{
+					ElectionID:   electionID,
+					Votes:        updated.CastedVotes,
+					CastedWeight: updated.CastedWeight,
+				})
+			}
+		}
 
 		// wait until voteCount increases or timeout
 		// if voteCount increases, update the election cache and generate the new results image
@@ -86,8 +86,9 @@ func pollToCast(ctx context.Context, handler *vocdoniHandler, poll *poll.Poll,
 		Custody:       user.CustodyAddress,
 		Verifications: user.VerificationsAddresses,
 	}
+	census := cappedCensus(defaultCensus, handler.maxCensusSize(user.FID, ""))
 	electionID, err := handler.createAndSaveElectionAndProfile(description,
-		defaultCensus, profile, true, false, "", ElectionSourceBot, nil)
+		census, profile, true, false, "", ElectionSourceBot, nil)
 	if err != nil {
 		return fmt.Errorf("error creating election: %w", err)
 	}
@@ -24,6 +24,7 @@ declare global {
   }
 
   type CensusResponse = {
+    censusId?: string
     root: string
     size: number
     uri: string
@@ -128,7 +129,10 @@ declare global {
     createdByDisplayname: string
     totalWeight: string
     options: string[]
+    // tally corrected by the delegation overrides, the one published
     tally: string[]
+    // tally of the Vocdoni chain, without the delegation overrides corrections
+    rawTally?: string[]
     participants: number[]
     finalized: boolean
   }
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/vocdoni/vote-frame/mongo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/util"
+)
+
+const (
+	// webhookSignatureHeader is the header that contains the signature of the
+	// body of the webhook deliveries, the hex encoded HMAC-SHA512 of the body
+	// with the secret of the webhook, like the neynar webhooks.
+	webhookSignatureHeader = "X-Votecaster-Signature"
+	// webhookEventHeader is the header that contains the event delivered.
+	webhookEventHeader = "X-Votecaster-Event"
+	// webhookDeliveryHeader is the header that contains the ID of the
+	// delivery, which is kept across its retries.
+	webhookDeliveryHeader = "X-Votecaster-Delivery"
+	// webhookRequestTimeout is the maximum time to wait for the response of
+	// the receiver of a delivery.
+	webhookRequestTimeout = 10 * time.Second
+	// webhookMaxAttempts is the number of attempts of a delivery before it is
+	// marked as failed.
+	webhookMaxAttempts = 6
+	// webhookRetryBaseDelay is the time to wait before retrying a delivery
+	// after its first attempt, it is doubled after every attempt.
+	webhookRetryBaseDelay = 30 * time.Second
+	// webhookRetrySweepInterval is the time between checks for deliveries to
+	// retry.
+	webhookRetrySweepInterval = 15 * time.Second
+	// webhookDeliveryLease is the time that an instance owns a delivery that
+	// it is retrying. If the instance stops during the attempt, other
+	// instance retries it once the lease expires.
+	webhookDeliveryLease = 6 * webhookRequestTimeout
+)
+
+// webhookAllowPrivateHosts allows to register and deliver webhooks to
+// loopback, private and link-local addresses. It must only be enabled to
+// deliver the webhooks to local receivers in tests.
+var webhookAllowPrivateHosts = false
+
+// webhookNonPublicPrefixes are the address ranges that are not covered by the
+// net.IP helpers but are not public either, like the shared address space,
+// where some cloud providers serve their metadata.
+var webhookNonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// webhookClient is the HTTP client used to send the webhook deliveries. It
+// checks the address of every connection when it is dialed, after resolving
+// the host, so the receivers cannot reach internal services by changing the
+// records of their domains after the registration. It does not use any proxy
+// and it does not follow redirects either.
+var webhookClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: webhookRequestTimeout,
+			Control: webhookDialControl,
+		}).DialContext,
+		TLSHandshakeTimeout: webhookRequestTimeout,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     time.Minute,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// webhookDialControl refuses the connections of the webhook deliveries to
+// addresses that are not public.
+func webhookDialControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("invalid webhook address %s", address)
+	}
+	if !webhookPublicAddr(ip) {
+		return fmt.Errorf("webhook address %s is not public", ip)
+	}
+	return nil
+}
+
+// webhookPublicAddr returns true if the address provided is a public one, so
+// the webhooks can be delivered to it. It returns true for every address if
+// webhookAllowPrivateHosts is set.
+func webhookPublicAddr(ip netip.Addr) bool {
+	if webhookAllowPrivateHosts {
+		return true
+	}
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range webhookNonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateWebhookURL parses the webhook URL provided and checks that it is an
+// http(s) URL whose host only resolves to public addresses. The addresses are
+// checked again when the deliveries are sent, since the host could resolve to
+// other ones later.
+func validateWebhookURL(ctx context.Context, rawURL string) (*url.URL, error) {
+	webhookURL, err := url.Parse(rawURL)
+	if err != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || webhookURL.Hostname() == "" {
+		return nil, fmt.Errorf("invalid webhook URL")
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", webhookURL.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return nil, fmt.Errorf("cannot resolve webhook host")
+	}
+	for _, addr := range addrs {
+		if !webhookPublicAddr(addr) {
+			return nil, fmt.Errorf("webhook host is not public")
+		}
+	}
+	return webhookURL, nil
+}
+
+// webhookPayload is the body of the webhook deliveries. The ID identifies
+// the event, so it is the same for every webhook that receives it.
+type webhookPayload struct {
+	ID          string    `json:"id"`
+	Event       string    `json:"event"`
+	CommunityID string    `json:"communityId"`
+	Timestamp   time.Time `json:"timestamp"`
+	Data        any       `json:"data"`
+}
+
+// webhookPollCreated is the data of the poll.created event.
+type webhookPollCreated struct {
+	ElectionID string    `json:"electionId"`
+	Question   string    `json:"question"`
+	CreatedBy  uint64    `json:"createdBy"`
+	EndTime    time.Time `json:"endTime"`
+	CensusSize uint32    `json:"censusSize"`
+}
+
+// webhookVoteCast is the data of the vote.cast event, it only includes the
+// vote counts of the poll and never the voter or the choice.
+type webhookVoteCast struct {
+	ElectionID   string `json:"electionId"`
+	Votes        uint64 `json:"votes"`
+	CastedWeight string `json:"castedWeight"`
+}
+
+// webhookPollResults is the data of the poll.ended and results.settled
+// events. The votes and the casted weight are corrected by the delegation
+// overrides, so they match the results settled in the community hub, while
+// the raw ones are the ones tallied by the Vocdoni chain, which count twice the
+// weight delegated when both the delegator and the delegate vote.
+type webhookPollResults struct {
+	ElectionID      string   `json:"electionId"`
+	Question        string   `json:"question"`
+	Choices         []string `json:"choices"`
+	Votes           []string `json:"votes"`
+	RawVotes        []string `json:"rawVotes,omitempty"`
+	VoteCount       uint64   `json:"voteCount"`
+	CastedWeight    string   `json:"castedWeight"`
+	RawCastedWeight string   `json:"rawCastedWeight,omitempty"`
+}
+
+// webhookDelegationChanged is the data of the delegation.changed event. The
+// action is the type of the delegation event (create, revoke or expire). The
+// actor of the expirations is 0, since they are registered by the service.
+type webhookDelegationChanged struct {
+	Action       string           `json:"action"`
+	DelegationID string           `json:"delegationId"`
+	Delegation   mongo.Delegation `json:"delegation"`
+	ActorFID     uint64           `json:"actorFid"`
+}
+
+// signWebhookPayload returns the signature of the body provided with the
+// secret provided, using the same scheme that neynar.VerifyRequest checks.
+func signWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha512.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRetryDelay returns the time to wait before the next attempt of a
+// delivery that has already been attempted the number of times provided.
+func webhookRetryDelay(attempts int) time.Duration {
+	return webhookRetryBaseDelay << (attempts - 1)
+}
+
+// emitCommunityEvent stores a delivery of the event provided for every
+// webhook of the community provided subscribed to it and tries to deliver
+// them. The deliveries that fail are retried at background by
+// retryWebhookDeliveriesAtBackground.
+func (v *vocdoniHandler) emitCommunityEvent(communityID, event string, data any) {
+	webhooks, err := v.db.Webhooks(communityID)
+	if err != nil {
+		log.Warnw("failed to get community webhooks", "community", communityID, "error", err)
+		return
+	}
+	var body []byte
+	for _, webhook := range webhooks {
+		if !webhook.Subscribed(event) {
+			continue
+		}
+		if body == nil {
+			if body, err = json.Marshal(&webhookPayload{
+				ID:          util.RandomHex(16),
+				Event:       event,
+				CommunityID: communityID,
+				Timestamp:   time.Now(),
+				Data:        data,
+			}); err != nil {
+				log.Warnw("failed to encode webhook payload", "event", event, "error", err)
+				return
+			}
+		}
+		// the first retry is scheduled in advance, so the delivery is retried
+		// even if the first attempt is interrupted
+		delivery := &mongo.WebhookDelivery{
+			WebhookID:   webhook.ID,
+			CommunityID: communityID,
+			Event:       event,
+			Payload:     string(body),
+			Status:      mongo.WebhookDeliveryPending,
+			NextAttempt: time.Now().Add(webhookRetryDelay(1)),
+		}
+		if err := v.db.AddWebhookDelivery(delivery); err != nil {
+			log.Warnw("failed to store webhook delivery", "webhook", webhook.ID.Hex(), "event", event, "error", err)
+			continue
+		}
+		go v.deliverWebhook(webhook, delivery)
+	}
+}
+
+// emitDelegationChanged notifies the webhooks of the community of the
+// delegation provided about its creation, revocation or expiration (see
+// mongo.DelegationEventCreate, mongo.DelegationEventRevoke and
+// mongo.DelegationEventExpire).
+func (v *vocdoniHandler) emitDelegationChanged(action, delegationID string, delegation mongo.Delegation, actorFID uint64) {
+	if id, err := primitive.ObjectIDFromHex(delegationID); err == nil {
+		delegation.ID = id
+	}
+	v.emitCommunityEvent(delegation.CommuniyID, mongo.WebhookEventDelegationChanged, &webhookDelegationChanged{
+		Action:       action,
+		DelegationID: delegationID,
+		Delegation:   delegation,
+		ActorFID:     actorFID,
+	})
+}
+
+// deliverWebhook sends the delivery provided to the webhook provided and
+// stores the result of the attempt.
+func (v *vocdoniHandler) deliverWebhook(webhook *mongo.Webhook, delivery *mongo.WebhookDelivery) {
+	attemptWebhookDelivery(webhook, delivery, time.Now())
+	if err := v.db.UpdateWebhookDelivery(delivery); err != nil {
+		log.Warnw("failed to update webhook delivery", "delivery", delivery.ID.Hex(), "error", err)
+	}
+}
+
+// attemptWebhookDelivery sends the delivery provided to the webhook provided
+// and updates the delivery with the result of the attempt. If it fails, the
+// next attempt is scheduled with an exponential backoff from the time
+// provided until the maximum number of attempts is reached.
+func attemptWebhookDelivery(webhook *mongo.Webhook, delivery *mongo.WebhookDelivery, now time.Time) {
+	delivery.Attempts++
+	status, err := sendWebhookDelivery(webhook, delivery)
+	delivery.ResponseStatus = status
+	switch {
+	case err == nil:
+		delivery.Status = mongo.WebhookDeliveryDelivered
+		delivery.LastError = ""
+	case delivery.Attempts >= webhookMaxAttempts:
+		delivery.Status = mongo.WebhookDeliveryFailed
+		delivery.LastError = err.Error()
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttempt = now.Add(webhookRetryDelay(delivery.Attempts))
+	}
+	if err != nil {
+		log.Debugw("failed to deliver webhook", "webhook", webhook.ID.Hex(), "delivery", delivery.ID.Hex(),
+			"attempts", delivery.Attempts, "error", err)
+	}
+}
+
+// sendWebhookDelivery sends the payload of the delivery provided to the URL
+// of the webhook provided, signed with its secret. It returns the status code
+// of the response and an error if the receiver does not accept it.
+func sendWebhookDelivery(webhook *mongo.Webhook, delivery *mongo.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	ctx, cancel := context.WithTimeout(context.Background(), webhookRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookSignatureHeader, signWebhookPayload(webhook.Secret, body))
+	req.Header.Set(webhookEventHeader, delivery.Event)
+	req.Header.Set(webhookDeliveryHeader, delivery.ID.Hex())
+	res, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// retryWebhookDeliveriesAtBackground checks for pending webhook deliveries
+// whose next attempt is due and tries to deliver them again. Every delivery is
+// claimed before the attempt, so it is not retried by several instances at
+// the same time. It must run in the background.
+func retryWebhookDeliveriesAtBackground(ctx context.Context, v *vocdoniHandler) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(webhookRetrySweepInterval):
+			for ctx.Err() == nil {
+				delivery, err := v.db.ClaimNextWebhookDelivery(webhookDeliveryLease)
+				if err != nil {
+					if mongo.IsDBClosed(err) {
+						return
+					}
+					if !errors.Is(err, mongo.ErrWebhookDeliveryUnknown) {
+						log.Warnw("failed to claim webhook delivery to retry", "error", err)
+					}
+					break
+				}
+				webhook, err := v.db.Webhook(delivery.WebhookID)
+				if err != nil {
+					if !errors.Is(err, mongo.ErrWebhookUnknown) {
+						log.Warnw("failed to get webhook of delivery", "delivery", delivery.ID.Hex(), "error", err)
+						continue
+					}
+					delivery.Status = mongo.WebhookDeliveryFailed
+					delivery.LastError = "webhook not found"
+					if err := v.db.UpdateWebhookDelivery(delivery); err != nil {
+						log.Warnw("failed to update webhook delivery", "delivery", delivery.ID.Hex(), "error", err)
+					}
+					continue
+				}
+				v.deliverWebhook(webhook, delivery)
+			}
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vocdoni/vote-frame/farcasterapi/neynar"
+	"github.com/vocdoni/vote-frame/mongo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// webhookReceiver is a local HTTP receiver of webhook deliveries that checks
+// their signature and headers and responds with the status codes provided,
+// in order, repeating the last one.
+type webhookReceiver struct {
+	t        *testing.T
+	secret   string
+	event    string
+	delivery string
+	statuses []int
+
+	mtx      sync.Mutex
+	received int
+}
+
+func (wr *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		wr.t.Errorf("cannot read webhook body: %v", err)
+	}
+	valid, err := neynar.VerifyRequest(wr.secret, r.Header.Get(webhookSignatureHeader), body)
+	if err != nil || !valid {
+		wr.t.Errorf("invalid webhook signature: %v", err)
+	}
+	if got := r.Header.Get(webhookEventHeader); got != wr.event {
+		wr.t.Errorf("unexpected event header: expected %q, got %q", wr.event, got)
+	}
+	if got := r.Header.Get(webhookDeliveryHeader); got != wr.delivery {
+		wr.t.Errorf("unexpected delivery header: expected %q, got %q", wr.delivery, got)
+	}
+	if got := r.Header.Get("Content-Type"); got != "application/json" {
+		wr.t.Errorf("unexpected content type: %q", got)
+	}
+	wr.mtx.Lock()
+	status := wr.statuses[min(wr.received, len(wr.statuses)-1)]
+	wr.received++
+	wr.mtx.Unlock()
+	w.WriteHeader(status)
+}
+
+func Test_attemptWebhookDelivery(t *testing.T) {
+	// the receivers of the tests are local
+	webhookAllowPrivateHosts = true
+	defer func() { webhookAllowPrivateHosts = false }()
+
+	tests := []struct {
+		name     string
+		statuses []int
+		// the number of attempts until the delivery is not pending anymore
+		attempts int
+		expected string
+	}{
+		{
+			name:     "delivered at first attempt",
+			statuses: []int{http.StatusOK},
+			attempts: 1,
+			expected: mongo.WebhookDeliveryDelivered,
+		},
+		{
+			name:     "delivered after retries",
+			statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusNoContent},
+			attempts: 3,
+			expected: mongo.WebhookDeliveryDelivered,
+		},
+		{
+			name:     "failed after max attempts",
+			statuses: []int{http.StatusServiceUnavailable},
+			attempts: webhookMaxAttempts,
+			expected: mongo.WebhookDeliveryFailed,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			delivery := &mongo.WebhookDelivery{
+				ID:      primitive.NewObjectID(),
+				Event:   mongo.WebhookEventDelegationChanged,
+				Payload: `{"id":"abc","event":"delegation.changed"}`,
+				Status:  mongo.WebhookDeliveryPending,
+			}
+			receiver := &webhookReceiver{
+				t:        t,
+				secret:   "secret",
+				event:    delivery.Event,
+				delivery: delivery.ID.Hex(),
+				statuses: tc.statuses,
+			}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+			webhook := &mongo.Webhook{ID: primitive.NewObjectID(), URL: server.URL, Secret: receiver.secret}
+
+			now := time.Now()
+			for delivery.Status == mongo.WebhookDeliveryPending {
+				if delivery.Attempts == tc.attempts {
+					t.Fatalf("delivery still pending after %d attempts", delivery.Attempts)
+				}
+				attemptWebhookDelivery(webhook, delivery, now)
+				if delivery.Status != mongo.WebhookDeliveryPending {
+					break
+				}
+				// the failed attempts are retried with an exponential backoff
+				if delivery.LastError == "" || delivery.ResponseStatus < 300 {
+					t.Errorf("unexpected result of failed attempt: status %d, error %q",
+						delivery.ResponseStatus, delivery.LastError)
+				}
+				expectedDelay := webhookRetryBaseDelay << (delivery.Attempts - 1)
+				if got := delivery.NextAttempt.Sub(now); got != expectedDelay {
+					t.Errorf("unexpected retry delay after %d attempts: expected %s, got %s",
+						delivery.Attempts, expectedDelay, got)
+				}
+				now = delivery.NextAttempt
+			}
+			if delivery.Status != tc.expected {
+				t.Errorf("unexpected delivery status: expected %q, got %q", tc.expected, delivery.Status)
+			}
+			receiver.mtx.Lock()
+			received := receiver.received
+			receiver.mtx.Unlock()
+			if delivery.Attempts != tc.attempts || received != tc.attempts {
+				t.Errorf("unexpected attempts: expected %d, got %d (received %d)",
+					tc.attempts, delivery.Attempts, received)
+			}
+			if tc.expected == mongo.WebhookDeliveryDelivered && delivery.LastError != "" {
+				t.Errorf("unexpected error of delivered delivery: %q", delivery.LastError)
+			}
+		})
+	}
+}
+
+func Test_webhookPrivateHosts(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		expectOk bool
+	}{
+		{name: "public address", url: "https://1.1.1.1/webhook", expectOk: true},
+		{name: "public IPv6 address", url: "https://[2606:4700:4700::1111]/webhook", expectOk: true},
+		{name: "loopback", url: "http://127.0.0.1:8080/webhook"},
+		{name: "localhost", url: "http://localhost/webhook"},
+		{name: "loopback IPv6", url: "http://[::1]/webhook"},
+		{name: "private network", url: "http://10.1.2.3/webhook"},
+		{name: "private network mapped to IPv6", url: "http://[::ffff:192.168.1.1]/webhook"},
+		{name: "cloud metadata", url: "http://169.254.169.254/latest/meta-data"},
+		{name: "shared address space", url: "http://100.100.100.200/latest/meta-data"},
+		{name: "unspecified address", url: "http://0.0.0.0/webhook"},
+		{name: "other scheme", url: "ftp://1.1.1.1/webhook"},
+		{name: "no host", url: "https:///webhook"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := validateWebhookURL(context.Background(), tc.url)
+			if tc.expectOk && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tc.expectOk && err == nil {
+				t.Error("expected the webhook URL to be rejected")
+			}
+		})
+	}
+
+	// the deliveries are not sent to local receivers even if they were
+	// registered, and the redirects are not followed
+	receiver := &webhookReceiver{t: t, statuses: []int{http.StatusOK}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	delivery := &mongo.WebhookDelivery{ID: primitive.NewObjectID(), Status: mongo.WebhookDeliveryPending}
+	attemptWebhookDelivery(&mongo.Webhook{URL: server.URL}, delivery, time.Now())
+	if delivery.Status != mongo.WebhookDeliveryPending || delivery.LastError == "" || delivery.ResponseStatus != 0 {
+		t.Errorf("unexpected delivery to a local receiver: %+v", delivery)
+	}
+	if receiver.received != 0 {
+		t.Errorf("local receiver got %d deliveries", receiver.received)
+	}
+
+	webhookAllowPrivateHosts = true
+	defer func() { webhookAllowPrivateHosts = false }()
+	redirect := httptest.NewServer(http.RedirectHandler(server.URL, http.StatusFound))
+	defer redirect.Close()
+	delivery = &mongo.WebhookDelivery{ID: primitive.NewObjectID(), Status: mongo.WebhookDeliveryPending}
+	attemptWebhookDelivery(&mongo.Webhook{URL: redirect.URL}, delivery, time.Now())
+	if delivery.ResponseStatus != http.StatusFound || receiver.received != 0 {
+		t.Errorf("unexpected redirected delivery: status %d, received %d", delivery.ResponseStatus, receiver.received)
+	}
+}